@@ -0,0 +1,80 @@
+package sqliteq
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ackTokens signs the ack IDs handed to one consumer, see WithAckTokens
+type ackTokens struct {
+	secret   []byte
+	consumer string
+}
+
+// WithAckTokens turns ack IDs into tokens signed with secret, binding them to the
+// consumer name and the time of the claim. Every delivery gets a new token, and
+// only the queue instance with the same consumer name accepts it: acknowledging,
+// handing back or pinning an item with a token issued to another consumer, or with
+// a stale one whose claim was given up to a visibility timeout or a restart and
+// maybe reassigned since, fails with ErrStaleClaim instead of completing the item
+// twice. Every process must use the same secret and a consumer name of its own.
+func WithAckTokens(secret []byte, consumer string) Option {
+	return func(q *Queue) {
+		if len(secret) > 0 {
+			q.ackTokens = &ackTokens{secret: secret, consumer: consumer}
+		}
+	}
+}
+
+// sign returns the signature of a claim of the queue table
+func (t *ackTokens) sign(table, nonce, claimedAt string) string {
+	mac := hmac.New(sha256.New, t.secret)
+	for _, part := range []string{table, t.consumer, nonce, claimedAt} {
+		// Prefix every part with its length, so parts can't run into each other
+		fmt.Fprintf(mac, "%d:%s", len(part), part)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// newAckID returns the ack ID of an item claimed at claimedAt: a token of the form
+// nonce.claimedAt.signature with WithAckTokens, or a plain unique ID
+func (q *Queue) newAckID(claimedAt time.Time) string {
+	if q.ackTokens == nil {
+		return q.manager.ids.NewID()
+	}
+
+	nonce, at := q.manager.ids.NewID(), strconv.FormatInt(claimedAt.UnixNano(), 10)
+
+	return nonce + "." + at + "." + q.ackTokens.sign(q.tableName, nonce, at)
+}
+
+// verifyAckID checks that ackID was issued to this consumer with WithAckTokens
+func (q *Queue) verifyAckID(ackID string) error {
+	if q.ackTokens == nil {
+		return nil
+	}
+
+	parts := strings.Split(ackID, ".")
+	if len(parts) == 3 && hmac.Equal([]byte(parts[2]), []byte(q.ackTokens.sign(q.tableName, parts[0], parts[1]))) {
+		return nil
+	}
+
+	return fmt.Errorf("%w %q: not issued to consumer %q", ErrStaleClaim, ackID, q.ackTokens.consumer)
+}
+
+// noClaim returns the error for an ack ID no item in processing holds: ErrStaleClaim
+// for the tokens of WithAckTokens, or sql.ErrNoRows for callers to report
+func (q *Queue) noClaim(ackID string) error {
+	if q.ackTokens == nil {
+		return sql.ErrNoRows
+	}
+
+	return fmt.Errorf("%w %q: no longer in processing", ErrStaleClaim, ackID)
+}
@@ -0,0 +1,67 @@
+package sqliteq
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestAckTokens(t *testing.T) {
+	dbPath := "test_ack_tokens.db"
+	defer os.Remove(dbPath)
+
+	secret := []byte("shared secret")
+
+	// Two processes consuming the same queue
+	queuesA := New(dbPath)
+	defer queuesA.Close()
+	queuesB := New(dbPath)
+	defer queuesB.Close()
+
+	a, err := queuesA.NewQueue("jobs", WithAckTokens(secret, "a"))
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	b, err := queuesB.NewQueue("jobs", WithAckTokens(secret, "b"))
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	a.Enqueue("job")
+
+	stale, err := a.DequeueMessageWithAckId()
+	if err != nil {
+		t.Fatalf("DequeueMessageWithAckId failed: %v", err)
+	}
+
+	if err := b.Ack(stale.AckID); !errors.Is(err, ErrStaleClaim) {
+		t.Errorf("Expected ErrStaleClaim for another consumer's token, got %v", err)
+	}
+
+	// The claim times out and the item is reassigned to b
+	a.RequeueNoAckRows()
+
+	m, err := b.DequeueMessageWithAckId()
+	if err != nil {
+		t.Fatalf("DequeueMessageWithAckId failed: %v", err)
+	}
+
+	if m.AckID == stale.AckID {
+		t.Fatal("Expected a new token for the new delivery")
+	}
+
+	if err := a.Ack(stale.AckID); !errors.Is(err, ErrStaleClaim) {
+		t.Errorf("Expected ErrStaleClaim for the stale token, got %v", err)
+	}
+	if err := a.Nack(stale.AckID); !errors.Is(err, ErrStaleClaim) {
+		t.Errorf("Expected ErrStaleClaim for the stale token, got %v", err)
+	}
+
+	if err := b.Ack(m.AckID); err != nil {
+		t.Errorf("Ack failed: %v", err)
+	}
+
+	if err := b.Ack(m.AckID + "x"); !errors.Is(err, ErrStaleClaim) {
+		t.Errorf("Expected ErrStaleClaim for a forged token, got %v", err)
+	}
+}
@@ -0,0 +1,48 @@
+package sqliteq
+
+import (
+	"context"
+	"fmt"
+)
+
+// Action is a destructive operation subject to authorization
+type Action string
+
+const (
+	// ActionPurge removes every item of a queue
+	ActionPurge Action = "purge"
+)
+
+// Authorizer decides whether a destructive operation may run, so deployments
+// sharing a database file can restrict who can destroy data. The context carries
+// whatever identifies the caller, e.g. a value set by an HTTP middleware.
+type Authorizer interface {
+	// Authorize returns nil to allow action on the queue, or an error to deny it
+	Authorize(ctx context.Context, action Action, queue string) error
+}
+
+// AuthorizerFunc adapts a function to the Authorizer interface
+type AuthorizerFunc func(ctx context.Context, action Action, queue string) error
+
+// Authorize calls f(ctx, action, queue)
+func (f AuthorizerFunc) Authorize(ctx context.Context, action Action, queue string) error {
+	return f(ctx, action, queue)
+}
+
+// authorize consults the manager's authorizer, if any, wrapping a denial in ErrUnauthorized
+func (q *Queue) authorize(ctx context.Context, action Action) error {
+	return q.manager.authorize(ctx, action, q.tableName)
+}
+
+// authorize consults the authorizer, if any, about action on queue
+func (m *Manager) authorize(ctx context.Context, action Action, queue string) error {
+	if m.authorizer == nil {
+		return nil
+	}
+
+	if err := m.authorizer.Authorize(ctx, action, queue); err != nil {
+		return fmt.Errorf("%w: %s %s: %w", ErrUnauthorized, action, queue, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,51 @@
+package sqliteq
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+)
+
+type operatorKey struct{}
+
+func TestAuthorizer(t *testing.T) {
+	dbPath := "test_authorizer.db"
+	defer os.Remove(dbPath)
+
+	errNotAdmin := errors.New("not an admin")
+	queues := New(dbPath, WithAuthorizer(AuthorizerFunc(func(ctx context.Context, action Action, queue string) error {
+		if ctx.Value(operatorKey{}) != "admin" {
+			return errNotAdmin
+		}
+		return nil
+	})))
+	defer queues.Close()
+
+	q, err := queues.NewQueue("authorized")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	q.Enqueue("a")
+	q.Enqueue("b")
+
+	_, err = q.PurgeContext(context.Background())
+	if !errors.Is(err, ErrUnauthorized) || !errors.Is(err, errNotAdmin) {
+		t.Errorf("Expected ErrUnauthorized wrapping the denial, got %v", err)
+	}
+
+	q.Purge()
+	if q.Len() != 2 {
+		t.Errorf("Expected a denied purge to keep the items, got length %d", q.Len())
+	}
+
+	purged, err := q.PurgeContext(context.WithValue(context.Background(), operatorKey{}, "admin"))
+	if err != nil {
+		t.Fatalf("Expected the purge to be allowed, got %v", err)
+	}
+
+	if purged != 2 || q.Len() != 0 {
+		t.Errorf("Expected 2 purged items, got %d (length %d)", purged, q.Len())
+	}
+}
@@ -0,0 +1,80 @@
+package sqliteq
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// ExponentialBackoff returns a WithRetryPolicy backoff function waiting base after
+// the first delivery and doubling after each one, up to maxDelay. A maxDelay of
+// zero or less doesn't cap the delay.
+func ExponentialBackoff(base, maxDelay time.Duration) func(attempt int) time.Duration {
+	return func(attempt int) time.Duration {
+		delay := base
+		for i := 1; i < attempt; i++ {
+			if maxDelay > 0 && delay >= maxDelay || delay > math.MaxInt64/2 {
+				break
+			}
+			delay *= 2
+		}
+
+		if maxDelay > 0 && delay > maxDelay {
+			return maxDelay
+		}
+
+		return delay
+	}
+}
+
+// retryLater returns the processing item holding ackID to pending after it failed
+// with cause, not before the delay of the retry policy, or moves it to the
+// dead-letter queue or marks it failed once its retries are exhausted
+// Returns the number of released items: 0 when another worker got there first
+func (q *Queue) retryLater(ackID string, cause error) (int64, error) {
+	var id int64
+	var attempts int
+
+	err := q.client.QueryRow(
+		fmt.Sprintf("SELECT id, attempts FROM %s WHERE ack_id = ? AND status = 'processing'", quoteIdent(q.tableName)),
+		ackID,
+	).Scan(&id, &attempts)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if attempts <= q.maxRetries {
+		now := q.now()
+
+		return rowsAffected(q.requeue("status = 'pending', not_before = ?, updated_at = ?", "id = ? AND status = 'processing'", 1,
+			now.Add(q.retryBackoff(attempts)), now, id))
+	}
+
+	reason := fmt.Sprintf("exhausted %d retries: %v", q.maxRetries, cause)
+
+	if q.deadLetter != nil {
+		return q.moveItems(q, q.deadLetter, "id = ? AND status = 'processing'", []any{id}, func(m *Message) {
+			m.Origin, m.FailReason = q.tableName, reason
+		})
+	}
+
+	return rowsAffected(q.client.Exec(
+		fmt.Sprintf("UPDATE %s SET status = 'failed', fail_reason = ?, updated_at = ? WHERE id = ? AND status = 'processing'",
+			quoteIdent(q.tableName)),
+		reason, q.now(), id,
+	))
+}
+
+// rowsAffected returns the number of rows affected by a statement
+func rowsAffected(result sql.Result, err error) (int64, error) {
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
@@ -0,0 +1,120 @@
+package sqliteq
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestExponentialBackoff(t *testing.T) {
+	backoff := ExponentialBackoff(time.Second, 10*time.Second)
+
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second}
+	for i, want := range expected {
+		if got := backoff(i + 1); got != want {
+			t.Errorf("Attempt %d: expected %v, got %v", i+1, want, got)
+		}
+	}
+
+	if got := ExponentialBackoff(time.Second, 0)(1000); got <= 0 {
+		t.Errorf("Expected an uncapped backoff not to overflow, got %v", got)
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	dbPath := "test_retry_policy.db"
+	defer os.Remove(dbPath)
+
+	queues := New(dbPath)
+	defer queues.Close()
+
+	var delays []int
+	backoff := func(attempt int) time.Duration {
+		delays = append(delays, attempt)
+		return time.Hour
+	}
+
+	q, err := queues.NewQueue("jobs", WithRetryPolicy(1, backoff), WithDeadLetterQueue("jobs_dlq", 10))
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	dlq, err := queues.NewQueue("jobs_dlq")
+	if err != nil {
+		t.Fatalf("Failed to open dead-letter queue: %v", err)
+	}
+
+	q.Enqueue("flaky")
+
+	m, err := q.DequeueMessageWithAckId()
+	if err != nil {
+		t.Fatalf("Dequeue failed: %v", err)
+	}
+
+	if err := q.Nack(m.AckID); err != nil {
+		t.Fatalf("Nack failed: %v", err)
+	}
+
+	if len(delays) != 1 || delays[0] != 1 {
+		t.Errorf("Expected a backoff for attempt 1, got %v", delays)
+	}
+
+	if _, err := q.DequeueMessage(); !errors.Is(err, ErrEmpty) {
+		t.Errorf("Expected the item to wait for its backoff, got %v", err)
+	}
+
+	if q.Len() != 1 {
+		t.Errorf("Expected the item to stay pending, got %d", q.Len())
+	}
+
+	// Pretend the backoff elapsed
+	q.client.Exec("UPDATE jobs SET not_before = NULL")
+
+	m, err = q.DequeueMessageWithAckId()
+	if err != nil || m.Attempts != 2 {
+		t.Fatalf("Expected the retry on attempt 2, got %+v, %v", m, err)
+	}
+
+	// The only retry is spent, so the item is dead-lettered
+	if err := q.Nack(m.AckID); err != nil {
+		t.Fatalf("Nack failed: %v", err)
+	}
+
+	if len(delays) != 1 {
+		t.Errorf("Expected no backoff once retries are exhausted, got %v", delays)
+	}
+
+	messages, err := dlq.Page(0, 10)
+	if err != nil || len(messages) != 1 {
+		t.Fatalf("Expected 1 dead letter, got %v, %v", messages, err)
+	}
+
+	if m := messages[0]; string(m.Data) != "flaky" || m.Origin != "jobs" || m.FailReason == "" {
+		t.Errorf("Expected 'flaky' from jobs with a reason, got %+v", m)
+	}
+
+	t.Run("WithoutDeadLetterQueue", func(t *testing.T) {
+		fq, err := queues.NewQueue("failing", WithRetryPolicy(0, ExponentialBackoff(time.Second, time.Minute)))
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+
+		fq.Enqueue("broken")
+
+		boom := errors.New("boom")
+		if _, err := fq.Drain(context.Background(), func(Message) error { return boom }); !errors.Is(err, boom) {
+			t.Fatalf("Expected the handler error, got %v", err)
+		}
+
+		depth, _ := fq.LenDetailed()
+		if want := (Depth{Failed: 1}); depth != want {
+			t.Errorf("Expected the item failed without retries, got %+v", depth)
+		}
+	})
+
+	if err := q.Nack("unknown"); !errors.Is(err, ErrUnknownAckID) {
+		t.Errorf("Expected ErrUnknownAckID, got %v", err)
+	}
+}
@@ -0,0 +1,140 @@
+package sqliteq
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// OverflowPolicy decides what enqueuing to a queue at its WithMaxLength limit does
+type OverflowPolicy int
+
+const (
+	// OverflowReject fails the enqueue with ErrQueueFull
+	OverflowReject OverflowPolicy = iota
+	// OverflowDropOldest deletes the oldest pending items to make room
+	OverflowDropOldest
+	// OverflowBlock waits until consumers make room, like EnqueueWait
+	OverflowBlock
+)
+
+// String returns the name of the policy
+func (p OverflowPolicy) String() string {
+	switch p {
+	case OverflowReject:
+		return "reject"
+	case OverflowDropOldest:
+		return "drop-oldest"
+	case OverflowBlock:
+		return "block"
+	}
+
+	return "unknown"
+}
+
+// enqueueWaitPoll is how often EnqueueWait retries when no item left the queue
+// through this process, e.g. when another process is consuming it
+const enqueueWaitPoll = 50 * time.Millisecond
+
+// spaceSignal wakes producers blocked in EnqueueWait when items leave the queue
+type spaceSignal struct {
+	mu sync.Mutex
+	// ch is closed by the next notify
+	ch chan struct{}
+}
+
+// wait returns a channel closed when items next leave the queue
+func (s *spaceSignal) wait() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ch == nil {
+		s.ch = make(chan struct{})
+	}
+
+	return s.ch
+}
+
+// notify wakes the producers waiting for space
+func (s *spaceSignal) notify() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ch != nil {
+		close(s.ch)
+		s.ch = nil
+	}
+}
+
+// EnqueueWait adds an item like Enqueue, but blocks while the queue has no room
+// for it instead of failing, resuming once items are dequeued or acknowledged.
+// The queue is full at its WithMaxLength limit, whatever the overflow policy, or
+// when SQLite reports ErrFull, as when the disk or the max_page_count limit is
+// reached. Other errors are returned at once, and the context's error if it ends
+// first.
+func (q *Queue) EnqueueWait(ctx context.Context, item any) error {
+	return q.enqueueWait(ctx, item, Message{})
+}
+
+// EnqueueWait adds an item with a specified priority, blocking while the queue
+// has no room for it like Queue.EnqueueWait
+func (pq *PriorityQueue) EnqueueWait(ctx context.Context, item any, priority int) error {
+	return pq.enqueueWait(ctx, item, Message{Priority: priority})
+}
+
+// enqueueWait inserts m, retrying while the queue is full
+func (q *Queue) enqueueWait(ctx context.Context, item any, m Message, hooks ...func(tx *sql.Tx, m *Message) error) error {
+	for {
+		// Subscribe before trying, so space freed in between isn't missed
+		freed := q.freed.wait()
+
+		err := q.enqueueOnce(item, m, true, hooks...)
+		if !errors.Is(err, ErrFull) && !errors.Is(err, ErrQueueFull) {
+			return err
+		}
+
+		timer := time.NewTimer(enqueueWaitPoll)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-freed:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// makeRoom checks within tx that n more items fit under the WithMaxLength limit,
+// deleting the oldest pending items with OverflowDropOldest
+// Returns ErrQueueFull when they don't fit and no item was dropped
+func (q *Queue) makeRoom(tx *sql.Tx, n int, wait bool) error {
+	if q.maxLength <= 0 {
+		return nil
+	}
+
+	var pending int
+	err := tx.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE status = 'pending'", quoteIdent(q.tableName))).Scan(&pending)
+	if err != nil {
+		return err
+	}
+
+	excess := pending + n - q.maxLength
+	if excess <= 0 {
+		return nil
+	}
+
+	if wait || q.overflow != OverflowDropOldest {
+		return ErrQueueFull
+	}
+
+	_, err = tx.Exec(fmt.Sprintf(
+		"DELETE FROM %[1]s WHERE id IN (SELECT id FROM %[1]s WHERE status = 'pending' ORDER BY seq LIMIT ?)",
+		quoteIdent(q.tableName),
+	), excess)
+
+	return err
+}
@@ -0,0 +1,166 @@
+package sqliteq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEnqueueWait(t *testing.T) {
+	dbPath := "test_enqueue_wait.db"
+	defer os.Remove(dbPath)
+
+	queues := New(dbPath)
+	defer queues.Close()
+
+	q, err := queues.NewQueue("bounded")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	// max_page_count is a per-connection limit
+	q.client.SetMaxOpenConns(1)
+
+	var pages int
+	q.client.QueryRow("PRAGMA page_count").Scan(&pages)
+	if _, err := q.client.Exec(fmt.Sprintf("PRAGMA max_page_count = %d", pages+8)); err != nil {
+		t.Fatalf("Failed to limit the database size: %v", err)
+	}
+
+	payload := strings.Repeat("x", 2048)
+	for i := 0; ; i++ {
+		if i == 100 {
+			t.Fatal("Expected the database to fill up")
+		}
+
+		_, err := q.EnqueueWithID(payload)
+		if errors.Is(err, ErrFull) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	t.Run("Canceled", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		if err := q.EnqueueWait(ctx, payload); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+		}
+	})
+
+	t.Run("Resumes", func(t *testing.T) {
+		done := make(chan error, 1)
+		go func() { done <- q.EnqueueWait(context.Background(), payload) }()
+
+		select {
+		case err := <-done:
+			t.Fatalf("Expected EnqueueWait to block while the queue is full, got %v", err)
+		case <-time.After(100 * time.Millisecond):
+		}
+
+		for i := 0; i < 4; i++ {
+			q.Dequeue()
+		}
+
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Errorf("Expected the item enqueued once space was freed, got %v", err)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("EnqueueWait didn't resume after items were dequeued")
+		}
+	})
+}
+
+func TestMaxLength(t *testing.T) {
+	dbPath := "test_max_length.db"
+	defer os.Remove(dbPath)
+
+	queues := New(dbPath)
+	defer queues.Close()
+
+	t.Run("Reject", func(t *testing.T) {
+		q, err := queues.NewQueue("reject", WithMaxLength(2))
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+
+		q.Enqueue("a")
+		q.Enqueue("b")
+
+		if _, err := q.EnqueueWithID("c"); !errors.Is(err, ErrQueueFull) {
+			t.Errorf("Expected ErrQueueFull, got %v", err)
+		}
+
+		// Items in processing don't count against the limit
+		q.DequeueWithAckId()
+		if !q.Enqueue("c") {
+			t.Error("Expected room once an item was dequeued")
+		}
+	})
+
+	t.Run("DropOldest", func(t *testing.T) {
+		pq, err := queues.NewPriorityQueue("drop", WithMaxLength(2), WithOverflowPolicy(OverflowDropOldest))
+		if err != nil {
+			t.Fatalf("Failed to create priority queue: %v", err)
+		}
+
+		pq.Enqueue("oldest", 1)
+		pq.Enqueue("older", 2)
+		pq.Enqueue("newest", 3)
+
+		if pq.Len() != 2 {
+			t.Fatalf("Expected 2 items, got %d", pq.Len())
+		}
+
+		if item, _ := pq.Dequeue(); string(item.([]byte)) != "older" {
+			t.Errorf("Expected 'oldest' to be dropped, got %s first", item)
+		}
+	})
+
+	t.Run("Block", func(t *testing.T) {
+		q, err := queues.NewQueue("block", WithMaxLength(1), WithOverflowPolicy(OverflowBlock))
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+
+		q.Enqueue("first")
+
+		done := make(chan bool, 1)
+		go func() { done <- q.Enqueue("second") }()
+
+		select {
+		case <-done:
+			t.Fatal("Expected Enqueue to block while the queue is full")
+		case <-time.After(100 * time.Millisecond):
+		}
+
+		if item, _ := q.Dequeue(); string(item.([]byte)) != "first" {
+			t.Errorf("Expected 'first', got %s", item)
+		}
+
+		select {
+		case ok := <-done:
+			if !ok {
+				t.Error("Expected the blocked Enqueue to succeed")
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("Enqueue didn't resume after an item was dequeued")
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		if err := q.EnqueueWait(ctx, "third"); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,228 @@
+package sqliteq
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// The benchmarks below compare the ways of moving items through a queue against
+// in-memory queues that lose their items on a crash, to weigh durability against
+// throughput:
+//
+//	go test -run '^$' -bench Compare -benchmem
+//
+// Every benchmark enqueues b.N items and dequeues them again, and reports the
+// round trips per second as items/s.
+
+// benchBatchSize is how many items the batched modes write or read at a time
+const benchBatchSize = 100
+
+// memQueue is the simplest durable-less queue, a slice guarded by a mutex
+type memQueue struct {
+	mu    sync.Mutex
+	items [][]byte
+}
+
+func (m *memQueue) Enqueue(item []byte) {
+	m.mu.Lock()
+	m.items = append(m.items, item)
+	m.mu.Unlock()
+}
+
+func (m *memQueue) Dequeue() ([]byte, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.items) == 0 {
+		return nil, false
+	}
+
+	item := m.items[0]
+	m.items = m.items[1:]
+	return item, true
+}
+
+// reportThroughput reports the items moved per second
+func reportThroughput(b *testing.B) {
+	b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "items/s")
+}
+
+// benchQueue opens a queue in a fresh database removed when the benchmark ends
+func benchQueue(b *testing.B, name string, opts ...Option) *Queue {
+	dbPath := fmt.Sprintf("bench_compare_%s.db", name)
+	os.Remove(dbPath)
+
+	queues := New(dbPath)
+	q, err := queues.NewQueue("bench_queue", opts...)
+	if err != nil {
+		b.Fatalf("Failed to create queue: %v", err)
+	}
+
+	b.Cleanup(func() {
+		queues.Close()
+		os.Remove(dbPath)
+	})
+
+	return q
+}
+
+func BenchmarkCompare(b *testing.B) {
+	payload := make([]byte, 128)
+
+	b.Run("channel", func(b *testing.B) {
+		ch := make(chan []byte, b.N)
+
+		for i := 0; i < b.N; i++ {
+			ch <- payload
+		}
+		for i := 0; i < b.N; i++ {
+			<-ch
+		}
+
+		reportThroughput(b)
+	})
+
+	b.Run("memory", func(b *testing.B) {
+		var m memQueue
+
+		for i := 0; i < b.N; i++ {
+			m.Enqueue(payload)
+		}
+		for i := 0; i < b.N; i++ {
+			m.Dequeue()
+		}
+
+		reportThroughput(b)
+	})
+
+	// Every item is committed, and removed, in a transaction of its own
+	b.Run("sqliteq/sync", func(b *testing.B) {
+		q := benchQueue(b, "sync")
+		b.ResetTimer()
+
+		for i := 0; i < b.N; i++ {
+			q.Enqueue(payload)
+		}
+		for i := 0; i < b.N; i++ {
+			q.Dequeue()
+		}
+
+		reportThroughput(b)
+	})
+
+	// Items are committed together by WithGroupCommit, and dequeued one at a time
+	b.Run("sqliteq/group-commit", func(b *testing.B) {
+		q := benchQueue(b, "group_commit", WithGroupCommit(time.Millisecond, benchBatchSize))
+		b.ResetTimer()
+
+		var last EnqueueToken
+		for i := 0; i < b.N; i++ {
+			token, err := q.EnqueueAsync(payload)
+			if err != nil {
+				b.Fatalf("EnqueueAsync failed: %v", err)
+			}
+			last = token
+		}
+		if err := q.WaitDurable(context.Background(), last); err != nil {
+			b.Fatalf("WaitDurable failed: %v", err)
+		}
+
+		for i := 0; i < b.N; i++ {
+			q.Dequeue()
+		}
+
+		reportThroughput(b)
+	})
+
+	// Items are written with Import and read with DequeueN, a batch at a time
+	b.Run("sqliteq/batch", func(b *testing.B) {
+		q := benchQueue(b, "batch")
+		b.ResetTimer()
+
+		batch := make([]Message, 0, benchBatchSize)
+		for i := 0; i < b.N; i += benchBatchSize {
+			batch = batch[:0]
+			for j := i; j < b.N && j < i+benchBatchSize; j++ {
+				batch = append(batch, Message{Data: payload})
+			}
+
+			if err := q.Import(batch); err != nil {
+				b.Fatalf("Import failed: %v", err)
+			}
+		}
+
+		for dequeued := 0; dequeued < b.N; {
+			items := q.DequeueN(benchBatchSize)
+			if len(items) == 0 {
+				b.Fatalf("Expected %d more items", b.N-dequeued)
+			}
+			dequeued += len(items)
+		}
+
+		reportThroughput(b)
+	})
+}
+
+// benchScanItems and benchScanPayload size the queue scanned by BenchmarkTuning
+// well beyond SQLite's default 2 MiB page cache
+const (
+	benchScanItems   = 4000
+	benchScanPayload = 4 << 10
+)
+
+// BenchmarkTuning scans a 16 MiB queue with and without the platform defaults of
+// WithMmapSize and WithTempStore:
+//
+//	go test -run '^$' -bench Tuning -benchmem
+//
+// Values reads every pending item, which memory-mapped I/O speeds up, and
+// FindByCorrelationID sorts every item by seq, which spills to temp storage.
+func BenchmarkTuning(b *testing.B) {
+	mmapSize, _ := platformDefaults()
+
+	scan := func(b *testing.B, name string, read func(q *Queue), opts ...ManagerOption) {
+		b.Run(name, func(b *testing.B) {
+			dbPath := "bench_tuning.db"
+			os.Remove(dbPath)
+			defer os.Remove(dbPath)
+
+			m, err := NewManager(dbPath, opts...)
+			if err != nil {
+				b.Fatalf("Failed to open database: %v", err)
+			}
+			defer m.Close()
+
+			q, err := m.NewQueue("bench_queue")
+			if err != nil {
+				b.Fatalf("Failed to create queue: %v", err)
+			}
+
+			messages := make([]Message, benchScanItems)
+			for i := range messages {
+				messages[i] = Message{Data: make([]byte, benchScanPayload), CorrelationID: "scan"}
+			}
+			if err := q.Import(messages); err != nil {
+				b.Fatalf("Import failed: %v", err)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				read(q)
+			}
+		})
+	}
+
+	values := func(q *Queue) { q.Values() }
+	sorted := func(q *Queue) { q.FindByCorrelationID("scan") }
+
+	scan(b, "values/mmap=0", values, WithMmapSize(0))
+	scan(b, fmt.Sprintf("values/mmap=%d", mmapSize), values, WithMmapSize(mmapSize))
+	scan(b, "sort/temp_store=file", sorted, WithTempStore(TempStoreFile))
+	scan(b, "sort/temp_store=memory", sorted, WithTempStore(TempStoreMemory))
+}
@@ -0,0 +1,125 @@
+package sqliteq
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned by Drain while the circuit breaker set with
+// WithCircuitBreaker stops it from claiming items. Consume pauses instead.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// The circuit breaker judges the failure rate over the last breakerWindow handler
+// executions, once at least breakerMinSamples were recorded
+const (
+	breakerWindow     = 20
+	breakerMinSamples = 5
+)
+
+// breakerState is the state of a circuit breaker
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// circuitBreaker stops consumers while most handler executions fail
+type circuitBreaker struct {
+	threshold float64
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    breakerState
+	openedAt time.Time
+	// outcomes is a ring of the most recent executions, true for failures
+	outcomes []bool
+	next     int
+	// probing is whether the single half-open probe is in flight
+	probing bool
+}
+
+func newCircuitBreaker(threshold float64, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		outcomes:  make([]bool, 0, breakerWindow),
+	}
+}
+
+// allow reports whether a consumer may claim an item. Once the cooldown of an open
+// breaker elapsed, a single probe is allowed until its outcome is recorded.
+func (b *circuitBreaker) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if now.Sub(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.state = breakerHalfOpen
+		b.probing = true
+		return true
+	case breakerHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	}
+
+	return true
+}
+
+// cancel gives up a claim allowed by allow that ran no handler, e.g. because the
+// queue was empty, so a half-open breaker can probe again
+func (b *circuitBreaker) cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+}
+
+// record adds the outcome of a handler execution, opening the breaker when the
+// failure rate reaches the threshold and closing it after a successful probe
+func (b *circuitBreaker) record(failed bool, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == breakerHalfOpen {
+		b.probing = false
+		if failed {
+			b.state, b.openedAt = breakerOpen, now
+		} else {
+			b.state = breakerClosed
+			b.outcomes, b.next = b.outcomes[:0], 0
+		}
+		return
+	}
+
+	if len(b.outcomes) < breakerWindow {
+		b.outcomes = append(b.outcomes, failed)
+	} else {
+		b.outcomes[b.next] = failed
+		b.next = (b.next + 1) % breakerWindow
+	}
+
+	if len(b.outcomes) < breakerMinSamples {
+		return
+	}
+
+	failures := 0
+	for _, f := range b.outcomes {
+		if f {
+			failures++
+		}
+	}
+
+	if float64(failures)/float64(len(b.outcomes)) >= b.threshold {
+		b.state, b.openedAt = breakerOpen, now
+		b.outcomes, b.next = b.outcomes[:0], 0
+	}
+}
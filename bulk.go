@@ -0,0 +1,199 @@
+package sqliteq
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// auditTable records the bulk operations run on queues
+const auditTable = "sqliteq_audit"
+
+// Actions of bulk operations, reported to the Authorizer and the audit log
+const (
+	// ActionFail marks pending items as failed
+	ActionFail Action = "fail"
+	// ActionDelete deletes items
+	ActionDelete Action = "delete"
+)
+
+// Filter selects items by their metadata; zero fields match every item
+type Filter struct {
+	// CreatedBefore and CreatedAfter bound when the items were enqueued
+	CreatedBefore time.Time
+	CreatedAfter  time.Time
+	// Priority, when set, only matches items of that priority
+	Priority *int
+	// PayloadContains only matches items whose payload contains it
+	PayloadContains []byte
+}
+
+// where returns the SQL condition matching the filter, "1" when it is empty
+func (f Filter) where(q *Queue) (string, []any) {
+	var conds []string
+	var args []any
+
+	if !f.CreatedBefore.IsZero() {
+		conds = append(conds, "created_at < ?")
+		args = append(args, f.CreatedBefore.UTC())
+	}
+	if !f.CreatedAfter.IsZero() {
+		conds = append(conds, "created_at > ?")
+		args = append(args, f.CreatedAfter.UTC())
+	}
+	if f.Priority != nil {
+		conds = append(conds, q.priorityColumn()+" = ?")
+		args = append(args, *f.Priority)
+	}
+	if len(f.PayloadContains) > 0 {
+		conds = append(conds, "instr(data, ?) > 0")
+		args = append(args, f.PayloadContains)
+	}
+
+	if len(conds) == 0 {
+		return "1", nil
+	}
+
+	return strings.Join(conds, " AND "), args
+}
+
+// AuditEntry records a bulk operation run on a queue
+type AuditEntry struct {
+	Queue    string
+	Action   Action
+	Affected int64
+	Detail   string
+	At       time.Time
+}
+
+// initAuditTable creates the audit table if it doesn't exist
+func initAuditTable(tx *sql.Tx) error {
+	_, err := tx.Exec(fmt.Sprintf(`
+	CREATE TABLE IF NOT EXISTS %[1]s (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		queue TEXT NOT NULL,
+		action TEXT NOT NULL,
+		affected INTEGER NOT NULL,
+		detail TEXT NOT NULL,
+		at TIMESTAMP NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS %[2]s ON %[1]s (at);
+	`, quoteIdent(auditTable), quoteIdent(auditTable+"_at_idx")))
+
+	return err
+}
+
+// audit records a bulk operation within the transaction performing it
+func (q *Queue) audit(tx *sql.Tx, action Action, affected int64, detail string) error {
+	if err := initAuditTable(tx); err != nil {
+		return err
+	}
+
+	_, err := tx.Exec(
+		fmt.Sprintf("INSERT INTO %s (queue, action, affected, detail, at) VALUES (?, ?, ?, ?, ?)", quoteIdent(auditTable)),
+		q.tableName, action, affected, detail, q.now(),
+	)
+
+	return err
+}
+
+// bulk runs a statement changing items once the Authorizer allows action, and
+// records it in the audit log within the same transaction
+// Returns the number of affected items
+func (q *Queue) bulk(ctx context.Context, action Action, detail, query string, args ...any) (affected int64, err error) {
+	defer func() { err = mapError(err) }()
+	defer q.invalidateStats()
+
+	if q.closed.Load() {
+		return 0, ErrClosed
+	}
+
+	if err := q.authorize(ctx, action); err != nil {
+		return 0, err
+	}
+
+	tx, err := q.client.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	result, err := tx.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	if affected, err = result.RowsAffected(); err != nil {
+		return 0, err
+	}
+
+	if err = q.audit(tx, action, affected, detail); err != nil {
+		return 0, err
+	}
+
+	return affected, tx.Commit()
+}
+
+// FailPending marks the pending items matching filter as failed with reason, so
+// they are no longer dequeued but stay available for inspection. It is meant for
+// incident cleanup and is recorded in the audit log.
+// Returns the number of failed items
+func (q *Queue) FailPending(ctx context.Context, filter Filter, reason string) (int64, error) {
+	cond, args := filter.where(q)
+
+	return q.bulk(ctx, ActionFail, reason,
+		fmt.Sprintf("UPDATE %s SET status = 'failed', fail_reason = ?, updated_at = ? WHERE status = 'pending' AND %s", quoteIdent(q.tableName), cond),
+		append([]any{reason, q.now()}, args...)...,
+	)
+}
+
+// DeleteOlderThan deletes the items enqueued before t, except those in processing.
+// It is recorded in the audit log.
+// Returns the number of deleted items
+func (q *Queue) DeleteOlderThan(ctx context.Context, t time.Time) (int64, error) {
+	return q.bulk(ctx, ActionDelete, "older than "+t.UTC().Format(time.RFC3339),
+		fmt.Sprintf("DELETE FROM %s WHERE status != 'processing' AND created_at < ?", quoteIdent(q.tableName)),
+		t.UTC(),
+	)
+}
+
+// AuditLog returns the bulk operations recorded since the given time, oldest first
+func (m *Manager) AuditLog(since time.Time) (entries []AuditEntry, err error) {
+	defer func() { err = mapError(err) }()
+
+	if m.closed.Load() {
+		return nil, ErrQueuesClosed
+	}
+
+	var exists bool
+	err = m.client.QueryRow("SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = ?)", auditTable).Scan(&exists)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	rows, err := m.client.Query(
+		fmt.Sprintf("SELECT queue, action, affected, detail, at FROM %s WHERE at >= ? ORDER BY id", quoteIdent(auditTable)),
+		since.UTC(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var e AuditEntry
+		if err := rows.Scan(&e.Queue, &e.Action, &e.Affected, &e.Detail, &e.At); err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, e)
+	}
+
+	return entries, rows.Err()
+}
@@ -0,0 +1,90 @@
+package sqliteq
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestBulkOperations(t *testing.T) {
+	dbPath := "test_bulk.db"
+	defer os.Remove(dbPath)
+
+	queues, err := NewManager(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	defer queues.Close()
+
+	pq, err := queues.NewPriorityQueue("bulk")
+	if err != nil {
+		t.Fatalf("Failed to create priority queue: %v", err)
+	}
+
+	start := time.Now()
+	pq.Enqueue("poison order", 1)
+	pq.Enqueue("poison refund", 2)
+	pq.Enqueue("good order", 1)
+
+	t.Run("FailPending", func(t *testing.T) {
+		priority := 1
+		failed, err := pq.FailPending(context.Background(), Filter{Priority: &priority, PayloadContains: []byte("poison")}, "bad upstream data")
+		if err != nil {
+			t.Fatalf("FailPending failed: %v", err)
+		}
+
+		if failed != 1 {
+			t.Errorf("Expected 1 failed item, got %d", failed)
+		}
+
+		depth, _ := pq.LenDetailed()
+		if depth.Pending != 2 || depth.Failed != 1 {
+			t.Errorf("Expected 2 pending and 1 failed item, got %+v", depth)
+		}
+
+		// Failed items are not dequeued
+		item, _ := pq.Dequeue()
+		if string(item.([]byte)) != "good order" {
+			t.Errorf("Expected good order, got %s", item)
+		}
+	})
+
+	t.Run("DeleteOlderThan", func(t *testing.T) {
+		pq.Enqueue("in flight", 0)
+		pq.DequeueWithAckId()
+
+		deleted, err := pq.DeleteOlderThan(context.Background(), time.Now().Add(time.Second))
+		if err != nil {
+			t.Fatalf("DeleteOlderThan failed: %v", err)
+		}
+
+		if deleted != 2 {
+			t.Errorf("Expected the failed and pending items to be deleted, got %d", deleted)
+		}
+
+		depth, _ := pq.LenDetailed()
+		if depth != (Depth{Processing: 1}) {
+			t.Errorf("Expected only the processing item to remain, got %+v", depth)
+		}
+	})
+
+	t.Run("AuditLog", func(t *testing.T) {
+		entries, err := queues.AuditLog(start)
+		if err != nil {
+			t.Fatalf("AuditLog failed: %v", err)
+		}
+
+		if len(entries) != 2 {
+			t.Fatalf("Expected 2 audit entries, got %d", len(entries))
+		}
+
+		if e := entries[0]; e.Queue != "bulk" || e.Action != ActionFail || e.Affected != 1 || e.Detail != "bad upstream data" {
+			t.Errorf("Unexpected fail entry %+v", e)
+		}
+
+		if e := entries[1]; e.Action != ActionDelete || e.Affected != 2 {
+			t.Errorf("Unexpected delete entry %+v", e)
+		}
+	})
+}
@@ -0,0 +1,50 @@
+package sqliteq
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// cloudEvent is the JSON format of a CloudEvents 1.0 envelope
+type cloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Time            string          `json:"time"`
+	DataContentType string          `json:"datacontenttype,omitempty"`
+	Data            json.RawMessage `json:"data,omitempty"`
+	DataBase64      []byte          `json:"data_base64,omitempty"`
+}
+
+// CloudEvents returns an interceptor wrapping every payload in a CloudEvents 1.0
+// JSON envelope with a fresh id, the given source and type, and the time it was
+// enqueued, so consumers forwarding items to HTTP endpoints or brokers get a
+// standard format. JSON payloads are embedded as data; others are base64-encoded
+// as data_base64.
+func CloudEvents(source, eventType string) EnqueueInterceptor {
+	return func(m *Message) error {
+		event := cloudEvent{
+			SpecVersion: "1.0",
+			ID:          newID(),
+			Source:      source,
+			Type:        eventType,
+			Time:        time.Now().UTC().Format(time.RFC3339Nano),
+		}
+
+		if json.Valid(m.Data) {
+			event.DataContentType = "application/json"
+			event.Data = m.Data
+		} else {
+			event.DataBase64 = m.Data
+		}
+
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+
+		m.Data = data
+		return nil
+	}
+}
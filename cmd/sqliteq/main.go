@@ -0,0 +1,89 @@
+// Command sqliteq runs maintenance tasks on SQLiteQ databases.
+//
+// Usage:
+//
+//	sqliteq doctor --db app.db [--fix] [--stale-after 1h] [--max-wal-size 67108864]
+//
+// doctor checks the on-disk format, missing indexes, items left in processing,
+// registry drift and the WAL size, and with --fix repairs what it can. It exits
+// with status 1 when problems remain. On a live database, --stale-after must
+// exceed the longest visibility timeout of its consumers, or --fix requeues
+// items they are still working on.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/goptics/sqliteq"
+)
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] != "doctor" {
+		fmt.Fprintln(os.Stderr, "usage: sqliteq doctor --db <path> [--fix] [--stale-after <duration>] [--max-wal-size <bytes>]")
+		os.Exit(2)
+	}
+
+	os.Exit(doctor(os.Args[2:]))
+}
+
+// doctor runs the doctor command and returns the exit status
+func doctor(args []string) int {
+	flags := flag.NewFlagSet("doctor", flag.ExitOnError)
+	dbPath := flags.String("db", "", "path to the database file")
+	fix := flags.Bool("fix", false, "repair the problems that can be repaired")
+	staleAfter := flags.Duration("stale-after", 0, "report items in processing for longer than this (default 1h)")
+	maxWALSize := flags.Int64("max-wal-size", 0, "report a WAL larger than this many bytes (default 64 MiB)")
+	flags.Parse(args)
+
+	if *dbPath == "" {
+		fmt.Fprintln(os.Stderr, "doctor: --db is required")
+		return 2
+	}
+
+	if _, err := os.Stat(*dbPath); err != nil {
+		fmt.Fprintf(os.Stderr, "doctor: %v\n", err)
+		return 2
+	}
+
+	m, err := sqliteq.NewManager(*dbPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "doctor: %v\n", err)
+		if errors.Is(err, sqliteq.ErrCorrupt) {
+			fmt.Fprintln(os.Stderr, "doctor: the database is corrupted; stop every process using it and salvage it with sqliteq.Recover")
+		}
+		return 1
+	}
+	defer m.Close()
+
+	findings, err := m.Doctor(context.Background(), sqliteq.DoctorOptions{
+		Fix:        *fix,
+		StaleAfter: *staleAfter,
+		MaxWALSize: *maxWALSize,
+	})
+
+	status := 0
+	for _, f := range findings {
+		state := "problem"
+		if f.Fixed {
+			state = "fixed"
+		} else {
+			status = 1
+		}
+		fmt.Printf("%-8s %-8s %s\n", f.Check, state, f.Problem)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "doctor: %v\n", err)
+		return 1
+	}
+
+	if len(findings) == 0 {
+		fmt.Println("no problems found")
+	}
+
+	return status
+}
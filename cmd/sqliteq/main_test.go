@@ -0,0 +1,60 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/goptics/sqliteq"
+)
+
+func TestDoctor(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "doctor.db")
+
+	if status := doctor([]string{"--db", dbPath}); status != 2 {
+		t.Errorf("Expected status 2 for a missing database, got %d", status)
+	}
+
+	m, err := sqliteq.NewManager(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+
+	q, err := m.NewQueue("jobs")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	q.Enqueue("item")
+	q.DequeueWithAckId()
+	m.Close()
+
+	// Silence the report
+	stdout := os.Stdout
+	os.Stdout, _ = os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	defer func() { os.Stdout = stdout }()
+
+	if status := doctor([]string{"--db", dbPath}); status != 0 {
+		t.Errorf("Expected status 0 for a healthy database, got %d", status)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+
+	if status := doctor([]string{"--db", dbPath, "--stale-after", "1ms"}); status != 1 {
+		t.Errorf("Expected status 1 for an orphaned item, got %d", status)
+	}
+
+	if status := doctor([]string{"--db", dbPath, "--stale-after", "1ms", "--fix"}); status != 0 {
+		t.Errorf("Expected status 0 once the orphan was fixed, got %d", status)
+	}
+
+	m, err = sqliteq.NewManager(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to reopen database: %v", err)
+	}
+	defer m.Close()
+
+	if d, _ := m.Describe("jobs"); d.Depth.Pending != 1 {
+		t.Errorf("Expected the orphan back in pending, got %+v", d.Depth)
+	}
+}
@@ -0,0 +1,108 @@
+package sqliteq
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// coalesceScanLimit is how many pending items DequeueCoalesced looks through for
+// items sharing the first item's key
+const coalesceScanLimit = 1000
+
+// DequeueCoalesced claims the next item together with up to max-1 later pending
+// items for which key returns the same value, so duplicate work such as fifty
+// "sync user 42" jobs is delivered once. Only the next coalesceScanLimit pending
+// items are considered. The items are returned in dequeue order, each in processing
+// with its own AckID; acknowledge them together with AckMany once the merged work is
+// done. Returns ErrEmpty when no item is ready.
+func (q *Queue) DequeueCoalesced(key func(Message) string, max int) ([]Message, error) {
+	if max < 1 {
+		max = 1
+	}
+
+	messages, err := q.claim(true, coalesceScanLimit, func(candidates []Message) []Message {
+		if len(candidates) == 0 {
+			return nil
+		}
+
+		first := key(candidates[0])
+		picked := []Message{candidates[0]}
+
+		for _, m := range candidates[1:] {
+			if len(picked) == max {
+				break
+			}
+			if key(m) == first {
+				picked = append(picked, m)
+			}
+		}
+
+		return picked
+	}, "")
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrEmpty
+	}
+
+	return messages, err
+}
+
+// AckMany acknowledges several items in one transaction, such as those returned by
+// DequeueCoalesced. If any ack ID is unknown, none of the items are acknowledged.
+// Retries and errors are as for Ack.
+func (q *Queue) AckMany(ackIDs []string) error {
+	err := q.retryWrite(func() error { return q.acknowledgeMany(ackIDs) })
+	if IsRetriable(err) {
+		return fmt.Errorf("%w: %w", ErrAckUncertain, err)
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: %w", ErrUnknownAckID, err)
+	}
+
+	return err
+}
+
+// acknowledgeMany completes the items holding ackIDs in one transaction
+func (q *Queue) acknowledgeMany(ackIDs []string) (err error) {
+	defer func() { err = mapError(err) }()
+	defer q.invalidateStats()
+	defer q.observeWrite(time.Now(), &err)
+
+	if q.closed.Load() {
+		return ErrClosed
+	}
+
+	if err = q.manager.awaitLease(); err != nil {
+		return err
+	}
+
+	tx, err := q.client.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	claimedAt := make([]time.Time, len(ackIDs))
+	for i, ackID := range ackIDs {
+		if claimedAt[i], err = q.acknowledgeTx(tx, ackID); err != nil {
+			return err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	q.observeAcks(claimedAt...)
+	q.freed.notify()
+	q.notifyAcked(ackIDs...)
+
+	return nil
+}
@@ -0,0 +1,75 @@
+package sqliteq
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDequeueCoalesced(t *testing.T) {
+	dbPath := "test_coalesce.db"
+	defer os.Remove(dbPath)
+
+	queues := New(dbPath)
+	defer queues.Close()
+
+	q, err := queues.NewQueue("test_queue")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	for _, job := range []string{"sync user 42", "sync user 7", "sync user 42", "sync user 42", "sync user 42"} {
+		q.Enqueue(job)
+	}
+
+	byPayload := func(m Message) string { return string(m.Data) }
+
+	messages, err := q.DequeueCoalesced(byPayload, 3)
+	if err != nil {
+		t.Fatalf("DequeueCoalesced failed: %v", err)
+	}
+
+	if len(messages) != 3 {
+		t.Fatalf("Expected 3 coalesced items, got %d", len(messages))
+	}
+
+	ackIDs := make([]string, len(messages))
+	for i, m := range messages {
+		if string(m.Data) != "sync user 42" || m.Status != StatusProcessing {
+			t.Errorf("Expected a claimed 'sync user 42', got %+v", m)
+		}
+		ackIDs[i] = m.AckID
+	}
+
+	if err := q.AckMany(ackIDs); err != nil {
+		t.Fatalf("AckMany failed: %v", err)
+	}
+
+	// The item left over by max comes after the other key
+	messages, _ = q.DequeueCoalesced(byPayload, 3)
+	if len(messages) != 1 || !strings.HasSuffix(string(messages[0].Data), "7") {
+		t.Errorf("Expected 'sync user 7' alone, got %v", messages)
+	}
+
+	t.Run("AllOrNothing", func(t *testing.T) {
+		rest, _ := q.DequeueCoalesced(byPayload, 3)
+		if len(rest) != 1 {
+			t.Fatalf("Expected the last item, got %v", rest)
+		}
+
+		if err := q.AckMany([]string{rest[0].AckID, "unknown"}); !errors.Is(err, ErrUnknownAckID) {
+			t.Errorf("Expected ErrUnknownAckID, got %v", err)
+		}
+
+		if depth, _ := q.LenDetailed(); depth.Processing != 2 {
+			t.Errorf("Expected both items still in processing, got %+v", depth)
+		}
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		if _, err := q.DequeueCoalesced(byPayload, 3); !errors.Is(err, ErrEmpty) {
+			t.Errorf("Expected ErrEmpty, got %v", err)
+		}
+	})
+}
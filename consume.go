@@ -0,0 +1,143 @@
+package sqliteq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// defaultPollInterval is how long Consume waits before looking for new items
+// when the queue is empty
+const defaultPollInterval = 100 * time.Millisecond
+
+// ConsumeOption configures Consume
+type ConsumeOption func(*consumeConfig)
+
+// consumeConfig holds the settings of a Consume call
+type consumeConfig struct {
+	pollInterval time.Duration
+	concurrency  int
+}
+
+// WithConcurrency makes Consume process up to n items in parallel, each in its own
+// goroutine claiming, handling and acknowledging items independently. Defaults to 1.
+func WithConcurrency(n int) ConsumeOption {
+	return func(c *consumeConfig) {
+		if n > 0 {
+			c.concurrency = n
+		}
+	}
+}
+
+// WithPollInterval sets how long Consume waits before looking for new items when
+// the queue is empty, the database is busy or the circuit breaker is open.
+// Defaults to 100ms.
+func WithPollInterval(interval time.Duration) ConsumeOption {
+	return func(c *consumeConfig) {
+		if interval > 0 {
+			c.pollInterval = interval
+		}
+	}
+}
+
+// Consume runs a worker loop calling handler for every item of the queue until ctx
+// ends, waiting for new items when the queue is empty. Items are dequeued with an
+// ack ID and acknowledged when handler succeeds; when it fails or panics they are
+// handed back with Nack, so WithRetryPolicy and WithDeadLetterQueue apply.
+// Once ctx ends, Consume waits for the running handlers to finish and settles
+// their items before returning, so shutdowns don't leave items in processing.
+// Returns the context's error once it ends, or ErrClosed once the queue is closed
+func (q *Queue) Consume(ctx context.Context, handler Handler, opts ...ConsumeOption) error {
+	c := consumeConfig{pollInterval: defaultPollInterval, concurrency: 1}
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	if c.concurrency == 1 {
+		return q.consumeLoop(ctx, handler, c)
+	}
+
+	// Every worker stops for the same reason, the context ending or the queue closing
+	errs := make([]error, c.concurrency)
+
+	var wg sync.WaitGroup
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = q.consumeLoop(ctx, handler, c)
+		}(i)
+	}
+	wg.Wait()
+
+	return errs[0]
+}
+
+// consumeLoop is a Consume worker processing one item at a time
+func (q *Queue) consumeLoop(ctx context.Context, handler Handler, c consumeConfig) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if !q.consumeOne(handler) {
+			if q.closed.Load() {
+				return ErrClosed
+			}
+
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(c.pollInterval):
+			}
+		}
+	}
+}
+
+// consumeOne processes the next item with handler
+// Returns false when no item could be claimed
+func (q *Queue) consumeOne(handler Handler) bool {
+	if q.breaker != nil && !q.breaker.allow(time.Now()) {
+		return false
+	}
+
+	m, err := q.dequeueInternal(true, "")
+	if err != nil {
+		if q.breaker != nil {
+			q.breaker.cancel()
+		}
+
+		return false
+	}
+
+	err = callHandler(handler, m)
+	if q.breaker != nil {
+		q.breaker.record(err != nil, time.Now())
+	}
+
+	// Busy writes are retried as set with WithWriteRetry; an item left in processing
+	// is delivered again after its visibility timeout
+	if err != nil {
+		q.retryWrite(func() error { return q.release(m.AckID, err) })
+	} else {
+		q.Ack(m.AckID)
+	}
+
+	return true
+}
+
+// errHandlerPanicked is the fail reason of items whose handler panicked
+var errHandlerPanicked = errors.New("handler panicked")
+
+// callHandler runs handler on m, turning a panic into an error
+func callHandler(handler Handler, m Message) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: %v", errHandlerPanicked, r)
+		}
+	}()
+
+	return handler(m)
+}
@@ -0,0 +1,153 @@
+package sqliteq
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConsume(t *testing.T) {
+	dbPath := "test_consume.db"
+	defer os.Remove(dbPath)
+
+	queues := New(dbPath)
+	defer queues.Close()
+
+	q, err := queues.NewQueue("jobs")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	q.Enqueue("ok")
+	q.Enqueue("flaky")
+	q.Enqueue("panics")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var mu sync.Mutex
+	calls := make(map[string]int)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- q.Consume(ctx, func(m Message) error {
+			mu.Lock()
+			calls[string(m.Data)]++
+			n := calls[string(m.Data)]
+			mu.Unlock()
+
+			switch {
+			case string(m.Data) == "flaky" && n == 1:
+				return errors.New("try again")
+			case string(m.Data) == "panics" && n == 1:
+				panic("boom")
+			case string(m.Data) == "late":
+				cancel()
+			}
+
+			return nil
+		}, WithPollInterval(10*time.Millisecond))
+	}()
+
+	// Wait for the loop to go idle, then wake it with a new item
+	deadline := time.Now().Add(2 * time.Second)
+	for q.Len() != 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	q.Enqueue("late")
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Consume didn't stop when the context was canceled")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if calls["ok"] != 1 || calls["flaky"] != 2 || calls["panics"] != 2 || calls["late"] != 1 {
+		t.Errorf("Expected failed items to be retried once, got %v", calls)
+	}
+
+	if depth, _ := q.LenDetailed(); depth != (Depth{}) {
+		t.Errorf("Expected every item acknowledged, got %+v", depth)
+	}
+
+	t.Run("Closed", func(t *testing.T) {
+		q.Close()
+
+		if err := q.Consume(context.Background(), func(Message) error { return nil }); !errors.Is(err, ErrClosed) {
+			t.Errorf("Expected ErrClosed, got %v", err)
+		}
+	})
+}
+
+func TestConsumeConcurrency(t *testing.T) {
+	dbPath := "test_consume_concurrency.db"
+	defer os.Remove(dbPath)
+
+	queues := New(dbPath)
+	defer queues.Close()
+
+	q, err := queues.NewQueue("jobs")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	for i := 0; i < 8; i++ {
+		q.Enqueue("job")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var mu sync.Mutex
+	var running, peak, handled int
+
+	err = q.Consume(ctx, func(m Message) error {
+		mu.Lock()
+		running++
+		if running > peak {
+			peak = running
+		}
+		mu.Unlock()
+
+		time.Sleep(50 * time.Millisecond)
+
+		mu.Lock()
+		running--
+		handled++
+		// Stop once the last items are being handled; they still complete
+		if handled == 6 {
+			cancel()
+		}
+		mu.Unlock()
+
+		return nil
+	}, WithConcurrency(4), WithPollInterval(10*time.Millisecond))
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if running != 0 {
+		t.Errorf("Expected Consume to wait for running handlers, %d still running", running)
+	}
+
+	if peak < 2 || peak > 4 {
+		t.Errorf("Expected between 2 and 4 handlers at once, got %d", peak)
+	}
+
+	depth, _ := q.LenDetailed()
+	if depth.Processing != 0 || depth.Pending != 8-handled {
+		t.Errorf("Expected no item left in processing and %d pending, got %+v", 8-handled, depth)
+	}
+}
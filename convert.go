@@ -0,0 +1,122 @@
+package sqliteq
+
+import (
+	"fmt"
+)
+
+// ConvertToPriority turns the queue name, or the queue an alias routes to, into a
+// priority queue in one transaction: its items are kept with priority 0, in the
+// same order, and the priority index is built. The Queue values opened for it by
+// this manager are closed; reopen it with NewPriorityQueue. Converting a priority
+// queue does nothing.
+// Returns ErrUnknownQueue when there is no such queue
+func (m *Manager) ConvertToPriority(name string) error {
+	return m.convert(name, kindPriorityQueue)
+}
+
+// ConvertToPlain turns the priority queue name, or the queue an alias routes to,
+// into a plain queue in one transaction: its items are kept and dequeued in the
+// order they were enqueued, and their priorities are dropped with the priority
+// index. The Queue values opened for it by this manager are closed; reopen it with
+// NewQueue. Converting a plain queue does nothing.
+// Returns ErrUnknownQueue when there is no such queue
+func (m *Manager) ConvertToPlain(name string) error {
+	return m.convert(name, kindQueue)
+}
+
+// convert migrates the schema and registry entry of a queue to kind
+func (m *Manager) convert(name, kind string) (err error) {
+	defer func() { err = mapError(err) }()
+
+	if m.closed.Load() {
+		return ErrQueuesClosed
+	}
+
+	if name, err = m.resolve(name); err != nil {
+		return err
+	}
+
+	if err = m.awaitLease(); err != nil {
+		return err
+	}
+
+	var detached []*Queue
+	// Closed once m.mu is released, see detach
+	defer func() { closeQueues(detached) }()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tx, err := m.client.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	current, err := registeredKind(tx, name)
+	if err != nil {
+		return err
+	}
+
+	switch current {
+	case "":
+		return fmt.Errorf("%w %q", ErrUnknownQueue, name)
+	case kind:
+		tx.Rollback()
+		return nil
+	}
+
+	columns, err := tableColumns(tx, name)
+	if err != nil {
+		return err
+	}
+
+	var hasPriority bool
+	for _, c := range columns {
+		hasPriority = hasPriority || c == "priority"
+	}
+
+	table := quoteIdent(name)
+	var statements []string
+	if kind == kindPriorityQueue {
+		// Queues opened as priority queues before conversions existed have the column
+		if !hasPriority {
+			statements = append(statements, fmt.Sprintf("ALTER TABLE %s ADD COLUMN priority INTEGER NOT NULL DEFAULT 0", table))
+		}
+		statements = append(statements, fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (priority ASC, seq ASC) WHERE status = 'pending'",
+			quoteIdent(name+"_priority_seq_idx"), table))
+	} else {
+		// The column can only be dropped once no index uses it
+		statements = append(statements,
+			fmt.Sprintf("DROP INDEX IF EXISTS %s", quoteIdent(name+"_priority_idx")),
+			fmt.Sprintf("DROP INDEX IF EXISTS %s", quoteIdent(name+"_priority_seq_idx")),
+		)
+		if hasPriority {
+			statements = append(statements, fmt.Sprintf("ALTER TABLE %s DROP COLUMN priority", table))
+		}
+	}
+
+	for _, statement := range statements {
+		if _, err = tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(fmt.Sprintf("UPDATE %s SET kind = ? WHERE name = ?", quoteIdent(registryTable)), kind, name)
+	if err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	// Open queues would keep using the old kind
+	detached = m.detach(name, fmt.Errorf("%w: %q was converted", ErrKindMismatch, name))
+
+	return nil
+}
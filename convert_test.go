@@ -0,0 +1,104 @@
+package sqliteq
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestConvert(t *testing.T) {
+	dbPath := "test_convert.db"
+	defer os.Remove(dbPath)
+
+	queues, err := NewManager(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	defer queues.Close()
+
+	q, err := queues.NewQueue("jobs")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	q.Enqueue("first")
+	q.Enqueue("second")
+
+	if _, err := queues.NewPriorityQueue("jobs"); !errors.Is(err, ErrKindMismatch) {
+		t.Fatalf("Expected ErrKindMismatch opening a plain queue as a priority queue, got %v", err)
+	}
+
+	if err := queues.ConvertToPriority("jobs"); err != nil {
+		t.Fatalf("ConvertToPriority failed: %v", err)
+	}
+
+	if _, ok := q.Dequeue(); ok {
+		t.Error("Expected the queue opened before the conversion to be closed")
+	}
+
+	pq, err := queues.NewPriorityQueue("jobs")
+	if err != nil {
+		t.Fatalf("Failed to open the converted queue: %v", err)
+	}
+	pq.Enqueue("urgent", -1)
+
+	// Dynamic opens follow the registered kind
+	if err := queues.EnqueueTo("jobs", "routed"); err != nil {
+		t.Fatalf("EnqueueTo failed: %v", err)
+	}
+
+	if info, err := queues.Describe("jobs"); err != nil || !info.Priority {
+		t.Errorf("Expected a priority queue, got %+v, %v", info, err)
+	}
+
+	if err := queues.ConvertToPlain("jobs"); err != nil {
+		t.Fatalf("ConvertToPlain failed: %v", err)
+	}
+
+	// Reopening would add the priority column back
+	if err := pq.Reopen(); !errors.Is(err, ErrKindMismatch) {
+		t.Errorf("Expected ErrKindMismatch reopening a converted queue, got %v", err)
+	}
+
+	if _, err := queues.NewPriorityQueue("jobs"); !errors.Is(err, ErrKindMismatch) {
+		t.Fatalf("Expected ErrKindMismatch opening a plain queue as a priority queue, got %v", err)
+	}
+
+	q, err = queues.NewQueue("jobs")
+	if err != nil {
+		t.Fatalf("Failed to open the converted queue: %v", err)
+	}
+
+	var got []string
+	for item, ok := q.Dequeue(); ok; item, ok = q.Dequeue() {
+		got = append(got, string(item.([]byte)))
+	}
+
+	// Without priorities the items come back in insertion order
+	expected := []string{"first", "second", "urgent", "routed"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+
+	t.Run("SameKind", func(t *testing.T) {
+		if err := queues.ConvertToPlain("jobs"); err != nil {
+			t.Errorf("Expected converting to the same kind to do nothing, got %v", err)
+		}
+
+		if _, err := queues.NewQueue("jobs"); err != nil {
+			t.Errorf("Expected the queue to stay open, got %v", err)
+		}
+	})
+
+	t.Run("Hooks", func(t *testing.T) {
+		awaitWithReaperHook(t, queues, "hooked", func() error {
+			return queues.ConvertToPriority("hooked")
+		})
+	})
+
+	t.Run("UnknownQueue", func(t *testing.T) {
+		if err := queues.ConvertToPriority("missing"); !errors.Is(err, ErrUnknownQueue) {
+			t.Errorf("Expected ErrUnknownQueue, got %v", err)
+		}
+	})
+}
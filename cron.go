@@ -0,0 +1,190 @@
+package sqliteq
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSchedule is a parsed cron expression: a bit set of the allowed values of
+// each field, or a fixed interval for @every
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	// domStar and dowStar record unrestricted day fields, which change how the
+	// day of month and day of week combine
+	domStar, dowStar bool
+	every            time.Duration
+}
+
+// cronField describes the range of a cron field
+type cronField struct {
+	name     string
+	min, max int
+}
+
+var cronFields = []cronField{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 6},
+}
+
+// cronMacros are the shorthands accepted in place of the five fields
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// parseCron parses a standard five-field cron expression (minute, hour, day of
+// month, month, day of week) supporting *, lists, ranges and steps, the @hourly
+// style macros, and "@every <duration>"
+func parseCron(spec string) (*cronSchedule, error) {
+	spec = strings.TrimSpace(spec)
+
+	if rest, ok := strings.CutPrefix(spec, "@every "); ok {
+		every, err := time.ParseDuration(strings.TrimSpace(rest))
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: %w", spec, err)
+		}
+		if every < time.Second {
+			return nil, fmt.Errorf("invalid schedule %q: interval must be at least 1s", spec)
+		}
+		return &cronSchedule{every: every}, nil
+	}
+
+	if macro, ok := cronMacros[spec]; ok {
+		spec = macro
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("invalid schedule %q: expected %d fields, got %d", spec, len(cronFields), len(fields))
+	}
+
+	sets := make([]uint64, len(fields))
+	for i, field := range fields {
+		set, err := parseCronField(field, cronFields[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: %w", spec, err)
+		}
+		sets[i] = set
+	}
+
+	// Sunday may also be written as 7
+	if sets[4]&(1<<7) != 0 {
+		sets[4] |= 1
+	}
+
+	return &cronSchedule{
+		minute: sets[0], hour: sets[1], dom: sets[2], month: sets[3], dow: sets[4],
+		domStar: fields[2] == "*" || strings.HasPrefix(fields[2], "*/"),
+		dowStar: fields[4] == "*" || strings.HasPrefix(fields[4], "*/"),
+	}, nil
+}
+
+// parseCronField parses one comma-separated field into a bit set of its values
+func parseCronField(field string, f cronField) (uint64, error) {
+	max := f.max
+	if f.name == "day of week" {
+		max = 7
+	}
+
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if base, s, ok := strings.Cut(part, "/"); ok {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("invalid step in %s field %q", f.name, part)
+			}
+			part, step = base, n
+		}
+
+		lo, hi := f.min, max
+		if part != "*" {
+			from, to, isRange := strings.Cut(part, "-")
+
+			var err error
+			if lo, err = strconv.Atoi(from); err != nil {
+				return 0, fmt.Errorf("invalid %s field %q", f.name, part)
+			}
+			hi = lo
+			if isRange {
+				if hi, err = strconv.Atoi(to); err != nil {
+					return 0, fmt.Errorf("invalid %s field %q", f.name, part)
+				}
+			} else if step > 1 {
+				// "5/15" means from 5 to the end in steps of 15
+				hi = max
+			}
+		}
+
+		if lo < f.min || hi > max || lo > hi {
+			return 0, fmt.Errorf("%s field %q out of range %d-%d", f.name, part, f.min, f.max)
+		}
+
+		for v := lo; v <= hi; v += step {
+			set |= 1 << v
+		}
+	}
+
+	return set, nil
+}
+
+// next returns the first time after t the schedule fires, in t's location, or the
+// zero time if it never does
+func (c *cronSchedule) next(t time.Time) time.Time {
+	if c.every > 0 {
+		return t.Truncate(time.Second).Add(c.every)
+	}
+
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	// Give up on expressions such as February 30th after a few years
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if !c.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if c.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
+
+// dayMatches applies the cron rule that, when both day fields are restricted, a
+// day matching either of them qualifies
+func (c *cronSchedule) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+
+	if c.domStar || c.dowStar {
+		return dom && dow
+	}
+
+	return dom || dow
+}
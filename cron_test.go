@@ -0,0 +1,51 @@
+package sqliteq
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	from := time.Date(2024, time.January, 31, 10, 7, 30, 0, time.UTC)
+
+	tt := []struct {
+		spec string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2024, time.January, 31, 10, 8, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, time.January, 31, 10, 15, 0, 0, time.UTC)},
+		{"0 9-17 * * *", time.Date(2024, time.January, 31, 11, 0, 0, 0, time.UTC)},
+		{"30 2 * * *", time.Date(2024, time.February, 1, 2, 30, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 1,7", time.Date(2024, time.February, 4, 0, 0, 0, 0, time.UTC)},
+		// Both day fields restricted: either matches
+		{"0 0 15 * 5", time.Date(2024, time.February, 2, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2024, time.January, 31, 11, 0, 0, 0, time.UTC)},
+		{"@every 90s", time.Date(2024, time.January, 31, 10, 9, 0, 0, time.UTC)},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.spec, func(t *testing.T) {
+			schedule, err := parseCron(tc.spec)
+			if err != nil {
+				t.Fatalf("parseCron failed: %v", err)
+			}
+
+			if got := schedule.next(from); !got.Equal(tc.want) {
+				t.Errorf("Expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "@every 1ms", "@often"} {
+		if _, err := parseCron(spec); err == nil {
+			t.Errorf("Expected an error for %q", spec)
+		}
+	}
+
+	never, _ := parseCron("0 0 30 2 *")
+	if got := never.next(from); !got.IsZero() {
+		t.Errorf("Expected February 30th never to fire, got %v", got)
+	}
+}
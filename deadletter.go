@@ -0,0 +1,103 @@
+package sqliteq
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// initDeadLetter opens the dead-letter queue set with WithDeadLetterQueue
+func (q *Queue) initDeadLetter() error {
+	if q.deadLetterName == "" {
+		return nil
+	}
+
+	tableName, err := q.manager.resolve(q.deadLetterName)
+	if err != nil {
+		return err
+	}
+
+	if tableName == q.tableName {
+		return errors.New("a queue can't be its own dead-letter queue")
+	}
+
+	// The dead-letter queue is held by this queue, so it isn't dropped when empty
+	q.deadLetter, err = q.manager.queue(tableName, false)
+
+	return err
+}
+
+// moveDeadLetters moves the pending items that exceeded the maximum attempts to the
+// dead-letter queue, in batches of the janitor batch size
+// Returns the number of moved items
+func (q *Queue) moveDeadLetters() (int64, error) {
+	if q.deadLetter == nil {
+		return 0, nil
+	}
+
+	reason := fmt.Sprintf("exceeded %d attempts", q.maxAttempts)
+
+	return q.moveItems(q, q.deadLetter, "status = 'pending' AND attempts > ?", []any{q.maxAttempts}, func(m *Message) {
+		m.Origin, m.FailReason = q.tableName, reason
+	})
+}
+
+// RedriveDLQ moves the items this queue sent to its dead-letter queue back to it,
+// as pending items with their attempts reset, e.g. once the bug that made them
+// fail is fixed. Items other queues sent to the same dead-letter queue stay there.
+// Returns the number of moved items
+func (q *Queue) RedriveDLQ() (int64, error) {
+	if q.closed.Load() {
+		return 0, ErrClosed
+	}
+
+	if q.deadLetter == nil {
+		return 0, nil
+	}
+
+	return q.moveItems(q.deadLetter, q, "status = 'pending' AND origin = ?", []any{q.tableName}, func(m *Message) {
+		m.Origin, m.FailReason, m.Attempts = "", "", 0
+	})
+}
+
+// moveItems moves the items of from matching cond with its arguments to the end of
+// to, adjusted by prepare, in batches of the janitor batch size
+// Returns the number of moved items
+func (q *Queue) moveItems(from, to *Queue, cond string, args []any, prepare func(m *Message)) (moved int64, err error) {
+	defer func() { err = mapError(err) }()
+	defer from.invalidateStats()
+	defer to.invalidateStats()
+	defer to.invalidateFront()
+
+	for {
+		n, err := q.moveBatch(from, to, cond, args, prepare)
+		moved += n
+		if err != nil || n < int64(q.janitorBatchSize) {
+			return moved, err
+		}
+	}
+}
+
+// moveBatch moves up to one janitor batch of items in a transaction, starting them
+// over without their schedule
+func (q *Queue) moveBatch(from, to *Queue, cond string, args []any, prepare func(m *Message)) (moved int64, err error) {
+	tx, err := q.client.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	moved, err = q.moveTx(tx, from, to, cond, args, q.janitorBatchSize, func(m *Message) {
+		m.NotBefore, m.RepeatEvery, m.RepeatUntil = time.Time{}, 0, time.Time{}
+		prepare(m)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return moved, tx.Commit()
+}
@@ -0,0 +1,88 @@
+package sqliteq
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestDeadLetterQueue(t *testing.T) {
+	dbPath := "test_dead_letter.db"
+	defer os.Remove(dbPath)
+
+	queues := New(dbPath)
+	defer queues.Close()
+
+	q, err := queues.NewQueue("jobs", WithDeadLetterQueue("jobs_dlq", 2))
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	dlq, err := queues.NewQueue("jobs_dlq")
+	if err != nil {
+		t.Fatalf("Failed to open dead-letter queue: %v", err)
+	}
+
+	q.Enqueue("poison")
+	q.Enqueue("fine")
+
+	// The first two failures are retried, the third moves the item
+	for attempt := 1; attempt <= 3; attempt++ {
+		m, err := q.DequeueMessageWithAckId()
+		if err != nil || string(m.Data) != "poison" {
+			t.Fatalf("Attempt %d: expected 'poison', got %v, %v", attempt, m.Data, err)
+		}
+
+		if err := q.Nack(m.AckID); err != nil {
+			t.Fatalf("Nack failed: %v", err)
+		}
+	}
+
+	if item, _ := q.Dequeue(); string(item.([]byte)) != "fine" {
+		t.Errorf("Expected 'fine' once 'poison' was dead-lettered, got %s", item)
+	}
+
+	messages, err := dlq.Page(0, 10)
+	if err != nil || len(messages) != 1 {
+		t.Fatalf("Expected 1 dead letter, got %v, %v", messages, err)
+	}
+
+	if m := messages[0]; string(m.Data) != "poison" || m.Origin != "jobs" || m.Attempts != 3 || m.FailReason == "" {
+		t.Errorf("Expected 'poison' from jobs with 3 attempts and a reason, got %+v", m)
+	}
+
+	t.Run("VisibilityTimeout", func(t *testing.T) {
+		vq, err := queues.NewQueue("timeouts", WithDeadLetterQueue("jobs_dlq", 1), WithVisibilityTimeout(time.Hour))
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+
+		vq.Enqueue("slow")
+		for i := 0; i < 2; i++ {
+			vq.DequeueWithAckId()
+			vq.client.Exec("UPDATE timeouts SET updated_at = ?", time.Now().UTC().Add(-2*time.Hour))
+			vq.reclaimExpired()
+		}
+
+		if vq.Len() != 0 || dlq.Len() != 2 {
+			t.Errorf("Expected the item dead-lettered after its second timeout, got %d pending and %d dead letters", vq.Len(), dlq.Len())
+		}
+	})
+
+	t.Run("Redrive", func(t *testing.T) {
+		redriven, err := q.RedriveDLQ()
+		if err != nil || redriven != 1 {
+			t.Fatalf("Expected 1 redriven item, got %d, %v", redriven, err)
+		}
+
+		m, err := q.DequeueMessage()
+		if err != nil || string(m.Data) != "poison" || m.Attempts != 1 || m.Origin != "" {
+			t.Errorf("Expected 'poison' back on its first attempt, got %+v, %v", m, err)
+		}
+
+		// The item of the other queue stays in the dead-letter queue
+		if dlq.Len() != 1 {
+			t.Errorf("Expected 1 dead letter left, got %d", dlq.Len())
+		}
+	})
+}
@@ -0,0 +1,157 @@
+package sqliteq
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ActionDeleteQueue drops a queue with DeleteQueue
+const ActionDeleteQueue Action = "delete_queue"
+
+// DeleteOption configures DeleteQueue
+type DeleteOption func(*deleteConfig)
+
+// deleteConfig holds the settings of a DeleteQueue call
+type deleteConfig struct {
+	archive bool
+}
+
+// WithArchive makes DeleteQueue copy the items of the queue, whatever their status,
+// into the table <name>_archive before dropping it, with the time they were
+// archived in an extra archived_at column. The archive is a plain table that is
+// never dequeued from; deleting a later queue of the same name appends to it.
+func WithArchive() DeleteOption {
+	return func(c *deleteConfig) {
+		c.archive = true
+	}
+}
+
+// DeleteQueue drops the queue name with its items and indexes, once the manager's
+// Authorizer, consulted with ctx, allows it. Its registry entry, the aliases routing
+// to it and the trash of its purges go too, and the Queue values opened for it by
+// this manager are closed. Aliases aren't followed: deleting one is Unalias.
+// Returns the number of deleted items, or ErrUnknownQueue when there is no such queue
+func (m *Manager) DeleteQueue(ctx context.Context, name string, opts ...DeleteOption) (deleted int64, err error) {
+	defer func() { err = mapError(err) }()
+
+	if m.closed.Load() {
+		return 0, ErrQueuesClosed
+	}
+
+	var c deleteConfig
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	if err = m.authorize(ctx, ActionDeleteQueue, name); err != nil {
+		return 0, err
+	}
+
+	if err = m.awaitLease(); err != nil {
+		return 0, err
+	}
+
+	var detached []*Queue
+	// Closed once m.mu is released, see detach
+	defer func() { closeQueues(detached) }()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tx, err := m.client.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var kind string
+	err = tx.QueryRow(fmt.Sprintf("SELECT kind FROM %s WHERE name = ?", quoteIdent(registryTable)), name).Scan(&kind)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && kind == kindAlias) {
+		return 0, fmt.Errorf("%w %q", ErrUnknownQueue, name)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	// A table dropped behind the registry's back only leaves its entries to clean up
+	if exists, err := tableExists(tx, name); err != nil {
+		return 0, err
+	} else if exists {
+		if err = tx.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s", quoteIdent(name))).Scan(&deleted); err != nil {
+			return 0, err
+		}
+
+		if c.archive {
+			if err = archiveRows(tx, name); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	if err = dropTable(tx, name); err != nil {
+		return 0, err
+	}
+
+	if _, err = tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE kind = ? AND target = ?", quoteIdent(registryTable)), kindAlias, name); err != nil {
+		return 0, err
+	}
+
+	if err = dropTrash(tx, name); err != nil {
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	detached = m.detach(name, fmt.Errorf("%w %q: deleted", ErrUnknownQueue, name))
+
+	return deleted, nil
+}
+
+// tableExists reports whether the database has a table of that name
+func tableExists(tx *sql.Tx, name string) (exists bool, err error) {
+	err = tx.QueryRow("SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = ?)", name).Scan(&exists)
+	return exists, err
+}
+
+// archiveRows appends every row of a queue table to its archive table within tx
+func archiveRows(tx *sql.Tx, name string) error {
+	archive := name + "_archive"
+
+	columns, err := mirrorTable(tx, name, archive, "NULL AS archived_at")
+	if err != nil {
+		return err
+	}
+
+	list := quoteColumns(columns)
+	_, err = tx.Exec(
+		fmt.Sprintf("INSERT INTO %s (%s, archived_at) SELECT %s, ? FROM %s", quoteIdent(archive), list, list, quoteIdent(name)),
+		time.Now().UTC(),
+	)
+
+	return err
+}
+
+// dropTrash drops the trash table of a queue within tx, with its purges, so they
+// can't be undone into a queue that no longer exists
+func dropTrash(tx *sql.Tx, name string) error {
+	exists, err := tableExists(tx, purgesTable)
+	if err != nil || !exists {
+		return err
+	}
+
+	if _, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE queue = ?", quoteIdent(purgesTable)), name); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(fmt.Sprintf("DROP TABLE IF EXISTS %s", quoteIdent(name+"_trash")))
+	return err
+}
@@ -0,0 +1,136 @@
+package sqliteq
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestDeleteQueue(t *testing.T) {
+	dbPath := "test_delete_queue.db"
+	defer os.Remove(dbPath)
+
+	queues, err := NewManager(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	defer queues.Close()
+
+	q, err := queues.NewQueue("obsolete")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	q.Enqueue("first")
+	q.Enqueue("second")
+	q.DequeueWithAckId()
+
+	if err := queues.Alias("obsolete", "old"); err != nil {
+		t.Fatalf("Alias failed: %v", err)
+	}
+
+	if _, err := queues.DeleteQueue(context.Background(), "old"); !errors.Is(err, ErrUnknownQueue) {
+		t.Errorf("Expected ErrUnknownQueue for an alias, got %v", err)
+	}
+
+	deleted, err := queues.DeleteQueue(context.Background(), "obsolete", WithArchive())
+	if err != nil {
+		t.Fatalf("DeleteQueue failed: %v", err)
+	}
+	if deleted != 2 {
+		t.Errorf("Expected 2 deleted items, got %d", deleted)
+	}
+
+	if !q.closed.Load() {
+		t.Error("Expected the open queue to be closed")
+	}
+
+	// Reopening would recreate the table behind the registry's back
+	if err := q.Reopen(); !errors.Is(err, ErrUnknownQueue) {
+		t.Errorf("Expected ErrUnknownQueue reopening a deleted queue, got %v", err)
+	}
+
+	var entries int
+	queues.client.QueryRow(`SELECT COUNT(*) FROM sqliteq_queues`).Scan(&entries)
+	if entries != 0 {
+		t.Errorf("Expected the queue and its alias gone from the registry, found %d entries", entries)
+	}
+
+	var archived int
+	if err := queues.client.QueryRow(`SELECT COUNT(*) FROM "obsolete_archive" WHERE archived_at IS NOT NULL`).Scan(&archived); err != nil {
+		t.Fatalf("Failed to read the archive: %v", err)
+	}
+	if archived != 2 {
+		t.Errorf("Expected 2 archived items, got %d", archived)
+	}
+
+	var tables int
+	queues.client.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE name = 'obsolete' OR tbl_name = 'obsolete'`).Scan(&tables)
+	if tables != 0 {
+		t.Errorf("Expected the table and its indexes dropped, found %d", tables)
+	}
+
+	if _, err := queues.DeleteQueue(context.Background(), "obsolete"); !errors.Is(err, ErrUnknownQueue) {
+		t.Errorf("Expected ErrUnknownQueue, got %v", err)
+	}
+}
+
+func TestDeleteQueueWithHooks(t *testing.T) {
+	dbPath := "test_delete_queue_hooks.db"
+	defer os.Remove(dbPath)
+
+	queues, err := NewManager(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	defer queues.Close()
+
+	awaitWithReaperHook(t, queues, "reclaimed", func() error {
+		_, err := queues.DeleteQueue(context.Background(), "reclaimed")
+		return err
+	})
+}
+
+// awaitWithReaperHook runs fn, which closes the queue name, while the visibility
+// timeout reaper of that queue runs a hook calling into the manager, failing the
+// test unless fn returns
+func awaitWithReaperHook(t *testing.T, queues *Manager, name string, fn func() error) {
+	t.Helper()
+
+	requeued := make(chan struct{})
+	q, err := queues.NewQueue(name,
+		WithVisibilityTimeout(50*time.Millisecond),
+		WithHooks(Hooks{OnRequeue: func(count int64) {
+			close(requeued)
+			// Give fn time to take the manager's lock
+			time.Sleep(50 * time.Millisecond)
+			queues.EnqueueTo(name+"_audit", "requeued")
+		}}),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	q.Enqueue("item")
+	q.DequeueWithAckId()
+	<-requeued
+
+	// Closing the queue waits for the reaper, running a hook that uses the manager
+	done := make(chan error, 1)
+	go func() { done <- fn() }()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Expected the queue to be closed, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the queue to be closed while a hook uses the manager")
+	}
+
+	if exists, _ := queues.Exists(name + "_audit"); !exists {
+		t.Error("Expected the hook's enqueue to go through")
+	}
+}
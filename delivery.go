@@ -0,0 +1,34 @@
+package sqliteq
+
+// DeliveryGuarantee describes whether items dequeued with an ack ID can be
+// delivered again
+type DeliveryGuarantee int
+
+const (
+	// AtLeastOnce returns unacknowledged items to pending when their visibility
+	// timeout elapses, when the queue is reopened and when a Drain handler fails,
+	// so every item is eventually processed but may be processed more than once
+	AtLeastOnce DeliveryGuarantee = iota
+	// AtMostOnce never returns a dequeued item to pending: unacknowledged items
+	// stay in processing and items whose Drain handler failed are marked failed,
+	// so no item is processed twice but some may not be processed at all
+	AtMostOnce
+)
+
+// String returns the name of the guarantee
+func (g DeliveryGuarantee) String() string {
+	switch g {
+	case AtLeastOnce:
+		return "at-least-once"
+	case AtMostOnce:
+		return "at-most-once"
+	}
+
+	return "unknown"
+}
+
+// DeliveryGuarantee returns the guarantee set with WithDeliveryGuarantee
+// Items removed with Dequeue are never delivered again under either guarantee
+func (q *Queue) DeliveryGuarantee() DeliveryGuarantee {
+	return q.delivery
+}
@@ -0,0 +1,79 @@
+package sqliteq
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestDeliveryGuarantee(t *testing.T) {
+	dbPath := "test_delivery_guarantee.db"
+	defer os.Remove(dbPath)
+
+	queues := New(dbPath)
+	defer queues.Close()
+
+	t.Run("DefaultAtLeastOnce", func(t *testing.T) {
+		q, err := queues.NewQueue("at_least_once")
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+
+		if q.DeliveryGuarantee() != AtLeastOnce {
+			t.Errorf("Expected %v, got %v", AtLeastOnce, q.DeliveryGuarantee())
+		}
+	})
+
+	q, err := queues.NewQueue("at_most_once",
+		WithDeliveryGuarantee(AtMostOnce),
+		WithVisibilityTimeout(time.Millisecond))
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	if q.DeliveryGuarantee() != AtMostOnce || q.DeliveryGuarantee().String() != "at-most-once" {
+		t.Errorf("Expected %v, got %v", AtMostOnce, q.DeliveryGuarantee())
+	}
+
+	q.Enqueue("unacked")
+	q.Enqueue("failing")
+
+	if _, success, _ := q.DequeueWithAckId(); !success {
+		t.Fatal("DequeueWithAckId failed")
+	}
+
+	t.Run("NoReclaim", func(t *testing.T) {
+		time.Sleep(10 * time.Millisecond)
+
+		if reclaimed, _ := q.reclaimExpired(); reclaimed != 0 {
+			t.Errorf("Expected no reclaimed item, got %d", reclaimed)
+		}
+	})
+
+	t.Run("DrainFailure", func(t *testing.T) {
+		boom := errors.New("boom")
+		_, err := q.Drain(context.Background(), func(m Message) error { return boom })
+		if !errors.Is(err, boom) {
+			t.Fatalf("Expected the handler error, got %v", err)
+		}
+
+		depth, _ := q.LenDetailed()
+		if want := (Depth{Processing: 1, Failed: 1}); depth != want {
+			t.Errorf("Expected %+v, got %+v", want, depth)
+		}
+	})
+
+	t.Run("NoRequeueOnOpen", func(t *testing.T) {
+		q.Close()
+		reopened, err := queues.NewQueue("at_most_once", WithDeliveryGuarantee(AtMostOnce))
+		if err != nil {
+			t.Fatalf("Failed to reopen queue: %v", err)
+		}
+
+		if _, success := reopened.Dequeue(); success {
+			t.Error("Expected no item to be delivered again")
+		}
+	})
+}
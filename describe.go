@@ -0,0 +1,119 @@
+package sqliteq
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// QueueDescription describes a queue for operators, see Describe
+type QueueDescription struct {
+	QueueInfo
+	// Labels are the queue's labels from the registry
+	Labels map[string]string
+	// Depth counts the items in each status
+	Depth Depth
+	// PayloadBytes is the total size of the payloads, a lower bound of the space
+	// the queue takes in the database file
+	PayloadBytes int64
+	// Options are the settings the queue was opened with by this manager, or nil
+	// when it isn't open here; other processes may use other settings
+	Options *QueueOptions
+}
+
+// QueueOptions are the settings of an open queue
+type QueueOptions struct {
+	Delivery          DeliveryGuarantee
+	RemoveOnComplete  bool
+	VisibilityTimeout time.Duration
+	// MaxLength is the WithMaxLength limit, 0 when unbounded
+	MaxLength int
+	// DeadLetterQueue and MaxAttempts are set with WithDeadLetterQueue
+	DeadLetterQueue string
+	MaxAttempts     int
+	// FairnessKey is the WithFairnessKey header, empty when dequeues don't take turns
+	FairnessKey string
+}
+
+// Exists reports whether the database has the queue name, or an alias routing to
+// one, without creating it
+func (m *Manager) Exists(name string) (exists bool, err error) {
+	defer func() { err = mapError(err) }()
+
+	if m.closed.Load() {
+		return false, ErrQueuesClosed
+	}
+
+	if name, err = m.resolve(name); err != nil {
+		return false, err
+	}
+
+	err = m.client.QueryRow(
+		fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM %s WHERE name = ? AND kind != ?)", quoteIdent(registryTable)), name, kindAlias,
+	).Scan(&exists)
+
+	return exists, err
+}
+
+// Describe returns the creation time, labels, item counts and payload size of the
+// queue name, or of the queue an alias routes to, and its options when this manager
+// has it open, so operators can check a queue before wiring consumers to it.
+// Returns ErrUnknownQueue when there is no such queue
+func (m *Manager) Describe(name string) (d QueueDescription, err error) {
+	defer func() { err = mapError(err) }()
+
+	if m.closed.Load() {
+		return d, ErrQueuesClosed
+	}
+
+	if d.Name, err = m.resolve(name); err != nil {
+		return d, err
+	}
+
+	var kind string
+	var labels sql.NullString
+	err = m.client.QueryRow(
+		fmt.Sprintf("SELECT kind, created_at, labels FROM %s WHERE name = ? AND kind != ?", quoteIdent(registryTable)), d.Name, kindAlias,
+	).Scan(&kind, &d.CreatedAt, &labels)
+	if errors.Is(err, sql.ErrNoRows) {
+		return d, fmt.Errorf("%w %q", ErrUnknownQueue, name)
+	}
+	if err != nil {
+		return d, err
+	}
+
+	d.Priority = kind == kindPriorityQueue
+
+	if d.Labels, err = decodeLabels(labels); err != nil {
+		return d, err
+	}
+
+	if d.Depth, err = countByStatus(m.client, d.Name); err != nil {
+		return d, err
+	}
+
+	err = m.client.QueryRow(fmt.Sprintf("SELECT COALESCE(SUM(LENGTH(data)), 0) FROM %s", quoteIdent(d.Name))).Scan(&d.PayloadBytes)
+	if err != nil {
+		return d, err
+	}
+
+	m.mu.Lock()
+	for _, q := range m.open {
+		if q.tableName == d.Name && !q.closed.Load() {
+			d.Options = &QueueOptions{
+				Delivery:          q.delivery,
+				RemoveOnComplete:  q.removeOnComplete,
+				VisibilityTimeout: q.visibilityTimeout,
+				MaxLength:         q.maxLength,
+				DeadLetterQueue:   q.deadLetterName,
+				MaxAttempts:       q.maxAttempts,
+				FairnessKey:       q.fairnessKey,
+			}
+			break
+		}
+	}
+	m.mu.Unlock()
+
+	return d, nil
+}
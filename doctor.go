@@ -0,0 +1,361 @@
+package sqliteq
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Defaults for DoctorOptions
+const (
+	defaultDoctorStaleAfter = time.Hour
+	defaultDoctorMaxWALSize = 64 << 20
+)
+
+// DoctorOptions configures Manager.Doctor
+type DoctorOptions struct {
+	// Fix repairs the problems that can be repaired safely
+	Fix bool
+	// StaleAfter is how long an item may stay in processing before it is reported
+	// as orphaned, an hour when zero. On a live database, items of queues other
+	// processes consume with longer visibility timeouts are still being worked on,
+	// and Fix hands them out a second time; set it above the longest timeout.
+	StaleAfter time.Duration
+	// MaxWALSize is the WAL size in bytes above which a checkpoint is recommended,
+	// 64 MiB when zero
+	MaxWALSize int64
+}
+
+// Finding is a problem found by Manager.Doctor
+type Finding struct {
+	// Check is the check that found the problem: format, index, orphans, registry or wal
+	Check string
+	// Problem describes the problem
+	Problem string
+	// Fixed is whether the problem was repaired
+	Fixed bool
+}
+
+// Doctor checks the database for problems, combining several maintenance passes
+// into one health check: the on-disk format, missing indexes, items left in
+// processing for longer than opts.StaleAfter, queue tables missing from the
+// registry or registry entries without a table, and the WAL size. With opts.Fix it
+// repairs what it can: it recreates indexes, returns orphaned items to pending,
+// reconciles the registry and checkpoints the WAL. Format violations are only
+// reported, and so are the orphans of at-most-once queues.
+func (m *Manager) Doctor(ctx context.Context, opts DoctorOptions) (findings []Finding, err error) {
+	defer func() { err = mapError(err) }()
+
+	if m.closed.Load() {
+		return nil, ErrQueuesClosed
+	}
+
+	if opts.StaleAfter <= 0 {
+		opts.StaleAfter = defaultDoctorStaleAfter
+	}
+	if opts.MaxWALSize <= 0 {
+		opts.MaxWALSize = defaultDoctorMaxWALSize
+	}
+
+	checks := []func(opts DoctorOptions) ([]Finding, error){
+		m.checkRegistry,
+		// Recreating indexes also adds missing columns, so it runs before the format check
+		m.checkIndexes,
+		m.checkFormat,
+		m.checkOrphans,
+		m.checkWAL,
+	}
+
+	for _, check := range checks {
+		if err := ctx.Err(); err != nil {
+			return findings, err
+		}
+
+		found, err := check(opts)
+		if err != nil {
+			return findings, err
+		}
+		findings = append(findings, found...)
+	}
+
+	return findings, nil
+}
+
+// registeredQueues returns the names and kinds of the queues in the registry that
+// have a table
+func (m *Manager) registeredQueues() (names []string, kinds map[string]string, err error) {
+	rows, err := m.client.Query(fmt.Sprintf(`
+	SELECT r.name, r.kind FROM %s r JOIN sqlite_master t ON t.type = 'table' AND t.name = r.name
+	WHERE r.kind != ? ORDER BY r.name
+	`, quoteIdent(registryTable)), kindAlias)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	kinds = make(map[string]string)
+	for rows.Next() {
+		var name, kind string
+		if err := rows.Scan(&name, &kind); err != nil {
+			return nil, nil, err
+		}
+		names = append(names, name)
+		kinds[name] = kind
+	}
+
+	return names, kinds, rows.Err()
+}
+
+// checkRegistry compares the registry with the tables of the database
+func (m *Manager) checkRegistry(opts DoctorOptions) (findings []Finding, err error) {
+	// Registry entries whose table or alias target is gone
+	rows, err := m.client.Query(fmt.Sprintf(`
+	SELECT r.name, r.kind FROM %s r
+	WHERE NOT EXISTS (SELECT 1 FROM sqlite_master t WHERE t.type = 'table' AND t.name = COALESCE(r.target, r.name))
+	ORDER BY r.name
+	`, quoteIdent(registryTable)))
+	if err != nil {
+		return nil, err
+	}
+
+	var dangling []string
+	for rows.Next() {
+		var name, kind string
+		if err := rows.Scan(&name, &kind); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		dangling = append(dangling, name)
+		findings = append(findings, Finding{Check: "registry", Problem: fmt.Sprintf("%s %s has no table", kind, name)})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Tables shaped like queues that the registry doesn't list
+	rows, err = m.client.Query(fmt.Sprintf(`
+	SELECT t.name, EXISTS (SELECT 1 FROM pragma_table_info(t.name) WHERE name = 'priority') FROM sqlite_master t
+	WHERE t.type = 'table' AND t.name NOT LIKE 'sqliteq\_%%' ESCAPE '\' AND t.name NOT LIKE 'sqlite\_%%' ESCAPE '\'
+		AND t.name NOT LIKE '%%\_trash' ESCAPE '\'
+		AND (SELECT COUNT(*) FROM pragma_table_info(t.name) WHERE name IN ('data', 'status', 'ack_id', 'ack', 'created_at', 'updated_at')) = 6
+		AND NOT EXISTS (SELECT 1 FROM %s r WHERE r.name = t.name)
+	ORDER BY t.name
+	`, quoteIdent(registryTable)))
+	if err != nil {
+		return nil, err
+	}
+
+	type table struct {
+		name     string
+		priority bool
+	}
+	var unregistered []table
+	for rows.Next() {
+		var t table
+		if err := rows.Scan(&t.name, &t.priority); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		unregistered = append(unregistered, t)
+		findings = append(findings, Finding{Check: "registry", Problem: fmt.Sprintf("table %s is not registered", t.name)})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if !opts.Fix {
+		return findings, nil
+	}
+
+	for _, name := range dangling {
+		if _, err := m.client.Exec(fmt.Sprintf("DELETE FROM %s WHERE name = ?", quoteIdent(registryTable)), name); err != nil {
+			return findings, err
+		}
+	}
+
+	for _, t := range unregistered {
+		kind := kindQueue
+		if t.priority {
+			kind = kindPriorityQueue
+		}
+
+		_, err := m.client.Exec(
+			fmt.Sprintf("INSERT OR IGNORE INTO %s (name, kind, created_at) VALUES (?, ?, ?)", quoteIdent(registryTable)),
+			t.name, kind, time.Now().UTC(),
+		)
+		if err != nil {
+			return findings, err
+		}
+	}
+
+	for i := range findings {
+		findings[i].Fixed = true
+	}
+
+	return findings, nil
+}
+
+// checkFormat reports the violations of the on-disk format, see VerifyFormat
+func (m *Manager) checkFormat(opts DoctorOptions) ([]Finding, error) {
+	violations, err := verifyFormat(m.client)
+	if err != nil {
+		return nil, err
+	}
+
+	findings := make([]Finding, len(violations))
+	for i, v := range violations {
+		findings[i] = Finding{Check: "format", Problem: v}
+	}
+
+	return findings, nil
+}
+
+// queueIndexes returns the suffixes of the indexes every queue table should have
+func queueIndexes(kind string) []string {
+	indexes := []string{"_status_idx", "_status_ack_idx", "_ack_id_idx", "_processing_idx", "_pending_idx", "_expires_idx", "_message_id_idx", "_correlation_idx"}
+	if kind == kindPriorityQueue {
+		indexes = append(indexes, "_priority_seq_idx")
+	}
+
+	return indexes
+}
+
+// checkIndexes reports queue tables missing indexes, recreating them with Fix
+func (m *Manager) checkIndexes(opts DoctorOptions) (findings []Finding, err error) {
+	names, kinds, err := m.registeredQueues()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range names {
+		var missing []string
+		for _, suffix := range queueIndexes(kinds[name]) {
+			var exists bool
+			err := m.client.QueryRow("SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'index' AND name = ?)", name+suffix).Scan(&exists)
+			if err != nil {
+				return nil, err
+			}
+			if !exists {
+				missing = append(missing, name+suffix)
+			}
+		}
+
+		if len(missing) == 0 {
+			continue
+		}
+
+		finding := Finding{Check: "index", Problem: fmt.Sprintf("%s is missing %s", name, strings.Join(missing, ", "))}
+
+		if opts.Fix {
+			// Initializing the table creates what is missing without touching its items
+			q := &Queue{manager: m, client: m.client, tableName: name, priority: kinds[name] == kindPriorityQueue}
+			if err := q.initTable(); err != nil {
+				return append(findings, finding), err
+			}
+			finding.Fixed = true
+		}
+
+		findings = append(findings, finding)
+	}
+
+	return findings, nil
+}
+
+// checkOrphans reports items left in processing for longer than opts.StaleAfter,
+// returning them to pending with Fix the way the visibility timeout reaper does.
+// Queues this manager has open are requeued with their options: an orphan must
+// also be past their visibility timeouts, and at-most-once queues are only
+// reported since their items must not be delivered twice. Other queues are
+// requeued as at-least-once queues with the default options.
+func (m *Manager) checkOrphans(opts DoctorOptions) (findings []Finding, err error) {
+	names, kinds, err := m.registeredQueues()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range names {
+		q := m.openQueue(name)
+		if q == nil {
+			q = &Queue{
+				manager:          m,
+				client:           m.client,
+				tableName:        name,
+				priority:         kinds[name] == kindPriorityQueue,
+				janitorBatchSize: defaultJanitorBatchSize,
+				janitorPause:     defaultJanitorPause,
+			}
+		}
+
+		staleAfter := opts.StaleAfter
+		if timeout := q.longestVisibilityTimeout(); timeout > staleAfter {
+			staleAfter = timeout
+		}
+		cutoff := time.Now().UTC().Add(-staleAfter)
+
+		var count int64
+		err := m.client.QueryRow(
+			fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE status = 'processing' AND ack = 0 AND updated_at < ?", quoteIdent(name)), cutoff,
+		).Scan(&count)
+		if err != nil {
+			return nil, err
+		}
+
+		if count == 0 {
+			continue
+		}
+
+		finding := Finding{Check: "orphans", Problem: fmt.Sprintf("%s has %d items in processing for more than %s", name, count, staleAfter)}
+
+		if q.delivery == AtMostOnce {
+			finding.Problem += ", left alone as it delivers at most once"
+		} else if opts.Fix {
+			reclaimed, err := q.reclaimStale(cutoff)
+			if err != nil {
+				return append(findings, finding), err
+			}
+			// Pinned items stay in processing
+			finding.Fixed = reclaimed == count
+		}
+
+		findings = append(findings, finding)
+	}
+
+	return findings, nil
+}
+
+// openQueue returns a queue of the table name this manager has open, nil if none
+func (m *Manager) openQueue(name string) *Queue {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, q := range m.open {
+		if q.tableName == name && !q.closed.Load() {
+			return q
+		}
+	}
+
+	return nil
+}
+
+// checkWAL reports a WAL larger than opts.MaxWALSize, checkpointing it with Fix
+func (m *Manager) checkWAL(opts DoctorOptions) ([]Finding, error) {
+	info, err := os.Stat(m.path + "-wal")
+	if err != nil || info.Size() <= opts.MaxWALSize {
+		return nil, nil
+	}
+
+	finding := Finding{Check: "wal", Problem: fmt.Sprintf("WAL is %d bytes, above %d", info.Size(), opts.MaxWALSize)}
+
+	if opts.Fix {
+		if _, err := m.client.Exec("PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+			return []Finding{finding}, err
+		}
+		finding.Fixed = true
+	}
+
+	return []Finding{finding}, nil
+}
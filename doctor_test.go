@@ -0,0 +1,136 @@
+package sqliteq
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestDoctor(t *testing.T) {
+	dbPath := "test_doctor.db"
+	defer os.Remove(dbPath)
+
+	m, err := NewManager(dbPath)
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	defer m.Close()
+
+	q, err := m.NewQueue("jobs")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	q.Enqueue("item")
+	q.DequeueWithAckId()
+
+	// Damage the database in every way the doctor checks
+	for _, stmt := range []string{
+		`DROP INDEX "jobs_pending_idx"`,
+		"UPDATE jobs SET updated_at = datetime('now', '-2 hours')",
+		"INSERT INTO sqliteq_queues (name, kind, created_at) VALUES ('gone', 'queue', '2024-01-01')",
+		"CREATE TABLE orphan (id INTEGER PRIMARY KEY, data BLOB, status TEXT, ack_id TEXT, ack BOOLEAN, created_at TIMESTAMP, updated_at TIMESTAMP)",
+	} {
+		if _, err := m.client.Exec(stmt); err != nil {
+			t.Fatalf("Failed to run %s: %v", stmt, err)
+		}
+	}
+
+	findings, err := m.Doctor(context.Background(), DoctorOptions{})
+	if err != nil {
+		t.Fatalf("Doctor failed: %v", err)
+	}
+
+	checks := make(map[string]int)
+	for _, f := range findings {
+		checks[f.Check]++
+		if f.Fixed {
+			t.Errorf("Expected nothing fixed without Fix, got %+v", f)
+		}
+	}
+
+	for check, want := range map[string]int{"registry": 2, "index": 1, "orphans": 1} {
+		if checks[check] != want {
+			t.Errorf("Expected %d %s findings, got %d in %+v", want, check, checks[check], findings)
+		}
+	}
+
+	findings, err = m.Doctor(context.Background(), DoctorOptions{Fix: true, StaleAfter: time.Hour})
+	if err != nil {
+		t.Fatalf("Doctor failed: %v", err)
+	}
+
+	for _, f := range findings {
+		if !f.Fixed {
+			t.Errorf("Expected %+v to be fixed", f)
+		}
+	}
+
+	if q.Len() != 1 {
+		t.Errorf("Expected the orphaned item back in pending, got %d", q.Len())
+	}
+
+	if findings, _ = m.Doctor(context.Background(), DoctorOptions{}); len(findings) != 0 {
+		t.Errorf("Expected no findings after fixing, got %+v", findings)
+	}
+}
+
+func TestDoctorOrphans(t *testing.T) {
+	dbPath := "test_doctor_orphans.db"
+	defer os.Remove(dbPath)
+
+	m, err := NewManager(dbPath)
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	defer m.Close()
+
+	var requeued int64
+	hooked, err := m.NewQueue("hooked", WithHooks(Hooks{OnRequeue: func(count int64) { requeued += count }}))
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	once, err := m.NewQueue("once", WithDeliveryGuarantee(AtMostOnce))
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	slow, err := m.NewQueue("slow", WithVisibilityTimeout(3*time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	for _, q := range []*Queue{hooked, once, slow} {
+		q.Enqueue("item")
+		q.DequeueWithAckId()
+
+		if _, err := m.client.Exec(fmt.Sprintf("UPDATE %s SET updated_at = datetime('now', '-2 hours')", q.tableName)); err != nil {
+			t.Fatalf("Failed to age the item: %v", err)
+		}
+	}
+
+	findings, err := m.Doctor(context.Background(), DoctorOptions{Fix: true, StaleAfter: time.Hour})
+	if err != nil {
+		t.Fatalf("Doctor failed: %v", err)
+	}
+
+	if len(findings) != 2 {
+		t.Fatalf("Expected orphans in hooked and once only, got %+v", findings)
+	}
+
+	// Orphans go through the queue's requeue path
+	if hooked.Len() != 1 || requeued != 1 {
+		t.Errorf("Expected the orphan requeued and reported to the hook, got length %d and %d requeued", hooked.Len(), requeued)
+	}
+
+	// Items of at-most-once queues are never delivered twice
+	if findings[1].Fixed || once.Len() != 0 {
+		t.Errorf("Expected the at-most-once orphan to be left alone, got %+v and length %d", findings[1], once.Len())
+	}
+
+	// Items within their visibility timeout aren't orphans
+	if slow.Len() != 0 {
+		t.Errorf("Expected the item within its visibility timeout to stay in processing, got length %d", slow.Len())
+	}
+}
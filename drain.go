@@ -0,0 +1,246 @@
+package sqliteq
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Handler processes a dequeued message
+type Handler func(m Message) error
+
+// Drain processes the items pending when it is called and returns once none are left,
+// without waiting for new ones. Items enqueued while draining are left for later.
+// Each item is acknowledged when handler succeeds; when it fails, the item is returned
+// to pending, or marked failed for AtMostOnce queues, and Drain stops with the
+// handler's error. With WithCircuitBreaker, Drain returns ErrCircuitOpen instead
+// of claiming items while the breaker is open.
+// Returns the number of items processed successfully
+func (q *Queue) Drain(ctx context.Context, handler Handler) (processed int, err error) {
+	if q.closed.Load() {
+		return 0, ErrClosed
+	}
+
+	var last int64
+	err = q.client.QueryRowContext(ctx, fmt.Sprintf("SELECT COALESCE(MAX(id), 0) FROM %s", quoteIdent(q.tableName))).Scan(&last)
+	if err != nil {
+		return 0, mapError(err)
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return processed, err
+		}
+
+		if q.breaker != nil && !q.breaker.allow(time.Now()) {
+			return processed, ErrCircuitOpen
+		}
+
+		m, err := q.dequeueInternal(true, "id <= ?", last)
+		if err != nil && q.breaker != nil {
+			q.breaker.cancel()
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			return processed, nil
+		}
+		if err != nil {
+			return processed, err
+		}
+
+		err = handler(m)
+		if q.breaker != nil {
+			q.breaker.record(err != nil, time.Now())
+		}
+
+		if err != nil {
+			if releaseErr := q.release(m.AckID, err); releaseErr != nil {
+				return processed, errors.Join(err, releaseErr)
+			}
+
+			return processed, err
+		}
+
+		if err := q.acknowledge(m.AckID); err != nil {
+			return processed, err
+		}
+
+		processed++
+	}
+}
+
+// Nack hands back an item that couldn't be processed: it returns to pending right
+// away, or after the delay of WithRetryPolicy, instead of waiting for its visibility
+// timeout or a restart. For AtMostOnce queues the item is marked failed instead.
+// An ack ID no item in processing holds is reported as ErrUnknownAckID, or as
+// ErrStaleClaim with WithAckTokens.
+func (q *Queue) Nack(ackID string) error {
+	err := q.retryWrite(func() error { return q.release(ackID, errNacked) })
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w %q: %w", ErrUnknownAckID, ackID, err)
+	}
+
+	return err
+}
+
+// errNacked is the fail reason of at-most-once items handed back with Nack
+var errNacked = errors.New("negatively acknowledged")
+
+// release returns the processing item holding ackID to pending after it failed
+// with cause, rescheduled by the retry policy if there is one, or marks it failed
+// for at-most-once queues
+// Returns sql.ErrNoRows when no item in processing holds the ack ID
+func (q *Queue) release(ackID string, cause error) (err error) {
+	defer func() { err = mapError(err) }()
+	defer q.invalidateStats()
+	defer q.invalidateFront()
+	defer q.observeWrite(time.Now(), &err)
+
+	if q.closed.Load() {
+		return ErrClosed
+	}
+
+	if err = q.verifyAckID(ackID); err != nil {
+		return err
+	}
+
+	if err = q.checkEpoch(q.client, ackID); err != nil {
+		return err
+	}
+
+	if err = q.manager.awaitLease(); err != nil {
+		return err
+	}
+
+	var released int64
+
+	switch {
+	case q.delivery == AtMostOnce:
+		released, err = rowsAffected(q.client.Exec(
+			fmt.Sprintf("UPDATE %s SET status = 'failed', fail_reason = ?, updated_at = ? WHERE ack_id = ? AND status = 'processing'",
+				quoteIdent(q.tableName)),
+			cause.Error(), q.now(), ackID,
+		))
+	case q.retryBackoff != nil:
+		released, err = q.retryLater(ackID, cause)
+	default:
+		released, err = rowsAffected(q.requeue("status = 'pending', updated_at = ?", "ack_id = ? AND status = 'processing'", 1, q.now(), ackID))
+	}
+	if err != nil {
+		return err
+	}
+
+	if released == 0 {
+		return q.noClaim(ackID)
+	}
+
+	_, err = q.moveDeadLetters()
+	return err
+}
+
+// NackRequest is an item handed back with NackBatch
+type NackRequest struct {
+	AckID string
+	// Delay is how long the item waits before it can be dequeued again, zero for
+	// right away
+	Delay time.Duration
+}
+
+// NackBatch hands back several items in one transaction, each after its own delay,
+// e.g. when a downstream bulk call covering all of them failed. Either every item
+// is handed back or none is. The delays replace those of WithRetryPolicy, whose
+// retry limit doesn't apply; WithDeadLetterQueue's does, as for Nack. For
+// AtMostOnce queues the items are marked failed instead.
+// Returns the number of items handed back: ack IDs no item in processing holds are
+// skipped, while tokens of WithAckTokens issued to another consumer fail the batch
+// with ErrStaleClaim.
+func (q *Queue) NackBatch(items []NackRequest) (int, error) {
+	var released int
+
+	err := q.retryWrite(func() (err error) {
+		released, err = q.releaseMany(items)
+		return err
+	})
+
+	return released, err
+}
+
+// releaseMany returns the processing items of requests to pending after their
+// delays in a single transaction, or marks them failed for at-most-once queues
+func (q *Queue) releaseMany(requests []NackRequest) (released int, err error) {
+	defer func() { err = mapError(err) }()
+	defer q.invalidateStats()
+	defer q.invalidateFront()
+	defer q.observeWrite(time.Now(), &err)
+
+	if q.closed.Load() {
+		return 0, ErrClosed
+	}
+
+	if len(requests) == 0 {
+		return 0, nil
+	}
+
+	for _, r := range requests {
+		if err = q.verifyAckID(r.AckID); err != nil {
+			return 0, err
+		}
+	}
+
+	if err = q.manager.awaitLease(); err != nil {
+		return 0, err
+	}
+
+	tx, err := q.client.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	now := q.now()
+
+	for _, r := range requests {
+		if err = q.checkEpoch(tx, r.AckID); err != nil {
+			return 0, err
+		}
+
+		var n int64
+
+		switch {
+		case q.delivery == AtMostOnce:
+			n, err = rowsAffected(tx.Exec(
+				fmt.Sprintf("UPDATE %s SET status = 'failed', fail_reason = ?, updated_at = ? WHERE ack_id = ? AND status = 'processing'",
+					quoteIdent(q.tableName)),
+				errNacked.Error(), now, r.AckID,
+			))
+		case r.Delay > 0:
+			n, err = rowsAffected(q.requeueTx(tx, "status = 'pending', not_before = ?, updated_at = ?", "ack_id = ? AND status = 'processing'", 1,
+				now.Add(r.Delay), now, r.AckID))
+		default:
+			n, err = rowsAffected(q.requeueTx(tx, "status = 'pending', updated_at = ?", "ack_id = ? AND status = 'processing'", 1, now, r.AckID))
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		released += int(n)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	// At-most-once items handed back are marked failed instead
+	if q.delivery != AtMostOnce {
+		q.notifyRequeued(int64(released))
+	}
+
+	_, err = q.moveDeadLetters()
+	return released, err
+}
@@ -0,0 +1,246 @@
+package sqliteq
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestDrain(t *testing.T) {
+	dbPath := "test_drain.db"
+	defer os.Remove(dbPath)
+
+	queues := New(dbPath)
+	defer queues.Close()
+
+	t.Run("ProcessesPendingItems", func(t *testing.T) {
+		q, err := queues.NewQueue("drain_all")
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+
+		for _, item := range []string{"a", "b", "c"} {
+			q.Enqueue(item)
+		}
+
+		var seen []string
+		processed, err := q.Drain(context.Background(), func(m Message) error {
+			seen = append(seen, string(m.Data))
+			// Items enqueued while draining are left for later
+			q.Enqueue("late")
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Drain failed: %v", err)
+		}
+
+		if processed != 3 || len(seen) != 3 || seen[0] != "a" || seen[2] != "c" {
+			t.Errorf("Expected a, b, c to be processed, got %d: %v", processed, seen)
+		}
+
+		if q.Len() != 3 {
+			t.Errorf("Expected 3 late items to remain, got %d", q.Len())
+		}
+	})
+
+	t.Run("StopsOnHandlerError", func(t *testing.T) {
+		q, err := queues.NewQueue("drain_error")
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+
+		q.Enqueue("ok")
+		q.Enqueue("bad")
+		q.Enqueue("never")
+
+		errBad := errors.New("bad item")
+		processed, err := q.Drain(context.Background(), func(m Message) error {
+			if string(m.Data) == "bad" {
+				return errBad
+			}
+			return nil
+		})
+		if !errors.Is(err, errBad) {
+			t.Errorf("Expected handler error, got %v", err)
+		}
+
+		if processed != 1 {
+			t.Errorf("Expected 1 processed item, got %d", processed)
+		}
+
+		if q.Len() != 2 {
+			t.Errorf("Expected the failed item to be pending again, got length %d", q.Len())
+		}
+	})
+
+	t.Run("HonorsContext", func(t *testing.T) {
+		q, err := queues.NewQueue("drain_context")
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+
+		q.Enqueue("item")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		if _, err := q.Drain(ctx, func(Message) error { return nil }); !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+
+		if q.Len() != 1 {
+			t.Errorf("Expected item to remain, got length %d", q.Len())
+		}
+	})
+}
+
+func TestNack(t *testing.T) {
+	dbPath := "test_nack.db"
+	defer os.Remove(dbPath)
+
+	queues := New(dbPath)
+	defer queues.Close()
+
+	q, err := queues.NewQueue("test_queue")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	q.Enqueue("first")
+	q.Enqueue("second")
+
+	m, err := q.DequeueMessageWithAckId()
+	if err != nil {
+		t.Fatalf("DequeueMessageWithAckId failed: %v", err)
+	}
+
+	if err := q.Nack(m.AckID); err != nil {
+		t.Fatalf("Nack failed: %v", err)
+	}
+
+	// The item keeps its place ahead of later items
+	again, err := q.DequeueMessageWithAckId()
+	if err != nil {
+		t.Fatalf("DequeueMessageWithAckId failed: %v", err)
+	}
+
+	if m.Attempts != 1 || string(again.Data) != "first" || again.Attempts != 2 {
+		t.Errorf("Expected 'first' on its second attempt, got %s with %d", again.Data, again.Attempts)
+	}
+
+	if err := q.Ack(again.AckID); err != nil {
+		t.Fatalf("Ack failed: %v", err)
+	}
+
+	if err := q.Nack(again.AckID); !errors.Is(err, ErrUnknownAckID) {
+		t.Errorf("Expected ErrUnknownAckID, got %v", err)
+	}
+}
+
+func TestNackBatch(t *testing.T) {
+	dbPath := "test_nack_batch.db"
+	defer os.Remove(dbPath)
+
+	queues := New(dbPath)
+	defer queues.Close()
+
+	q, err := queues.NewQueue("test_queue")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	q.Enqueue("now")
+	q.Enqueue("later")
+
+	_, ackIDs := q.DequeueNWithAckIds(2)
+	if len(ackIDs) != 2 {
+		t.Fatalf("Expected 2 items, got %d", len(ackIDs))
+	}
+
+	released, err := q.NackBatch([]NackRequest{
+		{AckID: ackIDs[0]},
+		{AckID: ackIDs[1], Delay: time.Hour},
+		{AckID: "unknown"},
+	})
+	if err != nil {
+		t.Fatalf("NackBatch failed: %v", err)
+	}
+
+	if released != 2 {
+		t.Errorf("Expected 2 items handed back, got %d", released)
+	}
+
+	// Only the item without a delay is ready again
+	m, err := q.DequeueMessageWithAckId()
+	if err != nil {
+		t.Fatalf("DequeueMessageWithAckId failed: %v", err)
+	}
+
+	if string(m.Data) != "now" || m.Attempts != 2 {
+		t.Errorf("Expected 'now' on its second attempt, got %s with %d", m.Data, m.Attempts)
+	}
+
+	if _, err := q.DequeueMessageWithAckId(); !errors.Is(err, ErrEmpty) {
+		t.Errorf("Expected the delayed item to wait, got %v", err)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	dbPath := "test_circuit_breaker.db"
+	defer os.Remove(dbPath)
+
+	queues := New(dbPath)
+	defer queues.Close()
+
+	q, err := queues.NewQueue("test_queue", WithCircuitBreaker(0.5, 50*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	for i := 0; i < 10; i++ {
+		q.Enqueue(i)
+	}
+
+	outage := errors.New("downstream unavailable")
+	failing := func(m Message) error { return outage }
+
+	// Drain stops at the first failure, so every call records one failed execution
+	for i := 0; i < breakerMinSamples; i++ {
+		if _, err := q.Drain(context.Background(), failing); !errors.Is(err, outage) {
+			t.Fatalf("Expected the handler error, got %v", err)
+		}
+	}
+
+	t.Run("Open", func(t *testing.T) {
+		if _, err := q.Drain(context.Background(), failing); !errors.Is(err, ErrCircuitOpen) {
+			t.Errorf("Expected ErrCircuitOpen, got %v", err)
+		}
+
+		if q.Len() != 10 {
+			t.Errorf("Expected no item claimed while open, got %d pending", q.Len())
+		}
+	})
+
+	t.Run("FailedProbe", func(t *testing.T) {
+		time.Sleep(60 * time.Millisecond)
+
+		if _, err := q.Drain(context.Background(), failing); !errors.Is(err, outage) {
+			t.Errorf("Expected the probe to run the handler, got %v", err)
+		}
+
+		if _, err := q.Drain(context.Background(), failing); !errors.Is(err, ErrCircuitOpen) {
+			t.Errorf("Expected ErrCircuitOpen after a failed probe, got %v", err)
+		}
+	})
+
+	t.Run("SuccessfulProbe", func(t *testing.T) {
+		time.Sleep(60 * time.Millisecond)
+
+		processed, err := q.Drain(context.Background(), func(m Message) error { return nil })
+		if err != nil || processed != 10 {
+			t.Errorf("Expected all 10 items processed once closed, got %d, %v", processed, err)
+		}
+	})
+}
@@ -0,0 +1,109 @@
+package sqliteq
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// epochsTable holds the epochs of the queues that started a new one with BumpEpoch
+const epochsTable = "sqliteq_epochs"
+
+// BumpEpoch starts a new epoch of the queue, fencing the work in flight: items
+// claimed under an earlier epoch can no longer be acknowledged, handed back or
+// pinned with the ack IDs they were claimed with, which fail with ErrEpochFenced.
+// They return to pending right away for consumers of the new epoch, or are marked
+// failed for AtMostOnce queues. Use it after deploying a consumer change that is
+// incompatible with the work in progress. The epoch is shared by every process
+// using the database.
+// Returns the new epoch
+func (q *Queue) BumpEpoch() (epoch int64, err error) {
+	defer func() { err = mapError(err) }()
+	defer q.invalidateStats()
+	defer q.invalidateFront()
+	defer q.observeWrite(time.Now(), &err)
+
+	if q.closed.Load() {
+		return 0, ErrClosed
+	}
+
+	if err = q.manager.awaitLease(); err != nil {
+		return 0, err
+	}
+
+	tx, err := q.client.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	err = tx.QueryRow(fmt.Sprintf(
+		"INSERT INTO %s (queue, epoch) VALUES (?, 1) ON CONFLICT (queue) DO UPDATE SET epoch = epoch + 1 RETURNING epoch",
+		quoteIdent(epochsTable),
+	), q.tableName).Scan(&epoch)
+	if err != nil {
+		return 0, err
+	}
+
+	var requeued int64
+	if q.delivery == AtMostOnce {
+		_, err = tx.Exec(
+			fmt.Sprintf("UPDATE %s SET status = 'failed', fail_reason = ?, updated_at = ? WHERE status = 'processing' AND epoch < ?", quoteIdent(q.tableName)),
+			fmt.Sprintf("fenced by epoch %d", epoch), q.now(), epoch,
+		)
+	} else {
+		requeued, err = rowsAffected(q.requeueTx(tx, "status = 'pending', updated_at = ?", "status = 'processing' AND epoch < ?", -1, q.now(), epoch))
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	q.notifyRequeued(requeued)
+
+	return epoch, nil
+}
+
+// currentEpoch returns the queue's epoch, 0 until BumpEpoch was first called
+func (q *Queue) currentEpoch(db querier) (epoch int64, err error) {
+	err = db.QueryRow(
+		fmt.Sprintf("SELECT COALESCE((SELECT epoch FROM %s WHERE queue = ?), 0)", quoteIdent(epochsTable)), q.tableName,
+	).Scan(&epoch)
+
+	return epoch, err
+}
+
+// checkEpoch returns ErrEpochFenced when the item holding ackID was claimed under
+// an earlier epoch. Unknown ack IDs are left to the caller.
+func (q *Queue) checkEpoch(db querier, ackID string) error {
+	var fenced bool
+	err := db.QueryRow(fmt.Sprintf(
+		"SELECT epoch < COALESCE((SELECT epoch FROM %s WHERE queue = ?), 0) FROM %s WHERE ack_id = ?",
+		quoteIdent(epochsTable), quoteIdent(q.tableName),
+	), q.tableName, ackID).Scan(&fenced)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if fenced {
+		return q.fenced(ackID)
+	}
+
+	return nil
+}
+
+// fenced returns the error for an ack ID claimed under an earlier epoch
+func (q *Queue) fenced(ackID string) error {
+	return fmt.Errorf("%w: ack ID %q of %s", ErrEpochFenced, ackID, q.tableName)
+}
@@ -0,0 +1,65 @@
+package sqliteq
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestBumpEpoch(t *testing.T) {
+	dbPath := "test_bump_epoch.db"
+	defer os.Remove(dbPath)
+
+	queues := New(dbPath)
+	defer queues.Close()
+
+	q, err := queues.NewQueue("test_queue")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	q.Enqueue("first")
+	q.Enqueue("second")
+
+	old, err := q.DequeueMessageWithAckId()
+	if err != nil {
+		t.Fatalf("DequeueMessageWithAckId failed: %v", err)
+	}
+	other, err := q.DequeueMessageWithAckId()
+	if err != nil {
+		t.Fatalf("DequeueMessageWithAckId failed: %v", err)
+	}
+
+	epoch, err := q.BumpEpoch()
+	if err != nil {
+		t.Fatalf("BumpEpoch failed: %v", err)
+	}
+	if epoch != 1 {
+		t.Errorf("Expected epoch 1, got %d", epoch)
+	}
+
+	// The work in flight is fenced and returns to pending
+	if err := q.Ack(old.AckID); !errors.Is(err, ErrEpochFenced) {
+		t.Errorf("Expected ErrEpochFenced, got %v", err)
+	}
+	if err := q.Nack(other.AckID); !errors.Is(err, ErrEpochFenced) {
+		t.Errorf("Expected ErrEpochFenced, got %v", err)
+	}
+
+	if n := q.Len(); n != 2 {
+		t.Fatalf("Expected 2 pending items, got %d", n)
+	}
+
+	m, err := q.DequeueMessageWithAckId()
+	if err != nil {
+		t.Fatalf("DequeueMessageWithAckId failed: %v", err)
+	}
+
+	if string(m.Data) != "first" || m.AckID == old.AckID {
+		t.Errorf("Expected 'first' with a new ack ID, got %s with %q", m.Data, m.AckID)
+	}
+
+	if err := q.Ack(m.AckID); err != nil {
+		t.Errorf("Ack failed: %v", err)
+	}
+}
@@ -0,0 +1,122 @@
+package sqliteq
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+var (
+	// ErrClosed is returned by operations on a queue that has been closed
+	ErrClosed = errors.New("queue is closed")
+	// ErrQueuesClosed is returned when the Queues manager owning a queue has been closed
+	ErrQueuesClosed = errors.New("queues manager is closed")
+	// ErrEmpty is returned by DequeueMessage and its variants when no item is ready
+	ErrEmpty = errors.New("queue is empty")
+	// ErrUnknownAckID is returned by Ack when no item in processing holds the ack ID,
+	// e.g. because it was already acknowledged
+	ErrUnknownAckID = errors.New("unknown ack ID")
+	// ErrRejected wraps the error of an EnqueueInterceptor that rejected a message
+	ErrRejected = errors.New("message rejected")
+	// ErrAckUncertain is returned by Ack when the acknowledgment couldn't be committed
+	// because the database stayed busy; the item may be delivered again
+	ErrAckUncertain = errors.New("acknowledgment uncertain")
+	// ErrUnauthorized wraps the error of an Authorizer that denied an operation
+	ErrUnauthorized = errors.New("operation not authorized")
+	// ErrQueueFull is returned when enqueuing to a queue at its WithMaxLength limit
+	// with the OverflowReject policy
+	ErrQueueFull = errors.New("queue is full")
+	// ErrDuplicateMessageID is returned when enqueuing an item with a message ID
+	// another item of the queue already has
+	ErrDuplicateMessageID = errors.New("duplicate message ID")
+	// ErrUnknownMessageID is returned by FindByMessageID when no item has the message ID
+	ErrUnknownMessageID = errors.New("unknown message ID")
+	// ErrNotPending is returned by UpdatePriority and UpdatePayload when no pending
+	// item has the ID, e.g. because it was dequeued in the meantime
+	ErrNotPending = errors.New("no pending item with this ID")
+	// ErrStaleClaim is returned for an ack token of WithAckTokens that was issued to
+	// another consumer, or whose item is no longer in processing under it
+	ErrStaleClaim = errors.New("stale claim")
+	// ErrEpochFenced is returned for an ack ID of an item claimed before the last
+	// BumpEpoch of its queue
+	ErrEpochFenced = errors.New("claimed under an earlier epoch")
+	// ErrUnknownQueue is returned by DeleteQueue and Describe when the database has
+	// no queue of that name
+	ErrUnknownQueue = errors.New("unknown queue")
+	// ErrKindMismatch is returned by NewQueue for a priority queue and by
+	// NewPriorityQueue for a plain queue; see ConvertToPriority and ConvertToPlain
+	ErrKindMismatch = errors.New("queue is of another kind")
+	// ErrUnknownLevel is returned by EnqueueLevel for a level that has no priority
+	ErrUnknownLevel = errors.New("unknown priority level")
+)
+
+// Errors reported by SQLite, matched with errors.Is against the errors returned by queues
+var (
+	// ErrBusy is returned when the database file is locked by another connection
+	ErrBusy = errors.New("database is busy")
+	// ErrLocked is returned when a table is locked by another statement on the same connection
+	ErrLocked = errors.New("database table is locked")
+	// ErrFull is returned when the disk or the database size limit is full
+	ErrFull = errors.New("database or disk is full")
+	// ErrIO is returned when the operating system reported an I/O error
+	ErrIO = errors.New("disk I/O error")
+	// ErrCorrupt is returned when the database file is malformed
+	ErrCorrupt = errors.New("database disk image is malformed")
+)
+
+// CorruptionError reports the problems found by the integrity check of a database file
+type CorruptionError struct {
+	Path     string
+	Problems []string
+}
+
+func (e *CorruptionError) Error() string {
+	return fmt.Sprintf("database %s is corrupted: %s", e.Path, strings.Join(e.Problems, "; "))
+}
+
+// Is makes a CorruptionError match ErrCorrupt
+func (e *CorruptionError) Is(target error) bool {
+	return target == ErrCorrupt
+}
+
+// sqliteErrors maps SQLite primary result codes to their package-level errors
+var sqliteErrors = map[sqlite3.ErrNo]error{
+	sqlite3.ErrBusy:    ErrBusy,
+	sqlite3.ErrLocked:  ErrLocked,
+	sqlite3.ErrFull:    ErrFull,
+	sqlite3.ErrIoErr:   ErrIO,
+	sqlite3.ErrCorrupt: ErrCorrupt,
+	sqlite3.ErrNotADB:  ErrCorrupt,
+}
+
+// mapError wraps SQLite errors so they match the package-level error for their result code
+// The driver error stays in the chain for callers that need the extended code
+func mapError(err error) error {
+	var sqliteErr sqlite3.Error
+	if err == nil || !errors.As(err, &sqliteErr) {
+		return err
+	}
+
+	if mapped, ok := sqliteErrors[sqliteErr.Code]; ok && !errors.Is(err, mapped) {
+		return fmt.Errorf("%w: %w", mapped, err)
+	}
+
+	return err
+}
+
+// IsRetriable reports whether err is a transient SQLite error, caused by lock
+// contention, after which the operation can be retried as is
+func IsRetriable(err error) bool {
+	if errors.Is(err, ErrBusy) || errors.Is(err, ErrLocked) {
+		return true
+	}
+
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked
+	}
+
+	return false
+}
@@ -0,0 +1,245 @@
+package sqliteq
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+func TestMapError(t *testing.T) {
+	tt := []struct {
+		code      sqlite3.ErrNo
+		want      error
+		retriable bool
+	}{
+		{sqlite3.ErrBusy, ErrBusy, true},
+		{sqlite3.ErrLocked, ErrLocked, true},
+		{sqlite3.ErrFull, ErrFull, false},
+		{sqlite3.ErrIoErr, ErrIO, false},
+		{sqlite3.ErrCorrupt, ErrCorrupt, false},
+		{sqlite3.ErrNotADB, ErrCorrupt, false},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.want.Error(), func(t *testing.T) {
+			err := mapError(fmt.Errorf("exec: %w", sqlite3.Error{Code: tc.code}))
+
+			if !errors.Is(err, tc.want) {
+				t.Errorf("Expected %v, got %v", tc.want, err)
+			}
+
+			var sqliteErr sqlite3.Error
+			if !errors.As(err, &sqliteErr) || sqliteErr.Code != tc.code {
+				t.Errorf("Expected the driver error to stay in the chain, got %v", err)
+			}
+
+			if IsRetriable(err) != tc.retriable {
+				t.Errorf("Expected IsRetriable %v, got %v", tc.retriable, !tc.retriable)
+			}
+		})
+	}
+
+	if mapError(nil) != nil {
+		t.Error("Expected nil to stay nil")
+	}
+
+	if err := mapError(sql.ErrNoRows); err != sql.ErrNoRows {
+		t.Errorf("Expected non-SQLite errors to pass through, got %v", err)
+	}
+
+	if IsRetriable(sql.ErrNoRows) {
+		t.Error("Expected sql.ErrNoRows not to be retriable")
+	}
+}
+
+func TestBusyErrorIsRetriable(t *testing.T) {
+	dbPath := "test_busy.db"
+	defer os.Remove(dbPath)
+
+	queues := New(dbPath + "?_busy_timeout=10")
+	q, err := queues.NewQueue("test_queue")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	defer queues.Close()
+
+	// Hold the write lock from another connection
+	other, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open second connection: %v", err)
+	}
+	defer other.Close()
+
+	tx, err := other.Begin()
+	if err != nil {
+		t.Fatalf("Failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(fmt.Sprintf("INSERT INTO %s (data, status) VALUES ('held', 'pending')", q.tableName)); err != nil {
+		t.Fatalf("Failed to take the write lock: %v", err)
+	}
+
+	err = q.enqueue([]byte("blocked"), 0)
+	if !errors.Is(err, ErrBusy) || !IsRetriable(err) {
+		t.Errorf("Expected a retriable ErrBusy, got %v", err)
+	}
+
+	stats, err := q.Stats()
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+
+	if stats.Contention.BusyErrors != 1 || stats.Contention.LockWait <= 0 {
+		t.Errorf("Expected 1 busy error with some lock wait, got %+v", stats.Contention)
+	}
+}
+
+func TestAckRetry(t *testing.T) {
+	dbPath := "test_ack_retry.db"
+	defer os.Remove(dbPath)
+
+	queues := New(dbPath + "?_busy_timeout=10")
+	q, err := queues.NewQueue("test_queue", WithWriteRetry(3, 20*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	defer queues.Close()
+
+	other, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open second connection: %v", err)
+	}
+	defer other.Close()
+
+	// holdLock takes the write lock from another connection
+	holdLock := func() *sql.Tx {
+		tx, err := other.Begin()
+		if err != nil {
+			t.Fatalf("Failed to begin transaction: %v", err)
+		}
+
+		if _, err := tx.Exec(fmt.Sprintf("UPDATE %s SET updated_at = updated_at", q.tableName)); err != nil {
+			t.Fatalf("Failed to take the write lock: %v", err)
+		}
+
+		return tx
+	}
+
+	q.Enqueue("a")
+	q.Enqueue("b")
+	_, _, first := q.DequeueWithAckId()
+	_, _, second := q.DequeueWithAckId()
+
+	t.Run("Uncertain", func(t *testing.T) {
+		tx := holdLock()
+		defer tx.Rollback()
+
+		if err := q.Ack(first); !errors.Is(err, ErrAckUncertain) || !errors.Is(err, ErrBusy) {
+			t.Errorf("Expected ErrAckUncertain wrapping ErrBusy, got %v", err)
+		}
+
+		if contention := q.Contention(); contention.BusyErrors != 3 || contention.Retries != 2 {
+			t.Errorf("Expected 3 busy attempts and 2 retries, got %+v", contention)
+		}
+	})
+
+	t.Run("RecoversWithinRetries", func(t *testing.T) {
+		tx := holdLock()
+		go func() {
+			time.Sleep(30 * time.Millisecond)
+			tx.Rollback()
+		}()
+
+		if err := q.Ack(second); err != nil {
+			t.Errorf("Expected the retried ack to succeed, got %v", err)
+		}
+	})
+}
+
+func TestErrorReturningVariants(t *testing.T) {
+	dbPath := "test_error_variants.db"
+	defer os.Remove(dbPath)
+
+	queues := New(dbPath)
+	defer queues.Close()
+
+	q, err := queues.NewQueue("test_queue")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	t.Run("Empty", func(t *testing.T) {
+		if _, err := q.DequeueMessage(); !errors.Is(err, ErrEmpty) {
+			t.Errorf("Expected ErrEmpty, got %v", err)
+		}
+
+		if _, err := q.DequeueMessageWithAckId(); !errors.Is(err, ErrEmpty) {
+			t.Errorf("Expected ErrEmpty, got %v", err)
+		}
+	})
+
+	t.Run("DequeueAndAck", func(t *testing.T) {
+		q.Enqueue("item")
+
+		m, err := q.DequeueMessageWithAckId()
+		if err != nil {
+			t.Fatalf("DequeueMessageWithAckId failed: %v", err)
+		}
+
+		if string(m.Data) != "item" || m.Status != StatusProcessing || m.AckID == "" {
+			t.Errorf("Expected the item in processing with an ack ID, got %+v", m)
+		}
+
+		if err := q.Ack(m.AckID); err != nil {
+			t.Errorf("Ack failed: %v", err)
+		}
+
+		if err := q.Ack(m.AckID); !errors.Is(err, ErrUnknownAckID) {
+			t.Errorf("Expected ErrUnknownAckID for a second ack, got %v", err)
+		}
+	})
+
+	t.Run("Busy", func(t *testing.T) {
+		busy := New(dbPath + "?_busy_timeout=10")
+		defer busy.Close()
+
+		bq, err := busy.NewQueue("test_queue")
+		if err != nil {
+			t.Fatalf("Failed to open queue: %v", err)
+		}
+		q.Enqueue("item")
+
+		other, err := sql.Open("sqlite3", dbPath)
+		if err != nil {
+			t.Fatalf("Failed to open second connection: %v", err)
+		}
+		defer other.Close()
+
+		tx, err := other.Begin()
+		if err != nil {
+			t.Fatalf("Failed to begin transaction: %v", err)
+		}
+		defer tx.Rollback()
+
+		if _, err := tx.Exec("UPDATE test_queue SET updated_at = updated_at"); err != nil {
+			t.Fatalf("Failed to take the write lock: %v", err)
+		}
+
+		if _, err := bq.DequeueMessage(); !errors.Is(err, ErrBusy) {
+			t.Errorf("Expected ErrBusy, got %v", err)
+		}
+	})
+
+	t.Run("Closed", func(t *testing.T) {
+		q.Close()
+		if _, err := q.DequeueMessage(); !errors.Is(err, ErrClosed) {
+			t.Errorf("Expected ErrClosed, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,188 @@
+// Command crossprocess shares a queue between processes through the database file:
+// producers and consumers only need the same path. With -role both it starts a
+// producer and the consumers as child processes of itself.
+//
+//	go run ./example/crossprocess -role both -jobs 50 -consumers 3
+//	go run ./example/crossprocess -role consumer & go run ./example/crossprocess -role producer
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+
+	"github.com/goptics/sqliteq"
+)
+
+// stop is the payload telling a consumer to exit, one per consumer
+const stop = "stop"
+
+// config holds the flags of the scenario
+type config struct {
+	db        string
+	role      string
+	jobs      int
+	consumers int
+}
+
+func main() {
+	var c config
+	flag.StringVar(&c.db, "db", "crossprocess.db", "path to the database file shared by the processes")
+	flag.StringVar(&c.role, "role", "both", "producer, consumer, or both to start them as child processes")
+	flag.IntVar(&c.jobs, "jobs", 50, "number of jobs the producer enqueues")
+	flag.IntVar(&c.consumers, "consumers", 3, "number of consumers the producer stops, and starts with -role both")
+	flag.Parse()
+
+	ctx := context.Background()
+	var err error
+	switch c.role {
+	case "producer":
+		err = produce(ctx, c, os.Stdout)
+	case "consumer":
+		_, err = consume(ctx, c, os.Stdout)
+	case "both":
+		err = spawn(c)
+	default:
+		err = fmt.Errorf("unknown role %q", c.role)
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// setup creates the queue before the processes start, so they don't race to
+// create its table
+func setup(c config) error {
+	queues, err := sqliteq.Open(c.db)
+	if err != nil {
+		return err
+	}
+	defer queues.Close()
+
+	_, err = queues.NewQueue("jobs")
+	return err
+}
+
+// produce enqueues the jobs, then one stop message per consumer
+func produce(ctx context.Context, c config, out io.Writer) error {
+	queues, err := sqliteq.Open(c.db)
+	if err != nil {
+		return err
+	}
+	defer queues.Close()
+
+	jobs, err := queues.NewQueue("jobs")
+	if err != nil {
+		return err
+	}
+
+	for i := 1; i <= c.jobs; i++ {
+		if err := jobs.EnqueueWait(ctx, "job "+strconv.Itoa(i)); err != nil {
+			return err
+		}
+	}
+	for i := 0; i < c.consumers; i++ {
+		if err := jobs.EnqueueWait(ctx, stop); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(out, "producer %d: enqueued %d jobs\n", os.Getpid(), c.jobs)
+	return nil
+}
+
+// consume processes jobs until it dequeues a stop message
+// Returns the number of jobs processed
+func consume(ctx context.Context, c config, out io.Writer) (int, error) {
+	queues, err := sqliteq.Open(c.db)
+	if err != nil {
+		return 0, err
+	}
+	defer queues.Close()
+
+	jobs, err := queues.NewQueue("jobs", sqliteq.WithWriteRetry(10, 10*time.Millisecond))
+	if err != nil {
+		return 0, err
+	}
+
+	processed := 0
+	for {
+		m, err := jobs.DequeueMessageWithAckId()
+		if errors.Is(err, sqliteq.ErrEmpty) {
+			// Nothing yet, the producer may not have started
+			select {
+			case <-ctx.Done():
+				return processed, ctx.Err()
+			case <-time.After(10 * time.Millisecond):
+			}
+			continue
+		}
+		if sqliteq.IsRetriable(err) {
+			// Another process holds the write lock, try again shortly
+			time.Sleep(10 * time.Millisecond)
+			continue
+		}
+		if err != nil {
+			return processed, err
+		}
+
+		// Opening a queue returns the items in processing to pending, as a previous
+		// run may have crashed, so a consumer starting late can take back an item we
+		// hold: it is delivered again and our ack is no longer known
+		if err := jobs.Ack(m.AckID); errors.Is(err, sqliteq.ErrUnknownAckID) {
+			continue
+		} else if err != nil {
+			return processed, err
+		}
+		if string(m.Data) == stop {
+			fmt.Fprintf(out, "consumer %d: processed %d jobs\n", os.Getpid(), processed)
+			return processed, nil
+		}
+		processed++
+	}
+}
+
+// spawn runs the consumers and a producer as child processes of this program
+func spawn(c config) error {
+	start := func(role string) (*exec.Cmd, error) {
+		cmd := exec.Command(os.Args[0], "-db", c.db, "-role", role,
+			"-jobs", strconv.Itoa(c.jobs), "-consumers", strconv.Itoa(c.consumers))
+		cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+
+		return cmd, cmd.Start()
+	}
+
+	if err := setup(c); err != nil {
+		return err
+	}
+
+	var cmds []*exec.Cmd
+	for i := 0; i < c.consumers; i++ {
+		cmd, err := start("consumer")
+		if err != nil {
+			return err
+		}
+		cmds = append(cmds, cmd)
+	}
+
+	producer, err := start("producer")
+	if err != nil {
+		return err
+	}
+	cmds = append(cmds, producer)
+
+	var errs []error
+	for _, cmd := range cmds {
+		errs = append(errs, cmd.Wait())
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestCrossProcess runs the consumers and the producer with managers of their own
+// on the same file, like separate processes would
+func TestCrossProcess(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	c := config{db: filepath.Join(t.TempDir(), "crossprocess.db"), jobs: 40, consumers: 3}
+
+	if err := setup(c); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+
+	type result struct {
+		processed int
+		err       error
+	}
+	results := make(chan result, c.consumers)
+	for i := 0; i < c.consumers; i++ {
+		go func() {
+			n, err := consume(ctx, c, io.Discard)
+			results <- result{n, err}
+		}()
+	}
+
+	if err := produce(ctx, c, io.Discard); err != nil {
+		t.Fatalf("produce failed: %v", err)
+	}
+
+	total := 0
+	for i := 0; i < c.consumers; i++ {
+		r := <-results
+		if r.err != nil {
+			t.Fatalf("consume failed: %v", r.err)
+		}
+		total += r.processed
+	}
+
+	if total != c.jobs {
+		t.Errorf("Expected %d jobs processed, got %d", c.jobs, total)
+	}
+}
@@ -0,0 +1,93 @@
+// Command dashboard serves an admin view of the queues of a database: a JSON
+// description of every queue at /queues and Prometheus metrics at /metrics.
+//
+//	go run ./example/dashboard -db app.db -addr :8080
+//	go run ./example/dashboard -seed 20 # with a few demo queues
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/goptics/sqliteq"
+)
+
+func main() {
+	db := flag.String("db", "dashboard.db", "path to the database file")
+	addr := flag.String("addr", "localhost:8080", "address to serve the dashboard on")
+	seed := flag.Int("seed", 0, "enqueue this many items into demo queues first")
+	flag.Parse()
+
+	queues, err := sqliteq.NewManager(*db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer queues.Close()
+
+	if *seed > 0 {
+		if err := seedQueues(queues, *seed); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	log.Printf("serving the dashboard on http://%s/queues", *addr)
+	log.Fatal(http.ListenAndServe(*addr, handler(queues)))
+}
+
+// seedQueues fills demo queues with n items each, leaving some of them in
+// processing so the depths differ
+func seedQueues(queues *sqliteq.Manager, n int) error {
+	for _, name := range []string{"emails", "reports"} {
+		q, err := queues.NewQueue(name, sqliteq.WithLabels(map[string]string{"team": "demo"}))
+		if err != nil {
+			return err
+		}
+
+		for i := 1; i <= n; i++ {
+			if !q.Enqueue(fmt.Sprintf("%s item %d", name, i)) {
+				return fmt.Errorf("failed to seed %s", name)
+			}
+		}
+
+		// Claim a third of the items and complete half of those
+		_, ackIDs := q.DequeueNWithAckIds(n / 3)
+		for _, ackID := range ackIDs[:len(ackIDs)/2] {
+			if err := q.Ack(ackID); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// handler serves the dashboard of queues
+func handler(queues *sqliteq.Manager) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", queues.MetricsHandler())
+	mux.HandleFunc("/queues", func(w http.ResponseWriter, r *http.Request) {
+		list, err := queues.List()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		descriptions := make([]sqliteq.QueueDescription, 0, len(list))
+		for _, info := range list {
+			d, err := queues.Describe(info.Name)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			descriptions = append(descriptions, d)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(descriptions)
+	})
+
+	return mux
+}
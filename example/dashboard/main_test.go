@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/goptics/sqliteq"
+)
+
+func TestDashboard(t *testing.T) {
+	queues, err := sqliteq.NewManager(filepath.Join(t.TempDir(), "dashboard.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer queues.Close()
+
+	if err := seedQueues(queues, 9); err != nil {
+		t.Fatalf("seedQueues failed: %v", err)
+	}
+
+	server := httptest.NewServer(handler(queues))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/queues")
+	if err != nil {
+		t.Fatalf("GET /queues failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var descriptions []sqliteq.QueueDescription
+	if err := json.NewDecoder(resp.Body).Decode(&descriptions); err != nil {
+		t.Fatalf("Failed to decode /queues: %v", err)
+	}
+
+	if len(descriptions) != 2 {
+		t.Fatalf("Expected 2 queues, got %d", len(descriptions))
+	}
+	for _, d := range descriptions {
+		if d.Depth.Pending != 6 || d.Depth.Processing != 2 {
+			t.Errorf("Expected 6 pending and 2 processing items in %s, got %+v", d.Name, d.Depth)
+		}
+		if d.Labels["team"] != "demo" {
+			t.Errorf("Expected the demo label on %s, got %v", d.Name, d.Labels)
+		}
+	}
+
+	resp, err = http.Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("GET /metrics failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var body strings.Builder
+	if _, err := io.Copy(&body, resp.Body); err != nil {
+		t.Fatalf("Failed to read /metrics: %v", err)
+	}
+	if !strings.Contains(body.String(), `queue="emails"`) {
+		t.Errorf("Expected metrics of the emails queue, got:\n%s", body.String())
+	}
+}
@@ -0,0 +1,100 @@
+// Command fanout publishes events to every queue subscribed to a topic, so each
+// subscriber processes all events at its own pace. Subscriptions are queue labels.
+//
+//	go run ./example/fanout -topic orders -subscribers billing,email,audit -events 5
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/goptics/sqliteq"
+)
+
+// config holds the flags of the scenario
+type config struct {
+	db          string
+	topic       string
+	subscribers []string
+	events      int
+}
+
+func main() {
+	var c config
+	var subscribers string
+	flag.StringVar(&c.db, "db", "fanout.db", "path to the database file")
+	flag.StringVar(&c.topic, "topic", "orders", "topic to publish to")
+	flag.StringVar(&subscribers, "subscribers", "billing,email,audit", "comma-separated queues subscribing to the topic")
+	flag.IntVar(&c.events, "events", 5, "number of events to publish")
+	flag.Parse()
+
+	c.subscribers = strings.Split(subscribers, ",")
+
+	if err := run(c, os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// subscribe opens the queue of a subscriber, labeled with the topic it follows
+func subscribe(queues *sqliteq.Manager, name, topic string) (*sqliteq.Queue, error) {
+	return queues.NewQueue(name, sqliteq.WithLabels(map[string]string{"topic": topic}))
+}
+
+// publish enqueues event to every queue subscribed to topic
+// Returns the number of subscribers reached
+func publish(queues *sqliteq.Manager, topic string, event any) (int, error) {
+	subscribers, err := queues.QueuesWithLabel("topic", topic)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, name := range subscribers {
+		if err := queues.EnqueueTo(name, event); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(subscribers), nil
+}
+
+// run subscribes the queues, publishes the events and lets every subscriber drain
+// its copy of them
+func run(c config, out io.Writer) error {
+	queues, err := sqliteq.NewManager(c.db)
+	if err != nil {
+		return err
+	}
+	defer queues.Close()
+
+	subscribers := make([]*sqliteq.Queue, len(c.subscribers))
+	for i, name := range c.subscribers {
+		if subscribers[i], err = subscribe(queues, name, c.topic); err != nil {
+			return err
+		}
+	}
+
+	for i := 1; i <= c.events; i++ {
+		reached, err := publish(queues, c.topic, fmt.Sprintf("%s event %d", c.topic, i))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "published %s event %d to %d subscribers\n", c.topic, i, reached)
+	}
+
+	for i, q := range subscribers {
+		received := 0
+		for {
+			if _, ok := q.Dequeue(); !ok {
+				break
+			}
+			received++
+		}
+		fmt.Fprintf(out, "%s received %d events\n", c.subscribers[i], received)
+	}
+
+	return nil
+}
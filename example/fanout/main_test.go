@@ -0,0 +1,22 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFanout(t *testing.T) {
+	var out strings.Builder
+	c := config{db: filepath.Join(t.TempDir(), "fanout.db"), topic: "orders", subscribers: []string{"billing", "email"}, events: 3}
+
+	if err := run(c, &out); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	for _, want := range []string{"published orders event 3 to 2 subscribers", "billing received 3 events", "email received 3 events"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected %q, got:\n%s", want, out.String())
+		}
+	}
+}
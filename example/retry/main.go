@@ -0,0 +1,120 @@
+// Command retry hands back jobs that fail with a transient error and retries them
+// later with exponential backoff, so a flaky downstream service gets time to recover.
+//
+//	go run ./example/retry -jobs 3 -failures 2 -base 100ms
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/goptics/sqliteq"
+)
+
+// config holds the flags of the scenario
+type config struct {
+	db       string
+	jobs     int
+	failures int
+	base     time.Duration
+}
+
+func main() {
+	var c config
+	flag.StringVar(&c.db, "db", "retry.db", "path to the database file")
+	flag.IntVar(&c.jobs, "jobs", 3, "number of jobs to enqueue")
+	flag.IntVar(&c.failures, "failures", 2, "times every job fails before succeeding")
+	flag.DurationVar(&c.base, "base", 100*time.Millisecond, "delay before the first retry, doubling after each one")
+	flag.Parse()
+
+	if err := run(context.Background(), c, os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// errUnavailable is the transient failure of the simulated downstream service
+var errUnavailable = errors.New("service unavailable")
+
+// result is how a job eventually succeeded
+type result struct {
+	job      string
+	attempts int
+	elapsed  time.Duration
+}
+
+// run enqueues the jobs and processes them until each one succeeded, reporting how
+// many attempts and how long it took
+func run(ctx context.Context, c config, out io.Writer) error {
+	queues, err := sqliteq.Open(c.db)
+	if err != nil {
+		return err
+	}
+	defer queues.Close()
+
+	// Retry at most failures times, so every job eventually succeeds
+	jobs, err := queues.NewQueue("jobs", sqliteq.WithRetryPolicy(c.failures, sqliteq.ExponentialBackoff(c.base, 0)))
+	if err != nil {
+		return err
+	}
+
+	for i := 1; i <= c.jobs; i++ {
+		if err := jobs.EnqueueWait(ctx, "job "+strconv.Itoa(i)); err != nil {
+			return err
+		}
+	}
+
+	var mu sync.Mutex
+	var results []result
+	finished := make(chan struct{})
+
+	handler := func(m sqliteq.Message) error {
+		if m.Attempts <= c.failures {
+			fmt.Fprintf(out, "%s failed on attempt %d: %v\n", m.Data, m.Attempts, errUnavailable)
+			return errUnavailable
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		results = append(results, result{job: string(m.Data), attempts: m.Attempts, elapsed: time.Since(m.CreatedAt)})
+		if len(results) == c.jobs {
+			close(finished)
+		}
+
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan error, 1)
+	go func() {
+		done <- jobs.Consume(ctx, handler, sqliteq.WithPollInterval(c.base/10))
+	}()
+
+	select {
+	case <-finished:
+	case <-ctx.Done():
+	}
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		return err
+	}
+	if len(results) < c.jobs {
+		return fmt.Errorf("only %d of %d jobs succeeded", len(results), c.jobs)
+	}
+
+	sort.Slice(results, func(i, j int) bool { return results[i].job < results[j].job })
+	for _, r := range results {
+		fmt.Fprintf(out, "%s succeeded on attempt %d after %v\n", r.job, r.attempts, r.elapsed.Round(time.Millisecond))
+	}
+
+	return nil
+}
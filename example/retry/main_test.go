@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRetry(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var out strings.Builder
+	c := config{db: filepath.Join(t.TempDir(), "retry.db"), jobs: 2, failures: 2, base: 20 * time.Millisecond}
+
+	start := time.Now()
+	if err := run(ctx, c, &out); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	// Two retries wait the base delay, then twice as long
+	if elapsed := time.Since(start); elapsed < 3*c.base {
+		t.Errorf("Expected the retries to back off for at least %v, took %v", 3*c.base, elapsed)
+	}
+
+	for _, job := range []string{"job 1", "job 2"} {
+		if !strings.Contains(out.String(), job+" succeeded on attempt 3") {
+			t.Errorf("Expected %s to succeed on its third attempt, got:\n%s", job, out.String())
+		}
+	}
+}
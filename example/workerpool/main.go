@@ -0,0 +1,127 @@
+// Command workerpool processes jobs with a pool of workers, moving the jobs that
+// keep failing to a dead-letter queue instead of retrying them forever.
+//
+//	go run ./example/workerpool -jobs 100 -workers 8 -fail-every 10
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/goptics/sqliteq"
+)
+
+// config holds the flags of the scenario
+type config struct {
+	db          string
+	jobs        int
+	workers     int
+	failEvery   int
+	maxAttempts int
+}
+
+func main() {
+	var c config
+	flag.StringVar(&c.db, "db", "workerpool.db", "path to the database file")
+	flag.IntVar(&c.jobs, "jobs", 100, "number of jobs to enqueue")
+	flag.IntVar(&c.workers, "workers", 8, "number of workers processing jobs in parallel")
+	flag.IntVar(&c.failEvery, "fail-every", 10, "make every nth job fail every time (0 for none)")
+	flag.IntVar(&c.maxAttempts, "max-attempts", 3, "deliveries before a failing job is dead-lettered")
+	flag.Parse()
+
+	if err := run(context.Background(), c, os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// errPoison is the failure of the jobs that never succeed
+var errPoison = errors.New("poison job")
+
+// run enqueues the jobs, processes them until none is left and reports the outcome
+func run(ctx context.Context, c config, out io.Writer) error {
+	queues, err := sqliteq.Open(c.db)
+	if err != nil {
+		return err
+	}
+	defer queues.Close()
+
+	jobs, err := queues.NewQueue("jobs", sqliteq.WithDeadLetterQueue("jobs_dlq", c.maxAttempts))
+	if err != nil {
+		return err
+	}
+	dlq, err := queues.NewQueue("jobs_dlq")
+	if err != nil {
+		return err
+	}
+
+	for i := 1; i <= c.jobs; i++ {
+		if err := jobs.EnqueueWait(ctx, "job "+strconv.Itoa(i)); err != nil {
+			return err
+		}
+	}
+
+	var processed atomic.Int64
+	handler := func(m sqliteq.Message) error {
+		n, err := strconv.Atoi(strings.TrimPrefix(string(m.Data), "job "))
+		if err != nil {
+			return err
+		}
+
+		if c.failEvery > 0 && n%c.failEvery == 0 {
+			return errPoison
+		}
+
+		processed.Add(1)
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan error, 1)
+	go func() {
+		done <- jobs.Consume(ctx, handler, sqliteq.WithConcurrency(c.workers), sqliteq.WithPollInterval(10*time.Millisecond))
+	}()
+
+	// Stop the workers once every job was processed or dead-lettered
+	err = waitIdle(ctx, jobs)
+	cancel()
+	if consumeErr := <-done; !errors.Is(consumeErr, context.Canceled) {
+		err = errors.Join(err, consumeErr)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "processed %d jobs, %d dead-lettered\n", processed.Load(), dlq.Len())
+	return nil
+}
+
+// waitIdle waits until the queue has no pending or processing item left
+func waitIdle(ctx context.Context, q *sqliteq.Queue) error {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		stats, err := q.Stats()
+		if err != nil {
+			return err
+		}
+		if stats.Pending+stats.Processing == 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
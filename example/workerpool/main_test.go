@@ -0,0 +1,25 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWorkerPool(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var out strings.Builder
+	c := config{db: filepath.Join(t.TempDir(), "workerpool.db"), jobs: 20, workers: 4, failEvery: 5, maxAttempts: 2}
+
+	if err := run(ctx, c, &out); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	if got := out.String(); got != "processed 16 jobs, 4 dead-lettered\n" {
+		t.Errorf("Expected 16 processed and 4 dead-lettered jobs, got %q", got)
+	}
+}
@@ -0,0 +1,52 @@
+package sqliteq
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// EnqueueWithTTL adds an item that is only worth delivering for ttl, e.g. a
+// time-sensitive notification. Once the TTL elapses the item is skipped by
+// dequeues and deleted by the expiry sweep, see WithExpirySweep.
+// Returns true if the operation was successful
+func (q *Queue) EnqueueWithTTL(item any, ttl time.Duration) bool {
+	if ttl <= 0 {
+		return false
+	}
+
+	return q.succeeded("enqueue", q.enqueueMessage(item, Message{ExpiresAt: time.Now().Add(ttl)}))
+}
+
+// EnqueueWithTTL adds an item with a specified priority that is only worth
+// delivering for ttl
+// Returns true if the operation was successful
+func (pq *PriorityQueue) EnqueueWithTTL(item any, priority int, ttl time.Duration) bool {
+	if ttl <= 0 {
+		return false
+	}
+
+	return pq.succeeded("enqueue", pq.enqueueMessage(item, Message{Priority: priority, ExpiresAt: time.Now().Add(ttl)}))
+}
+
+// sweepExpired deletes the pending items whose TTL elapsed, in batches of the
+// janitor batch size. Items already in processing are left to finish.
+// Returns the number of deleted items
+func (q *Queue) sweepExpired() (swept int64, err error) {
+	defer func() { err = mapError(err) }()
+	defer q.invalidateStats()
+
+	if q.closed.Load() {
+		return 0, ErrClosed
+	}
+
+	// Items may have been enqueued by a host with a skewed clock
+	expiry := q.expiryNow()
+
+	return q.inBatches(func(limit int) (sql.Result, error) {
+		return q.client.Exec(fmt.Sprintf(
+			"DELETE FROM %[1]s WHERE id IN (SELECT id FROM %[1]s WHERE expires_at IS NOT NULL AND expires_at <= ? AND status = 'pending' LIMIT ?)",
+			quoteIdent(q.tableName),
+		), expiry, limit)
+	})
+}
@@ -0,0 +1,87 @@
+package sqliteq
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestEnqueueWithTTL(t *testing.T) {
+	dbPath := "test_ttl.db"
+	defer os.Remove(dbPath)
+
+	queues, err := NewManager(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	defer queues.Close()
+
+	q, err := queues.NewQueue("notifications")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	if q.EnqueueWithTTL("never", 0) {
+		t.Error("Expected a non-positive TTL to be rejected")
+	}
+
+	q.EnqueueWithTTL("stale", time.Hour)
+	q.EnqueueWithTTL("fresh", time.Hour)
+	q.Enqueue("forever")
+
+	messages, err := q.Page(0, 10)
+	if err != nil || len(messages) != 3 {
+		t.Fatalf("Expected 3 items, got %v, %v", messages, err)
+	}
+
+	if messages[0].ExpiresAt.IsZero() || !messages[2].ExpiresAt.IsZero() {
+		t.Errorf("Expected only TTL items to expire, got %v and %v", messages[0].ExpiresAt, messages[2].ExpiresAt)
+	}
+
+	q.client.Exec("UPDATE notifications SET expires_at = ? WHERE data = ?", time.Now().Add(-time.Minute).UTC(), []byte("stale"))
+
+	if item, ok := q.Dequeue(); !ok || string(item.([]byte)) != "fresh" {
+		t.Errorf("Expected the expired item to be skipped, got %v", item)
+	}
+
+	report, err := queues.RunMaintenance(context.Background())
+	if err != nil {
+		t.Fatalf("RunMaintenance failed: %v", err)
+	}
+
+	if report.Expired["notifications"] != 1 {
+		t.Errorf("Expected 1 expired item, got %v", report.Expired)
+	}
+
+	if q.Len() != 1 {
+		t.Errorf("Expected only 'forever' left, got %d items", q.Len())
+	}
+
+	t.Run("Sweep", func(t *testing.T) {
+		pq, err := queues.NewPriorityQueue("alerts", WithExpirySweep(10*time.Millisecond))
+		if err != nil {
+			t.Fatalf("Failed to create priority queue: %v", err)
+		}
+
+		pq.EnqueueWithTTL("brief", 1, 20*time.Millisecond)
+		pq.Enqueue("lasting", 2)
+
+		// Len already skips expired items, so count the rows the sweep leaves
+		rows := func() (n int) {
+			pq.client.QueryRow("SELECT COUNT(*) FROM alerts").Scan(&n)
+			return n
+		}
+
+		deadline := time.Now().Add(2 * time.Second)
+		for rows() != 1 && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		var data []byte
+		pq.client.QueryRow("SELECT data FROM alerts").Scan(&data)
+		if rows() != 1 || string(data) != "lasting" {
+			t.Errorf("Expected the expired item to be swept, got %d rows, %q left", rows(), data)
+		}
+	})
+}
@@ -0,0 +1,70 @@
+package sqliteq
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Export calls fn with every item of the queue, whatever its status, in insertion
+// order, reading them one at a time from a single read transaction. The items form
+// one snapshot of the queue, however long fn takes: items enqueued, dequeued or
+// acknowledged meanwhile don't show up halfway, and producers and consumers aren't
+// blocked by the export. The messages can be stored back with Import.
+// As a backup format, payloads are exported as stored, bypassing WithRedactor; use
+// ExportRedacted for exports shared with people or tools that mustn't see secrets.
+// It stops at the first error of fn or once ctx is done, returning the error. A
+// long export keeps the WAL from being checkpointed past its snapshot, so the WAL
+// file grows until it ends.
+func (q *Queue) Export(ctx context.Context, fn func(m Message) error) (err error) {
+	defer func() { err = mapError(err) }()
+
+	if q.closed.Load() {
+		return ErrClosed
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM %s ORDER BY seq ASC, id ASC", q.messageColumns(), quoteIdent(q.tableName))
+
+	return q.readSnapshot(ctx, query, nil, func(rows *sql.Rows) error {
+		m, err := q.scanMessage(rows)
+		if err != nil {
+			return err
+		}
+
+		return fn(m)
+	})
+}
+
+// ExportRedacted is Export with every payload passed through Redact. The redacted
+// messages can't be restored with Import.
+func (q *Queue) ExportRedacted(ctx context.Context, fn func(m Message) error) error {
+	return q.Export(ctx, func(m Message) error {
+		m.Data = q.Redact(m.Data)
+		return fn(m)
+	})
+}
+
+// readSnapshot runs query in a read transaction, calling fn with each row as it is
+// read. Every statement run within the transaction reads the same snapshot.
+func (q *Queue) readSnapshot(ctx context.Context, query string, args []any, fn func(rows *sql.Rows) error) error {
+	tx, err := q.client.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	// Nothing is written, so rolling back only releases the snapshot
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := fn(rows); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
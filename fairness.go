@@ -0,0 +1,100 @@
+package sqliteq
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// fairnessTable records when each value of a queue's fairness header was last
+// served, see WithFairnessKey
+const fairnessTable = "sqliteq_fairness"
+
+// initFairnessTable creates the fairness table if it doesn't exist
+func initFairnessTable(db *sql.DB) error {
+	_, err := db.Exec(fmt.Sprintf(`
+	CREATE TABLE IF NOT EXISTS %s (
+		queue TEXT NOT NULL,
+		value TEXT NOT NULL,
+		turn INTEGER NOT NULL,
+		PRIMARY KEY (queue, value)
+	);
+	`, quoteIdent(fairnessTable)))
+
+	return err
+}
+
+// WithFairnessKey makes dequeues take turns across the distinct values of the
+// header, such as a tenant ID set with EnqueueWithHeaders, so a tenant enqueueing
+// 100k jobs can't hold back tenants with a few each. Among the ready items of the
+// best priority, the next one comes from the value served least recently; items
+// of one value keep their order, and items without the header share a turn.
+// Turns are stored in the database, so they are shared by every process using
+// the queue with the same header.
+// Each dequeue ranks all ready items, which costs more than the index lookup of a
+// plain dequeue on very long queues, and bypasses WithDequeueCache.
+func WithFairnessKey(header string) Option {
+	return func(q *Queue) {
+		q.fairnessKey = header
+	}
+}
+
+// fairnessPath returns the JSON path of the fairness header in the headers column
+func (q *Queue) fairnessPath() string {
+	return `$."` + strings.ReplaceAll(q.fairnessKey, `"`, `\"`) + `"`
+}
+
+// fairQuery returns the query reading up to n items matching due, with its
+// arguments, ranking each item within its header value and priority and ordering
+// the values by their last turn
+func (q *Queue) fairQuery(due string, args []any, n int) (string, []any) {
+	partition, order := "", ""
+	if q.priority {
+		partition, order = "priority, ", "priority ASC, "
+	}
+
+	query := fmt.Sprintf(`
+	SELECT %[1]s FROM (
+		SELECT *, COALESCE(json_extract(headers, ?), '') AS fair_value,
+			ROW_NUMBER() OVER (PARTITION BY %[4]sCOALESCE(json_extract(headers, ?), '') ORDER BY seq) AS fair_rank
+		FROM %[2]s WHERE %[3]s
+	) AS ready
+	LEFT JOIN %[5]s AS turns ON turns.queue = ? AND turns.value = ready.fair_value
+	ORDER BY %[6]sfair_rank ASC, COALESCE(turns.turn, 0) ASC, seq ASC
+	LIMIT ?`,
+		q.messageColumns(), quoteIdent(q.tableName), due, partition, quoteIdent(fairnessTable), order)
+
+	path := q.fairnessPath()
+	queryArgs := append([]any{path, path}, args...)
+
+	return query, append(queryArgs, q.tableName, n)
+}
+
+// takeTurns records within tx that the header values of the claimed messages
+// were served, the values of later messages last
+func (q *Queue) takeTurns(tx *sql.Tx, messages []Message) error {
+	var last int64
+	err := tx.QueryRow(
+		fmt.Sprintf("SELECT COALESCE(MAX(turn), 0) FROM %s WHERE queue = ?", quoteIdent(fairnessTable)), q.tableName,
+	).Scan(&last)
+	if err != nil {
+		return err
+	}
+
+	turns := make(map[string]int64, len(messages))
+	for i, m := range messages {
+		turns[m.Headers[q.fairnessKey]] = last + int64(i) + 1
+	}
+
+	for value, turn := range turns {
+		_, err = tx.Exec(fmt.Sprintf(
+			"INSERT INTO %s (queue, value, turn) VALUES (?, ?, ?) ON CONFLICT (queue, value) DO UPDATE SET turn = excluded.turn",
+			quoteIdent(fairnessTable),
+		), q.tableName, value, turn)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
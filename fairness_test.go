@@ -0,0 +1,120 @@
+package sqliteq
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestWithFairnessKey(t *testing.T) {
+	dbPath := "test_fairness.db"
+	defer os.Remove(dbPath)
+
+	queues, err := NewManager(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	defer queues.Close()
+
+	t.Run("RoundRobin", func(t *testing.T) {
+		q, err := queues.NewQueue("jobs", WithFairnessKey("tenant_id"))
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+
+		// A busy tenant enqueues ahead of two small ones and an item without the header
+		for i := 0; i < 5; i++ {
+			q.EnqueueWithHeaders(fmt.Sprintf("big-%d", i), map[string]string{"tenant_id": "big"})
+		}
+		q.EnqueueWithHeaders("a-0", map[string]string{"tenant_id": "a"})
+		q.EnqueueWithHeaders("b-0", map[string]string{"tenant_id": "b"})
+		q.EnqueueWithHeaders("b-1", map[string]string{"tenant_id": "b"})
+		q.Enqueue("none-0")
+
+		var got []string
+		for {
+			item, ok := q.Dequeue()
+			if !ok {
+				break
+			}
+			got = append(got, string(item.([]byte)))
+		}
+
+		expected := []string{"big-0", "a-0", "b-0", "none-0", "big-1", "b-1", "big-2", "big-3", "big-4"}
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected %v, got %v", expected, got)
+		}
+	})
+
+	t.Run("Batch", func(t *testing.T) {
+		q, err := queues.NewQueue("batch", WithFairnessKey("tenant_id"))
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+
+		for _, tenant := range []string{"a", "a", "a", "b"} {
+			q.EnqueueWithHeaders(tenant, map[string]string{"tenant_id": tenant})
+		}
+
+		var got []string
+		for _, item := range q.DequeueN(3) {
+			got = append(got, string(item.([]byte)))
+		}
+
+		if !reflect.DeepEqual(got, []string{"a", "b", "a"}) {
+			t.Errorf("Expected [a b a], got %v", got)
+		}
+
+		if d, err := queues.Describe("batch"); err != nil || d.Options == nil || d.Options.FairnessKey != "tenant_id" {
+			t.Errorf("Expected the fairness key in the description, got %+v, %v", d.Options, err)
+		}
+	})
+
+	t.Run("Priority", func(t *testing.T) {
+		pq, err := queues.NewPriorityQueue("urgent", WithFairnessKey("tenant_id"))
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+
+		pq.EnqueueWithHeaders("a-low", 5, map[string]string{"tenant_id": "a"})
+		pq.EnqueueWithHeaders("a-high-0", 1, map[string]string{"tenant_id": "a"})
+		pq.EnqueueWithHeaders("a-high-1", 1, map[string]string{"tenant_id": "a"})
+		pq.EnqueueWithHeaders("b-high", 1, map[string]string{"tenant_id": "b"})
+
+		var got []string
+		for item, ok := pq.Dequeue(); ok; item, ok = pq.Dequeue() {
+			got = append(got, string(item.([]byte)))
+		}
+
+		// Turns only apply among items of the same priority
+		expected := []string{"a-high-0", "b-high", "a-high-1", "a-low"}
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected %v, got %v", expected, got)
+		}
+	})
+
+	t.Run("SharedTurns", func(t *testing.T) {
+		first, err := queues.NewQueue("shared", WithFairnessKey("tenant_id"))
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+		second, err := queues.NewQueue("shared", WithFairnessKey("tenant_id"))
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+
+		for _, tenant := range []string{"a", "a", "b"} {
+			first.EnqueueWithHeaders(tenant, map[string]string{"tenant_id": tenant})
+		}
+
+		if item, _ := first.Dequeue(); string(item.([]byte)) != "a" {
+			t.Errorf("Expected a, got %v", item)
+		}
+
+		// The other instance sees that a was just served
+		if item, _ := second.Dequeue(); string(item.([]byte)) != "b" {
+			t.Errorf("Expected b from the other instance, got %v", item)
+		}
+	})
+}
@@ -0,0 +1,182 @@
+package sqliteq
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// FormatVersion is the version of the on-disk format described in FORMAT.md,
+// written by this package and required to open a database
+const FormatVersion = 1
+
+// metaTable holds database-wide settings, such as the format version
+const metaTable = "sqliteq_meta"
+
+// ErrUnsupportedFormat is returned when opening a database written in a newer format
+var ErrUnsupportedFormat = errors.New("unsupported database format")
+
+// queueFormatColumns are the columns every queue table has in format 1
+var queueFormatColumns = []string{
+	"id", "data", "status", "ack_id", "ack", "created_at", "updated_at",
+	"not_before", "repeat_every", "repeat_until", "seq", "fail_reason",
+}
+
+// initFormat records the format version of a new database and rejects newer ones
+func initFormat(db *sql.DB) error {
+	_, err := db.Exec(fmt.Sprintf(`
+	CREATE TABLE IF NOT EXISTS %[1]s (
+		key TEXT PRIMARY KEY,
+		value TEXT NOT NULL
+	);
+	INSERT OR IGNORE INTO %[1]s (key, value) VALUES ('format_version', '%[2]d');
+	`, quoteIdent(metaTable), FormatVersion))
+	if err != nil {
+		return err
+	}
+
+	version, err := formatVersion(db)
+	if err != nil {
+		return err
+	}
+
+	if version > FormatVersion {
+		return fmt.Errorf("%w: version %d, this package supports %d", ErrUnsupportedFormat, version, FormatVersion)
+	}
+
+	return nil
+}
+
+// formatVersion reads the format version recorded in the database
+func formatVersion(db *sql.DB) (int, error) {
+	var value string
+	err := db.QueryRow(fmt.Sprintf("SELECT value FROM %s WHERE key = 'format_version'", quoteIdent(metaTable))).Scan(&value)
+	if err != nil {
+		return 0, err
+	}
+
+	version, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid version %q", ErrUnsupportedFormat, value)
+	}
+
+	return version, nil
+}
+
+// VerifyFormat checks that the database at dbPath follows the on-disk format
+// described in FORMAT.md: the format version, the columns of every registered
+// queue and the invariants of the item state machine. It is a conformance check
+// for files written by other tools or versions, and doesn't modify the file.
+// Returns the violations found, none for a conforming file
+func VerifyFormat(dbPath string) (violations []string, err error) {
+	defer func() { err = mapError(err) }()
+
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	return verifyFormat(db)
+}
+
+// verifyFormat checks an open database against the format description
+func verifyFormat(db *sql.DB) (violations []string, err error) {
+	version, err := formatVersion(db)
+	if err != nil {
+		return []string{fmt.Sprintf("format version can't be read: %v", err)}, nil
+	}
+	if version != FormatVersion {
+		violations = append(violations, fmt.Sprintf("format version is %d, expected %d", version, FormatVersion))
+	}
+
+	rows, err := db.Query(fmt.Sprintf("SELECT name, kind FROM %s WHERE kind != ? ORDER BY name", quoteIdent(registryTable)), kindAlias)
+	if err != nil {
+		return append(violations, fmt.Sprintf("registry can't be read: %v", err)), nil
+	}
+
+	queues := make(map[string]string)
+	var names []string
+	for rows.Next() {
+		var name, kind string
+		if err := rows.Scan(&name, &kind); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		queues[name] = kind
+		names = append(names, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, name := range names {
+		found, err := verifyQueue(db, name, queues[name])
+		if err != nil {
+			return nil, err
+		}
+		violations = append(violations, found...)
+	}
+
+	return violations, nil
+}
+
+// verifyQueue checks the columns and item invariants of one queue table
+func verifyQueue(db *sql.DB, name, kind string) ([]string, error) {
+	var violations []string
+
+	switch kind {
+	case kindQueue, kindPriorityQueue:
+	default:
+		violations = append(violations, fmt.Sprintf("%s: unknown kind %q", name, kind))
+	}
+
+	columns := queueFormatColumns
+	if kind == kindPriorityQueue {
+		columns = append(columns[:len(columns):len(columns)], "priority")
+	}
+
+	for _, column := range columns {
+		exists, err := hasColumn(db, name, column)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			violations = append(violations, fmt.Sprintf("%s: missing column %s", name, column))
+		}
+	}
+	if len(violations) > 0 {
+		return violations, nil
+	}
+
+	table := quoteIdent(name)
+	checks := []struct {
+		problem string
+		query   string
+		args    []any
+	}{
+		{"items with an unknown status", fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE status NOT IN ('pending', 'processing', 'completed', 'failed')", table), nil},
+		{"processing items without an ack ID", fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE status = 'processing' AND ack_id IS NULL", table), nil},
+		{"completed items not marked as acknowledged", fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE status = 'completed' AND ack != 1", table), nil},
+		{"items without a seq", fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE seq IS NULL", table), nil},
+		{"items sharing a seq", fmt.Sprintf("SELECT COUNT(*) FROM (SELECT seq FROM %s WHERE seq IS NOT NULL GROUP BY seq HAVING COUNT(*) > 1)", table), nil},
+		{"items with a negative repeat interval", fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE repeat_every < 0", table), nil},
+		// Without a counter row the next seq follows the highest one in the table
+		{"items with a seq beyond the queue counter", fmt.Sprintf(
+			"SELECT COUNT(*) FROM %s WHERE seq > (SELECT seq FROM %s WHERE queue = ?)", table, quoteIdent(sequencesTable)), []any{name}},
+	}
+
+	for _, check := range checks {
+		var count int
+		if err := db.QueryRow(check.query, check.args...).Scan(&count); err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			violations = append(violations, fmt.Sprintf("%s: %d %s", name, count, check.problem))
+		}
+	}
+
+	return violations, nil
+}
@@ -0,0 +1,180 @@
+package sqliteq
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatConformance(t *testing.T) {
+	dbPath := "test_format.db"
+	defer os.Remove(dbPath)
+
+	queues, err := NewManager(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+
+	q, err := queues.NewQueue("plain", WithRemoveOnComplete(false))
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	pq, err := queues.NewPriorityQueue("prioritized")
+	if err != nil {
+		t.Fatalf("Failed to create priority queue: %v", err)
+	}
+
+	q.Enqueue("pending")
+	q.Enqueue("processing")
+	q.Enqueue("completed")
+	q.EnqueueRepeating("repeating", time.Hour, time.Time{})
+	_, _, ackID := q.DequeueWithAckId()
+	q.Acknowledge(ackID)
+	q.DequeueWithAckId()
+	pq.Enqueue("urgent", 0)
+	pq.Enqueue("later", 5)
+	queues.Alias("prioritized", "alias")
+	queues.Close()
+
+	violations, err := VerifyFormat(dbPath)
+	if err != nil {
+		t.Fatalf("VerifyFormat failed: %v", err)
+	}
+
+	if len(violations) > 0 {
+		t.Errorf("Expected a conforming file, got %v", violations)
+	}
+}
+
+// TestForeignWriter writes a queue file with plain SQL following FORMAT.md, the way
+// a tool in another language would, and reads it back through the package
+func TestForeignWriter(t *testing.T) {
+	dbPath := "test_format_foreign.db"
+	defer os.Remove(dbPath)
+
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+
+	_, err = db.Exec(`
+	PRAGMA journal_mode=WAL;
+	CREATE TABLE sqliteq_meta (key TEXT PRIMARY KEY, value TEXT NOT NULL);
+	INSERT INTO sqliteq_meta VALUES ('format_version', '1');
+	CREATE TABLE sqliteq_queues (name TEXT PRIMARY KEY, kind TEXT NOT NULL, target TEXT, created_at TIMESTAMP NOT NULL, empty_since TIMESTAMP, empty_seq INTEGER, labels TEXT);
+	INSERT INTO sqliteq_queues (name, kind, created_at) VALUES ('jobs', 'priority', '2024-01-01 00:00:00+00:00');
+	CREATE TABLE sqliteq_sequences (queue TEXT PRIMARY KEY, seq INTEGER NOT NULL);
+	INSERT INTO sqliteq_sequences VALUES ('jobs', 3);
+	CREATE TABLE jobs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		data BLOB NOT NULL,
+		status TEXT NOT NULL,
+		ack_id TEXT UNIQUE,
+		ack BOOLEAN DEFAULT 0,
+		created_at TIMESTAMP,
+		updated_at TIMESTAMP,
+		not_before TIMESTAMP,
+		repeat_every INTEGER NOT NULL DEFAULT 0,
+		repeat_until TIMESTAMP,
+		seq INTEGER,
+		fail_reason TEXT,
+		priority INTEGER NOT NULL DEFAULT 0
+	);
+	INSERT INTO jobs (data, status, created_at, updated_at, seq, priority) VALUES
+		('low', 'pending', '2024-01-01 00:00:00+00:00', '2024-01-01 00:00:00+00:00', 1, 5),
+		('high', 'pending', '2024-01-01 00:00:01+00:00', '2024-01-01 00:00:01+00:00', 2, 1);
+	INSERT INTO jobs (data, status, ack_id, created_at, updated_at, seq, priority) VALUES
+		('taken', 'processing', 'foreign-ack', '2024-01-01 00:00:02+00:00', '2024-01-01 00:00:02+00:00', 3, 0);
+	`)
+	db.Close()
+	if err != nil {
+		t.Fatalf("Failed to write foreign file: %v", err)
+	}
+
+	if violations, err := VerifyFormat(dbPath); err != nil || len(violations) > 0 {
+		t.Fatalf("Expected the foreign file to conform, got %v (%v)", violations, err)
+	}
+
+	queues := New(dbPath)
+	defer queues.Close()
+
+	pq, err := queues.NewPriorityQueue("jobs")
+	if err != nil {
+		t.Fatalf("Failed to open foreign queue: %v", err)
+	}
+
+	// The unacknowledged item was returned to pending on open and keeps its ack ID
+	for _, want := range []string{"taken", "high", "low"} {
+		item, ok, ackID := pq.DequeueWithAckId()
+		if !ok || string(item.([]byte)) != want {
+			t.Fatalf("Expected %s, got %v", want, item)
+		}
+
+		if want == "taken" && ackID != "foreign-ack" {
+			t.Errorf("Expected the foreign ack ID to be kept, got %s", ackID)
+		}
+
+		pq.Acknowledge(ackID)
+	}
+
+	pq.Enqueue("new", 0)
+	messages, _ := pq.Page(0, 1)
+	if len(messages) != 1 || messages[0].Seq != 4 {
+		t.Errorf("Expected the next seq to follow the foreign counter, got %+v", messages)
+	}
+}
+
+func TestUnsupportedFormat(t *testing.T) {
+	dbPath := "test_format_newer.db"
+	defer os.Remove(dbPath)
+
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+
+	_, err = db.Exec(`
+	CREATE TABLE sqliteq_meta (key TEXT PRIMARY KEY, value TEXT NOT NULL);
+	INSERT INTO sqliteq_meta VALUES ('format_version', '2');
+	`)
+	db.Close()
+	if err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	if _, err := Open(dbPath); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("Expected ErrUnsupportedFormat, got %v", err)
+	}
+}
+
+func TestVerifyFormatViolations(t *testing.T) {
+	dbPath := "test_format_violations.db"
+	defer os.Remove(dbPath)
+
+	queues := New(dbPath)
+	q, err := queues.NewQueue("broken")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	q.Enqueue("a")
+	q.client.Exec("UPDATE broken SET status = 'processing', ack_id = NULL")
+	q.client.Exec("INSERT INTO broken (data, status, seq) VALUES ('b', 'lost', 1)")
+	queues.Close()
+
+	violations, err := VerifyFormat(dbPath)
+	if err != nil {
+		t.Fatalf("VerifyFormat failed: %v", err)
+	}
+
+	got := strings.Join(violations, "\n")
+	for _, want := range []string{"unknown status", "without an ack ID", "sharing a seq"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Expected a violation about %q, got %v", want, violations)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package sqliteq
+
+import (
+	"container/heap"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// frontEntry is a cached pending item, ordered by priority and seq
+type frontEntry struct {
+	id       int64
+	priority int
+	seq      int64
+}
+
+// frontHeap is a min-heap of cached items in dequeue order
+type frontHeap []frontEntry
+
+func (h frontHeap) Len() int { return len(h) }
+
+func (h frontHeap) Less(i, j int) bool { return h[i].before(h[j]) }
+
+func (h frontHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *frontHeap) Push(x any) { *h = append(*h, x.(frontEntry)) }
+
+func (h *frontHeap) Pop() any {
+	old := *h
+	e := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return e
+}
+
+// before reports whether e is dequeued before other
+func (e frontEntry) before(other frontEntry) bool {
+	if e.priority != other.priority {
+		return e.priority < other.priority
+	}
+
+	return e.seq < other.seq
+}
+
+// frontCache holds the IDs of the next pending items for WithDequeueCache, so
+// dequeues can claim a known row instead of sorting the pending items each time.
+// It holds the best due items known to this process: items enqueued through the
+// queue are added, and any other write that can return items to pending empties it.
+type frontCache struct {
+	mu      sync.Mutex
+	size    int
+	entries frontHeap
+	// complete is whether entries held every due item when they were read
+	complete bool
+	// gen changes on every change, so refills racing with one are dropped
+	gen uint64
+}
+
+// pop takes the next cached item
+// Returns false and the generation a refill has to match when the cache is empty
+func (c *frontCache) pop() (id int64, gen uint64, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.entries) == 0 {
+		return 0, c.gen, false
+	}
+
+	return heap.Pop(&c.entries).(frontEntry).id, c.gen, true
+}
+
+// fill replaces the cached items with entries read in dequeue order, unless the
+// cache changed since gen was returned by pop
+func (c *frontCache) fill(gen uint64, entries []frontEntry, complete bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.gen != gen {
+		return
+	}
+
+	// Entries in dequeue order already form a heap
+	c.entries, c.complete = entries, complete
+	c.gen++
+}
+
+// push adds an item enqueued through the queue if it belongs to the cached front
+func (c *frontCache) push(e frontEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+
+	// An empty cache is refilled from the table by the next dequeue
+	if len(c.entries) == 0 {
+		return
+	}
+
+	// Unless every due item is cached, items after the last cached one may be
+	// preceded by items of the table that aren't cached
+	last := c.last()
+	if !c.complete && !e.before(c.entries[last]) {
+		return
+	}
+
+	heap.Push(&c.entries, e)
+
+	if len(c.entries) > c.size {
+		heap.Remove(&c.entries, c.last())
+		c.complete = false
+	}
+}
+
+// last returns the index of the cached item dequeued last, which is a leaf
+func (c *frontCache) last() int {
+	last := len(c.entries) - 1
+	for i := len(c.entries) / 2; i < len(c.entries); i++ {
+		if c.entries[last].before(c.entries[i]) {
+			last = i
+		}
+	}
+
+	return last
+}
+
+// invalidate empties the cache, so the next dequeue reads the front from the table
+func (c *frontCache) invalidate() {
+	c.mu.Lock()
+	c.entries, c.complete = nil, false
+	c.gen++
+	c.mu.Unlock()
+}
+
+// invalidateFront empties the dequeue cache after a write that may return items to pending
+func (q *Queue) invalidateFront() {
+	if q.front != nil {
+		q.front.invalidate()
+	}
+}
+
+// pushFront adds an item enqueued through the queue to the dequeue cache if it is due
+func (q *Queue) pushFront(m *Message) {
+	if q.front == nil || m.Status != StatusPending || m.NotBefore.After(time.Now()) {
+		return
+	}
+
+	e := frontEntry{id: m.ID, seq: m.Seq}
+	if q.priority {
+		e.priority = m.Priority
+	}
+
+	q.front.push(e)
+}
+
+// readFront reads the next due item from the dequeue cache, refilling the cache
+// from the table when it is empty. due is the condition of due items, with args.
+// Returns no message when the cached item is gone, emptying the cache, or when no
+// item is due
+func (q *Queue) readFront(tx *sql.Tx, due string, args []any) ([]Message, error) {
+	id, gen, ok := q.front.pop()
+	if !ok {
+		rows, err := tx.Query(fmt.Sprintf("SELECT id, %s, seq FROM %s WHERE %s ORDER BY %s LIMIT ?",
+			q.priorityColumn(), quoteIdent(q.tableName), due, q.orderBy()), append(args, q.front.size+1)...)
+		if err != nil {
+			return nil, err
+		}
+
+		var entries []frontEntry
+		for rows.Next() {
+			var e frontEntry
+			if err := rows.Scan(&e.id, &e.priority, &e.seq); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			entries = append(entries, e)
+		}
+		rows.Close()
+
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+
+		if len(entries) == 0 {
+			return nil, nil
+		}
+
+		// The first item is claimed now, the others are cached
+		id = entries[0].id
+		q.front.fill(gen, entries[1:], len(entries) <= q.front.size)
+	}
+
+	messages, err := q.queryMessages(tx,
+		fmt.Sprintf("SELECT %s FROM %s WHERE id = ? AND %s", q.messageColumns(), quoteIdent(q.tableName), due),
+		append([]any{id}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Another process claimed or removed the item, so the cache is stale
+	if len(messages) == 0 {
+		q.front.invalidate()
+	}
+
+	return messages, nil
+}
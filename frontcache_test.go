@@ -0,0 +1,95 @@
+package sqliteq
+
+import (
+	"os"
+	"testing"
+)
+
+func TestDequeueCache(t *testing.T) {
+	dbPath := "test_dequeue_cache.db"
+	defer os.Remove(dbPath)
+
+	queues := New(dbPath)
+	defer queues.Close()
+
+	pq, err := queues.NewPriorityQueue("hot", WithDequeueCache(2))
+	if err != nil {
+		t.Fatalf("Failed to create priority queue: %v", err)
+	}
+
+	dequeue := func() string {
+		t.Helper()
+
+		item, ok := pq.Dequeue()
+		if !ok {
+			return ""
+		}
+
+		return string(item.([]byte))
+	}
+
+	pq.Enqueue("low", 5)
+	pq.Enqueue("mid", 3)
+	pq.Enqueue("high", 1)
+	pq.Enqueue("lowest", 9)
+
+	// The first dequeue fills the cache with "mid" and "low"
+	if got := dequeue(); got != "high" {
+		t.Fatalf("Expected 'high', got %q", got)
+	}
+
+	if len(pq.front.entries) != 2 {
+		t.Fatalf("Expected 2 cached items, got %d", len(pq.front.entries))
+	}
+
+	// Enqueued ahead of the cached items, so it is cached too, evicting "low"
+	pq.Enqueue("urgent", 0)
+	// Behind the cached items, so it is left in the table
+	pq.Enqueue("later", 7)
+
+	for _, want := range []string{"urgent", "mid", "low", "later", "lowest", ""} {
+		if got := dequeue(); got != want {
+			t.Errorf("Expected %q, got %q", want, got)
+		}
+	}
+
+	t.Run("RemovedByAnotherProcess", func(t *testing.T) {
+		pq.Enqueue("a", 1)
+		pq.Enqueue("b", 2)
+		pq.Enqueue("c", 3)
+
+		if got := dequeue(); got != "a" {
+			t.Fatalf("Expected 'a', got %q", got)
+		}
+
+		// Claim the cached "b" behind the cache's back
+		if _, err := pq.client.Exec("UPDATE hot SET status = 'processing' WHERE data = ?", []byte("b")); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+
+		if got := dequeue(); got != "c" {
+			t.Errorf("Expected 'c' after the cached item was gone, got %q", got)
+		}
+
+		pq.Purge()
+	})
+
+	t.Run("Nack", func(t *testing.T) {
+		pq.Enqueue("first", 1)
+		pq.Enqueue("second", 2)
+		pq.Enqueue("third", 3)
+
+		m, err := pq.DequeueMessageWithAckId()
+		if err != nil || string(m.Data) != "first" {
+			t.Fatalf("Expected 'first', got %+v, %v", m, err)
+		}
+
+		if err := pq.Nack(m.AckID); err != nil {
+			t.Fatalf("Nack failed: %v", err)
+		}
+
+		if got := dequeue(); got != "first" {
+			t.Errorf("Expected the nacked 'first' ahead of the cached items, got %q", got)
+		}
+	})
+}
@@ -0,0 +1,279 @@
+package sqliteq
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// startGC starts the background collection of empty queues set up by WithDropEmptyAfter
+func (m *Manager) startGC() {
+	if m.dropEmptyAfter <= 0 {
+		return
+	}
+
+	interval := m.dropEmptyAfter / 2
+	if interval < time.Millisecond {
+		interval = time.Millisecond
+	}
+
+	m.stop = make(chan struct{})
+	m.wg.Add(1)
+
+	go func() {
+		defer m.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-m.stop:
+				return
+			case <-ticker.C:
+				m.dropEmptyQueues()
+			}
+		}
+	}()
+}
+
+// withDynamic flags a queue created by EnqueueTo in the registry, so
+// WithDropEmptyAfter may drop it once it is empty
+func withDynamic() Option {
+	return func(q *Queue) {
+		q.dynamic = true
+	}
+}
+
+// stopGC stops the background collection and waits for a running pass to finish
+func (m *Manager) stopGC() {
+	if m.stop == nil {
+		return
+	}
+
+	close(m.stop)
+	m.wg.Wait()
+	m.stop = nil
+}
+
+// dropEmptyQueues drops the queues that have been empty and untouched for the
+// WithDropEmptyAfter period. A queue is marked when it is first seen empty, along
+// with its seq counter; any enqueue since then moves the counter and resets the mark.
+// Only the queues created by EnqueueTo and never opened with NewQueue or
+// NewPriorityQueue are dropped, by any process.
+// Returns the names of the dropped queues
+func (m *Manager) dropEmptyQueues() (dropped []string, err error) {
+	defer func() { err = mapError(err) }()
+
+	if m.dropEmptyAfter <= 0 || m.closed.Load() {
+		return nil, nil
+	}
+
+	rows, err := m.client.Query(
+		fmt.Sprintf("SELECT name, empty_since, empty_seq FROM %s WHERE kind IN (?, ?) AND dynamic = 1", quoteIdent(registryTable)),
+		kindQueue, kindPriorityQueue,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	type entry struct {
+		name       string
+		emptySince sql.NullTime
+		emptySeq   sql.NullInt64
+	}
+
+	var entries []entry
+	for rows.Next() {
+		var e entry
+		if err := rows.Scan(&e.name, &e.emptySince, &e.emptySeq); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+
+	for _, e := range entries {
+		if m.heldOpen(e.name) {
+			continue
+		}
+
+		empty, seq, err := m.emptyState(e.name)
+		if err != nil {
+			return dropped, err
+		}
+
+		switch {
+		case !empty:
+			_, err = m.client.Exec(
+				fmt.Sprintf("UPDATE %s SET empty_since = NULL, empty_seq = NULL WHERE name = ?", quoteIdent(registryTable)),
+				e.name,
+			)
+		case !e.emptySince.Valid || !e.emptySeq.Valid || e.emptySeq.Int64 != seq:
+			_, err = m.client.Exec(
+				fmt.Sprintf("UPDATE %s SET empty_since = ?, empty_seq = ? WHERE name = ?", quoteIdent(registryTable)),
+				now, seq, e.name,
+			)
+		case now.Sub(e.emptySince.Time) >= m.dropEmptyAfter:
+			var ok bool
+			if ok, err = m.dropIfEmpty(e.name, seq); ok {
+				dropped = append(dropped, e.name)
+			}
+		}
+
+		if err != nil {
+			return dropped, err
+		}
+	}
+
+	return dropped, nil
+}
+
+// heldOpen reports whether a queue was opened by the application and is still open
+// Queues opened implicitly by EnqueueTo don't count
+func (m *Manager) heldOpen(name string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, q := range m.open {
+		if q.tableName == name && !q.dynamic && !q.closed.Load() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// emptyState reports whether a queue table has no rows, and its seq counter
+func (m *Manager) emptyState(name string) (empty bool, seq int64, err error) {
+	var exists bool
+	err = m.client.QueryRow(fmt.Sprintf("SELECT NOT EXISTS (SELECT 1 FROM %s)", quoteIdent(name))).Scan(&empty)
+	if err != nil {
+		// A table dropped behind the registry's back counts as empty
+		if err := m.client.QueryRow("SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = ?)", name).Scan(&exists); err == nil && !exists {
+			return true, 0, nil
+		}
+		return false, 0, err
+	}
+
+	err = m.client.QueryRow(
+		fmt.Sprintf("SELECT seq FROM %s WHERE queue = ?", quoteIdent(sequencesTable)), name,
+	).Scan(&seq)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = nil
+	}
+
+	return empty, seq, err
+}
+
+// dropIfEmpty drops a queue and its registry entry, provided it is still empty and
+// untouched. An open queue created by EnqueueTo is closed and forgotten.
+// Reports whether the queue was dropped
+func (m *Manager) dropIfEmpty(name string, seq int64) (dropped bool, err error) {
+	var detached []*Queue
+	// Closed once m.mu is released, see detach
+	defer func() { closeQueues(detached) }()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tx, err := m.client.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if err != nil || !dropped {
+			tx.Rollback()
+		}
+	}()
+
+	// Check again within the transaction, so a concurrent enqueue keeps the queue
+	var current int64
+	err = tx.QueryRow(fmt.Sprintf("SELECT COALESCE((SELECT seq FROM %s WHERE queue = ?), 0)", quoteIdent(sequencesTable)), name).Scan(&current)
+	if err != nil || current != seq {
+		return false, err
+	}
+
+	var hasTable bool
+	if err = tx.QueryRow("SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = ?)", name).Scan(&hasTable); err != nil {
+		return false, err
+	}
+
+	if hasTable {
+		var empty bool
+		if err = tx.QueryRow(fmt.Sprintf("SELECT NOT EXISTS (SELECT 1 FROM %s)", quoteIdent(name))).Scan(&empty); err != nil || !empty {
+			return false, err
+		}
+	}
+
+	if err = dropTable(tx, name); err != nil {
+		return false, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return false, err
+	}
+	dropped = true
+
+	detached = m.detach(name, fmt.Errorf("%w %q: dropped while empty", ErrUnknownQueue, name))
+
+	return true, nil
+}
+
+// dropTable drops a queue table within tx, along with its seq counter, epoch,
+// fairness turns and registry entry
+func dropTable(tx *sql.Tx, name string) error {
+	statements := []struct {
+		query string
+		args  []any
+	}{
+		{fmt.Sprintf("DROP TABLE IF EXISTS %s", quoteIdent(name)), nil},
+		{fmt.Sprintf("DELETE FROM %s WHERE queue = ?", quoteIdent(sequencesTable)), []any{name}},
+		{fmt.Sprintf("DELETE FROM %s WHERE queue = ?", quoteIdent(epochsTable)), []any{name}},
+		{fmt.Sprintf("DELETE FROM %s WHERE queue = ?", quoteIdent(fairnessTable)), []any{name}},
+		{fmt.Sprintf("DELETE FROM %s WHERE name = ?", quoteIdent(registryTable)), []any{name}},
+	}
+
+	for _, s := range statements {
+		if _, err := tx.Exec(s.query, s.args...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// detach stops tracking the open queues of a dropped table and returns them, to be
+// closed with closeQueues once m.mu is released: closing waits for the background
+// loops of the queues, whose hooks may call back into the manager. Reopening them
+// fails with reason.
+// The caller holds m.mu
+func (m *Manager) detach(name string, reason error) []*Queue {
+	var detached []*Queue
+	open := m.open[:0]
+	for _, q := range m.open {
+		if q.tableName == name {
+			q.detached = reason
+			detached = append(detached, q)
+			continue
+		}
+		open = append(open, q)
+	}
+	m.open = open
+
+	return detached
+}
+
+// closeQueues closes the queues returned by detach
+func closeQueues(queues []*Queue) {
+	for _, q := range queues {
+		q.Close()
+	}
+}
@@ -0,0 +1,118 @@
+package sqliteq
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestDropEmptyAfter(t *testing.T) {
+	dbPath := "test_drop_empty.db"
+	defer os.Remove(dbPath)
+
+	// Without the option no collector runs in the background, so passes are run by hand
+	queues, err := NewManager(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	defer queues.Close()
+	queues.dropEmptyAfter = 20 * time.Millisecond
+
+	queues.EnqueueTo("device_1", "reading")
+	queues.EnqueueTo("device_2", "reading")
+
+	held, err := queues.NewQueue("held")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	drained, _ := queues.queue("device_1", true)
+	drained.Dequeue()
+
+	// The first pass marks the empty queues
+	if dropped, err := queues.dropEmptyQueues(); err != nil || len(dropped) != 0 {
+		t.Fatalf("Expected nothing dropped on the first pass, got %v (%v)", dropped, err)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+
+	dropped, err := queues.dropEmptyQueues()
+	if err != nil {
+		t.Fatalf("dropEmptyQueues failed: %v", err)
+	}
+
+	if len(dropped) != 1 || dropped[0] != "device_1" {
+		t.Errorf("Expected device_1 to be dropped, got %v", dropped)
+	}
+
+	var tables int
+	queues.client.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'device_1'").Scan(&tables)
+	if tables != 0 {
+		t.Error("Expected the device_1 table to be dropped")
+	}
+
+	if held.closed.Load() {
+		t.Error("Expected the held queue to stay open")
+	}
+
+	// Enqueueing again recreates the queue
+	if err := queues.EnqueueTo("device_1", "reading"); err != nil {
+		t.Fatalf("EnqueueTo after drop failed: %v", err)
+	}
+
+	q, _ := queues.queue("device_1", true)
+	if q.Len() != 1 {
+		t.Errorf("Expected 1 item in the recreated queue, got %d", q.Len())
+	}
+
+	t.Run("TouchedQueueIsKept", func(t *testing.T) {
+		q, _ := queues.queue("device_1", true)
+		q.Dequeue()
+		queues.dropEmptyQueues()
+
+		// Activity between passes moves the seq counter and resets the mark
+		q.Enqueue("reading")
+		q.Dequeue()
+		time.Sleep(30 * time.Millisecond)
+
+		if dropped, _ := queues.dropEmptyQueues(); len(dropped) != 0 {
+			t.Errorf("Expected the touched queue to be kept, got %v", dropped)
+		}
+	})
+
+	t.Run("StaticQueuesAreKept", func(t *testing.T) {
+		// Empty queues opened by the application are kept even once closed, and so
+		// are dynamic ones it opened since
+		static, err := queues.NewQueue("static")
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+		static.Close()
+
+		queues.EnqueueTo("device_3", "reading")
+		adopted, err := queues.NewQueue("device_3")
+		if err != nil {
+			t.Fatalf("Failed to open queue: %v", err)
+		}
+		adopted.Dequeue()
+		adopted.Close()
+
+		queues.dropEmptyQueues()
+		time.Sleep(30 * time.Millisecond)
+
+		dropped, err := queues.dropEmptyQueues()
+		if err != nil {
+			t.Fatalf("dropEmptyQueues failed: %v", err)
+		}
+
+		for _, name := range dropped {
+			if name == "static" || name == "device_3" || name == "held" {
+				t.Errorf("Expected %s to be kept, got %v dropped", name, dropped)
+			}
+		}
+
+		if exists, _ := queues.Exists("static"); !exists {
+			t.Error("Expected the static queue to still exist")
+		}
+	})
+}
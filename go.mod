@@ -3,5 +3,3 @@ module github.com/goptics/sqliteq
 go 1.20
 
 require github.com/mattn/go-sqlite3 v1.14.28
-
-require github.com/lucsky/cuid v1.2.1
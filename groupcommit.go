@@ -0,0 +1,240 @@
+package sqliteq
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// EnqueueToken identifies an item enqueued with EnqueueAsync
+type EnqueueToken uint64
+
+// groupCommit batches asynchronous enqueues into shared transactions
+type groupCommit struct {
+	maxDelay time.Duration
+	maxBatch int
+
+	// flushMu serializes flushes so batches commit in token order
+	flushMu sync.Mutex
+
+	mu      sync.Mutex
+	pending []Message
+	last    EnqueueToken
+	// closed is set once the queue's final flush ran, refusing further items
+	closed bool
+	// durable is the last token whose batch was committed or failed
+	durable EnqueueToken
+	// failed holds the token ranges of failed batches in ascending order, one
+	// entry per run of batches failing with the same error
+	failed []failedTokens
+	// changed is closed and replaced whenever durable advances
+	changed chan struct{}
+}
+
+// failedTokens is a range of tokens whose items weren't committed
+type failedTokens struct {
+	first, last EnqueueToken
+	err         error
+}
+
+func newGroupCommit(maxDelay time.Duration, maxBatch int) *groupCommit {
+	return &groupCommit{
+		maxDelay: maxDelay,
+		maxBatch: maxBatch,
+		changed:  make(chan struct{}),
+	}
+}
+
+// fail records that the items of tokens first to last weren't committed and marks
+// them durable. The caller holds g.mu.
+func (g *groupCommit) fail(first, last EnqueueToken, err error) {
+	if n := len(g.failed); n > 0 && g.failed[n-1].last+1 == first && g.failed[n-1].err.Error() == err.Error() {
+		g.failed[n-1].last = last
+	} else {
+		g.failed = append(g.failed, failedTokens{first: first, last: last, err: err})
+	}
+
+	g.advance(last)
+}
+
+// advance marks the tokens up to last durable and wakes the waiters. The caller
+// holds g.mu.
+func (g *groupCommit) advance(last EnqueueToken) {
+	g.durable = last
+	close(g.changed)
+	g.changed = make(chan struct{})
+}
+
+// failure returns the error that kept the item of token from being committed, nil
+// if it was committed. The caller holds g.mu.
+func (g *groupCommit) failure(token EnqueueToken) error {
+	i := sort.Search(len(g.failed), func(i int) bool { return g.failed[i].last >= token })
+	if i < len(g.failed) && g.failed[i].first <= token {
+		return g.failed[i].err
+	}
+
+	return nil
+}
+
+// EnqueueAsync adds an item without waiting for it to be committed. With
+// WithGroupCommit, items are collected and committed together in one transaction,
+// trading per-item durability for throughput; WaitDurable confirms a given item was
+// committed. Without it, the item is committed before EnqueueAsync returns.
+// Payload conversion and interceptors run immediately and report their errors here.
+func (q *Queue) EnqueueAsync(item any) (EnqueueToken, error) {
+	return q.enqueueAsync(item, Message{})
+}
+
+// enqueueAsync queues a message for the next group commit
+func (q *Queue) enqueueAsync(item any, m Message) (token EnqueueToken, err error) {
+	if q.groupCommit == nil {
+		return 0, q.enqueueMessage(item, m)
+	}
+
+	defer func() { err = mapError(err) }()
+
+	if q.closed.Load() {
+		return 0, ErrClosed
+	}
+
+	if m.Data, err = payloadBytes(item); err != nil {
+		return 0, err
+	}
+
+	m.Status = StatusPending
+	if err := q.intercept(&m); err != nil {
+		return 0, err
+	}
+
+	g := q.groupCommit
+	g.mu.Lock()
+	// Checked under the lock, so no item is added after the final flush of Close
+	if g.closed {
+		g.mu.Unlock()
+		return 0, ErrClosed
+	}
+	g.last++
+	token = g.last
+	g.pending = append(g.pending, m)
+	full := len(g.pending) >= g.maxBatch
+	g.mu.Unlock()
+
+	if full {
+		q.flushAsync()
+	}
+
+	return token, nil
+}
+
+// WaitDurable blocks until the item identified by token has been committed, and
+// returns the error that prevented it otherwise. Tokens of zero, returned when
+// group commit is disabled, are durable already.
+func (q *Queue) WaitDurable(ctx context.Context, token EnqueueToken) error {
+	g := q.groupCommit
+	if g == nil || token == 0 {
+		return nil
+	}
+
+	for {
+		g.mu.Lock()
+		durable, changed := g.durable, g.changed
+		err := g.failure(token)
+		g.mu.Unlock()
+
+		if token <= durable {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// flushAsync commits the pending asynchronous enqueues in a single transaction
+func (q *Queue) flushAsync() {
+	g := q.groupCommit
+	g.flushMu.Lock()
+	defer g.flushMu.Unlock()
+
+	g.mu.Lock()
+	batch := g.pending
+	g.pending = nil
+	last := g.last
+	g.mu.Unlock()
+
+	if len(batch) == 0 {
+		return
+	}
+
+	err := q.insertBatch(batch)
+
+	g.mu.Lock()
+	if err != nil {
+		g.fail(last-EnqueueToken(len(batch))+1, last, err)
+	} else {
+		g.advance(last)
+	}
+	g.mu.Unlock()
+}
+
+// closeAsync commits the pending asynchronous enqueues one last time and refuses
+// further ones. Items added while the final flush ran fail with ErrClosed.
+func (q *Queue) closeAsync() {
+	q.flushAsync()
+
+	g := q.groupCommit
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.closed = true
+	if len(g.pending) > 0 {
+		g.fail(g.last-EnqueueToken(len(g.pending))+1, g.last, ErrClosed)
+		g.pending = nil
+	}
+}
+
+// insertBatch stores messages in one transaction
+func (q *Queue) insertBatch(batch []Message) (err error) {
+	defer func() { err = mapError(err) }()
+	defer q.invalidateStats()
+	defer q.invalidateFront()
+	defer q.observeWrite(time.Now(), &err)
+
+	if err = q.manager.awaitLease(); err != nil {
+		return err
+	}
+
+	tx, err := q.client.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// The group commit can't wait for room, so OverflowBlock rejects the batch
+	if err = q.makeRoom(tx, len(batch), false); err != nil {
+		return err
+	}
+
+	for i := range batch {
+		if err = q.insert(tx, &batch[i]); err != nil {
+			return err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	q.enqueueRate.observe(len(batch), time.Now())
+	q.notifyEnqueued(batch...)
+
+	return nil
+}
@@ -0,0 +1,135 @@
+package sqliteq
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestGroupCommit(t *testing.T) {
+	dbPath := "test_group_commit.db"
+	defer os.Remove(dbPath)
+
+	queues := New(dbPath)
+	defer queues.Close()
+
+	t.Run("WaitDurable", func(t *testing.T) {
+		q, err := queues.NewQueue("group_commit", WithGroupCommit(20*time.Millisecond, 100))
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+
+		var last EnqueueToken
+		for i := 0; i < 10; i++ {
+			if last, err = q.EnqueueAsync(i); err != nil {
+				t.Fatalf("EnqueueAsync failed: %v", err)
+			}
+		}
+
+		if q.Len() != 0 {
+			t.Errorf("Expected items to wait for the group commit, got length %d", q.Len())
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+
+		if err := q.WaitDurable(ctx, last); err != nil {
+			t.Fatalf("WaitDurable failed: %v", err)
+		}
+
+		if q.Len() != 10 {
+			t.Errorf("Expected 10 committed items, got %d", q.Len())
+		}
+
+		item, _ := q.Dequeue()
+		if string(item.([]byte)) != "0" {
+			t.Errorf("Expected items in order, got %s first", item)
+		}
+	})
+
+	t.Run("FullBatch", func(t *testing.T) {
+		q, err := queues.NewQueue("group_commit_full", WithGroupCommit(time.Hour, 3))
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+
+		for i := 0; i < 3; i++ {
+			q.EnqueueAsync(i)
+		}
+
+		if q.Len() != 3 {
+			t.Errorf("Expected a full batch to be committed at once, got length %d", q.Len())
+		}
+
+		q.EnqueueAsync("leftover")
+		q.Close()
+		q.Reopen()
+
+		if q.Len() != 4 {
+			t.Errorf("Expected Close to commit the leftover item, got length %d", q.Len())
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		q, err := queues.NewQueue("group_commit_disabled")
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+
+		token, err := q.EnqueueAsync("item")
+		if err != nil || q.WaitDurable(context.Background(), token) != nil || q.Len() != 1 {
+			t.Errorf("Expected a synchronous enqueue, got %v (length %d)", err, q.Len())
+		}
+	})
+	t.Run("Closed", func(t *testing.T) {
+		q, err := queues.NewQueue("group_commit_closed", WithGroupCommit(time.Hour, 100))
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+
+		token, _ := q.EnqueueAsync("committed")
+		q.Close()
+
+		if _, err := q.EnqueueAsync("late"); !errors.Is(err, ErrClosed) {
+			t.Errorf("Expected ErrClosed after Close, got %v", err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+
+		if err := q.WaitDurable(ctx, token); err != nil {
+			t.Errorf("Expected the item enqueued before Close to be committed, got %v", err)
+		}
+
+		q.Reopen()
+		if _, err := q.EnqueueAsync("reopened"); err != nil {
+			t.Errorf("Expected EnqueueAsync to work again after Reopen, got %v", err)
+		}
+	})
+
+	t.Run("FailedRanges", func(t *testing.T) {
+		g := newGroupCommit(time.Hour, 100)
+		busy := errors.New("busy")
+
+		g.fail(1, 3, busy)
+		g.fail(4, 6, errors.New("busy"))
+		g.advance(8)
+		g.fail(9, 9, ErrClosed)
+
+		if len(g.failed) != 2 {
+			t.Errorf("Expected consecutive failures to be merged, got %d ranges", len(g.failed))
+		}
+
+		for token, expected := range map[EnqueueToken]string{1: "busy", 6: "busy", 7: "", 9: ErrClosed.Error()} {
+			var got string
+			if err := g.failure(token); err != nil {
+				got = err.Error()
+			}
+			if got != expected {
+				t.Errorf("Expected %q for token %d, got %q", expected, token, got)
+			}
+		}
+	})
+}
@@ -0,0 +1,49 @@
+package sqliteq
+
+import (
+	"database/sql"
+	"encoding/json"
+)
+
+// EnqueueWithHeaders adds an item carrying headers, key/value metadata such as
+// tracing IDs, content types or routing hints kept apart from the payload and
+// returned in Message.Headers. Headers follow the item when it repeats or is moved
+// to a dead letter queue.
+// Returns true if the operation was successful
+func (q *Queue) EnqueueWithHeaders(item any, headers map[string]string) bool {
+	return q.succeeded("enqueue", q.enqueueMessage(item, Message{Headers: headers}))
+}
+
+// EnqueueWithHeaders adds an item with a specified priority carrying headers
+// Returns true if the operation was successful
+func (pq *PriorityQueue) EnqueueWithHeaders(item any, priority int, headers map[string]string) bool {
+	return pq.succeeded("enqueue", pq.enqueueMessage(item, Message{Priority: priority, Headers: headers}))
+}
+
+// encodeHeaders stores headers as a JSON object, or NULL when there are none
+func encodeHeaders(headers map[string]string) (sql.NullString, error) {
+	if len(headers) == 0 {
+		return sql.NullString{}, nil
+	}
+
+	data, err := json.Marshal(headers)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
+
+// decodeHeaders reads headers stored by encodeHeaders, nil when there are none
+func decodeHeaders(stored sql.NullString) (map[string]string, error) {
+	if !stored.Valid || stored.String == "" {
+		return nil, nil
+	}
+
+	var headers map[string]string
+	if err := json.Unmarshal([]byte(stored.String), &headers); err != nil {
+		return nil, err
+	}
+
+	return headers, nil
+}
@@ -0,0 +1,77 @@
+package sqliteq
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestEnqueueWithHeaders(t *testing.T) {
+	dbPath := "test_headers.db"
+	defer os.Remove(dbPath)
+
+	queues := New(dbPath)
+	defer queues.Close()
+
+	q, err := queues.NewQueue("events", WithDeadLetterQueue("events_dlq", 1))
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	headers := map[string]string{"trace-id": "abc123", "content-type": "application/json"}
+	if !q.EnqueueWithHeaders(`{"id":1}`, headers) {
+		t.Fatal("EnqueueWithHeaders failed")
+	}
+	q.Enqueue("plain")
+
+	m, err := q.DequeueMessageWithAckId()
+	if err != nil {
+		t.Fatalf("Dequeue failed: %v", err)
+	}
+
+	if len(m.Headers) != 2 || m.Headers["trace-id"] != "abc123" || m.Headers["content-type"] != "application/json" {
+		t.Errorf("Expected the headers back, got %v", m.Headers)
+	}
+
+	if plain, _ := q.DequeueMessage(); plain.Headers != nil {
+		t.Errorf("Expected no headers on a plain item, got %v", plain.Headers)
+	}
+
+	t.Run("DeadLetter", func(t *testing.T) {
+		q.Nack(m.AckID)
+		m, _ = q.DequeueMessageWithAckId()
+		q.Nack(m.AckID)
+
+		dlq, err := queues.NewQueue("events_dlq")
+		if err != nil {
+			t.Fatalf("Failed to open dead-letter queue: %v", err)
+		}
+
+		messages, err := dlq.Page(0, 10)
+		if err != nil || len(messages) != 1 || messages[0].Headers["trace-id"] != "abc123" {
+			t.Errorf("Expected the dead letter to keep its headers, got %+v, %v", messages, err)
+		}
+	})
+
+	t.Run("Repeating", func(t *testing.T) {
+		pq, err := queues.NewPriorityQueue("reports")
+		if err != nil {
+			t.Fatalf("Failed to create priority queue: %v", err)
+		}
+
+		pq.enqueueMessage("daily", Message{Priority: 1, RepeatEvery: time.Hour, Headers: map[string]string{"tenant": "acme"}})
+
+		m, err := pq.DequeueMessageWithAckId()
+		if err != nil {
+			t.Fatalf("Dequeue failed: %v", err)
+		}
+		if err := pq.Ack(m.AckID); err != nil {
+			t.Fatalf("Ack failed: %v", err)
+		}
+
+		messages, err := pq.Page(0, 10)
+		if err != nil || len(messages) != 1 || messages[0].Headers["tenant"] != "acme" {
+			t.Errorf("Expected the next occurrence to keep its headers, got %+v, %v", messages, err)
+		}
+	})
+}
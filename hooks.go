@@ -0,0 +1,83 @@
+package sqliteq
+
+// Hooks are called after the writes of a queue are committed, so metrics, auditing
+// or payload decoding can be added without wrapping every call. Unset hooks are
+// skipped. Hooks run on the goroutine of the operation, which waits for them, and
+// aren't called for writes that fail or roll back. Background tasks such as the
+// visibility timeout call them too.
+type Hooks struct {
+	// OnEnqueue is called with each item once it is stored, after the enqueue
+	// interceptors ran; it must not modify the payload. Items added with EnqueueTx,
+	// whose commit the queue doesn't see, with Import or by moves between queues are
+	// left out.
+	OnEnqueue func(m Message)
+	// OnDequeue is called with each dequeued item before it is returned, and may
+	// modify it, e.g. to decode a payload encoded by an enqueue interceptor
+	OnDequeue func(m *Message)
+	// OnAck is called with the ack ID of each acknowledged item
+	OnAck func(ackID string)
+	// OnRequeue is called with the number of items returned to pending at once,
+	// whether handed back with Nack, retried by the retry policy, reclaimed after
+	// their visibility timeout or when the queue was opened, or fenced by BumpEpoch
+	OnRequeue func(count int64)
+}
+
+// WithHooks adds hooks called after the queue's writes. Hooks added by several
+// calls run in the order they were added.
+func WithHooks(hooks Hooks) Option {
+	return func(q *Queue) {
+		q.hooks = append(q.hooks, hooks)
+	}
+}
+
+// notifyEnqueued calls the OnEnqueue hooks with committed items
+func (q *Queue) notifyEnqueued(messages ...Message) {
+	for _, h := range q.hooks {
+		if h.OnEnqueue == nil {
+			continue
+		}
+
+		for _, m := range messages {
+			h.OnEnqueue(m)
+		}
+	}
+}
+
+// notifyDequeued calls the OnDequeue hooks with claimed items, which they may modify
+func (q *Queue) notifyDequeued(messages []Message) {
+	for _, h := range q.hooks {
+		if h.OnDequeue == nil {
+			continue
+		}
+
+		for i := range messages {
+			h.OnDequeue(&messages[i])
+		}
+	}
+}
+
+// notifyAcked calls the OnAck hooks with the ack IDs of acknowledged items
+func (q *Queue) notifyAcked(ackIDs ...string) {
+	for _, h := range q.hooks {
+		if h.OnAck == nil {
+			continue
+		}
+
+		for _, ackID := range ackIDs {
+			h.OnAck(ackID)
+		}
+	}
+}
+
+// notifyRequeued calls the OnRequeue hooks when items returned to pending
+func (q *Queue) notifyRequeued(count int64) {
+	if count == 0 {
+		return
+	}
+
+	for _, h := range q.hooks {
+		if h.OnRequeue != nil {
+			h.OnRequeue(count)
+		}
+	}
+}
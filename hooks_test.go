@@ -0,0 +1,69 @@
+package sqliteq
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestWithHooks(t *testing.T) {
+	dbPath := "test_hooks.db"
+	defer os.Remove(dbPath)
+
+	queues := New(dbPath)
+	defer queues.Close()
+
+	var events []string
+	q, err := queues.NewQueue("jobs",
+		WithHooks(Hooks{
+			OnEnqueue: func(m Message) { events = append(events, "enqueue "+string(m.Data)) },
+			OnDequeue: func(m *Message) {
+				events = append(events, "dequeue "+string(m.Data))
+				m.Data = bytes.ToUpper(m.Data)
+			},
+			OnAck:     func(ackID string) { events = append(events, "ack") },
+			OnRequeue: func(count int64) { events = append(events, "requeue") },
+		}),
+		WithHooks(Hooks{
+			OnDequeue: func(m *Message) { events = append(events, "then "+string(m.Data)) },
+		}),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	q.Enqueue("first")
+	q.Enqueue("second")
+
+	m, err := q.DequeueMessageWithAckId()
+	if err != nil {
+		t.Fatalf("Dequeue failed: %v", err)
+	}
+	if string(m.Data) != "FIRST" {
+		t.Errorf("Expected the payload modified by OnDequeue, got %q", m.Data)
+	}
+
+	if err := q.Nack(m.AckID); err != nil {
+		t.Fatalf("Nack failed: %v", err)
+	}
+
+	_, _, ackID := q.DequeueWithAckId()
+	if !q.Acknowledge(ackID) {
+		t.Fatal("Acknowledge failed")
+	}
+
+	// Failed writes aren't reported
+	q.Acknowledge("unknown")
+
+	expected := []string{
+		"enqueue first", "enqueue second",
+		"dequeue first", "then FIRST",
+		"requeue",
+		"dequeue first", "then FIRST",
+		"ack",
+	}
+	if !reflect.DeepEqual(events, expected) {
+		t.Errorf("Expected %v, got %v", expected, events)
+	}
+}
@@ -0,0 +1,50 @@
+package sqliteq
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"time"
+)
+
+// crockford is the alphabet of ULIDs, Crockford's base32 without I, L, O and U
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// IDGenerator generates the ack IDs of claimed items and the IDs write leases are
+// held under, see WithIDGenerator. IDs are opaque and must be unique across every
+// process sharing the database.
+type IDGenerator interface {
+	NewID() string
+}
+
+// ulidGenerator is the default IDGenerator, generating ULIDs with newID
+type ulidGenerator struct{}
+
+func (ulidGenerator) NewID() string { return newID() }
+
+// newID returns a new unique ID for ack IDs, lease holders and event IDs: a ULID,
+// 26 characters encoding a millisecond timestamp followed by 80 random bits, so IDs
+// sort by creation time. IDs are opaque, and those of earlier versions, which were
+// cuids, stay valid in existing databases.
+func newID() string {
+	var b [16]byte
+	ms := uint64(time.Now().UnixMilli())
+	binary.BigEndian.PutUint16(b[0:2], uint16(ms>>32))
+	binary.BigEndian.PutUint32(b[2:6], uint32(ms))
+
+	if _, err := rand.Read(b[6:]); err != nil {
+		// The system's random source failing leaves nothing safe to fall back on
+		panic("sqliteq: failed to read random bytes: " + err.Error())
+	}
+
+	// 128 bits in 26 characters of 5 bits, the first one holding the top 3 bits
+	hi, lo := binary.BigEndian.Uint64(b[0:8]), binary.BigEndian.Uint64(b[8:16])
+
+	var id [26]byte
+	for i := 25; i >= 0; i-- {
+		id[i] = crockford[lo&31]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+
+	return string(id[:])
+}
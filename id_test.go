@@ -0,0 +1,66 @@
+package sqliteq
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewID(t *testing.T) {
+	seen := make(map[string]bool)
+
+	for i := 0; i < 1000; i++ {
+		id := newID()
+
+		if len(id) != 26 || strings.Trim(id, crockford) != "" {
+			t.Fatalf("Expected a 26 character ULID, got %q", id)
+		}
+
+		if seen[id] {
+			t.Fatalf("Expected unique IDs, got %q twice", id)
+		}
+		seen[id] = true
+	}
+
+	// IDs sort by creation time, at millisecond resolution
+	first := newID()
+	time.Sleep(2 * time.Millisecond)
+	if second := newID(); second <= first {
+		t.Errorf("Expected %q to sort after %q", second, first)
+	}
+}
+
+// sequence generates IDs from a counter
+type sequence struct{ n int }
+
+func (s *sequence) NewID() string {
+	s.n++
+	return fmt.Sprintf("id-%d", s.n)
+}
+
+func TestWithIDGenerator(t *testing.T) {
+	dbPath := "test_id_generator.db"
+	defer os.Remove(dbPath)
+
+	queues := New(dbPath, WithIDGenerator(&sequence{}), WithWriteLease(time.Second))
+	defer queues.Close()
+
+	q, err := queues.NewQueue("jobs")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	q.Enqueue("item")
+
+	// The lease holder was generated first
+	_, _, ackID := q.DequeueWithAckId()
+	if ackID != "id-2" {
+		t.Errorf("Expected the ack ID from the generator, got %q", ackID)
+	}
+
+	if !q.Acknowledge(ackID) {
+		t.Error("Expected the generated ack ID to be acknowledged")
+	}
+}
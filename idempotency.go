@@ -0,0 +1,98 @@
+package sqliteq
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// idempotencyTable records the dedup keys claimed by EnqueueIdempotent for all queues
+const idempotencyTable = "sqliteq_idempotency"
+
+// errDuplicate reports an idempotency key that was already claimed
+var errDuplicate = errors.New("duplicate idempotency key")
+
+// initIdempotencyTable creates the idempotency table if it doesn't exist
+func initIdempotencyTable(db *sql.DB) error {
+	_, err := db.Exec(fmt.Sprintf(`
+	CREATE TABLE IF NOT EXISTS %[1]s (
+		namespace TEXT NOT NULL,
+		key TEXT NOT NULL,
+		queue TEXT NOT NULL,
+		item_id INTEGER NOT NULL,
+		created_at TIMESTAMP NOT NULL,
+		expires_at TIMESTAMP,
+		PRIMARY KEY (namespace, key)
+	);
+	CREATE INDEX IF NOT EXISTS %[2]s ON %[1]s (expires_at) WHERE expires_at IS NOT NULL;
+	`, quoteIdent(idempotencyTable), quoteIdent(idempotencyTable+"_expires_idx")))
+
+	return err
+}
+
+// EnqueueIdempotent adds an item unless key was already claimed in the queue's
+// idempotency namespace, by this or any other queue in the database sharing it.
+// Keys are kept for the TTL set with WithIdempotency, forever without it.
+// Returns true if the item was enqueued and false if it was a duplicate
+func (q *Queue) EnqueueIdempotent(item any, key string) (bool, error) {
+	return q.enqueueIdempotent(item, Message{}, key)
+}
+
+// enqueueIdempotent inserts an item and claims key in the same transaction
+func (q *Queue) enqueueIdempotent(item any, m Message, key string) (bool, error) {
+	err := q.enqueueMessage(item, m, func(tx *sql.Tx, m *Message) error {
+		return q.claimKey(tx, key, m.ID)
+	})
+	if errors.Is(err, errDuplicate) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// claimKey records key for the item, failing with errDuplicate while an unexpired
+// claim exists
+func (q *Queue) claimKey(tx *sql.Tx, key string, id int64) error {
+	now := q.now()
+
+	var expires sql.NullTime
+	if q.idempotencyTTL > 0 {
+		expires = sql.NullTime{Time: now.Add(q.idempotencyTTL), Valid: true}
+	}
+
+	// An expired claim is taken over; a live one leaves the row unchanged
+	result, err := tx.Exec(fmt.Sprintf(`
+	INSERT INTO %s (namespace, key, queue, item_id, created_at, expires_at) VALUES (?, ?, ?, ?, ?, ?)
+	ON CONFLICT (namespace, key) DO UPDATE SET
+		queue = excluded.queue, item_id = excluded.item_id,
+		created_at = excluded.created_at, expires_at = excluded.expires_at
+	WHERE expires_at IS NOT NULL AND expires_at <= ?
+	`, quoteIdent(idempotencyTable)), q.idempotencyNamespace, key, q.tableName, id, now, expires, q.expiryNow())
+	if err != nil {
+		return err
+	}
+
+	claimed, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if claimed == 0 {
+		return errDuplicate
+	}
+
+	return nil
+}
+
+// pruneIdempotencyKeys deletes expired idempotency keys of every namespace
+// Returns the number of deleted keys
+func (q *Queue) pruneIdempotencyKeys() (int64, error) {
+	now := q.expiryNow()
+
+	return q.inBatches(func(limit int) (sql.Result, error) {
+		return q.client.Exec(
+			fmt.Sprintf("DELETE FROM %[1]s WHERE rowid IN (SELECT rowid FROM %[1]s WHERE expires_at IS NOT NULL AND expires_at <= ? LIMIT ?)", quoteIdent(idempotencyTable)),
+			now, limit,
+		)
+	})
+}
@@ -0,0 +1,76 @@
+package sqliteq
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestEnqueueIdempotent(t *testing.T) {
+	dbPath := "test_idempotency.db"
+	defer os.Remove(dbPath)
+
+	queues := New(dbPath)
+	defer queues.Close()
+
+	t.Run("AcrossQueues", func(t *testing.T) {
+		webhooks, err := queues.NewQueue("idem_webhooks", WithIdempotency("events", 0))
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+
+		polling, err := queues.NewPriorityQueue("idem_polling", WithIdempotency("events", 0))
+		if err != nil {
+			t.Fatalf("Failed to create priority queue: %v", err)
+		}
+
+		if ok, err := webhooks.EnqueueIdempotent("event", "evt-1"); !ok || err != nil {
+			t.Fatalf("Expected first delivery to be enqueued, got %v, %v", ok, err)
+		}
+
+		if ok, err := polling.EnqueueIdempotent("event", 1, "evt-1"); ok || err != nil {
+			t.Errorf("Expected duplicate delivery to be skipped, got %v, %v", ok, err)
+		}
+
+		if webhooks.Len()+polling.Len() != 1 {
+			t.Errorf("Expected exactly one item, got %d", webhooks.Len()+polling.Len())
+		}
+
+		other, err := queues.NewQueue("idem_other", WithIdempotency("other", 0))
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+
+		if ok, _ := other.EnqueueIdempotent("event", "evt-1"); !ok {
+			t.Error("Expected the key to be free in another namespace")
+		}
+	})
+
+	t.Run("ExpiresAfterTTL", func(t *testing.T) {
+		q, err := queues.NewQueue("idem_ttl", WithIdempotency("ttl", 20*time.Millisecond))
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+
+		q.EnqueueIdempotent("event", "evt-2")
+		if ok, _ := q.EnqueueIdempotent("event", "evt-2"); ok {
+			t.Error("Expected duplicate within the TTL to be skipped")
+		}
+
+		time.Sleep(60 * time.Millisecond)
+
+		var keys int
+		q.client.QueryRow("SELECT COUNT(*) FROM sqliteq_idempotency WHERE namespace = 'ttl'").Scan(&keys)
+		if keys != 0 {
+			t.Errorf("Expected expired key to be pruned, got %d keys", keys)
+		}
+
+		if ok, _ := q.EnqueueIdempotent("event", "evt-2"); !ok {
+			t.Error("Expected the key to be reusable after the TTL")
+		}
+
+		if q.Len() != 2 {
+			t.Errorf("Expected 2 items, got %d", q.Len())
+		}
+	})
+}
@@ -0,0 +1,76 @@
+package sqliteq
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// idleAlert watches for pending items nobody dequeues, see WithIdleAlert
+type idleAlert struct {
+	after time.Duration
+	fn    func(queue string, idle time.Duration)
+	// since is when the queue was last seen making progress, in Unix nanoseconds:
+	// its last dequeue, or the last check finding no pending item
+	since atomic.Int64
+	// fired is set once fn was called, until the next dequeue
+	fired atomic.Bool
+}
+
+// WithIdleAlert calls fn once pending items have gone without a dequeue through
+// this queue for longer than d, the sign of consumers that silently died, and sets
+// Stats.Stalled until the next dequeue. fn gets the queue name and how long it has
+// been idle, and is called from a background goroutine once per idle stretch.
+// Like ETA, only dequeues made through this queue instance are observed, so use it
+// in the process running the consumers.
+func WithIdleAlert(d time.Duration, fn func(queue string, idle time.Duration)) Option {
+	return func(q *Queue) {
+		if d > 0 && fn != nil {
+			q.idleAlert = &idleAlert{after: d, fn: fn}
+		}
+	}
+}
+
+// interval returns how often the alert is checked
+func (a *idleAlert) interval() time.Duration {
+	if a.after < 4*time.Millisecond {
+		return time.Millisecond
+	}
+
+	return a.after / 4
+}
+
+// progressed records that the queue made progress at now, re-arming the alert
+func (a *idleAlert) progressed(now time.Time) {
+	a.since.Store(now.UnixNano())
+	a.fired.Store(false)
+}
+
+// observeDequeue re-arms the idle alert after a dequeue
+func (q *Queue) observeDequeue(now time.Time) {
+	if q.idleAlert != nil {
+		q.idleAlert.progressed(now)
+	}
+}
+
+// checkIdle fires the idle alert when ready items have waited past its limit
+// without a dequeue. An empty queue counts as progress, so items enqueued after a
+// quiet period get the full limit.
+func (q *Queue) checkIdle() {
+	a := q.idleAlert
+	now := time.Now()
+
+	if q.Len() == 0 {
+		a.since.Store(now.UnixNano())
+		return
+	}
+
+	idle := now.Sub(time.Unix(0, a.since.Load()))
+	if idle > a.after && a.fired.CompareAndSwap(false, true) {
+		a.fn(q.tableName, idle)
+	}
+}
+
+// stalled reports whether the idle alert fired since the last dequeue
+func (q *Queue) stalled() bool {
+	return q.idleAlert != nil && q.idleAlert.fired.Load()
+}
@@ -0,0 +1,62 @@
+package sqliteq
+
+import (
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestIdleAlert(t *testing.T) {
+	dbPath := "test_idle_alert.db"
+	defer os.Remove(dbPath)
+
+	queues := New(dbPath)
+	defer queues.Close()
+
+	var alerts atomic.Int32
+	alerted := make(chan time.Duration, 10)
+
+	q, err := queues.NewQueue("abandoned", WithIdleAlert(30*time.Millisecond, func(queue string, idle time.Duration) {
+		if queue != "abandoned" {
+			t.Errorf("Expected the alert for 'abandoned', got %q", queue)
+		}
+		alerts.Add(1)
+		alerted <- idle
+	}))
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	// An empty queue isn't abandoned, however long nothing is dequeued
+	time.Sleep(100 * time.Millisecond)
+	if n := alerts.Load(); n != 0 {
+		t.Fatalf("Expected no alert while the queue is empty, got %d", n)
+	}
+
+	q.Enqueue("waiting")
+
+	select {
+	case idle := <-alerted:
+		if idle < 30*time.Millisecond {
+			t.Errorf("Expected the alert after 30ms idle, got %v", idle)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected an idle alert")
+	}
+
+	if stats, _ := q.Stats(); !stats.Stalled {
+		t.Error("Expected Stats.Stalled once the alert fired")
+	}
+
+	// The alert fires once per idle stretch
+	time.Sleep(100 * time.Millisecond)
+	if n := alerts.Load(); n != 1 {
+		t.Errorf("Expected a single alert, got %d", n)
+	}
+
+	q.Dequeue()
+	if stats, _ := q.Stats(); stats.Stalled {
+		t.Error("Expected the dequeue to clear Stats.Stalled")
+	}
+}
@@ -0,0 +1,21 @@
+package sqliteq
+
+import "fmt"
+
+// EnqueueInterceptor inspects a message before it is stored
+// It may modify the message, e.g. to normalize or strip fields from the payload,
+// or return an error to reject it, in which case nothing is stored and the error is
+// reported to the producer. Data may alias the producer's slice: assign a new slice
+// instead of modifying it in place.
+type EnqueueInterceptor func(m *Message) error
+
+// intercept runs the configured interceptors in order, stopping at the first rejection
+func (q *Queue) intercept(m *Message) error {
+	for _, interceptor := range q.interceptors {
+		if err := interceptor(m); err != nil {
+			return fmt.Errorf("%w: %w", ErrRejected, err)
+		}
+	}
+
+	return nil
+}
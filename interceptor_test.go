@@ -0,0 +1,220 @@
+package sqliteq
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestEnqueueInterceptor(t *testing.T) {
+	dbPath := "test_interceptor.db"
+	defer os.Remove(dbPath)
+
+	errTooLarge := errors.New("payload too large")
+	var calls []string
+
+	queues := New(dbPath)
+	q, err := queues.NewQueue("test_queue",
+		WithEnqueueInterceptor(func(m *Message) error {
+			calls = append(calls, "limit")
+			if len(m.Data) > 8 {
+				return errTooLarge
+			}
+			return nil
+		}),
+		WithEnqueueInterceptor(func(m *Message) error {
+			calls = append(calls, "upper")
+			m.Data = bytes.ToUpper(m.Data)
+			return nil
+		}),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	defer queues.Close()
+
+	t.Run("Mutate", func(t *testing.T) {
+		payload := []byte("hello")
+		if !q.Enqueue(payload) {
+			t.Fatal("Enqueue failed")
+		}
+
+		if string(payload) != "hello" {
+			t.Errorf("Interceptor modified the producer's slice: %q", payload)
+		}
+
+		item, _ := q.Dequeue()
+		if string(item.([]byte)) != "HELLO" {
+			t.Errorf("Expected 'HELLO', got '%s'", string(item.([]byte)))
+		}
+
+		if len(calls) != 2 || calls[0] != "limit" || calls[1] != "upper" {
+			t.Errorf("Expected interceptors to run in order, got %v", calls)
+		}
+	})
+
+	t.Run("Reject", func(t *testing.T) {
+		calls = nil
+
+		if q.Enqueue([]byte("way too long")) {
+			t.Error("Enqueue should fail when an interceptor rejects")
+		}
+
+		err := q.enqueue([]byte("way too long"), 0)
+		if !errors.Is(err, ErrRejected) || !errors.Is(err, errTooLarge) {
+			t.Errorf("Expected ErrRejected wrapping the interceptor error, got %v", err)
+		}
+
+		if q.Len() != 0 {
+			t.Errorf("Expected nothing stored, got %d items", q.Len())
+		}
+
+		// Later interceptors are skipped after a rejection
+		for _, call := range calls {
+			if call == "upper" {
+				t.Error("Expected interceptors after the rejecting one to be skipped")
+			}
+		}
+	})
+}
+
+func TestEnqueueInterceptorPriority(t *testing.T) {
+	dbPath := "test_interceptor_priority.db"
+	defer os.Remove(dbPath)
+
+	queuesInstance := New(dbPath)
+	pq, err := queuesInstance.NewPriorityQueue("test_priority_queue",
+		WithEnqueueInterceptor(func(m *Message) error {
+			if bytes.HasPrefix(m.Data, []byte("urgent")) {
+				m.Priority = 0
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create priority queue: %v", err)
+	}
+	defer queuesInstance.Close()
+
+	pq.Enqueue([]byte("routine"), 5)
+	pq.Enqueue([]byte("urgent fix"), 5)
+
+	item, _ := pq.Dequeue()
+	if string(item.([]byte)) != "urgent fix" {
+		t.Errorf("Expected the interceptor to raise the priority, got '%s'", string(item.([]byte)))
+	}
+}
+
+func TestPayloadBytes(t *testing.T) {
+	tt := []struct {
+		input any
+		want  string
+	}{
+		{[]byte("bytes"), "bytes"},
+		{"text", "text"},
+		{42, "42"},
+		{1.5, "1.5"},
+		{true, "1"},
+	}
+
+	for _, tc := range tt {
+		got, err := payloadBytes(tc.input)
+		if err != nil || string(got) != tc.want {
+			t.Errorf("payloadBytes(%v) = %q, %v; want %q", tc.input, got, err, tc.want)
+		}
+	}
+
+	if _, err := payloadBytes(nil); err == nil {
+		t.Error("Expected an error for a nil payload")
+	}
+}
+
+func TestRedact(t *testing.T) {
+	dbPath := "test_redact.db"
+	defer os.Remove(dbPath)
+
+	queues := New(dbPath)
+	defer queues.Close()
+
+	plain, err := queues.NewQueue("plain")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	if got := plain.Redact([]byte("secret")); string(got) != "secret" {
+		t.Errorf("Expected the payload unchanged, got %q", got)
+	}
+
+	q, err := queues.NewQueue("redacted", WithRedactor(func(payload []byte) []byte {
+		return bytes.ReplaceAll(payload, []byte("hunter2"), []byte("***"))
+	}))
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	q.Enqueue([]byte(`{"password":"hunter2"}`))
+
+	messages, err := q.Page(0, 1)
+	if err != nil || len(messages) != 1 {
+		t.Fatalf("Page failed: %v", err)
+	}
+
+	if got := q.Redact(messages[0].Data); string(got) != `{"password":"***"}` {
+		t.Errorf("Expected the redacted payload, got %s", got)
+	}
+
+	item, _ := q.Dequeue()
+	if string(item.([]byte)) != `{"password":"hunter2"}` {
+		t.Errorf("Expected dequeued payloads not to be redacted, got %s", item)
+	}
+}
+
+func TestCloudEvents(t *testing.T) {
+	dbPath := "test_cloudevents.db"
+	defer os.Remove(dbPath)
+
+	queues := New(dbPath)
+	defer queues.Close()
+
+	q, err := queues.NewQueue("events", WithEnqueueInterceptor(CloudEvents("/orders", "com.example.order.created")))
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	q.Enqueue([]byte(`{"order":42}`))
+	q.Enqueue([]byte("plain text"))
+
+	var event map[string]any
+
+	item, _ := q.Dequeue()
+	if err := json.Unmarshal(item.([]byte), &event); err != nil {
+		t.Fatalf("Expected a JSON envelope, got %s", item)
+	}
+
+	for key, want := range map[string]string{"specversion": "1.0", "source": "/orders", "type": "com.example.order.created", "datacontenttype": "application/json"} {
+		if event[key] != want {
+			t.Errorf("Expected %s %q, got %v", key, want, event[key])
+		}
+	}
+
+	if event["id"] == "" || event["time"] == nil {
+		t.Errorf("Expected an id and a time, got %v", event)
+	}
+
+	if data, _ := json.Marshal(event["data"]); string(data) != `{"order":42}` {
+		t.Errorf("Expected the JSON payload as data, got %s", data)
+	}
+
+	item, _ = q.Dequeue()
+	event = nil
+	if err := json.Unmarshal(item.([]byte), &event); err != nil {
+		t.Fatalf("Expected a JSON envelope, got %s", item)
+	}
+
+	if event["data_base64"] != base64.StdEncoding.EncodeToString([]byte("plain text")) {
+		t.Errorf("Expected the payload as data_base64, got %v", event["data_base64"])
+	}
+}
@@ -0,0 +1,263 @@
+package sqliteq
+
+import (
+	"database/sql"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Defaults for WithJanitorBatchSize and WithJanitorPause
+const (
+	defaultJanitorBatchSize = 1000
+	defaultJanitorPause     = 5 * time.Millisecond
+)
+
+// loop is a periodic background task that runs while the queue is open
+type loop struct {
+	// name identifies the task in logs
+	name     string
+	interval time.Duration
+	run      func() error
+}
+
+// initLoops registers the background tasks required by the configured options
+func (q *Queue) initLoops() error {
+	if q.statsInterval > 0 {
+		if err := initStatsTable(q.client); err != nil {
+			return err
+		}
+
+		q.loops = append(q.loops, loop{"sample stats", q.statsInterval, q.sampleStats})
+	}
+
+	if q.groupCommit != nil {
+		// Errors of the flush are reported to the callers of EnqueueAsync
+		q.loops = append(q.loops, loop{"flush async enqueues", q.groupCommit.maxDelay, func() error { q.flushAsync(); return nil }})
+	}
+
+	if q.idempotencyTTL > 0 {
+		q.loops = append(q.loops, loop{"prune idempotency keys", q.idempotencyTTL, func() error {
+			_, err := q.pruneIdempotencyKeys()
+			return err
+		}})
+	}
+
+	if q.expirySweepInterval > 0 {
+		q.loops = append(q.loops, loop{"sweep expired items", q.expirySweepInterval, func() error {
+			_, err := q.sweepExpired()
+			return err
+		}})
+	}
+
+	if interval := q.reapInterval(); interval > 0 {
+		q.loops = append(q.loops, loop{"reclaim expired claims", interval, func() error {
+			_, err := q.reclaimExpired()
+			return err
+		}})
+	}
+
+	if q.idleAlert != nil {
+		// Items left from before the queue was opened get the full limit too
+		q.idleAlert.progressed(time.Now())
+		q.loops = append(q.loops, loop{"check idle", q.idleAlert.interval(), func() error { q.checkIdle(); return nil }})
+	}
+
+	return nil
+}
+
+// reapInterval returns how often the reaper runs: half the shortest visibility timeout
+// The reaper doesn't run for at-most-once queues
+func (q *Queue) reapInterval() time.Duration {
+	if q.delivery == AtMostOnce {
+		return 0
+	}
+
+	shortest := q.visibilityTimeout
+	for _, timeout := range q.visibilityTimeouts {
+		if timeout > 0 && (shortest <= 0 || timeout < shortest) {
+			shortest = timeout
+		}
+	}
+
+	if shortest <= 0 {
+		return 0
+	}
+
+	if shortest < 2*time.Millisecond {
+		return time.Millisecond
+	}
+
+	return shortest / 2
+}
+
+// reclaimExpired returns processing items whose visibility timeout elapsed to pending,
+// except pinned ones
+// Reclaimed items are ordered by the WithRedeliveryOrder policy among items of the
+// same priority; WithReclaimPriorityBump additionally raises their priority
+// Returns the number of reclaimed items
+func (q *Queue) reclaimExpired() (reclaimed int64, err error) {
+	defer func() { err = mapError(err) }()
+	defer q.invalidateStats()
+	defer q.invalidateFront()
+
+	if q.closed.Load() {
+		return 0, ErrClosed
+	}
+
+	if q.delivery == AtMostOnce {
+		return 0, nil
+	}
+
+	now := q.now()
+	// Items may have been dequeued by a host whose clock is ahead of ours
+	expiry := q.expiryNow()
+
+	// Items are expired when they were last updated before their cutoff; without any
+	// timeout for their priority the cutoff is NULL, which never matches
+	var global any
+	if q.visibilityTimeout > 0 {
+		global = expiry.Add(-q.visibilityTimeout)
+	}
+
+	set := "status = 'pending', updated_at = ?"
+	args := []any{now}
+
+	if q.priority && q.reclaimPriorityBump > 0 {
+		set += ", priority = MAX(priority - ?, 0)"
+		args = append(args, q.reclaimPriorityBump)
+	}
+
+	cutoff := "?"
+
+	if len(q.visibilityTimeouts) > 0 {
+		priorities := make([]int, 0, len(q.visibilityTimeouts))
+		for priority := range q.visibilityTimeouts {
+			priorities = append(priorities, priority)
+		}
+		sort.Ints(priorities)
+
+		var b strings.Builder
+		b.WriteString("CASE ")
+		b.WriteString(q.priorityColumn())
+		for _, priority := range priorities {
+			b.WriteString(" WHEN ? THEN ?")
+
+			var c any
+			if timeout := q.visibilityTimeouts[priority]; timeout > 0 {
+				c = expiry.Add(-timeout)
+			}
+			args = append(args, priority, c)
+		}
+		b.WriteString(" ELSE ? END")
+		cutoff = b.String()
+	}
+	args = append(args, global)
+
+	reclaimed, err = q.inBatches(func(limit int) (sql.Result, error) {
+		return q.requeue(set, "status = 'processing' AND ack = 0 AND pinned = 0 AND updated_at < "+cutoff, limit, args...)
+	})
+	if err != nil || reclaimed == 0 {
+		return reclaimed, err
+	}
+
+	q.logger().Info("requeued items past their visibility timeout", "queue", q.tableName, "count", reclaimed)
+
+	_, err = q.moveDeadLetters()
+	return reclaimed, err
+}
+
+// reclaimStale returns the unpinned items in processing last updated before cutoff
+// to pending, for Manager.Doctor
+func (q *Queue) reclaimStale(cutoff time.Time) (int64, error) {
+	defer q.invalidateStats()
+	defer q.invalidateFront()
+
+	return q.inBatches(func(limit int) (sql.Result, error) {
+		return q.requeue("status = 'pending', updated_at = ?", "status = 'processing' AND ack = 0 AND pinned = 0 AND updated_at < ?", limit, q.now(), cutoff)
+	})
+}
+
+// longestVisibilityTimeout returns the longest visibility timeout of any priority
+func (q *Queue) longestVisibilityTimeout() time.Duration {
+	longest := q.visibilityTimeout
+	for _, timeout := range q.visibilityTimeouts {
+		if timeout > longest {
+			longest = timeout
+		}
+	}
+
+	return longest
+}
+
+// inBatches runs a maintenance statement affecting at most the janitor batch size of
+// rows at a time, pausing between batches so producers can take the write lock.
+// It stops once a batch affects fewer rows than the limit or the queue is closed.
+// Returns the total number of affected rows
+func (q *Queue) inBatches(exec func(limit int) (sql.Result, error)) (total int64, err error) {
+	for {
+		result, err := exec(q.janitorBatchSize)
+		if err != nil {
+			return total, err
+		}
+
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return total, err
+		}
+
+		total += affected
+		if affected < int64(q.janitorBatchSize) {
+			return total, nil
+		}
+
+		time.Sleep(q.janitorPause)
+
+		if q.closed.Load() {
+			return total, nil
+		}
+	}
+}
+
+// startLoops starts every registered background task
+func (q *Queue) startLoops() {
+	if len(q.loops) == 0 {
+		return
+	}
+
+	q.stop = make(chan struct{})
+
+	for _, l := range q.loops {
+		q.wg.Add(1)
+
+		go func(l loop, stop <-chan struct{}) {
+			defer q.wg.Done()
+
+			ticker := time.NewTicker(l.interval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-stop:
+					return
+				case <-ticker.C:
+					if err := l.run(); err != nil && !errors.Is(err, ErrClosed) {
+						q.logger().Error("background task failed", "queue", q.tableName, "task", l.name, "error", err)
+					}
+				}
+			}
+		}(l, q.stop)
+	}
+}
+
+// stopLoops stops the background tasks and waits for running passes to finish
+func (q *Queue) stopLoops() {
+	if q.stop == nil {
+		return
+	}
+
+	close(q.stop)
+	q.wg.Wait()
+	q.stop = nil
+}
@@ -0,0 +1,247 @@
+package sqliteq
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestVisibilityTimeout(t *testing.T) {
+	dbPath := "test_visibility_timeout.db"
+	defer os.Remove(dbPath)
+
+	queues := New(dbPath)
+	q, err := queues.NewQueue("test_queue", WithVisibilityTimeout(20*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	defer queues.Close()
+
+	q.Enqueue([]byte("slow item"))
+
+	_, success, _ := q.DequeueWithAckId()
+	if !success {
+		t.Fatal("DequeueWithAckId failed")
+	}
+
+	if q.Len() != 0 {
+		t.Fatalf("Expected no pending item while processing, got %d", q.Len())
+	}
+
+	// The reaper hands the unacknowledged item back to pending
+	deadline := time.Now().Add(2 * time.Second)
+	for q.Len() == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	item, success, _ := q.DequeueWithAckId()
+	if !success || string(item.([]byte)) != "slow item" {
+		t.Errorf("Expected the reclaimed item to be redelivered, got %v", item)
+	}
+}
+
+func TestVisibilityTimeoutByPriority(t *testing.T) {
+	dbPath := "test_visibility_timeout_priority.db"
+	defer os.Remove(dbPath)
+
+	queuesInstance := New(dbPath)
+	pq, err := queuesInstance.NewPriorityQueue("test_priority_queue",
+		WithVisibilityTimeout(time.Hour),
+		WithVisibilityTimeoutByPriority(map[int]time.Duration{0: time.Minute, 5: 0}))
+	if err != nil {
+		t.Fatalf("Failed to create priority queue: %v", err)
+	}
+	defer queuesInstance.Close()
+
+	pq.Enqueue([]byte("urgent"), 0)
+	pq.Enqueue([]byte("no timeout"), 5)
+	pq.Enqueue([]byte("batch"), 10)
+
+	for i := 0; i < 3; i++ {
+		if _, success, _ := pq.DequeueWithAckId(); !success {
+			t.Fatal("DequeueWithAckId failed")
+		}
+	}
+
+	// Pretend every item was claimed 2 minutes ago
+	pq.client.Exec(fmt.Sprintf("UPDATE %s SET updated_at = ?", quoteIdent(pq.tableName)), time.Now().UTC().Add(-2*time.Minute))
+
+	reclaimed, err := pq.reclaimExpired()
+	if err != nil {
+		t.Fatalf("reclaimExpired failed: %v", err)
+	}
+
+	// Only the urgent item has a timeout shorter than 2 minutes
+	if reclaimed != 1 {
+		t.Errorf("Expected 1 reclaimed item, got %d", reclaimed)
+	}
+
+	messages, _ := pq.Page(0, 10)
+	if len(messages) != 1 || string(messages[0].Data) != "urgent" {
+		t.Errorf("Expected only 'urgent' to be pending again, got %v", messages)
+	}
+}
+
+func TestJanitorBatches(t *testing.T) {
+	dbPath := "test_janitor_batches.db"
+	defer os.Remove(dbPath)
+
+	queues := New(dbPath)
+	defer queues.Close()
+
+	q, err := queues.NewQueue("janitor_batches", WithJanitorBatchSize(2), WithJanitorPause(time.Millisecond))
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	q.visibilityTimeout = time.Millisecond
+
+	for i := 0; i < 5; i++ {
+		q.Enqueue(i)
+		q.DequeueWithAckId()
+	}
+
+	time.Sleep(5 * time.Millisecond)
+
+	reclaimed, err := q.reclaimExpired()
+	if err != nil {
+		t.Fatalf("Failed to reclaim: %v", err)
+	}
+
+	if reclaimed != 5 || q.Len() != 5 {
+		t.Errorf("Expected all 5 items to be reclaimed in batches, got %d (length %d)", reclaimed, q.Len())
+	}
+}
+
+func TestReclaimOrdering(t *testing.T) {
+	dbPath := "test_reclaim_ordering.db"
+	defer os.Remove(dbPath)
+
+	queues := New(dbPath)
+	defer queues.Close()
+
+	t.Run("KeepsOriginalPosition", func(t *testing.T) {
+		pq, err := queues.NewPriorityQueue("reclaim_position")
+		if err != nil {
+			t.Fatalf("Failed to create priority queue: %v", err)
+		}
+		pq.visibilityTimeout = time.Millisecond
+
+		pq.Enqueue("first", 1)
+		pq.DequeueWithAckId()
+		pq.Enqueue("second", 1)
+
+		time.Sleep(5 * time.Millisecond)
+		pq.reclaimExpired()
+
+		item, _ := pq.Dequeue()
+		if string(item.([]byte)) != "first" {
+			t.Errorf("Expected the reclaimed item to keep its place ahead of later arrivals, got %s", item)
+		}
+	})
+
+	t.Run("BumpsPriority", func(t *testing.T) {
+		pq, err := queues.NewPriorityQueue("reclaim_bump", WithReclaimPriorityBump(2))
+		if err != nil {
+			t.Fatalf("Failed to create priority queue: %v", err)
+		}
+		pq.visibilityTimeout = time.Millisecond
+
+		pq.Enqueue("retried", 3)
+		pq.DequeueWithAckId()
+		pq.Enqueue("fresh", 2)
+
+		time.Sleep(5 * time.Millisecond)
+		pq.reclaimExpired()
+
+		item, _ := pq.Dequeue()
+		if string(item.([]byte)) != "retried" {
+			t.Errorf("Expected the bumped item first, got %s", item)
+		}
+
+		// Bumping stops at the highest priority
+		pq.Enqueue("again", 1)
+		pq.DequeueWithAckId()
+		time.Sleep(5 * time.Millisecond)
+		pq.reclaimExpired()
+
+		messages, _ := pq.Page(0, 10)
+		if len(messages) != 2 || string(messages[0].Data) != "again" || messages[0].Priority != 0 {
+			t.Errorf("Expected the bumped item at priority 0 first, got %+v", messages)
+		}
+	})
+
+	t.Run("ArrivalOrder", func(t *testing.T) {
+		pq, err := queues.NewPriorityQueue("reclaim_arrival", WithRedeliveryOrder(ArrivalOrder))
+		if err != nil {
+			t.Fatalf("Failed to create priority queue: %v", err)
+		}
+		pq.visibilityTimeout = time.Millisecond
+
+		for _, item := range []string{"first", "second", "third"} {
+			pq.Enqueue(item, 1)
+		}
+		pq.DequeueWithAckId()
+		pq.DequeueWithAckId()
+
+		time.Sleep(5 * time.Millisecond)
+		if reclaimed, err := pq.reclaimExpired(); err != nil || reclaimed != 2 {
+			t.Fatalf("Expected 2 reclaimed items, got %d, %v", reclaimed, err)
+		}
+
+		// Later arrivals still queue up behind the reclaimed items
+		pq.Enqueue("fourth", 1)
+
+		_, _, ackID := pq.DequeueWithAckId()
+		if err := pq.Nack(ackID); err != nil {
+			t.Fatalf("Nack failed: %v", err)
+		}
+
+		var order []string
+		for {
+			item, ok := pq.Dequeue()
+			if !ok {
+				break
+			}
+			order = append(order, string(item.([]byte)))
+		}
+
+		if got := fmt.Sprint(order); got != "[first second fourth third]" {
+			t.Errorf("Expected redelivered items at the back of the line, got %s", got)
+		}
+	})
+}
+
+func TestClockSkewTolerance(t *testing.T) {
+	dbPath := "test_clock_skew.db"
+	defer os.Remove(dbPath)
+
+	queuesInstance := New(dbPath)
+	defer queuesInstance.Close()
+
+	q, err := queuesInstance.NewQueue("test_queue",
+		WithVisibilityTimeout(time.Minute),
+		WithClockSkewTolerance(5*time.Minute))
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	q.Enqueue([]byte("item"))
+	if _, success, _ := q.DequeueWithAckId(); !success {
+		t.Fatal("DequeueWithAckId failed")
+	}
+
+	// Pretend the item was claimed 2 minutes ago, or later by a host whose clock is ahead
+	q.client.Exec(fmt.Sprintf("UPDATE %s SET updated_at = ?", quoteIdent(q.tableName)), time.Now().UTC().Add(-2*time.Minute))
+
+	if reclaimed, _ := q.reclaimExpired(); reclaimed != 0 {
+		t.Errorf("Expected no item reclaimed within the tolerance, got %d", reclaimed)
+	}
+
+	q.client.Exec(fmt.Sprintf("UPDATE %s SET updated_at = ?", quoteIdent(q.tableName)), time.Now().UTC().Add(-7*time.Minute))
+
+	if reclaimed, _ := q.reclaimExpired(); reclaimed != 1 {
+		t.Errorf("Expected the item reclaimed past the tolerance, got %d", reclaimed)
+	}
+}
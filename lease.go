@@ -0,0 +1,140 @@
+package sqliteq
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// leasesTable holds the write lease shared by the processes using a database
+const leasesTable = "sqliteq_leases"
+
+// Limits of WithWriteLease, in lease durations
+const (
+	// leaseMaxHold is how long a process may keep renewing the lease before it
+	// has to let it expire, so the other processes get their turn
+	leaseMaxHold = 4
+	// leaseMaxWait is how long a write waits for the lease before failing with ErrBusy
+	leaseMaxWait = 10
+)
+
+// writeLease is the state of this process's write lease, see WithWriteLease
+type writeLease struct {
+	duration time.Duration
+	// holder identifies this manager in the leases table
+	holder string
+
+	mu sync.Mutex
+	// until is when the lease held by this manager ends
+	until time.Time
+}
+
+// newWriteLease returns the lease state of a manager holding leases for d, its
+// holder being set once the manager's IDGenerator is known
+func newWriteLease(d time.Duration) *writeLease {
+	return &writeLease{duration: d}
+}
+
+// initLeases creates the leases table
+func initLeases(db *sql.DB) error {
+	_, err := db.Exec(fmt.Sprintf(`
+	CREATE TABLE IF NOT EXISTS %s (
+		name TEXT PRIMARY KEY,
+		holder TEXT NOT NULL,
+		acquired_at TIMESTAMP NOT NULL,
+		expires_at TIMESTAMP NOT NULL
+	)`, quoteIdent(leasesTable)))
+
+	return err
+}
+
+// awaitLease waits until this manager holds the write lease, renewing it when it
+// is about to end. Does nothing without WithWriteLease.
+// Returns ErrBusy when another process kept the lease for too long
+func (m *Manager) awaitLease() error {
+	l := m.lease
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Leave a margin for writes started under the lease to finish
+	if time.Until(l.until) > l.duration/4 {
+		return nil
+	}
+
+	poll := l.duration / 4
+	if poll < time.Millisecond {
+		poll = time.Millisecond
+	}
+
+	deadline := time.Now().Add(leaseMaxWait * l.duration)
+
+	for {
+		acquired, err := m.acquireLease(l)
+		if err != nil && !IsRetriable(err) {
+			return mapError(err)
+		}
+
+		if acquired {
+			return nil
+		}
+
+		if m.closed.Load() {
+			return ErrQueuesClosed
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%w: the write lease is held by another process", ErrBusy)
+		}
+
+		time.Sleep(poll)
+	}
+}
+
+// acquireLease takes the write lease if it is free or expired, or renews it if
+// this manager holds it and hasn't held it for too long
+// Returns whether the lease is held
+func (m *Manager) acquireLease(l *writeLease) (bool, error) {
+	now := time.Now().UTC()
+	expires := now.Add(l.duration)
+
+	// A renewal keeps acquired_at, so the holder has to let go after leaseMaxHold
+	result, err := m.client.Exec(fmt.Sprintf(`
+	INSERT INTO %s (name, holder, acquired_at, expires_at) VALUES ('write', ?, ?, ?)
+	ON CONFLICT (name) DO UPDATE SET
+		acquired_at = CASE WHEN holder = excluded.holder AND expires_at > excluded.acquired_at THEN acquired_at ELSE excluded.acquired_at END,
+		holder = excluded.holder,
+		expires_at = excluded.expires_at
+	WHERE expires_at <= excluded.acquired_at OR (holder = excluded.holder AND acquired_at > ?)
+	`, quoteIdent(leasesTable)), l.holder, now, expires, now.Add(-leaseMaxHold*l.duration))
+	if err != nil {
+		return false, err
+	}
+
+	acquired, err := result.RowsAffected()
+	if err != nil || acquired == 0 {
+		return false, err
+	}
+
+	l.until = time.Now().Add(l.duration)
+
+	return true, nil
+}
+
+// releaseLease gives up the write lease held by this manager, if any
+func (m *Manager) releaseLease() error {
+	if m.lease == nil {
+		return nil
+	}
+
+	_, err := m.client.Exec(
+		fmt.Sprintf("DELETE FROM %s WHERE name = 'write' AND holder = ?", quoteIdent(leasesTable)),
+		m.lease.holder,
+	)
+
+	return err
+}
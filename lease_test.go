@@ -0,0 +1,66 @@
+package sqliteq
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestWriteLease(t *testing.T) {
+	dbPath := "test_write_lease.db"
+	defer os.Remove(dbPath)
+
+	const lease = 300 * time.Millisecond
+
+	first := New(dbPath, WithWriteLease(lease))
+	defer first.Close()
+
+	second := New(dbPath, WithWriteLease(lease))
+
+	q1, err := first.NewQueue("shared")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	q2, err := second.NewQueue("shared")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	if !q1.Enqueue("first") {
+		t.Fatal("Enqueue under a free lease failed")
+	}
+
+	// The second manager waits for the first one's lease to expire
+	start := time.Now()
+	if !q2.Enqueue("second") {
+		t.Fatal("Enqueue after waiting for the lease failed")
+	}
+
+	if waited := time.Since(start); waited < lease/2 {
+		t.Errorf("Expected the second writer to wait for the lease, waited %v", waited)
+	}
+
+	// Writes under a held lease don't wait
+	start = time.Now()
+	q2.Enqueue("third")
+	if waited := time.Since(start); waited >= lease/2 {
+		t.Errorf("Expected the holder to write at once, waited %v", waited)
+	}
+
+	// Closing releases the lease
+	second.Close()
+
+	start = time.Now()
+	if _, ok := q1.Dequeue(); !ok {
+		t.Fatal("Dequeue after the lease was released failed")
+	}
+
+	if waited := time.Since(start); waited >= lease/2 {
+		t.Errorf("Expected the released lease to be taken at once, waited %v", waited)
+	}
+
+	if q1.Len() != 2 {
+		t.Errorf("Expected 2 items left, got %d", q1.Len())
+	}
+}
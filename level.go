@@ -0,0 +1,94 @@
+package sqliteq
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Level names a priority, so call sites read clearly and dashboards can label the
+// band of priorities from its value up to the next level's
+type Level string
+
+// Levels every priority queue knows, unless WithPriorityLevels maps them elsewhere
+const (
+	// High is priority 0, dequeued first
+	High Level = "high"
+	// Normal is priority 10
+	Normal Level = "normal"
+	// Low is priority 20
+	Low Level = "low"
+)
+
+// defaultLevels are the priorities of the predefined levels
+var defaultLevels = map[Level]int{High: 0, Normal: 10, Low: 20}
+
+// WithPriorityLevels names priorities for EnqueueLevel and LevelOf, e.g.
+// {"critical": 0, "batch": 50}, adding to or remapping High, Normal and Low. The
+// priority column keeps storing the numbers, so levels can be renamed or added freely.
+// It only affects priority queues.
+func WithPriorityLevels(levels map[string]int) Option {
+	return func(q *Queue) {
+		q.priorityLevels = make(map[Level]int, len(defaultLevels)+len(levels))
+		for level, priority := range defaultLevels {
+			q.priorityLevels[level] = priority
+		}
+		for name, priority := range levels {
+			q.priorityLevels[Level(name)] = priority
+		}
+	}
+}
+
+// levels returns the queue's named priorities
+func (q *Queue) levels() map[Level]int {
+	if q.priorityLevels == nil {
+		return defaultLevels
+	}
+
+	return q.priorityLevels
+}
+
+// EnqueueLevel adds an item with the priority of level
+// Returns ErrUnknownLevel when the queue has no such level
+func (pq *PriorityQueue) EnqueueLevel(item any, level Level) error {
+	priority, ok := pq.levels()[level]
+	if !ok {
+		return fmt.Errorf("%w %q", ErrUnknownLevel, level)
+	}
+
+	return pq.enqueue(item, priority)
+}
+
+// LevelOf returns the level whose band holds priority: the level with the highest
+// priority number not above it. Priorities ahead of every level report false.
+func (pq *PriorityQueue) LevelOf(priority int) (Level, bool) {
+	var found Level
+	best, ok := 0, false
+
+	for level, p := range pq.levels() {
+		// Ties between levels of the same priority go to the first name, for stable labels
+		if p <= priority && (!ok || p > best || (p == best && level < found)) {
+			found, best, ok = level, p, true
+		}
+	}
+
+	return found, ok
+}
+
+// Levels returns the queue's levels ordered by priority, highest first
+func (pq *PriorityQueue) Levels() []Level {
+	levels := pq.levels()
+
+	names := make([]Level, 0, len(levels))
+	for level := range levels {
+		names = append(names, level)
+	}
+
+	sort.Slice(names, func(i, j int) bool {
+		if levels[names[i]] != levels[names[j]] {
+			return levels[names[i]] < levels[names[j]]
+		}
+		return names[i] < names[j]
+	})
+
+	return names
+}
@@ -0,0 +1,52 @@
+package sqliteq
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestPriorityLevels(t *testing.T) {
+	dbPath := "test_priority_levels.db"
+	defer os.Remove(dbPath)
+
+	queues := New(dbPath)
+	defer queues.Close()
+
+	pq, err := queues.NewPriorityQueue("test_queue", WithPriorityLevels(map[string]int{"batch": 50}))
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	for _, level := range []Level{"batch", Low, High} {
+		if err := pq.EnqueueLevel(string(level), level); err != nil {
+			t.Fatalf("EnqueueLevel failed: %v", err)
+		}
+	}
+
+	if err := pq.EnqueueLevel("lost", "urgent"); !errors.Is(err, ErrUnknownLevel) {
+		t.Errorf("Expected ErrUnknownLevel, got %v", err)
+	}
+
+	for _, want := range []string{"high", "low", "batch"} {
+		m, err := pq.DequeueMessage()
+		if err != nil {
+			t.Fatalf("DequeueMessage failed: %v", err)
+		}
+
+		if string(m.Data) != want {
+			t.Errorf("Expected %q, got %s", want, m.Data)
+		}
+		if level, _ := pq.LevelOf(m.Priority); string(level) != want {
+			t.Errorf("Expected priority %d labeled %q, got %q", m.Priority, want, level)
+		}
+	}
+
+	if level, ok := pq.LevelOf(15); !ok || level != Normal {
+		t.Errorf("Expected priority 15 in the normal band, got %q", level)
+	}
+
+	if levels := pq.Levels(); len(levels) != 4 || levels[0] != High || levels[3] != "batch" {
+		t.Errorf("Expected levels ordered by priority, got %v", levels)
+	}
+}
@@ -0,0 +1,47 @@
+package sqliteq
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// Logger receives what the library otherwise handles silently: retried writes,
+// rolled back transactions, requeued items and the errors swallowed by methods
+// reporting failure as false or zero, such as Enqueue and Dequeue, or by
+// background tasks. Arguments after the message are alternating keys and values,
+// so a *slog.Logger can be used as is.
+type Logger interface {
+	Debug(msg string, args ...any)
+	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+// nopLogger discards everything, the default Logger
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...any) {}
+func (nopLogger) Info(string, ...any)  {}
+func (nopLogger) Warn(string, ...any)  {}
+func (nopLogger) Error(string, ...any) {}
+
+// logger returns the Logger of the queue's manager
+func (q *Queue) logger() Logger {
+	return q.manager.logger
+}
+
+// quiet reports whether err is an expected outcome rather than a failure: nothing
+// to dequeue or acknowledge, or a closed queue
+func quiet(err error) bool {
+	return errors.Is(err, sql.ErrNoRows) || errors.Is(err, ErrEmpty) || errors.Is(err, ErrClosed)
+}
+
+// succeeded logs the failure of op, if any, for methods that only report success
+// Returns whether err is nil
+func (q *Queue) succeeded(op string, err error) bool {
+	if err != nil && !quiet(err) {
+		q.logger().Error(op+" failed", "queue", q.tableName, "error", err)
+	}
+
+	return err == nil
+}
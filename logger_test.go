@@ -0,0 +1,131 @@
+package sqliteq
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingLogger keeps the messages logged at each level
+type recordingLogger struct {
+	mu      sync.Mutex
+	entries []string
+}
+
+func (l *recordingLogger) log(level, msg string, args ...any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, level+" "+msg+" "+fmt.Sprint(args...))
+}
+
+func (l *recordingLogger) Debug(msg string, args ...any) { l.log("DEBUG", msg, args...) }
+func (l *recordingLogger) Info(msg string, args ...any)  { l.log("INFO", msg, args...) }
+func (l *recordingLogger) Warn(msg string, args ...any)  { l.log("WARN", msg, args...) }
+func (l *recordingLogger) Error(msg string, args ...any) { l.log("ERROR", msg, args...) }
+
+// take returns the entries logged since the last call
+func (l *recordingLogger) take() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entries := l.entries
+	l.entries = nil
+	return entries
+}
+
+// has reports whether one of entries starts with prefix
+func has(entries []string, prefix string) bool {
+	for _, e := range entries {
+		if strings.HasPrefix(e, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestLogger(t *testing.T) {
+	dbPath := "test_logger.db"
+	defer os.Remove(dbPath)
+
+	logger := &recordingLogger{}
+	queues, err := NewManager(dbPath+"?_busy_timeout=10", WithLogger(logger))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer queues.Close()
+
+	errInvalid := errors.New("invalid job")
+	q, err := queues.NewQueue("jobs", WithWriteRetry(2, time.Millisecond), WithEnqueueInterceptor(func(m *Message) error {
+		if string(m.Data) == "bad" {
+			return errInvalid
+		}
+		return nil
+	}))
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	t.Run("Quiet", func(t *testing.T) {
+		q.Dequeue()
+		q.Acknowledge("unknown")
+		if entries := logger.take(); len(entries) != 0 {
+			t.Errorf("Expected an empty queue and an unknown ack ID not to be logged, got %v", entries)
+		}
+	})
+
+	t.Run("SwallowedErrors", func(t *testing.T) {
+		if q.Enqueue("bad") {
+			t.Fatal("Expected the interceptor to reject the item")
+		}
+
+		if entries := logger.take(); !has(entries, "ERROR enqueue failed") {
+			t.Errorf("Expected the rejected enqueue to be logged, got %v", entries)
+		}
+	})
+
+	t.Run("Retries", func(t *testing.T) {
+		q.Enqueue("job")
+		_, _, ackID := q.DequeueWithAckId()
+
+		other, err := sql.Open("sqlite3", dbPath)
+		if err != nil {
+			t.Fatalf("Failed to open second connection: %v", err)
+		}
+		defer other.Close()
+
+		tx, err := other.Begin()
+		if err != nil {
+			t.Fatalf("Failed to begin transaction: %v", err)
+		}
+		if _, err := tx.Exec("UPDATE jobs SET updated_at = updated_at"); err != nil {
+			t.Fatalf("Failed to take the write lock: %v", err)
+		}
+
+		logger.take()
+		q.Acknowledge(ackID)
+		tx.Rollback()
+
+		entries := logger.take()
+		if !has(entries, "WARN retrying write") || !has(entries, "DEBUG write rolled back") || !has(entries, "ERROR acknowledge failed") {
+			t.Errorf("Expected the retry, the rollbacks and the swallowed error to be logged, got %v", entries)
+		}
+	})
+
+	t.Run("RequeueOnOpen", func(t *testing.T) {
+		q.Enqueue("abandoned")
+		q.DequeueWithAckId()
+		logger.take()
+
+		if _, err := queues.NewQueue("jobs"); err != nil {
+			t.Fatalf("Failed to reopen queue: %v", err)
+		}
+
+		if entries := logger.take(); !has(entries, "WARN requeued items left in processing") {
+			t.Errorf("Expected the requeue on open to be logged, got %v", entries)
+		}
+	})
+}
@@ -0,0 +1,104 @@
+package sqliteq
+
+import (
+	"context"
+	"fmt"
+)
+
+// MaintenanceReport describes what a RunMaintenance pass did
+type MaintenanceReport struct {
+	// Requeued is the number of items reclaimed after their visibility timeout, per queue
+	Requeued map[string]int64
+	// Expired is the number of pending items deleted after their TTL, per queue
+	Expired map[string]int64
+	// ExpiredKeys is the number of expired idempotency keys deleted
+	ExpiredKeys int64
+	// TrashedRows is the number of purged rows deleted past the WithPurgeUndo window
+	TrashedRows int64
+	// PrunedSamples is the number of stats samples deleted past their retention
+	PrunedSamples int64
+	// DroppedQueues lists the queues dropped by WithDropEmptyAfter
+	DroppedQueues []string
+	// CheckpointedFrames is the number of WAL frames copied back into the database file
+	CheckpointedFrames int
+}
+
+// RunMaintenance runs every background maintenance pass once for the open queues
+// of the manager and checkpoints the WAL, for deployments without a long-lived
+// process to run them in the background. Queues are maintained according to their
+// options; passes they don't enable are skipped.
+// The context is checked between passes; the report covers the passes completed.
+func (m *Manager) RunMaintenance(ctx context.Context) (report MaintenanceReport, err error) {
+	defer func() { err = mapError(err) }()
+
+	report.Requeued = make(map[string]int64)
+	report.Expired = make(map[string]int64)
+
+	if m.closed.Load() {
+		return report, ErrQueuesClosed
+	}
+
+	m.mu.Lock()
+	open := append([]*Queue(nil), m.open...)
+	m.mu.Unlock()
+
+	for _, q := range open {
+		if q.closed.Load() {
+			continue
+		}
+
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+
+		if q.reapInterval() > 0 {
+			requeued, err := q.reclaimExpired()
+			if err != nil {
+				return report, fmt.Errorf("failed to reclaim %s: %w", q.tableName, err)
+			}
+			report.Requeued[q.tableName] += requeued
+		}
+
+		expired, err := q.sweepExpired()
+		if err != nil {
+			return report, fmt.Errorf("failed to sweep expired items of %s: %w", q.tableName, err)
+		}
+		if expired > 0 {
+			report.Expired[q.tableName] += expired
+		}
+
+		pruned, err := q.pruneStats()
+		if err != nil {
+			return report, fmt.Errorf("failed to prune stats of %s: %w", q.tableName, err)
+		}
+		report.PrunedSamples += pruned
+	}
+
+	// Expired keys are pruned from the table shared by every namespace, once
+	if len(open) > 0 {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+
+		if report.ExpiredKeys, err = open[0].pruneIdempotencyKeys(); err != nil {
+			return report, fmt.Errorf("failed to prune idempotency keys: %w", err)
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return report, err
+	}
+
+	if report.TrashedRows, err = m.pruneTrash(); err != nil {
+		return report, fmt.Errorf("failed to prune trash: %w", err)
+	}
+
+	if report.DroppedQueues, err = m.dropEmptyQueues(); err != nil {
+		return report, fmt.Errorf("failed to drop empty queues: %w", err)
+	}
+
+	var busy, frames int
+	err = m.client.QueryRowContext(ctx, "PRAGMA wal_checkpoint(PASSIVE)").Scan(&busy, &frames, &report.CheckpointedFrames)
+
+	return report, err
+}
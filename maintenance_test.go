@@ -0,0 +1,62 @@
+package sqliteq
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestRunMaintenance(t *testing.T) {
+	dbPath := "test_maintenance.db"
+	defer os.Remove(dbPath)
+
+	queues, err := NewManager(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	defer queues.Close()
+
+	// Timeouts and TTLs are longer than the test, so only RunMaintenance acts on them
+	q, err := queues.NewQueue("maintenance",
+		WithVisibilityTimeout(time.Hour),
+		WithIdempotency("maintenance", time.Hour),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	q.Enqueue("stuck")
+	q.DequeueWithAckId()
+	q.EnqueueIdempotent("event", "evt")
+
+	// Age the processing item and the key past their limits
+	past := time.Now().Add(-2 * time.Hour).UTC()
+	q.client.Exec("UPDATE maintenance SET updated_at = ? WHERE status = 'processing'", past)
+	q.client.Exec("UPDATE sqliteq_idempotency SET expires_at = ?", past)
+
+	report, err := queues.RunMaintenance(context.Background())
+	if err != nil {
+		t.Fatalf("RunMaintenance failed: %v", err)
+	}
+
+	if report.Requeued["maintenance"] != 1 {
+		t.Errorf("Expected 1 requeued item, got %v", report.Requeued)
+	}
+
+	if report.ExpiredKeys != 1 {
+		t.Errorf("Expected 1 expired key, got %d", report.ExpiredKeys)
+	}
+
+	if q.Len() != 2 {
+		t.Errorf("Expected 2 pending items, got %d", q.Len())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := queues.RunMaintenance(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
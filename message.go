@@ -0,0 +1,123 @@
+package sqliteq
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Status is the lifecycle state of a queue item
+type Status string
+
+const (
+	// StatusPending items are waiting to be dequeued
+	StatusPending Status = "pending"
+	// StatusProcessing items were dequeued with an ack ID and await acknowledgment
+	StatusProcessing Status = "processing"
+	// StatusCompleted items were acknowledged and kept by WithRemoveOnComplete(false)
+	StatusCompleted Status = "completed"
+	// StatusFailed items were set aside by FailPending and are no longer dequeued
+	StatusFailed Status = "failed"
+)
+
+// Message is a queue item together with its metadata
+type Message struct {
+	// ID is the row ID of the item, unique within its queue
+	ID int64
+	// Data is the payload as stored by Enqueue, a fresh copy owned by the caller
+	Data []byte
+	// Status is the lifecycle state of the item
+	Status Status
+	// Priority is the priority of the item, always 0 for plain queues
+	Priority int
+	// AckID is the acknowledgment ID of an item in processing
+	AckID string
+	// CreatedAt is when the item was enqueued
+	CreatedAt time.Time
+	// UpdatedAt is when the item last changed status
+	UpdatedAt time.Time
+	// NotBefore is the earliest time the item can be dequeued, zero if it is ready at once
+	NotBefore time.Time
+	// RepeatEvery is the interval at which the item is enqueued again, zero if it doesn't repeat
+	RepeatEvery time.Duration
+	// RepeatUntil is when a repeating item stops being enqueued again, zero to repeat forever
+	RepeatUntil time.Time
+	// Seq is the position of the item in the queue's insertion order, increasing
+	// with every enqueue regardless of the system clock
+	Seq int64
+	// FailReason is why a failed item was set aside
+	FailReason string
+	// Attempts is the number of times the item was dequeued, including the delivery
+	// that returned it, so handlers can back off or detect poison messages
+	Attempts int
+	// Origin is the queue a dead-lettered item was moved from, see WithDeadLetterQueue
+	Origin string
+	// ExpiresAt is when a pending item is no longer worth delivering, zero if it never expires
+	ExpiresAt time.Time
+	// Pinned is whether an item in processing is exempt from its visibility timeout, see Pin
+	Pinned bool
+	// Headers is the key/value metadata stored with the item, see EnqueueWithHeaders
+	Headers map[string]string
+	// MessageID is the producer-supplied ID of the item, unique within its queue,
+	// see EnqueueWithMessageID
+	MessageID string
+	// CorrelationID ties the item to an upstream event or request, see EnqueueWithMessageID
+	CorrelationID string
+}
+
+// priorityColumn returns the SQL expression for an item's priority, 0 for plain queues
+func (q *Queue) priorityColumn() string {
+	if q.priority {
+		return "priority"
+	}
+
+	return "0"
+}
+
+// messageColumns returns the select list read by scanMessage
+func (q *Queue) messageColumns() string {
+	return "id, data, status, " + q.priorityColumn() + ", ack_id, created_at, updated_at, not_before, repeat_every, repeat_until, seq, fail_reason, attempts, origin, expires_at, pinned, headers, message_id, correlation_id"
+}
+
+// scanMessage scans a row selected with messageColumns
+func (q *Queue) scanMessage(rows *sql.Rows) (Message, error) {
+	var m Message
+	var ackID sql.NullString
+	var createdAt, updatedAt, notBefore, repeatUntil, expiresAt sql.NullTime
+	var repeatEvery int64
+	var seq sql.NullInt64
+	var failReason, origin, headers, messageID, correlationID sql.NullString
+
+	if err := rows.Scan(&m.ID, &m.Data, &m.Status, &m.Priority, &ackID, &createdAt, &updatedAt, &notBefore, &repeatEvery, &repeatUntil, &seq, &failReason, &m.Attempts, &origin, &expiresAt, &m.Pinned, &headers, &messageID, &correlationID); err != nil {
+		return Message{}, err
+	}
+
+	var err error
+	if m.Headers, err = decodeHeaders(headers); err != nil {
+		return Message{}, err
+	}
+
+	m.AckID = ackID.String
+	m.CreatedAt = createdAt.Time
+	m.UpdatedAt = updatedAt.Time
+	m.NotBefore = notBefore.Time
+	m.RepeatEvery = time.Duration(repeatEvery)
+	m.RepeatUntil = repeatUntil.Time
+	m.Seq = seq.Int64
+	m.FailReason = failReason.String
+	m.Origin = origin.String
+	m.ExpiresAt = expiresAt.Time
+	m.MessageID = messageID.String
+	m.CorrelationID = correlationID.String
+
+	return m, nil
+}
+
+// nullTime stores the zero time as NULL
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t.UTC(), Valid: !t.IsZero()}
+}
+
+// nullString stores an empty string as NULL
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
@@ -0,0 +1,82 @@
+package sqliteq
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// EnqueueWithMessageID adds an item with a producer-supplied message ID and
+// correlation ID, so it can be tied to the upstream event it came from and looked
+// up with FindByMessageID and FindByCorrelationID. Either may be empty. Message IDs
+// are unique among the items of the queue, including completed items kept by
+// WithRemoveOnComplete(false); a duplicate is reported as ErrDuplicateMessageID.
+// Returns the ID of the new item, like EnqueueWithID
+func (q *Queue) EnqueueWithMessageID(item any, messageID, correlationID string) (int64, error) {
+	return q.enqueueWithID(item, Message{MessageID: messageID, CorrelationID: correlationID})
+}
+
+// EnqueueWithMessageID adds an item with a specified priority, message ID and
+// correlation ID, like Queue.EnqueueWithMessageID
+func (pq *PriorityQueue) EnqueueWithMessageID(item any, priority int, messageID, correlationID string) (int64, error) {
+	return pq.enqueueWithID(item, Message{Priority: priority, MessageID: messageID, CorrelationID: correlationID})
+}
+
+// checkMessageID fails with ErrDuplicateMessageID when an item of the queue already
+// has messageID; the unique index would reject it too, with a less helpful error
+func (q *Queue) checkMessageID(tx *sql.Tx, messageID string) error {
+	if messageID == "" {
+		return nil
+	}
+
+	var exists bool
+	err := tx.QueryRow(
+		fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM %s WHERE message_id = ?)", quoteIdent(q.tableName)), messageID,
+	).Scan(&exists)
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		return fmt.Errorf("%w %q", ErrDuplicateMessageID, messageID)
+	}
+
+	return nil
+}
+
+// FindByMessageID returns the item with the given producer-supplied message ID,
+// whatever its status
+// An ID no item has is reported as ErrUnknownMessageID.
+func (q *Queue) FindByMessageID(messageID string) (m Message, err error) {
+	defer func() { err = mapError(err) }()
+
+	if q.closed.Load() {
+		return Message{}, ErrClosed
+	}
+
+	messages, err := q.queryMessages(q.client, fmt.Sprintf(
+		"SELECT %s FROM %s WHERE message_id = ?", q.messageColumns(), quoteIdent(q.tableName),
+	), messageID)
+	if err != nil {
+		return Message{}, err
+	}
+
+	if len(messages) == 0 {
+		return Message{}, fmt.Errorf("%w %q: %w", ErrUnknownMessageID, messageID, sql.ErrNoRows)
+	}
+
+	return messages[0], nil
+}
+
+// FindByCorrelationID returns the items with the given correlation ID, whatever
+// their status, in insertion order
+func (q *Queue) FindByCorrelationID(correlationID string) (messages []Message, err error) {
+	defer func() { err = mapError(err) }()
+
+	if q.closed.Load() {
+		return nil, ErrClosed
+	}
+
+	return q.queryMessages(q.client, fmt.Sprintf(
+		"SELECT %s FROM %s WHERE correlation_id = ? ORDER BY seq", q.messageColumns(), quoteIdent(q.tableName),
+	), correlationID)
+}
@@ -0,0 +1,58 @@
+package sqliteq
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestEnqueueWithMessageID(t *testing.T) {
+	dbPath := "test_message_id.db"
+	defer os.Remove(dbPath)
+
+	queues := New(dbPath)
+	defer queues.Close()
+
+	q, err := queues.NewQueue("orders", WithRemoveOnComplete(false))
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	id, err := q.EnqueueWithMessageID("created", "evt-1", "order-42")
+	if err != nil {
+		t.Fatalf("EnqueueWithMessageID failed: %v", err)
+	}
+	q.EnqueueWithMessageID("paid", "evt-2", "order-42")
+	q.EnqueueWithMessageID("shipped", "", "order-7")
+
+	if _, err := q.EnqueueWithMessageID("created again", "evt-1", ""); !errors.Is(err, ErrDuplicateMessageID) {
+		t.Errorf("Expected ErrDuplicateMessageID, got %v", err)
+	}
+
+	m, err := q.FindByMessageID("evt-1")
+	if err != nil || m.ID != id || m.CorrelationID != "order-42" || string(m.Data) != "created" {
+		t.Errorf("Expected item %d with its correlation ID, got %+v, %v", id, m, err)
+	}
+
+	// Completed items are still found, and still hold their message ID
+	m, _ = q.DequeueMessageWithAckId()
+	q.Ack(m.AckID)
+
+	if m, err := q.FindByMessageID("evt-1"); err != nil || m.Status != StatusCompleted {
+		t.Errorf("Expected the completed item, got %+v, %v", m, err)
+	}
+
+	if _, err := q.EnqueueWithMessageID("created again", "evt-1", ""); !errors.Is(err, ErrDuplicateMessageID) {
+		t.Errorf("Expected ErrDuplicateMessageID for a completed item's ID, got %v", err)
+	}
+
+	messages, err := q.FindByCorrelationID("order-42")
+	if err != nil || len(messages) != 2 || messages[0].MessageID != "evt-1" || messages[1].MessageID != "evt-2" {
+		t.Errorf("Expected evt-1 and evt-2 in insertion order, got %+v, %v", messages, err)
+	}
+
+	if _, err := q.FindByMessageID("evt-9"); !errors.Is(err, ErrUnknownMessageID) || !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected ErrUnknownMessageID, got %v", err)
+	}
+}
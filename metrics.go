@@ -0,0 +1,115 @@
+package sqliteq
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// metricsContentType is the content type of the Prometheus text exposition format
+const metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// MetricsHandler returns an HTTP handler serving the stats of the queues open in
+// the manager in the Prometheus text exposition format, to be mounted at /metrics
+// so deployments can be scraped without the Prometheus client library.
+func (m *Manager) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var buf bytes.Buffer
+		if err := m.WriteMetrics(&buf); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", metricsContentType)
+		w.Write(buf.Bytes())
+	})
+}
+
+// WriteMetrics writes the stats of the queues open in the manager to w in the
+// Prometheus text exposition format. Queues opened more than once are reported
+// once, with their write contention added up and stalled if any instance is.
+func (m *Manager) WriteMetrics(w io.Writer) error {
+	if m.closed.Load() {
+		return ErrQueuesClosed
+	}
+
+	m.mu.Lock()
+	open := append([]*Queue(nil), m.open...)
+	m.mu.Unlock()
+
+	stats := make(map[string]Stats)
+	for _, q := range open {
+		if q.closed.Load() {
+			continue
+		}
+
+		s, err := q.Stats()
+		if err != nil {
+			return fmt.Errorf("failed to read stats of %s: %w", q.tableName, err)
+		}
+
+		if seen, ok := stats[q.tableName]; ok {
+			seen.Contention.BusyErrors += s.Contention.BusyErrors
+			seen.Contention.Retries += s.Contention.Retries
+			seen.Contention.LockWait += s.Contention.LockWait
+			seen.Stalled = seen.Stalled || s.Stalled
+			s = seen
+		}
+		stats[q.tableName] = s
+	}
+
+	names := make([]string, 0, len(stats))
+	for name := range stats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+
+	metric := func(name, kind, help string, value func(s Stats) string) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+		for _, queue := range names {
+			fmt.Fprintf(&b, "%s{queue=\"%s\"} %s\n", name, escapeLabel(queue), value(stats[queue]))
+		}
+	}
+
+	fmt.Fprintf(&b, "# HELP sqliteq_items Number of items in a queue by status.\n# TYPE sqliteq_items gauge\n")
+	for _, queue := range names {
+		s := stats[queue]
+		for _, status := range []struct {
+			name  Status
+			count int
+		}{{StatusPending, s.Pending}, {StatusProcessing, s.Processing}, {StatusCompleted, s.Completed}, {StatusFailed, s.Failed}} {
+			fmt.Fprintf(&b, "sqliteq_items{queue=\"%s\",status=\"%s\"} %d\n", escapeLabel(queue), status.name, status.count)
+		}
+	}
+
+	metric("sqliteq_oldest_pending_seconds", "gauge", "How long the oldest pending item has been waiting.",
+		func(s Stats) string { return fmt.Sprint(s.OldestPending.Seconds()) })
+	metric("sqliteq_requeued_on_open", "gauge", "Unacknowledged items returned to pending when the queue was opened.",
+		func(s Stats) string { return fmt.Sprint(s.RequeuedOnOpen) })
+	metric("sqliteq_write_busy_errors_total", "counter", "Write attempts that failed because the database was busy or locked.",
+		func(s Stats) string { return fmt.Sprint(s.Contention.BusyErrors) })
+	metric("sqliteq_write_retries_total", "counter", "Write attempts repeated while the database was busy.",
+		func(s Stats) string { return fmt.Sprint(s.Contention.Retries) })
+	metric("sqliteq_write_lock_wait_seconds_total", "counter", "Time spent waiting for the database write lock.",
+		func(s Stats) string { return fmt.Sprint(s.Contention.LockWait.Seconds()) })
+	metric("sqliteq_stalled", "gauge", "1 while pending items have gone without a dequeue past the idle alert limit.",
+		func(s Stats) string {
+			if s.Stalled {
+				return "1"
+			}
+			return "0"
+		})
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// escapeLabel escapes a label value for the Prometheus text format
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
@@ -0,0 +1,58 @@
+package sqliteq
+
+import (
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	dbPath := "test_metrics.db"
+	defer os.Remove(dbPath)
+
+	queues, err := NewManager(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	defer queues.Close()
+
+	q, err := queues.NewQueue("jobs")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	if _, err := queues.NewQueue(`odd"name`); err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	q.Enqueue("a")
+	q.Enqueue("b")
+	q.DequeueWithAckId()
+
+	rec := httptest.NewRecorder()
+	queues.MetricsHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	if rec.Code != 200 {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body)
+	}
+
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Expected the Prometheus text format, got %q", ct)
+	}
+
+	body := rec.Body.String()
+	for _, want := range []string{
+		"# TYPE sqliteq_items gauge\n",
+		`sqliteq_items{queue="jobs",status="pending"} 1` + "\n",
+		`sqliteq_items{queue="jobs",status="processing"} 1` + "\n",
+		`sqliteq_items{queue="odd\"name",status="pending"} 0` + "\n",
+		"# TYPE sqliteq_oldest_pending_seconds gauge\n",
+		"# TYPE sqliteq_write_retries_total counter\n",
+		`sqliteq_write_busy_errors_total{queue="jobs"} 0` + "\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected %q in the metrics, got:\n%s", want, body)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package sqliteq
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MoveTo moves up to n pending items of this queue, in dequeue order, to the end
+// of target in a single transaction, e.g. to drain a queue into a maintenance queue
+// or to spread a backlog over other queues. The items keep their payload, schedule
+// and metadata but get new IDs; moving to a PriorityQueue keeps their priority. A
+// negative n moves every pending item. Both queues must be opened by the same
+// Queues manager.
+// Returns the number of moved items
+func (q *Queue) MoveTo(target *Queue, n int) (int64, error) {
+	return q.move(target, "status = 'pending'", nil, n)
+}
+
+// MoveByID moves the pending items with the given IDs to the end of target in a
+// single transaction, like MoveTo. IDs of items that aren't pending are skipped.
+// Returns the number of moved items
+func (q *Queue) MoveByID(target *Queue, ids ...int64) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+
+	cond := fmt.Sprintf("status = 'pending' AND id IN (%s)", strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", "))
+
+	return q.move(target, cond, args, len(ids))
+}
+
+// move moves up to limit items matching cond to target in a transaction
+func (q *Queue) move(target *Queue, cond string, args []any, limit int) (moved int64, err error) {
+	defer func() { err = mapError(err) }()
+	defer q.invalidateStats()
+	defer q.invalidateFront()
+	defer target.invalidateStats()
+	defer target.invalidateFront()
+	defer q.observeWrite(time.Now(), &err)
+
+	if q.closed.Load() || target.closed.Load() {
+		return 0, ErrClosed
+	}
+
+	if target.manager != q.manager {
+		return 0, errors.New("can't move items to a queue of another manager")
+	}
+
+	if target.tableName == q.tableName {
+		return 0, errors.New("can't move items to their own queue")
+	}
+
+	if err = q.manager.awaitLease(); err != nil {
+		return 0, err
+	}
+
+	tx, err := q.client.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if moved, err = q.moveTx(tx, q, target, cond, args, limit, func(*Message) {}); err != nil {
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	q.freed.notify()
+
+	return moved, nil
+}
+
+// moveTx moves up to limit items of from matching cond with its arguments to the
+// end of to within tx, as pending items adjusted by prepare; a negative limit moves
+// every match
+// Returns the number of moved items
+func (q *Queue) moveTx(tx *sql.Tx, from, to *Queue, cond string, args []any, limit int, prepare func(m *Message)) (int64, error) {
+	rows, err := tx.Query(fmt.Sprintf("SELECT %s FROM %s WHERE %s ORDER BY %s LIMIT ?",
+		from.messageColumns(), quoteIdent(from.tableName), cond, from.orderBy()), append(args, limit)...)
+	if err != nil {
+		return 0, err
+	}
+
+	var messages []Message
+	for rows.Next() {
+		m, err := from.scanMessage(rows)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		messages = append(messages, m)
+	}
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, m := range messages {
+		if _, err = tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE id = ?", quoteIdent(from.tableName)), m.ID); err != nil {
+			return 0, err
+		}
+
+		// The moved item becomes a new pending item of the target queue
+		m.Status, m.AckID, m.UpdatedAt = StatusPending, "", q.now()
+		prepare(&m)
+
+		if err = to.insert(tx, &m); err != nil {
+			return 0, err
+		}
+	}
+
+	return int64(len(messages)), nil
+}
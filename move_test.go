@@ -0,0 +1,64 @@
+package sqliteq
+
+import (
+	"os"
+	"testing"
+)
+
+func TestMoveTo(t *testing.T) {
+	dbPath := "test_move.db"
+	defer os.Remove(dbPath)
+
+	queues := New(dbPath)
+	defer queues.Close()
+
+	q, err := queues.NewQueue("source")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	maintenance, err := queues.NewPriorityQueue("maintenance")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	ids := make([]int64, 4)
+	for i, item := range []string{"a", "b", "c", "d"} {
+		if ids[i], err = q.EnqueueWithID(item); err != nil {
+			t.Fatalf("EnqueueWithID failed: %v", err)
+		}
+	}
+
+	// An item in processing isn't moved
+	q.DequeueWithAckId()
+
+	moved, err := q.MoveTo(maintenance.Queue, 2)
+	if err != nil {
+		t.Fatalf("MoveTo failed: %v", err)
+	}
+	if moved != 2 {
+		t.Errorf("Expected 2 moved items, got %d", moved)
+	}
+
+	moved, err = q.MoveByID(maintenance.Queue, ids[0], ids[3])
+	if err != nil {
+		t.Fatalf("MoveByID failed: %v", err)
+	}
+	if moved != 1 {
+		t.Errorf("Expected 1 moved item, got %d", moved)
+	}
+
+	if n := q.Len(); n != 0 {
+		t.Errorf("Expected no pending items left, got %d", n)
+	}
+
+	for _, want := range []string{"b", "c", "d"} {
+		item, ok := maintenance.Dequeue()
+		if !ok || string(item.([]byte)) != want {
+			t.Errorf("Expected %q, got %v", want, item)
+		}
+	}
+
+	if _, err := q.MoveTo(q, -1); err == nil {
+		t.Error("Expected an error moving items to their own queue")
+	}
+}
@@ -1,8 +1,76 @@
 package sqliteq
 
+import "time"
+
 // Option is a function type that can be used to configure a Queue
 type Option func(*Queue)
 
+// ManagerOption is a function type that can be used to configure a Manager
+type ManagerOption func(*Manager)
+
+// WithAuthorizer sets the Authorizer consulted before destructive operations,
+// such as PurgeContext, on every queue of the manager
+func WithAuthorizer(authorizer Authorizer) ManagerOption {
+	return func(m *Manager) {
+		m.authorizer = authorizer
+	}
+}
+
+// WithPurgeUndo makes purges move the rows of a queue to a trash table instead of
+// deleting them, so the most recent purge within window can be reverted with
+// UndoLastPurge. Trashed rows older than window are deleted on later purges and by
+// RunMaintenance.
+func WithPurgeUndo(window time.Duration) ManagerOption {
+	return func(m *Manager) {
+		m.purgeUndoWindow = window
+	}
+}
+
+// WithDropEmptyAfter drops queues that have been empty and untouched for d, keeping
+// databases with dynamic per-entity queues from accumulating tables. Only queues
+// created by EnqueueTo are dropped, and closed if open; opening one with NewQueue
+// or NewPriorityQueue keeps it for good, like the queues created that way. Zero
+// (the default) never drops queues.
+func WithDropEmptyAfter(d time.Duration) ManagerOption {
+	return func(m *Manager) {
+		m.dropEmptyAfter = d
+	}
+}
+
+// WithWriteLease makes the processes sharing the database take turns writing:
+// a process acquires a lease for d before it enqueues, dequeues or acknowledges
+// items, renewing it while it keeps writing, and others wait for it to expire or
+// be released by Close. This replaces storms of busy errors between many
+// processes on slow storage with orderly waits. Every process must use it.
+func WithWriteLease(d time.Duration) ManagerOption {
+	return func(m *Manager) {
+		if d > 0 {
+			m.lease = newWriteLease(d)
+		}
+	}
+}
+
+// WithLogger sets the Logger told about retried writes, rollbacks, requeues and
+// swallowed errors of every queue of the manager. Nothing is logged by default.
+func WithLogger(logger Logger) ManagerOption {
+	return func(m *Manager) {
+		if logger != nil {
+			m.logger = logger
+		}
+	}
+}
+
+// WithIDGenerator sets the IDGenerator of the ack IDs and write lease holders of
+// every queue of the manager, such as UUIDs to match IDs used elsewhere. ULIDs are
+// generated by default.
+func WithIDGenerator(ids IDGenerator) ManagerOption {
+	return func(m *Manager) {
+		if ids != nil {
+			m.ids = ids
+		}
+	}
+}
+
 // WithRemoveOnComplete sets whether acknowledged items should be deleted
 // from the database when true, or just marked as completed when false
 func WithRemoveOnComplete(remove bool) Option {
@@ -10,3 +78,270 @@ func WithRemoveOnComplete(remove bool) Option {
 		q.removeOnComplete = remove
 	}
 }
+
+// WithStatsHistory records a snapshot of the queue depth every interval into a
+// stats table shared by all queues in the database, so trends can be charted with
+// StatsHistory. Samples older than retention are pruned; zero keeps them forever.
+func WithStatsHistory(interval, retention time.Duration) Option {
+	return func(q *Queue) {
+		q.statsInterval = interval
+		q.statsRetention = retention
+	}
+}
+
+// WithDeliveryGuarantee sets whether items dequeued with an ack ID may be delivered
+// again, AtLeastOnce by default. AtMostOnce disables every path returning them to
+// pending: visibility timeouts, the recovery run when the queue is opened and
+// Drain's handling of failed items.
+func WithDeliveryGuarantee(guarantee DeliveryGuarantee) Option {
+	return func(q *Queue) {
+		q.delivery = guarantee
+	}
+}
+
+// WithRedeliveryOrder sets where items returned to pending by Nack, a failed Drain
+// handler, a visibility timeout or a restart are dequeued among pending items of the
+// same priority: ReclaimedFirst (the default) keeps their place ahead of later
+// arrivals, ArrivalOrder sends them to the back of the line.
+func WithRedeliveryOrder(order RedeliveryOrder) Option {
+	return func(q *Queue) {
+		q.redeliveryOrder = order
+	}
+}
+
+// WithMaxLength bounds the number of pending items so a queue can't grow until it
+// exhausts the disk. What enqueuing to a full queue does depends on the
+// WithOverflowPolicy, rejecting the item with ErrQueueFull by default. Zero (the
+// default) leaves the queue unbounded.
+func WithMaxLength(n int) Option {
+	return func(q *Queue) {
+		q.maxLength = n
+	}
+}
+
+// WithOverflowPolicy sets what enqueuing to a queue at its WithMaxLength limit
+// does: OverflowReject (the default) fails with ErrQueueFull, OverflowDropOldest
+// deletes the oldest pending items to make room and OverflowBlock waits for
+// consumers to make room. Batches of WithGroupCommit can't wait and are rejected
+// under OverflowBlock.
+func WithOverflowPolicy(policy OverflowPolicy) Option {
+	return func(q *Queue) {
+		q.overflow = policy
+	}
+}
+
+// WithDeadLetterQueue moves items that returned to pending more than maxAttempts
+// times, through Nack, failed Drain handlers, visibility timeouts or restarts, to
+// the queue name instead of delivering them again. Moved items keep their payload
+// and attempts, with Origin set to this queue; RedriveDLQ moves them back.
+func WithDeadLetterQueue(name string, maxAttempts int) Option {
+	return func(q *Queue) {
+		if name != "" && maxAttempts > 0 {
+			q.deadLetterName, q.maxAttempts = name, maxAttempts
+		}
+	}
+}
+
+// WithRetryPolicy reschedules items handed back with Nack or by a failed Drain
+// handler instead of returning them to pending at once: the item becomes available
+// again backoff(attempt) later, attempt being its number of deliveries so far. Once
+// an item failed more than maxRetries retries it is moved to the dead-letter queue
+// if there is one, or marked failed. ExponentialBackoff builds a backoff function.
+func WithRetryPolicy(maxRetries int, backoff func(attempt int) time.Duration) Option {
+	return func(q *Queue) {
+		if maxRetries >= 0 && backoff != nil {
+			q.maxRetries, q.retryBackoff = maxRetries, backoff
+		}
+	}
+}
+
+// WithCircuitBreaker makes Drain and Consume stop claiming items once the fraction
+// of failed handler executions among the recent ones reaches threshold, e.g. 0.5,
+// as during a downstream outage. Drain returns ErrCircuitOpen, while Consume pauses
+// and keeps polling. After cooldown a single item is let through as a probe: its
+// success resumes consumption, its failure waits another cooldown.
+func WithCircuitBreaker(threshold float64, cooldown time.Duration) Option {
+	return func(q *Queue) {
+		if threshold > 0 {
+			q.breaker = newCircuitBreaker(threshold, cooldown)
+		}
+	}
+}
+
+// WithExpirySweep deletes the pending items whose EnqueueWithTTL TTL elapsed every
+// interval in the background. Expired items are never dequeued either way; without
+// a sweep they stay in the table until RunMaintenance or a purge removes them.
+func WithExpirySweep(interval time.Duration) Option {
+	return func(q *Queue) {
+		q.expirySweepInterval = interval
+	}
+}
+
+// WithVisibilityTimeout sets how long an item may stay in processing without being
+// acknowledged. A background reaper returns items past the timeout to pending so
+// another worker can pick them up. Zero (the default) disables reclaiming.
+func WithVisibilityTimeout(timeout time.Duration) Option {
+	return func(q *Queue) {
+		q.visibilityTimeout = timeout
+	}
+}
+
+// WithVisibilityTimeoutByPriority sets visibility timeouts per priority, e.g. a short
+// reclaim window for urgent items and a generous one for long-running batch items.
+// Priorities missing from the map use the WithVisibilityTimeout value.
+// Items of a plain queue have priority 0.
+func WithVisibilityTimeoutByPriority(timeouts map[int]time.Duration) Option {
+	return func(q *Queue) {
+		q.visibilityTimeouts = make(map[int]time.Duration, len(timeouts))
+		for priority, timeout := range timeouts {
+			q.visibilityTimeouts[priority] = timeout
+		}
+	}
+}
+
+// WithReclaimPriorityBump raises the priority of an item by bump (lowering its
+// priority number, down to 0) each time the reaper reclaims it, so items that keep
+// timing out aren't starved by new arrivals. It only affects priority queues.
+func WithReclaimPriorityBump(bump int) Option {
+	return func(q *Queue) {
+		q.reclaimPriorityBump = bump
+	}
+}
+
+// WithEnqueueInterceptor adds an interceptor run on every message before it is stored,
+// able to modify or reject it. Interceptors run in the order they were added.
+func WithEnqueueInterceptor(interceptor EnqueueInterceptor) Option {
+	return func(q *Queue) {
+		q.interceptors = append(q.interceptors, interceptor)
+	}
+}
+
+// WithRedactor sets the function Redact passes payloads through before they are
+// displayed by operational tooling, so secrets in job payloads don't leak into logs
+// or dashboards
+func WithRedactor(redactor func(payload []byte) []byte) Option {
+	return func(q *Queue) {
+		q.redactor = redactor
+	}
+}
+
+// WithWriteRetry sets how many times writes that fail because the database is
+// busy or locked are attempted, and the backoff before the first retry, doubling
+// after each one. It currently applies to Ack, Acknowledge, AckMany and Nack, and
+// retries are counted in Stats.Contention. Defaults to 3 attempts with a 10ms
+// backoff; 1 attempt disables retries.
+func WithWriteRetry(attempts int, backoff time.Duration) Option {
+	return func(q *Queue) {
+		if attempts > 0 {
+			q.writeRetryAttempts = attempts
+		}
+		if backoff >= 0 {
+			q.writeRetryBackoff = backoff
+		}
+	}
+}
+
+// WithDequeueCache keeps the IDs of the next size pending items in memory, so
+// dequeues on a hot queue with a large backlog claim a known row instead of
+// sorting the pending items each time. Items enqueued through the queue are added
+// to the cache, and writes returning items to pending empty it. Items enqueued by
+// other processes or becoming due after a delay are only seen once the cache
+// runs empty and is refilled, so their order isn't strict. Zero (the default)
+// disables the cache.
+func WithDequeueCache(size int) Option {
+	return func(q *Queue) {
+		if size > 0 {
+			q.front = &frontCache{size: size}
+		}
+	}
+}
+
+// WithStatsCacheTTL reuses the results of Len and Stats for up to ttl, so frequent
+// health checks and dashboards don't query the database on every call. Writes
+// through this queue invalidate the cache immediately; writes by other processes
+// show up once the TTL elapses. Zero (the default) disables caching.
+func WithStatsCacheTTL(ttl time.Duration) Option {
+	return func(q *Queue) {
+		q.statsCacheTTL = ttl
+	}
+}
+
+// WithJanitorBatchSize sets the maximum number of rows each background maintenance
+// pass (reclaiming, pruning stats and idempotency keys) changes per transaction.
+// Larger passes are split into batches. Defaults to 1000.
+func WithJanitorBatchSize(size int) Option {
+	return func(q *Queue) {
+		if size > 0 {
+			q.janitorBatchSize = size
+		}
+	}
+}
+
+// WithJanitorPause sets how long background maintenance sleeps between batches,
+// leaving producers a window to take the write lock. Defaults to 5ms.
+func WithJanitorPause(pause time.Duration) Option {
+	return func(q *Queue) {
+		if pause >= 0 {
+			q.janitorPause = pause
+		}
+	}
+}
+
+// WithIdempotency sets the namespace in which EnqueueIdempotent claims keys, so
+// queues sharing a namespace create at most one item per key between them, and how
+// long keys are remembered. Expired keys are pruned in the background; zero keeps
+// them forever. Queues without this option use the empty namespace.
+func WithIdempotency(namespace string, ttl time.Duration) Option {
+	return func(q *Queue) {
+		q.idempotencyNamespace = namespace
+		q.idempotencyTTL = ttl
+	}
+}
+
+// WithGroupCommit makes EnqueueAsync collect items and commit them together, at
+// least every maxDelay or once maxBatch items are waiting, so bursts of small
+// enqueues share one transaction. Items are not durable until committed; use
+// WaitDurable when a caller needs confirmation. Enqueue is unaffected.
+func WithGroupCommit(maxDelay time.Duration, maxBatch int) Option {
+	return func(q *Queue) {
+		if maxDelay <= 0 {
+			maxDelay = time.Millisecond
+		}
+		if maxBatch <= 0 {
+			maxBatch = 1
+		}
+		q.groupCommit = newGroupCommit(maxDelay, maxBatch)
+	}
+}
+
+// WithLabels sets the labels of the queue in the registry when it is opened,
+// replacing any it had; see Manager.SetLabels
+func WithLabels(labels map[string]string) Option {
+	return func(q *Queue) {
+		q.labels = make(map[string]string, len(labels))
+		for k, v := range labels {
+			q.labels[k] = v
+		}
+	}
+}
+
+// WithClockSkewTolerance makes expiry decisions allow for the clocks of other hosts
+// sharing the database file, e.g. over a network filesystem, being up to tolerance
+// ahead: visibility timeouts and idempotency key TTLs are only considered elapsed
+// tolerance later. SQLite evaluates 'now' on the host running the query, so using
+// database time instead would not remove the skew.
+func WithClockSkewTolerance(tolerance time.Duration) Option {
+	return func(q *Queue) {
+		q.clockSkew = tolerance
+	}
+}
+
+// WithTimePrecision truncates the timestamps stored for items to a multiple of
+// precision, e.g. time.Millisecond for compact, portable values. Items are ordered
+// by a monotonic sequence number, so coarse or backwards-jumping clocks don't
+// affect dequeue order. Zero (the default) keeps full precision.
+func WithTimePrecision(precision time.Duration) Option {
+	return func(q *Queue) {
+		q.timePrecision = precision
+	}
+}
@@ -0,0 +1,99 @@
+package sqliteq
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// EnqueueTx adds an item within tx, a transaction the caller opened on the queue's
+// database file, so the item is stored if and only if the caller's own writes in
+// tx commit (the transactional outbox pattern). tx may belong to another connection
+// pool than the queue's, e.g. one opened with sql.Open("sqlite3", path).
+// Interceptors and the WithMaxLength limit apply as for Enqueue, except that
+// OverflowBlock rejects the item with ErrQueueFull, as waiting would hold tx open.
+// Until tx commits the item is invisible to the queue; afterwards it is seen like an
+// item enqueued by another process, by the next refill of the WithDequeueCache cache.
+// Returns the ID of the new item
+func (q *Queue) EnqueueTx(tx *sql.Tx, item any) (int64, error) {
+	return q.enqueueTx(tx, item, Message{})
+}
+
+// EnqueueTx adds an item with a specified priority within the caller's transaction,
+// like Queue.EnqueueTx
+func (pq *PriorityQueue) EnqueueTx(tx *sql.Tx, item any, priority int) (int64, error) {
+	return pq.enqueueTx(tx, item, Message{Priority: priority})
+}
+
+// EnqueueIdempotentTx adds an item within the caller's transaction unless key was
+// already claimed, combining EnqueueTx and EnqueueIdempotent: the key is only
+// claimed if tx commits, so a producer retrying a rolled back transaction enqueues
+// the item again.
+// Returns true if the item was enqueued and false if it was a duplicate
+func (q *Queue) EnqueueIdempotentTx(tx *sql.Tx, item any, key string) (bool, error) {
+	return q.enqueueIdempotentTx(tx, item, Message{}, key)
+}
+
+// EnqueueIdempotentTx adds an item with a specified priority within the caller's
+// transaction unless key was already claimed, like Queue.EnqueueIdempotentTx
+func (pq *PriorityQueue) EnqueueIdempotentTx(tx *sql.Tx, item any, priority int, key string) (bool, error) {
+	return pq.enqueueIdempotentTx(tx, item, Message{Priority: priority}, key)
+}
+
+// enqueueIdempotentTx inserts an item within tx and claims key for it
+func (q *Queue) enqueueIdempotentTx(tx *sql.Tx, item any, m Message, key string) (bool, error) {
+	_, err := q.enqueueTx(tx, item, m, func(tx *sql.Tx, m *Message) error {
+		return q.claimKey(tx, key, m.ID)
+	})
+	if errors.Is(err, errDuplicate) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// enqueueTx inserts a message within the caller's transaction, running hooks after it
+func (q *Queue) enqueueTx(tx *sql.Tx, item any, m Message, hooks ...func(tx *sql.Tx, m *Message) error) (id int64, err error) {
+	defer func() { err = mapError(err) }()
+	defer q.invalidateStats()
+
+	if q.closed.Load() {
+		return 0, ErrClosed
+	}
+
+	if m.Data, err = payloadBytes(item); err != nil {
+		return 0, err
+	}
+
+	m.Status = StatusPending
+	if err := q.intercept(&m); err != nil {
+		return 0, err
+	}
+
+	// A failed enqueue leaves the caller's transaction as it was, so the caller
+	// can still commit its other writes, e.g. after a duplicate key
+	if _, err = tx.Exec("SAVEPOINT sqliteq_enqueue"); err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Exec("ROLLBACK TO sqliteq_enqueue")
+		}
+		tx.Exec("RELEASE sqliteq_enqueue")
+	}()
+
+	if err = q.makeRoom(tx, 1, false); err != nil {
+		return 0, err
+	}
+
+	if err = q.insert(tx, &m); err != nil {
+		return 0, err
+	}
+
+	for _, hook := range hooks {
+		if err = hook(tx, &m); err != nil {
+			return 0, err
+		}
+	}
+
+	return m.ID, nil
+}
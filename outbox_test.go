@@ -0,0 +1,86 @@
+package sqliteq
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestEnqueueTx(t *testing.T) {
+	dbPath := "test_outbox.db"
+	defer os.Remove(dbPath)
+
+	queues := New(dbPath)
+	defer queues.Close()
+
+	q, err := queues.NewQueue("outbox")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	// The application writes through its own connection pool
+	db, err := sql.Open("sqlite3", dbPath+"?_busy_timeout=5000")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec("CREATE TABLE orders (id INTEGER PRIMARY KEY, total INTEGER)"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	placeOrder := func(total int, commit bool) {
+		tx, err := db.Begin()
+		if err != nil {
+			t.Fatalf("Failed to begin: %v", err)
+		}
+
+		tx.Exec("INSERT INTO orders (total) VALUES (?)", total)
+		if _, err := q.EnqueueTx(tx, fmt.Sprintf(`{"total":%d}`, total)); err != nil {
+			t.Fatalf("EnqueueTx failed: %v", err)
+		}
+
+		if commit {
+			err = tx.Commit()
+		} else {
+			err = tx.Rollback()
+		}
+		if err != nil {
+			t.Fatalf("Failed to end transaction: %v", err)
+		}
+	}
+
+	placeOrder(10, false)
+	if q.Len() != 0 {
+		t.Errorf("Expected no item after a rollback, got %d", q.Len())
+	}
+
+	placeOrder(20, true)
+	if item, ok := q.Dequeue(); !ok || string(item.([]byte)) != `{"total":20}` {
+		t.Errorf("Expected the committed order's item, got %s", item)
+	}
+
+	t.Run("Idempotent", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			tx, _ := db.Begin()
+			tx.Exec("INSERT INTO orders (total) VALUES (30)")
+
+			enqueued, err := q.EnqueueIdempotentTx(tx, "order-30", "order-30")
+			if err != nil || enqueued != (i == 0) {
+				t.Fatalf("Attempt %d: expected enqueued %v, got %v, %v", i, i == 0, enqueued, err)
+			}
+
+			// A duplicate leaves the caller's own writes in place
+			if err := tx.Commit(); err != nil {
+				t.Fatalf("Commit failed: %v", err)
+			}
+		}
+
+		var orders int
+		db.QueryRow("SELECT COUNT(*) FROM orders WHERE total = 30").Scan(&orders)
+		if orders != 2 || q.Len() != 1 {
+			t.Errorf("Expected 2 orders and 1 item, got %d and %d", orders, q.Len())
+		}
+	})
+}
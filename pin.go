@@ -0,0 +1,75 @@
+package sqliteq
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Pin exempts the item in processing holding ackID from its visibility timeout,
+// e.g. while it waits on a known long maintenance operation, until Unpin or its
+// acknowledgment. Pinned items are still requeued when the queue is reopened, and
+// InFlight lists them so they aren't forgotten.
+// An ack ID no item in processing holds is reported as ErrUnknownAckID, or as
+// ErrStaleClaim with WithAckTokens.
+func (q *Queue) Pin(ackID string) error {
+	return q.setPinned(ackID, true)
+}
+
+// Unpin subjects a pinned item to its visibility timeout again, counted from now
+// An ack ID no item in processing holds is reported as ErrUnknownAckID, or as
+// ErrStaleClaim with WithAckTokens.
+func (q *Queue) Unpin(ackID string) error {
+	return q.setPinned(ackID, false)
+}
+
+// setPinned pins or unpins the processing item holding ackID
+func (q *Queue) setPinned(ackID string, pinned bool) (err error) {
+	defer func() { err = mapError(err) }()
+	defer q.invalidateStats()
+
+	if q.closed.Load() {
+		return ErrClosed
+	}
+
+	if err = q.verifyAckID(ackID); err != nil {
+		return err
+	}
+
+	if err = q.checkEpoch(q.client, ackID); err != nil {
+		return err
+	}
+
+	// Unpinning restarts the timeout, so the item isn't reclaimed right away
+	updated, err := rowsAffected(q.client.Exec(
+		fmt.Sprintf("UPDATE %s SET pinned = ?, updated_at = ? WHERE ack_id = ? AND status = 'processing'", quoteIdent(q.tableName)),
+		pinned, q.now(), ackID,
+	))
+	if err != nil {
+		return err
+	}
+
+	if updated == 0 {
+		if q.ackTokens != nil {
+			return q.noClaim(ackID)
+		}
+
+		return fmt.Errorf("%w %q: %w", ErrUnknownAckID, ackID, sql.ErrNoRows)
+	}
+
+	return nil
+}
+
+// InFlight returns up to limit items in processing with their metadata, oldest
+// update first, skipping the first offset items. Pinned items have Pinned set.
+func (q *Queue) InFlight(offset, limit int) (messages []Message, err error) {
+	defer func() { err = mapError(err) }()
+
+	if q.closed.Load() {
+		return nil, ErrClosed
+	}
+
+	return q.queryMessages(q.client, fmt.Sprintf(
+		"SELECT %s FROM %s WHERE status = 'processing' ORDER BY updated_at, id LIMIT ? OFFSET ?",
+		q.messageColumns(), quoteIdent(q.tableName),
+	), limit, offset)
+}
@@ -0,0 +1,66 @@
+package sqliteq
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestPin(t *testing.T) {
+	dbPath := "test_pin.db"
+	defer os.Remove(dbPath)
+
+	queues := New(dbPath)
+	defer queues.Close()
+
+	q, err := queues.NewQueue("maintenance", WithVisibilityTimeout(time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	q.Enqueue("migration")
+	q.Enqueue("report")
+
+	m, err := q.DequeueMessageWithAckId()
+	if err != nil {
+		t.Fatalf("Dequeue failed: %v", err)
+	}
+	q.DequeueWithAckId()
+
+	if err := q.Pin(m.AckID); err != nil {
+		t.Fatalf("Pin failed: %v", err)
+	}
+
+	age := func() {
+		q.client.Exec("UPDATE maintenance SET updated_at = ?", time.Now().UTC().Add(-2*time.Hour))
+	}
+
+	age()
+	if reclaimed, err := q.reclaimExpired(); err != nil || reclaimed != 1 {
+		t.Fatalf("Expected only the unpinned item reclaimed, got %d, %v", reclaimed, err)
+	}
+
+	inFlight, err := q.InFlight(0, 10)
+	if err != nil || len(inFlight) != 1 || !inFlight[0].Pinned || string(inFlight[0].Data) != "migration" {
+		t.Fatalf("Expected the pinned 'migration' in flight, got %+v, %v", inFlight, err)
+	}
+
+	if err := q.Unpin(m.AckID); err != nil {
+		t.Fatalf("Unpin failed: %v", err)
+	}
+
+	// Unpinning restarts the timeout
+	if reclaimed, _ := q.reclaimExpired(); reclaimed != 0 {
+		t.Errorf("Expected the unpinned item to get a fresh timeout, reclaimed %d", reclaimed)
+	}
+
+	age()
+	if reclaimed, _ := q.reclaimExpired(); reclaimed != 1 {
+		t.Errorf("Expected the unpinned item reclaimed once its timeout elapsed, reclaimed %d", reclaimed)
+	}
+
+	if err := q.Pin(m.AckID); !errors.Is(err, ErrUnknownAckID) {
+		t.Errorf("Expected ErrUnknownAckID for an item no longer in processing, got %v", err)
+	}
+}
@@ -4,8 +4,6 @@ import (
 	"database/sql"
 	"fmt"
 	"time"
-
-	"github.com/lucsky/cuid"
 )
 
 // PriorityQueue extends Queue with priority-based dequeuing
@@ -14,152 +12,163 @@ type PriorityQueue struct {
 }
 
 // newPriorityQueue creates a new SQLite-based priority queue
-func newPriorityQueue(db *sql.DB, tableName string, opts ...Option) (*PriorityQueue, error) {
-	baseQueue, err := newQueue(db, tableName, opts...)
+func newPriorityQueue(m *Manager, tableName string, opts ...Option) (*PriorityQueue, error) {
+	baseQueue, err := newQueue(m, tableName, append([]Option{withPriority()}, opts...)...)
 	if err != nil {
 		return nil, err
 	}
 
-	pq := &PriorityQueue{
+	return &PriorityQueue{
 		Queue: baseQueue,
-	}
+	}, nil
+}
 
-	// Add the priority column if it doesn't exist
-	if err := pq.initPriorityColumn(); err != nil {
-		return nil, fmt.Errorf("failed to initialize priority column: %w", err)
+// withPriority marks the queue as a priority queue before its table is initialized
+func withPriority() Option {
+	return func(q *Queue) {
+		q.priority = true
 	}
-
-	return pq, nil
 }
 
 // initPriorityColumn adds the priority column to the table if it doesn't exist
-func (pq *PriorityQueue) initPriorityColumn() error {
-	// Check if priority column exists
-	var name string
-	err := pq.client.QueryRow(fmt.Sprintf("PRAGMA table_info(%s)", quoteIdent(pq.tableName))).Scan(nil, &name, nil, nil, nil, nil)
+func (q *Queue) initPriorityColumn() error {
+	exists, err := hasColumn(q.client, q.tableName, "priority")
+	if err != nil {
+		return err
+	}
 
-	if err != nil || name != "priority" {
+	if !exists {
 		// Add priority column with default value 0
-		_, err := pq.client.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN priority INTEGER NOT NULL DEFAULT 0", quoteIdent(pq.tableName)))
-		if err != nil {
-			return err
-		}
-
-		// Create index on priority (ASC for lower numbers = higher priority)
-		_, err = pq.client.Exec(fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (priority ASC, created_at ASC)", quoteIdent(pq.tableName+"_priority_idx"), quoteIdent(pq.tableName)))
+		_, err := q.client.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN priority INTEGER NOT NULL DEFAULT 0", quoteIdent(q.tableName)))
 		if err != nil {
 			return err
 		}
 	}
 
-	return nil
+	// Create index on pending items by priority (ASC for lower numbers = higher priority),
+	// replacing the index on creation time used before items were ordered by seq
+	_, err = q.client.Exec(fmt.Sprintf(`
+	DROP INDEX IF EXISTS %[2]s;
+	CREATE INDEX IF NOT EXISTS %[3]s ON %[1]s (priority ASC, seq ASC) WHERE status = 'pending';
+	`, quoteIdent(q.tableName), quoteIdent(q.tableName+"_priority_idx"), quoteIdent(q.tableName+"_priority_seq_idx")))
+	return err
 }
 
 // Enqueue adds an item to the queue with a specified priority
 // Lower priority numbers will be dequeued first (0 is highest priority)
 // Returns true if the operation was successful
 func (pq *PriorityQueue) Enqueue(item any, priority int) bool {
-	if pq.closed.Load() {
-		return false
-	}
+	return pq.succeeded("enqueue", pq.enqueue(item, priority))
+}
 
-	now := time.Now().UTC()
-	tx, err := pq.client.Begin()
+// EnqueueWithID adds an item with a specified priority and returns its ID for
+// WaitForAck
+func (pq *PriorityQueue) EnqueueWithID(item any, priority int) (int64, error) {
+	return pq.enqueueWithID(item, Message{Priority: priority})
+}
 
-	if err != nil {
+// EnqueueAt adds an item with a specified priority that isn't dequeued before t
+// Returns true if the operation was successful
+func (pq *PriorityQueue) EnqueueAt(item any, priority int, t time.Time) bool {
+	return pq.succeeded("enqueue", pq.enqueueMessage(item, Message{Priority: priority, NotBefore: t}))
+}
+
+// EnqueueAfter adds an item with a specified priority that isn't dequeued before
+// delay has passed
+// Returns true if the operation was successful
+func (pq *PriorityQueue) EnqueueAfter(item any, priority int, delay time.Duration) bool {
+	return pq.EnqueueAt(item, priority, time.Now().Add(delay))
+}
+
+// EnqueueRepeating adds an item with a specified priority that is enqueued again
+// every interval after it has been dequeued or acknowledged, until the given time.
+// A zero until repeats forever.
+// Returns true if the operation was successful
+func (pq *PriorityQueue) EnqueueRepeating(item any, priority int, every time.Duration, until time.Time) bool {
+	if every <= 0 {
 		return false
 	}
 
-	defer func() {
-		if err != nil {
-			tx.Rollback()
-		}
-	}()
+	return pq.succeeded("enqueue", pq.enqueueMessage(item, Message{Priority: priority, RepeatEvery: every, RepeatUntil: until}))
+}
+
+// EnqueueIdempotent adds an item with a specified priority unless key was already
+// claimed in the queue's idempotency namespace
+// Returns true if the item was enqueued and false if it was a duplicate
+func (pq *PriorityQueue) EnqueueIdempotent(item any, priority int, key string) (bool, error) {
+	return pq.enqueueIdempotent(item, Message{Priority: priority}, key)
+}
 
-	_, err = tx.Exec(
-		fmt.Sprintf("INSERT INTO %s (data, status, created_at, updated_at, priority) VALUES (?, ?, ?, ?, ?)", quoteIdent(pq.tableName)),
-		item, "pending", now, now, priority,
-	)
+// EnqueueAsync adds an item with a specified priority without waiting for it to be
+// committed; see Queue.EnqueueAsync
+func (pq *PriorityQueue) EnqueueAsync(item any, priority int) (EnqueueToken, error) {
+	return pq.enqueueAsync(item, Message{Priority: priority})
+}
 
-	if err != nil {
-		return false
+// DequeueUpTo removes and returns the next item whose priority is less than or
+// equal to maxPriority, leaving lower priority items for other workers
+// Returns the item and a boolean indicating if the operation was successful
+func (pq *PriorityQueue) DequeueUpTo(maxPriority int) (any, bool) {
+	m, err := pq.dequeueInternal(false, "priority <= ?", maxPriority)
+	if !pq.succeeded("dequeue", err) {
+		return nil, false
 	}
 
-	err = tx.Commit()
+	return m.Data, true
+}
 
-	return err == nil
+// DequeueMessageUpTo is like DequeueUpTo but returns the item's metadata and reports
+// why nothing was returned, see Queue.DequeueMessage
+func (pq *PriorityQueue) DequeueMessageUpTo(maxPriority int) (Message, error) {
+	return pq.dequeueMessage(false, "priority <= ?", maxPriority)
 }
 
-// dequeueInternal overrides the base dequeueInternal method to consider priority
-func (pq *PriorityQueue) dequeueInternal(withAckId bool) (any, bool, string) {
-	if pq.closed.Load() {
+// DequeueWithAckIdUpTo is like DequeueUpTo but keeps the item in processing
+// state until it is acknowledged
+// Returns the item, a boolean indicating if the operation was successful, and the acknowledgment ID
+func (pq *PriorityQueue) DequeueWithAckIdUpTo(maxPriority int) (any, bool, string) {
+	m, err := pq.dequeueInternal(true, "priority <= ?", maxPriority)
+	if !pq.succeeded("dequeue", err) {
 		return nil, false, ""
 	}
 
-	tx, err := pq.client.Begin()
+	return m.Data, true, m.AckID
+}
 
-	if err != nil {
-		return nil, false, ""
-	}
+// DequeueMessageWithAckIdUpTo is like DequeueWithAckIdUpTo but returns the item's
+// metadata and reports why nothing was returned, see Queue.DequeueMessage
+func (pq *PriorityQueue) DequeueMessageWithAckIdUpTo(maxPriority int) (Message, error) {
+	return pq.dequeueMessage(true, "priority <= ?", maxPriority)
+}
 
-	defer func() {
-		if err != nil {
-			tx.Rollback()
-		}
-	}()
-
-	// Get the highest priority pending item (lower priority numbers come first)
-	var id int64
-	var data []byte
-	row := tx.QueryRow(fmt.Sprintf(
-		"SELECT id, data FROM %s WHERE status = 'pending' ORDER BY priority ASC, created_at ASC LIMIT 1",
-		quoteIdent(pq.tableName),
-	))
-	err = row.Scan(&id, &data)
-	if err != nil {
-		return nil, false, ""
-	}
+// UpdatePriority moves the pending item with the given ID to newPriority, e.g. to
+// bump or demote work at runtime. The item keeps its place in insertion order, so it
+// is dequeued after the items of newPriority enqueued before it.
+// An ID no pending item has is reported as ErrNotPending.
+func (pq *PriorityQueue) UpdatePriority(id int64, newPriority int) (err error) {
+	defer func() { err = mapError(err) }()
+	defer pq.invalidateFront()
+	defer pq.observeWrite(time.Now(), &err)
 
-	// Update the status to 'processing' with ack ID or remove directly if no ack ID
-	now := time.Now().UTC()
-	var ackID string
-
-	if withAckId {
-		ackID = cuid.New()
-
-		_, err = tx.Exec(
-			fmt.Sprintf("UPDATE %s SET status = 'processing', ack_id = ?, updated_at = ? WHERE id = ?", quoteIdent(pq.tableName)),
-			ackID, now, id,
-		)
-	} else {
-		// remove the row if there is no ack
-		_, err = tx.Exec(
-			fmt.Sprintf("DELETE FROM %s WHERE id = ?", quoteIdent(pq.tableName)),
-			id,
-		)
+	if pq.closed.Load() {
+		return ErrClosed
 	}
 
-	if err != nil {
-		return nil, false, ""
+	if err = pq.manager.awaitLease(); err != nil {
+		return err
 	}
 
-	// Commit transaction
-	err = tx.Commit()
-
+	updated, err := rowsAffected(pq.client.Exec(
+		fmt.Sprintf("UPDATE %s SET priority = ? WHERE id = ? AND status = 'pending'", quoteIdent(pq.tableName)),
+		newPriority, id,
+	))
 	if err != nil {
-		return nil, false, ""
+		return err
 	}
 
-	return data, true, ackID
-}
-
-// Dequeue overrides the base Dequeue method to use priority-based dequeuing
-func (pq *PriorityQueue) Dequeue() (any, bool) {
-	item, success, _ := pq.dequeueInternal(false)
-	return item, success
-}
+	if updated == 0 {
+		return fmt.Errorf("%w: %d: %w", ErrNotPending, id, sql.ErrNoRows)
+	}
 
-// DequeueWithAckId overrides the base DequeueWithAckId method to use priority-based dequeuing
-func (pq *PriorityQueue) DequeueWithAckId() (any, bool, string) {
-	return pq.dequeueInternal(true)
+	return nil
 }
@@ -1,6 +1,7 @@
 package sqliteq
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"testing"
@@ -553,3 +554,221 @@ func TestPriorityQueueConcurrentOperations(t *testing.T) {
 		t.Errorf("Expected empty queue, got length %d", pq.Len())
 	}
 }
+
+// Test dequeuing restricted to a maximum priority
+func TestPriorityQueueDequeueUpTo(t *testing.T) {
+	dbPath := "test_priority_up_to.db"
+	defer os.Remove(dbPath)
+
+	queuesInstance := New(dbPath)
+	pq, err := queuesInstance.NewPriorityQueue("test_priority_queue")
+	if err != nil {
+		t.Fatalf("Failed to create priority queue: %v", err)
+	}
+	defer queuesInstance.Close()
+
+	pq.Enqueue([]byte("background"), 20)
+	pq.Enqueue([]byte("urgent"), 1)
+	pq.Enqueue([]byte("normal"), 10)
+
+	t.Run("SkipsLowerPriority", func(t *testing.T) {
+		item, success := pq.DequeueUpTo(5)
+		if !success {
+			t.Fatal("DequeueUpTo failed")
+		}
+
+		if string(item.([]byte)) != "urgent" {
+			t.Errorf("Expected 'urgent', got '%s'", string(item.([]byte)))
+		}
+
+		// Nothing else has priority <= 5
+		if item, success := pq.DequeueUpTo(5); success {
+			t.Errorf("Expected no item with priority <= 5, got '%s'", string(item.([]byte)))
+		}
+
+		if pq.Len() != 2 {
+			t.Errorf("Expected queue length 2, got %d", pq.Len())
+		}
+	})
+
+	t.Run("WithAckId", func(t *testing.T) {
+		item, success, ackID := pq.DequeueWithAckIdUpTo(10)
+		if !success {
+			t.Fatal("DequeueWithAckIdUpTo failed")
+		}
+
+		if string(item.([]byte)) != "normal" {
+			t.Errorf("Expected 'normal', got '%s'", string(item.([]byte)))
+		}
+
+		if !pq.Acknowledge(ackID) {
+			t.Error("Acknowledge failed")
+		}
+
+		if _, success, _ := pq.DequeueWithAckIdUpTo(10); success {
+			t.Error("Expected no item with priority <= 10")
+		}
+	})
+}
+
+// Test that priority operations honor the closed state
+func TestPriorityQueueOperationsAfterClose(t *testing.T) {
+	dbPath := "test_priority_after_close.db"
+	defer os.Remove(dbPath)
+
+	queuesInstance := New(dbPath)
+	pq, err := queuesInstance.NewPriorityQueue("test_priority_queue")
+	if err != nil {
+		t.Fatalf("Failed to create priority queue: %v", err)
+	}
+	defer queuesInstance.Close()
+
+	pq.Enqueue([]byte("item"), 1)
+	pq.Close()
+
+	if pq.Enqueue([]byte("late item"), 0) {
+		t.Error("Enqueue should fail after Close")
+	}
+
+	if _, success := pq.Dequeue(); success {
+		t.Error("Dequeue should fail after Close")
+	}
+
+	if _, success := pq.DequeueUpTo(5); success {
+		t.Error("DequeueUpTo should fail after Close")
+	}
+
+	if _, success, _ := pq.DequeueWithAckIdUpTo(5); success {
+		t.Error("DequeueWithAckIdUpTo should fail after Close")
+	}
+
+	if err := pq.enqueue([]byte("late item"), 0); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed, got %v", err)
+	}
+}
+
+// Test reopening a database holding an existing priority queue
+func TestPriorityQueueReopenDatabase(t *testing.T) {
+	dbPath := "test_priority_reopen.db"
+	defer os.Remove(dbPath)
+
+	queuesInstance := New(dbPath)
+	pq, err := queuesInstance.NewPriorityQueue("test_priority_queue")
+	if err != nil {
+		t.Fatalf("Failed to create priority queue: %v", err)
+	}
+
+	pq.Enqueue([]byte("low"), 5)
+	pq.Enqueue([]byte("high"), 1)
+	queuesInstance.Close()
+
+	queuesInstance = New(dbPath)
+	defer queuesInstance.Close()
+
+	pq, err = queuesInstance.NewPriorityQueue("test_priority_queue")
+	if err != nil {
+		t.Fatalf("Failed to reopen priority queue: %v", err)
+	}
+
+	item, success := pq.Dequeue()
+	if !success || string(item.([]byte)) != "high" {
+		t.Errorf("Expected 'high', got %v", item)
+	}
+}
+
+// Test that Values and Page follow priority order and expose priorities
+func TestPriorityQueueValuesAndPage(t *testing.T) {
+	dbPath := "test_priority_page.db"
+	defer os.Remove(dbPath)
+
+	queuesInstance := New(dbPath)
+	pq, err := queuesInstance.NewPriorityQueue("test_priority_queue")
+	if err != nil {
+		t.Fatalf("Failed to create priority queue: %v", err)
+	}
+	defer queuesInstance.Close()
+
+	pq.Enqueue([]byte("low"), 20)
+	pq.Enqueue([]byte("high"), 0)
+	pq.Enqueue([]byte("medium"), 10)
+	pq.Enqueue([]byte("second high"), 0)
+
+	want := []string{"high", "second high", "medium", "low"}
+	wantPriorities := []int{0, 0, 10, 20}
+
+	t.Run("Values", func(t *testing.T) {
+		values := pq.Values()
+		if len(values) != len(want) {
+			t.Fatalf("Expected %d values, got %d", len(want), len(values))
+		}
+
+		for i, value := range values {
+			if string(value.([]byte)) != want[i] {
+				t.Errorf("Expected '%s' at position %d, got '%s'", want[i], i, string(value.([]byte)))
+			}
+		}
+	})
+
+	t.Run("Page", func(t *testing.T) {
+		messages, err := pq.Page(1, 2)
+		if err != nil {
+			t.Fatalf("Page failed: %v", err)
+		}
+
+		if len(messages) != 2 {
+			t.Fatalf("Expected 2 messages, got %d", len(messages))
+		}
+
+		for i, m := range messages {
+			if string(m.Data) != want[i+1] || m.Priority != wantPriorities[i+1] {
+				t.Errorf("Expected '%s' with priority %d, got '%s' with priority %d",
+					want[i+1], wantPriorities[i+1], string(m.Data), m.Priority)
+			}
+
+			if m.Status != StatusPending || m.ID == 0 || m.CreatedAt.IsZero() {
+				t.Errorf("Expected pending message with ID and creation time, got %+v", m)
+			}
+		}
+	})
+}
+
+func TestPriorityQueueUpdatePriority(t *testing.T) {
+	dbPath := "test_priority_queue_update_priority.db"
+	defer os.Remove(dbPath)
+
+	queues := New(dbPath)
+	defer queues.Close()
+
+	pq, err := queues.NewPriorityQueue("reprioritize", WithDequeueCache(4))
+	if err != nil {
+		t.Fatalf("Failed to create priority queue: %v", err)
+	}
+
+	oldest, _ := pq.EnqueueWithID("oldest", 5)
+	pq.Enqueue("urgent", 1)
+	pq.Enqueue("routine", 5)
+	newest, _ := pq.EnqueueWithID("newest", 5)
+
+	// Warm up the dequeue cache, which the update must not leave stale
+	if item, _ := pq.Dequeue(); string(item.([]byte)) != "urgent" {
+		t.Fatalf("Expected 'urgent' first, got %s", item)
+	}
+
+	if err := pq.UpdatePriority(newest, 1); err != nil {
+		t.Fatalf("UpdatePriority failed: %v", err)
+	}
+	if err := pq.UpdatePriority(oldest, 1); err != nil {
+		t.Fatalf("UpdatePriority failed: %v", err)
+	}
+
+	// Bumped items keep their insertion order among themselves
+	for _, want := range []string{"oldest", "newest", "routine"} {
+		if item, _ := pq.Dequeue(); string(item.([]byte)) != want {
+			t.Errorf("Expected %q, got %s", want, item)
+		}
+	}
+
+	if err := pq.UpdatePriority(oldest, 0); !errors.Is(err, ErrNotPending) {
+		t.Errorf("Expected ErrNotPending for a dequeued item, got %v", err)
+	}
+}
@@ -0,0 +1,138 @@
+// Package prometheus exports the metrics of sqliteq queues through the Prometheus
+// client library: item counts by status, the age of the oldest pending item,
+// enqueue, dequeue and ack totals, ack latency and write contention, labeled with
+// the queue name.
+//
+//	import sqliteqprom "github.com/goptics/sqliteq/prometheus"
+//
+//	prometheus.MustRegister(sqliteqprom.NewCollector(jobs, emails))
+//
+// Priority queues are collected through their embedded Queue.
+//
+// Manager.MetricsHandler serves similar metrics without this dependency.
+package prometheus
+
+import (
+	"errors"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+
+	"github.com/goptics/sqliteq"
+)
+
+var (
+	itemsDesc = prom.NewDesc("sqliteq_items",
+		"Number of items in a queue by status; processing items are in flight.", []string{"queue", "status"}, nil)
+	oldestPendingDesc = prom.NewDesc("sqliteq_oldest_pending_seconds",
+		"How long the oldest pending item has been waiting.", []string{"queue"}, nil)
+	enqueuedDesc = prom.NewDesc("sqliteq_enqueued_total",
+		"Items enqueued through the queue since it was opened.", []string{"queue"}, nil)
+	dequeuedDesc = prom.NewDesc("sqliteq_dequeued_total",
+		"Items dequeued through the queue since it was opened.", []string{"queue"}, nil)
+	ackedDesc = prom.NewDesc("sqliteq_acked_total",
+		"Items acknowledged through the queue since it was opened.", []string{"queue"}, nil)
+	ackLatencyDesc = prom.NewDesc("sqliteq_ack_latency_seconds",
+		"Time acknowledged items spent in processing.", []string{"queue"}, nil)
+	busyErrorsDesc = prom.NewDesc("sqliteq_write_busy_errors_total",
+		"Write attempts that failed because the database was busy or locked.", []string{"queue"}, nil)
+	retriesDesc = prom.NewDesc("sqliteq_write_retries_total",
+		"Write attempts repeated while the database was busy.", []string{"queue"}, nil)
+)
+
+// Collector collects the metrics of a set of queues
+type Collector struct {
+	queues []*sqliteq.Queue
+}
+
+// NewCollector returns a collector of the given queues, to be registered on any
+// prometheus.Registerer. Counters only cover operations made through these queue
+// instances, so collect the queues of every process. Instances of the same queue
+// are reported once, with their counters added up. Closed queues are skipped.
+func NewCollector(queues ...*sqliteq.Queue) *Collector {
+	return &Collector{queues: queues}
+}
+
+// Register registers a collector of queues on reg
+func Register(reg prom.Registerer, queues ...*sqliteq.Queue) error {
+	return reg.Register(NewCollector(queues...))
+}
+
+// Describe implements prometheus.Collector
+func (c *Collector) Describe(ch chan<- *prom.Desc) {
+	for _, desc := range []*prom.Desc{itemsDesc, oldestPendingDesc, enqueuedDesc, dequeuedDesc, ackedDesc, ackLatencyDesc, busyErrorsDesc, retriesDesc} {
+		ch <- desc
+	}
+}
+
+// snapshot is what is collected of a queue, summed over its instances
+type snapshot struct {
+	stats   sqliteq.Stats
+	metrics sqliteq.Metrics
+}
+
+// Collect implements prometheus.Collector
+func (c *Collector) Collect(ch chan<- prom.Metric) {
+	var names []string
+	snapshots := make(map[string]*snapshot)
+
+	for _, q := range c.queues {
+		stats, err := q.Stats()
+		if errors.Is(err, sqliteq.ErrClosed) {
+			continue
+		}
+		if err != nil {
+			ch <- prom.NewInvalidMetric(itemsDesc, err)
+			continue
+		}
+
+		metrics := q.Metrics()
+
+		s, ok := snapshots[q.Name()]
+		if !ok {
+			names = append(names, q.Name())
+			snapshots[q.Name()] = &snapshot{stats: stats, metrics: metrics}
+			continue
+		}
+
+		s.stats.Contention.BusyErrors += stats.Contention.BusyErrors
+		s.stats.Contention.Retries += stats.Contention.Retries
+		s.metrics.Enqueued += metrics.Enqueued
+		s.metrics.Dequeued += metrics.Dequeued
+		s.metrics.Acked += metrics.Acked
+		s.metrics.AckLatency.Count += metrics.AckLatency.Count
+		s.metrics.AckLatency.Sum += metrics.AckLatency.Sum
+		for i := range s.metrics.AckLatency.Buckets {
+			s.metrics.AckLatency.Buckets[i].Count += metrics.AckLatency.Buckets[i].Count
+		}
+	}
+
+	for _, name := range names {
+		s := snapshots[name]
+
+		for _, item := range []struct {
+			status sqliteq.Status
+			count  int
+		}{
+			{sqliteq.StatusPending, s.stats.Pending},
+			{sqliteq.StatusProcessing, s.stats.Processing},
+			{sqliteq.StatusCompleted, s.stats.Completed},
+			{sqliteq.StatusFailed, s.stats.Failed},
+		} {
+			ch <- prom.MustNewConstMetric(itemsDesc, prom.GaugeValue, float64(item.count), name, string(item.status))
+		}
+
+		ch <- prom.MustNewConstMetric(oldestPendingDesc, prom.GaugeValue, s.stats.OldestPending.Seconds(), name)
+		ch <- prom.MustNewConstMetric(enqueuedDesc, prom.CounterValue, float64(s.metrics.Enqueued), name)
+		ch <- prom.MustNewConstMetric(dequeuedDesc, prom.CounterValue, float64(s.metrics.Dequeued), name)
+		ch <- prom.MustNewConstMetric(ackedDesc, prom.CounterValue, float64(s.metrics.Acked), name)
+		ch <- prom.MustNewConstMetric(busyErrorsDesc, prom.CounterValue, float64(s.stats.Contention.BusyErrors), name)
+		ch <- prom.MustNewConstMetric(retriesDesc, prom.CounterValue, float64(s.stats.Contention.Retries), name)
+
+		latency := s.metrics.AckLatency
+		buckets := make(map[float64]uint64, len(latency.Buckets))
+		for _, b := range latency.Buckets {
+			buckets[b.UpperBound.Seconds()] = uint64(b.Count)
+		}
+		ch <- prom.MustNewConstHistogram(ackLatencyDesc, uint64(latency.Count), latency.Sum.Seconds(), buckets, name)
+	}
+}
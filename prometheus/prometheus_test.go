@@ -0,0 +1,80 @@
+package prometheus
+
+import (
+	"os"
+	"testing"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/goptics/sqliteq"
+)
+
+func TestCollector(t *testing.T) {
+	dbPath := "test_prometheus.db"
+	defer os.Remove(dbPath)
+
+	queues := sqliteq.New(dbPath)
+	defer queues.Close()
+
+	jobs, err := queues.NewQueue("jobs")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	// A second instance of the same queue, as another part of the program may open
+	consumer, err := queues.NewQueue("jobs")
+	if err != nil {
+		t.Fatalf("Failed to open queue: %v", err)
+	}
+
+	for i := 0; i < 5; i++ {
+		jobs.Enqueue("job")
+	}
+	_, ackIDs := consumer.DequeueNWithAckIds(3)
+	consumer.Acknowledge(ackIDs[0])
+
+	reg := prom.NewRegistry()
+	if err := Register(reg, jobs, consumer); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather failed: %v", err)
+	}
+
+	metrics := make(map[string][]*dto.Metric)
+	for _, f := range families {
+		metrics[f.GetName()] = f.GetMetric()
+	}
+
+	items := make(map[string]float64)
+	for _, m := range metrics["sqliteq_items"] {
+		for _, label := range m.GetLabel() {
+			if label.GetName() == "status" {
+				items[label.GetValue()] = m.GetGauge().GetValue()
+			}
+		}
+	}
+	if items["pending"] != 2 || items["processing"] != 2 {
+		t.Errorf("Expected 2 pending and 2 processing items, got %v", items)
+	}
+
+	counters := map[string]float64{"sqliteq_enqueued_total": 5, "sqliteq_dequeued_total": 3, "sqliteq_acked_total": 1}
+	for name, want := range counters {
+		if got := metrics[name]; len(got) != 1 || got[0].GetCounter().GetValue() != want {
+			t.Errorf("Expected a single %s of %v, got %v", name, want, got)
+		}
+	}
+
+	latency := metrics["sqliteq_ack_latency_seconds"]
+	if len(latency) != 1 || latency[0].GetHistogram().GetSampleCount() != 1 {
+		t.Errorf("Expected one ack latency observation, got %v", latency)
+	}
+
+	jobs.Close()
+	consumer.Close()
+	if families, err := reg.Gather(); err != nil || len(families) != 0 {
+		t.Errorf("Expected closed queues to be skipped, got %v (%v)", families, err)
+	}
+}
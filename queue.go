@@ -1,29 +1,157 @@
 package sqliteq
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"sync"
 	"sync/atomic"
 	"time"
 
-	"github.com/lucsky/cuid"
 	_ "github.com/mattn/go-sqlite3"
 )
 
 // Queue implements the Queue interface using SQLite as the storage backend
 type Queue struct {
+	manager          *Manager
 	client           *sql.DB
 	tableName        string
 	removeOnComplete bool
 	closed           atomic.Bool
+	// priority is set by PriorityQueue: the table carries a priority column
+	// and items are dequeued by priority before insertion order
+	priority bool
+
+	statsInterval  time.Duration
+	statsRetention time.Duration
+
+	visibilityTimeout  time.Duration
+	visibilityTimeouts map[int]time.Duration
+	// reclaimPriorityBump is subtracted from the priority of reclaimed items
+	reclaimPriorityBump int
+	// priorityLevels names priorities, see WithPriorityLevels
+	priorityLevels map[Level]int
+
+	interceptors []EnqueueInterceptor
+	// hooks are called after committed writes, see WithHooks
+	hooks []Hooks
+
+	idempotencyNamespace string
+	idempotencyTTL       time.Duration
+
+	// timePrecision truncates stored timestamps; ordering relies on seq instead
+	timePrecision time.Duration
+
+	// enqueueRate, dequeueRate and ackRate track the throughput of this instance,
+	// see Metrics
+	enqueueRate rateEstimator
+	dequeueRate rateEstimator
+	ackRate     rateEstimator
+	ackLatency  latencyHistogram
+
+	// groupCommit batches EnqueueAsync calls, nil unless WithGroupCommit is set
+	groupCommit *groupCommit
+
+	// labels are stored in the registry when the queue is opened
+	labels map[string]string
+
+	// dynamic is set on queues created by EnqueueTo, which may be dropped by
+	// WithDropEmptyAfter while open, see withDynamic
+	dynamic bool
+
+	// requeuedOnOpen is the number of items recovered from processing when the queue was created
+	requeuedOnOpen int64
+	// implicit is set on queues opened by the manager rather than the application,
+	// which leave the items in processing alone, see withoutRecovery
+	implicit bool
+	// detached is why Reopen fails once the table was dropped or converted behind
+	// the queue, guarded by the manager's mu
+	detached error
+
+	// writeRetryAttempts and writeRetryBackoff bound the retries of busy writes
+	writeRetryAttempts int
+	writeRetryBackoff  time.Duration
+
+	// delivery is whether dequeued items can be redelivered, see WithDeliveryGuarantee
+	delivery DeliveryGuarantee
+	// redeliveryOrder places redelivered items among pending ones, see WithRedeliveryOrder
+	redeliveryOrder RedeliveryOrder
+
+	// deadLetter receives items that returned to pending more than maxAttempts
+	// times, see WithDeadLetterQueue
+	deadLetterName string
+	deadLetter     *Queue
+	maxAttempts    int
+
+	// maxRetries and retryBackoff reschedule failed items, see WithRetryPolicy
+	maxRetries   int
+	retryBackoff func(attempt int) time.Duration
+
+	// expirySweepInterval is how often expired items are deleted, see WithExpirySweep
+	expirySweepInterval time.Duration
+
+	// maxLength bounds the pending items, see WithMaxLength
+	maxLength int
+	overflow  OverflowPolicy
+	// freed wakes producers blocked in EnqueueWait
+	freed spaceSignal
+
+	// front caches the next pending items, see WithDequeueCache
+	front *frontCache
+
+	// fairnessKey is the header whose values take turns, see WithFairnessKey
+	fairnessKey string
+
+	// breaker stops Drain during downstream outages, see WithCircuitBreaker
+	breaker *circuitBreaker
+
+	// idleAlert reports pending items nobody dequeues, see WithIdleAlert
+	idleAlert *idleAlert
+
+	// ackTokens signs ack IDs for this consumer, see WithAckTokens
+	ackTokens *ackTokens
+
+	// contention counts writes that found the database busy, see Contention
+	contention contentionCounters
+
+	// clockSkew delays expiry decisions against other hosts' clocks, see WithClockSkewTolerance
+	clockSkew time.Duration
+
+	// redactor hides secrets in payloads shown by operational tooling, see WithRedactor
+	redactor func(payload []byte) []byte
+
+	// statsCacheTTL is how long Len and Stats results are reused, see WithStatsCacheTTL
+	statsCacheTTL time.Duration
+	statsCache    statsCache
+
+	// janitorBatchSize and janitorPause bound each write of background maintenance
+	janitorBatchSize int
+	janitorPause     time.Duration
+
+	// background tasks, running between startLoops and stopLoops
+	loops []loop
+	stop  chan struct{}
+	wg    sync.WaitGroup
 }
 
 // newQueue creates a new SQLite-based queue
-func newQueue(db *sql.DB, tableName string, opts ...Option) (*Queue, error) {
+func newQueue(m *Manager, name string, opts ...Option) (*Queue, error) {
+	tableName, err := m.resolve(name)
+	if err != nil {
+		return nil, err
+	}
+
 	q := &Queue{
-		client:           db,
+		manager:          m,
+		client:           m.client,
 		tableName:        tableName,
 		removeOnComplete: true, // Default to removing completed items
+		janitorBatchSize: defaultJanitorBatchSize,
+		janitorPause:     defaultJanitorPause,
+
+		writeRetryAttempts: defaultWriteRetryAttempts,
+		writeRetryBackoff:  defaultWriteRetryBackoff,
 	}
 
 	// Apply any provided options
@@ -31,12 +159,39 @@ func newQueue(db *sql.DB, tableName string, opts ...Option) (*Queue, error) {
 		opt(q)
 	}
 
+	if err := q.checkKind(); err != nil {
+		return nil, mapError(err)
+	}
+
 	if err := q.initTable(); err != nil {
-		db.Close()
 		return nil, fmt.Errorf("failed to initialize table: %w", err)
 	}
 
-	q.RequeueNoAckRows()
+	if err := q.register(); err != nil {
+		return nil, fmt.Errorf("failed to register queue: %w", err)
+	}
+
+	if err := q.initDeadLetter(); err != nil {
+		return nil, fmt.Errorf("failed to open dead-letter queue: %w", err)
+	}
+
+	// Items left in processing by a previous run are returned to pending
+	if !q.implicit {
+		requeued, err := q.requeueNoAckRows()
+		if err != nil {
+			q.logger().Error("requeuing unacknowledged items failed", "queue", q.tableName, "error", err)
+		} else if requeued > 0 {
+			q.logger().Warn("requeued items left in processing by a previous run", "queue", q.tableName, "count", requeued)
+		}
+		q.requeuedOnOpen = requeued
+	}
+
+	if err := q.initLoops(); err != nil {
+		return nil, fmt.Errorf("failed to initialize background tasks: %w", err)
+	}
+
+	q.startLoops()
+	m.track(q)
 
 	return q, nil
 }
@@ -56,46 +211,120 @@ func (q *Queue) initTable() error {
 	CREATE INDEX IF NOT EXISTS %[2]s ON %[1]s (status, created_at);
 	CREATE INDEX IF NOT EXISTS %[3]s ON %[1]s (status, ack);
 	CREATE INDEX IF NOT EXISTS %[4]s ON %[1]s (ack_id);
+	CREATE INDEX IF NOT EXISTS %[5]s ON %[1]s (updated_at) WHERE status = 'processing';
 	`,
 		quoteIdent(q.tableName),
 		quoteIdent(q.tableName+"_status_idx"),
 		quoteIdent(q.tableName+"_status_ack_idx"),
-		quoteIdent(q.tableName+"_ack_id_idx"))
+		quoteIdent(q.tableName+"_ack_id_idx"),
+		quoteIdent(q.tableName+"_processing_idx"))
 
-	_, err := q.client.Exec(createTableSQL)
-	return err
+	if _, err := q.client.Exec(createTableSQL); err != nil {
+		return err
+	}
+
+	if err := q.migrateColumns(); err != nil {
+		return err
+	}
+
+	if err := initIdempotencyTable(q.client); err != nil {
+		return err
+	}
+
+	if q.priority {
+		if err := q.initPriorityColumn(); err != nil {
+			return fmt.Errorf("failed to initialize priority column: %w", err)
+		}
+	}
+
+	return nil
 }
 
+// RequeueNoAckRows moves items that were dequeued but never acknowledged back to pending
+// It does nothing for AtMostOnce queues
 func (q *Queue) RequeueNoAckRows() {
-	tx, err := q.client.Begin()
+	requeued, err := q.requeueNoAckRows()
+	if q.succeeded("requeue", err) && requeued > 0 {
+		q.logger().Info("requeued unacknowledged items", "queue", q.tableName, "count", requeued)
+	}
+}
 
-	defer func() {
-		if err != nil {
-			tx.Rollback()
-		}
-	}()
+// requeueNoAckRows returns unacknowledged processing items to pending
+// Returns the number of requeued items
+func (q *Queue) requeueNoAckRows() (requeued int64, err error) {
+	defer func() { err = mapError(err) }()
+	defer q.invalidateStats()
+	defer q.invalidateFront()
 
-	_, err = tx.Exec(
-		fmt.Sprintf("UPDATE %s SET status = 'pending', updated_at = ? WHERE  status = 'processing' AND ack = 0",
-			quoteIdent(q.tableName)),
-		time.Now().UTC(),
-	)
+	if q.closed.Load() {
+		return 0, ErrClosed
+	}
 
-	err = tx.Commit()
+	if q.delivery == AtMostOnce {
+		return 0, nil
+	}
+
+	requeued, err = rowsAffected(q.requeue("status = 'pending', updated_at = ?", "status = 'processing' AND ack = 0", -1, q.now()))
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = q.moveDeadLetters()
+	return requeued, err
 }
 
 // Enqueue adds an item to the queue
 // It serializes the item to JSON and stores it in the database
 // Returns true if the operation was successful
 func (q *Queue) Enqueue(item any) bool {
+	return q.succeeded("enqueue", q.enqueue(item, 0))
+}
+
+// enqueue inserts an item as pending
+// The priority is only stored when the table has a priority column
+func (q *Queue) enqueue(item any, priority int) error {
+	return q.enqueueMessage(item, Message{Priority: priority})
+}
+
+// enqueueMessage inserts an item as pending with the attributes set on m
+// Each hook runs in the inserting transaction after the item is stored; an error
+// from a hook rolls the insert back
+func (q *Queue) enqueueMessage(item any, m Message, hooks ...func(tx *sql.Tx, m *Message) error) error {
+	if q.maxLength > 0 && q.overflow == OverflowBlock {
+		return q.enqueueWait(context.Background(), item, m, hooks...)
+	}
+
+	return q.enqueueOnce(item, m, false, hooks...)
+}
+
+// enqueueOnce inserts an item like enqueueMessage, without waiting for room
+// With wait, a queue at its maximum length is reported as ErrQueueFull whatever
+// its overflow policy
+func (q *Queue) enqueueOnce(item any, m Message, wait bool, hooks ...func(tx *sql.Tx, m *Message) error) (err error) {
+	defer func() { err = mapError(err) }()
+	defer q.invalidateStats()
+	defer q.observeWrite(time.Now(), &err)
+
 	if q.closed.Load() {
-		return false
+		return ErrClosed
+	}
+
+	if m.Data, err = payloadBytes(item); err != nil {
+		return err
+	}
+
+	m.Status = StatusPending
+	if err := q.intercept(&m); err != nil {
+		return err
+	}
+
+	if err = q.manager.awaitLease(); err != nil {
+		return err
 	}
 
-	now := time.Now().UTC()
 	tx, err := q.client.Begin()
 	if err != nil {
-		return false
+		return err
 	}
 	defer func() {
 		if err != nil {
@@ -103,28 +332,212 @@ func (q *Queue) Enqueue(item any) bool {
 		}
 	}()
 
-	_, err = tx.Exec(
-		fmt.Sprintf("INSERT INTO %s (data, status, ack, created_at, updated_at) VALUES (?, ?, ?, ?, ?)",
-			quoteIdent(q.tableName)), item, "pending", 0, now, now)
+	if err = q.makeRoom(tx, 1, wait); err != nil {
+		return err
+	}
+
+	if err = q.insert(tx, &m); err != nil {
+		return err
+	}
+
+	for _, hook := range hooks {
+		if err = hook(tx, &m); err != nil {
+			return err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	q.enqueueRate.observe(1, time.Now())
+	q.pushFront(&m)
+	q.notifyEnqueued(m)
+
+	return nil
+}
+
+// insert stores a new item, filling in its ID, seq and any missing status and timestamps
+func (q *Queue) insert(tx *sql.Tx, m *Message) (err error) {
+	now := q.now()
+	if m.CreatedAt.IsZero() {
+		m.CreatedAt = now
+	}
+	if m.UpdatedAt.IsZero() {
+		m.UpdatedAt = m.CreatedAt
+	}
+	if m.Status == "" {
+		m.Status = StatusPending
+	}
+
+	if m.Seq, err = q.nextSeq(tx); err != nil {
+		return err
+	}
+
+	headers, err := encodeHeaders(m.Headers)
 	if err != nil {
+		return err
+	}
+
+	if err = q.checkMessageID(tx, m.MessageID); err != nil {
+		return err
+	}
+
+	columns := "data, status, ack_id, ack, created_at, updated_at, not_before, repeat_every, repeat_until, seq, fail_reason, attempts, origin, expires_at, pinned, headers, message_id, correlation_id"
+	values := "?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?"
+	args := []any{
+		m.Data, m.Status, nullString(m.AckID), m.Status == StatusCompleted,
+		m.CreatedAt.UTC(), m.UpdatedAt.UTC(), nullTime(m.NotBefore), int64(m.RepeatEvery), nullTime(m.RepeatUntil), m.Seq,
+		nullString(m.FailReason), m.Attempts, nullString(m.Origin), nullTime(m.ExpiresAt),
+		m.Pinned && m.Status == StatusProcessing, headers, nullString(m.MessageID), nullString(m.CorrelationID),
+	}
+
+	if q.priority {
+		columns += ", priority"
+		values += ", ?"
+		args = append(args, m.Priority)
+	}
+
+	result, err := tx.Exec(fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", quoteIdent(q.tableName), columns, values), args...)
+	if err != nil {
+		return err
+	}
+
+	m.ID, err = result.LastInsertId()
+	return err
+}
+
+// reschedule enqueues the next occurrence of a repeating item completed by the
+// current transaction, unless its repetition has ended
+func (q *Queue) reschedule(tx *sql.Tx, id int64) error {
+	var notBefore, until sql.NullTime
+	var every int64
+
+	err := tx.QueryRow(
+		fmt.Sprintf("SELECT not_before, repeat_every, repeat_until FROM %s WHERE id = ?", quoteIdent(q.tableName)), id,
+	).Scan(&notBefore, &every, &until)
+	if err != nil || every <= 0 {
+		return err
+	}
+
+	// Keep the cadence of the original schedule, skipping the runs that were missed
+	now := q.now()
+	next := notBefore.Time
+	if next.IsZero() {
+		next = now
+	}
+	for !next.After(now) {
+		next = next.Add(time.Duration(every))
+	}
+
+	if until.Valid && next.After(until.Time) {
+		return nil
+	}
+
+	seq, err := q.nextSeq(tx)
+	if err != nil {
+		return err
+	}
+
+	// Message IDs are unique, so only the correlation ID carries over to the next occurrence
+	columns := "data, status, ack, created_at, updated_at, not_before, repeat_every, repeat_until, seq, headers, correlation_id"
+	values := "data, 'pending', 0, ?, ?, ?, repeat_every, repeat_until, ?, headers, correlation_id"
+	if q.priority {
+		columns += ", priority"
+		values += ", priority"
+	}
+
+	_, err = tx.Exec(fmt.Sprintf(
+		"INSERT INTO %[1]s (%[2]s) SELECT %[3]s FROM %[1]s WHERE id = ?",
+		quoteIdent(q.tableName), columns, values,
+	), now, now, next, seq, id)
+
+	return err
+}
+
+// EnqueueAt adds an item that isn't dequeued before t, e.g. for scheduled jobs
+// Returns true if the operation was successful
+func (q *Queue) EnqueueAt(item any, t time.Time) bool {
+	return q.succeeded("enqueue", q.enqueueMessage(item, Message{NotBefore: t}))
+}
+
+// EnqueueAfter adds an item that isn't dequeued before delay has passed, e.g. to
+// retry work later
+// Returns true if the operation was successful
+func (q *Queue) EnqueueAfter(item any, delay time.Duration) bool {
+	return q.EnqueueAt(item, time.Now().Add(delay))
+}
+
+// EnqueueRepeating adds an item that is enqueued again every interval after it has
+// been dequeued or acknowledged, until the given time. A zero until repeats forever.
+// Returns true if the operation was successful
+func (q *Queue) EnqueueRepeating(item any, every time.Duration, until time.Time) bool {
+	if every <= 0 {
 		return false
 	}
 
-	err = tx.Commit()
-	return err == nil
+	return q.succeeded("enqueue", q.enqueueMessage(item, Message{RepeatEvery: every, RepeatUntil: until}))
+}
+
+// now returns the current UTC time truncated to the configured precision
+func (q *Queue) now() time.Time {
+	return time.Now().UTC().Truncate(q.timePrecision)
+}
+
+// expiryNow returns the time against which deadlines that may have been written by
+// another host are compared, pushed back by the WithClockSkewTolerance tolerance
+func (q *Queue) expiryNow() time.Time {
+	return q.now().Add(-q.clockSkew)
+}
+
+// orderBy returns the ORDER BY clause used to pick the next item
+func (q *Queue) orderBy() string {
+	if q.priority {
+		return "priority ASC, seq ASC"
+	}
+
+	return "seq ASC"
 }
 
 // dequeueInternal is a helper function for both Dequeue and DequeueWithAckId
 // It handles the common operations of finding and retrieving an item from the queue
 // If withAckId is true, it will generate and store an ack ID
-func (q *Queue) dequeueInternal(withAckId bool) (item any, success bool, ackID string) {
+// An optional SQL condition (with its arguments) further restricts which pending items qualify
+// Returns sql.ErrNoRows when no item qualifies
+func (q *Queue) dequeueInternal(withAckId bool, cond string, args ...any) (Message, error) {
+	messages, err := q.dequeueBatch(withAckId, 1, cond, args...)
+	if err != nil {
+		return Message{}, err
+	}
+
+	return messages[0], nil
+}
+
+// dequeueBatch claims up to n items in one transaction, like dequeueInternal
+// Returns sql.ErrNoRows when no item qualifies
+func (q *Queue) dequeueBatch(withAckId bool, n int, cond string, args ...any) ([]Message, error) {
+	return q.claim(withAckId, n, nil, cond, args...)
+}
+
+// claim reads up to n qualifying items in dequeue order and claims those kept by
+// pick, or all of them when pick is nil, in one transaction
+// Returns sql.ErrNoRows when no item qualifies or pick keeps none
+func (q *Queue) claim(withAckId bool, n int, pick func([]Message) []Message, cond string, args ...any) (messages []Message, err error) {
+	defer func() { err = mapError(err) }()
+	defer q.invalidateStats()
+	defer q.observeWrite(time.Now(), &err)
+
 	if q.closed.Load() {
-		return nil, false, ""
+		return nil, ErrClosed
+	}
+
+	if err = q.manager.awaitLease(); err != nil {
+		return nil, err
 	}
 
 	tx, err := q.client.Begin()
 	if err != nil {
-		return nil, false, ""
+		return nil, err
 	}
 
 	defer func() {
@@ -133,93 +546,346 @@ func (q *Queue) dequeueInternal(withAckId bool) (item any, success bool, ackID s
 		}
 	}()
 
-	// Get the oldest pending item
-	var id int64
-	var data []byte
+	cached := q.front != nil && q.fairnessKey == "" && n == 1 && pick == nil && cond == ""
 
-	// Only dequeue pending items in FIFO order
-	row := tx.QueryRow(fmt.Sprintf(
-		"SELECT id, data, ack_id FROM %s WHERE status = 'pending' ORDER BY created_at ASC LIMIT 1",
-		quoteIdent(q.tableName),
-	))
+	// Only dequeue items that are ready, in FIFO (or priority) order
+	due, dueArgs := q.readyCond()
+	if cond != "" {
+		due += " AND " + cond
+	}
+	args = append(dueArgs, args...)
 
-	// Use NullString to handle NULL values from database
-	var nullAckID sql.NullString
+	if cached {
+		// The popped item is lost to the cache if the claim fails
+		defer func() {
+			if err != nil {
+				q.invalidateFront()
+			}
+		}()
 
-	// Scan the row data
-	err = row.Scan(&id, &data, &nullAckID) // ackID may be NULL for pending items
-	// Extract the string value if valid
-	if nullAckID.Valid {
-		ackID = nullAckID.String
+		if messages, err = q.readFront(tx, due, args); err != nil {
+			return nil, err
+		}
 	}
 
-	if err != nil {
-		return nil, false, ""
+	if len(messages) == 0 && q.fairnessKey != "" {
+		query, queryArgs := q.fairQuery(due, args, n)
+		if messages, err = q.queryMessages(tx, query, queryArgs...); err != nil {
+			return nil, err
+		}
+	} else if len(messages) == 0 {
+		messages, err = q.queryMessages(tx, fmt.Sprintf("SELECT %s FROM %s WHERE %s ORDER BY %s LIMIT ?",
+			q.messageColumns(), quoteIdent(q.tableName), due, q.orderBy()), append(args, n)...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if pick != nil {
+		messages = pick(messages)
 	}
 
-	// Update the status to 'processing' or delete the item, based on withAckId
-	now := time.Now().UTC()
+	if len(messages) == 0 {
+		return nil, sql.ErrNoRows
+	}
 
+	// Update the status to 'processing' or delete the items, based on withAckId
+	now := q.now()
+
+	var epoch int64
 	if withAckId {
-		if ackID == "" {
-			ackID = cuid.New()
+		if epoch, err = q.currentEpoch(tx); err != nil {
+			return nil, err
 		}
+	}
 
-		// Update the item to processing status
-		_, err = tx.Exec(
-			fmt.Sprintf("UPDATE %s SET status = 'processing', ack_id = ?, updated_at = ? WHERE id = ?",
-				quoteIdent(q.tableName)),
-			ackID, now, id,
-		)
-	} else {
-		// For regular Dequeue, just delete the item immediately
-		_, err = tx.Exec(
-			fmt.Sprintf("DELETE FROM %s WHERE id = ?", quoteIdent(q.tableName)),
-			id,
-		)
+	for i := range messages {
+		m := &messages[i]
+
+		if withAckId {
+			fresh := q.newAckID(now)
+			if m.AckID == "" || q.ackTokens != nil {
+				m.AckID = fresh
+			}
+
+			// Update the item to processing status under the current epoch, counting
+			// the delivery; a pin left by an earlier delivery doesn't carry over, nor
+			// does an ack ID fenced by BumpEpoch
+			err = tx.QueryRow(
+				fmt.Sprintf("UPDATE %s SET status = 'processing', ack_id = CASE WHEN epoch < ? THEN ? ELSE ? END, epoch = ?, attempts = attempts + 1, pinned = 0, updated_at = ? WHERE id = ? RETURNING ack_id",
+					quoteIdent(q.tableName)),
+				epoch, fresh, m.AckID, epoch, now, m.ID,
+			).Scan(&m.AckID)
+			m.Status = StatusProcessing
+		} else {
+			m.AckID = ""
+			m.Status = StatusCompleted
+
+			// Regular Dequeue completes the item, so schedule its next occurrence
+			if err = q.reschedule(tx, m.ID); err != nil {
+				return nil, err
+			}
+
+			// For regular Dequeue, just delete the item immediately
+			_, err = tx.Exec(
+				fmt.Sprintf("DELETE FROM %s WHERE id = ?", quoteIdent(q.tableName)),
+				m.ID,
+			)
+		}
+		m.UpdatedAt = now
+		m.Attempts++
+		m.Pinned = false
+
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	if err != nil {
-		return nil, false, ""
+	if q.fairnessKey != "" {
+		if err = q.takeTurns(tx, messages); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	dequeuedAt := time.Now()
+	q.dequeueRate.observe(len(messages), dequeuedAt)
+	q.observeDequeue(dequeuedAt)
+	q.freed.notify()
+	q.notifyDequeued(messages)
+
+	return messages, nil
+}
+
+// readyCond returns the condition matching the items the next dequeue may
+// deliver, with its arguments: pending items that are due and unexpired
+func (q *Queue) readyCond() (string, []any) {
+	cond := "status = 'pending' AND (not_before IS NULL OR not_before <= ?) AND (expires_at IS NULL OR expires_at > ?)"
+	args := []any{q.now(), q.expiryNow()}
+
+	// Items awaiting their move to the dead-letter queue are never delivered again
+	if q.deadLetter != nil {
+		cond += " AND attempts <= ?"
+		args = append(args, q.maxAttempts)
 	}
 
-	err = tx.Commit()
+	return cond, args
+}
 
+// querier runs queries on the database or within a transaction
+type querier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// queryMessages reads the messages selected by query with args
+func (q *Queue) queryMessages(db querier, query string, args ...any) ([]Message, error) {
+	rows, err := db.Query(query, args...)
 	if err != nil {
-		return nil, false, ""
+		return nil, err
 	}
+	defer rows.Close()
 
-	return data, true, ackID
+	var messages []Message
+	for rows.Next() {
+		m, err := q.scanMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+
+	return messages, rows.Err()
 }
 
 // Dequeue removes and returns the next item from the queue
+// Priority queues return the highest priority item first
+// The payload is a fresh copy owned by the caller, free to modify or retain
 // Returns the item and a boolean indicating if the operation was successful
 func (q *Queue) Dequeue() (any, bool) {
-	item, success, _ := q.dequeueInternal(false)
-	return item, success
+	m, err := q.dequeueInternal(false, "")
+	if !q.succeeded("dequeue", err) {
+		return nil, false
+	}
+
+	return m.Data, true
 }
 
 // DequeueWithAckId removes and returns the next item from the queue with an acknowledgment ID
 // Returns the item, a boolean indicating if the operation was successful, and the acknowledgment ID
 func (q *Queue) DequeueWithAckId() (any, bool, string) {
-	return q.dequeueInternal(true)
+	m, err := q.dequeueInternal(true, "")
+	if !q.succeeded("dequeue", err) {
+		return nil, false, ""
+	}
+
+	return m.Data, true, m.AckID
+}
+
+// DequeueN removes and returns up to n items from the queue in one transaction, in
+// the order Dequeue would return them, so batch workers don't pay a transaction
+// per item. Returns no items if the queue is empty or the operation failed
+func (q *Queue) DequeueN(n int) []any {
+	messages, err := q.dequeueBatch(false, n, "")
+	if !q.succeeded("dequeue", err) {
+		return nil
+	}
+
+	items := make([]any, len(messages))
+	for i, m := range messages {
+		items[i] = m.Data
+	}
+
+	return items
+}
+
+// DequeueNWithAckIds is like DequeueN but keeps the items in processing state until
+// they are acknowledged. Returns the items and their acknowledgment IDs, in the same order
+func (q *Queue) DequeueNWithAckIds(n int) ([]any, []string) {
+	messages, err := q.dequeueBatch(true, n, "")
+	if !q.succeeded("dequeue", err) {
+		return nil, nil
+	}
+
+	items := make([]any, len(messages))
+	ackIDs := make([]string, len(messages))
+	for i, m := range messages {
+		items[i], ackIDs[i] = m.Data, m.AckID
+	}
+
+	return items, ackIDs
+}
+
+// DequeueMessage removes and returns the next item from the queue with its metadata
+// Unlike Dequeue it reports why nothing was returned: ErrEmpty when no item is
+// ready, or the error of the database, e.g. one matching ErrBusy or ErrFull
+func (q *Queue) DequeueMessage() (Message, error) {
+	return q.dequeueMessage(false, "")
+}
+
+// DequeueMessageWithAckId is like DequeueMessage but keeps the item in processing
+// state until it is acknowledged with Ack, using the AckID of the returned message
+func (q *Queue) DequeueMessageWithAckId() (Message, error) {
+	return q.dequeueMessage(true, "")
+}
+
+// dequeueMessage dequeues an item, reporting an empty queue as ErrEmpty
+func (q *Queue) dequeueMessage(withAckId bool, cond string, args ...any) (Message, error) {
+	m, err := q.dequeueInternal(withAckId, cond, args...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Message{}, ErrEmpty
+	}
+
+	return m, err
 }
 
 // Acknowledge marks an item as completed
 // Returns true if the item was successfully acknowledged, false otherwise
 func (q *Queue) Acknowledge(ackID string) bool {
+	return q.succeeded("acknowledge", q.Ack(ackID))
+}
+
+// Ack marks an item as completed, retrying while the database is busy according
+// to WithWriteRetry. When the retries are exhausted it returns an error matching
+// ErrAckUncertain: the work is done but the item may be delivered again, so callers
+// can record a possible duplicate. An ack ID no item holds is reported as
+// ErrUnknownAckID, and with WithAckTokens one no item in processing holds as
+// ErrStaleClaim.
+func (q *Queue) Ack(ackID string) error {
+	err := q.retryWrite(func() error { return q.acknowledge(ackID) })
+	if IsRetriable(err) {
+		return fmt.Errorf("%w: %w", ErrAckUncertain, err)
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w %q: %w", ErrUnknownAckID, ackID, err)
+	}
+
+	return err
+}
+
+// acknowledge completes the item holding ackID
+// Returns sql.ErrNoRows when no item holds the ack ID
+func (q *Queue) acknowledge(ackID string) (err error) {
+	defer func() { err = mapError(err) }()
+	defer q.invalidateStats()
+	defer q.observeWrite(time.Now(), &err)
+
+	if q.closed.Load() {
+		return ErrClosed
+	}
+
+	if err = q.manager.awaitLease(); err != nil {
+		return err
+	}
+
 	tx, err := q.client.Begin()
 	if err != nil {
-		return false
+		return err
 	}
-	var rowsAffected int64
 
 	defer func() {
-		if err != nil || rowsAffected == 0 {
+		if err != nil {
 			tx.Rollback()
 		}
 	}()
 
+	claimedAt, err := q.acknowledgeTx(tx, ackID)
+	if err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	q.observeAcks(claimedAt)
+	q.freed.notify()
+	q.notifyAcked(ackID)
+
+	return nil
+}
+
+// acknowledgeTx completes the item holding ackID within tx
+// Returns when the item was claimed, zero if it wasn't in processing
+func (q *Queue) acknowledgeTx(tx *sql.Tx, ackID string) (claimedAt time.Time, err error) {
+	if err = q.verifyAckID(ackID); err != nil {
+		return time.Time{}, err
+	}
+
+	var id int64
+	var status Status
+	var fenced bool
+	var updatedAt sql.NullTime
+
+	err = tx.QueryRow(
+		fmt.Sprintf("SELECT id, status, epoch < COALESCE((SELECT epoch FROM %s WHERE queue = ?), 0), updated_at FROM %s WHERE ack_id = ?",
+			quoteIdent(epochsTable), quoteIdent(q.tableName)),
+		q.tableName, ackID,
+	).Scan(&id, &status, &fenced, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && status != StatusProcessing && q.ackTokens != nil) {
+		return time.Time{}, q.noClaim(ackID)
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if fenced {
+		return time.Time{}, q.fenced(ackID)
+	}
+
+	if status == StatusProcessing {
+		if err = q.reschedule(tx, id); err != nil {
+			return time.Time{}, err
+		}
+
+		// Items in processing were last updated by their claim, or a later Pin
+		claimedAt = updatedAt.Time
+	}
+
 	var result sql.Result
 
 	if q.removeOnComplete {
@@ -232,64 +898,227 @@ func (q *Queue) Acknowledge(ackID string) bool {
 		// Otherwise, mark it as completed and set ack to 1 (true in SQLite)
 		result, err = tx.Exec(
 			fmt.Sprintf("UPDATE %s SET status = 'completed', ack = 1, updated_at = ? WHERE ack_id = ?", quoteIdent(q.tableName)),
-			time.Now().UTC(), ackID,
+			q.now(), ackID,
 		)
 	}
 
 	if err != nil {
-		return false
+		return time.Time{}, err
 	}
 
-	rowsAffected, err = result.RowsAffected()
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return time.Time{}, err
+	}
 
-	if err != nil || rowsAffected == 0 {
-		return false
+	if rowsAffected == 0 {
+		return time.Time{}, sql.ErrNoRows
 	}
 
-	err = tx.Commit()
+	return claimedAt, nil
+}
 
-	return err == nil
+// Name returns the name of the queue, which is also the name of its table
+func (q *Queue) Name() string {
+	return q.tableName
 }
 
 // Len returns the number of pending items in the queue
 func (q *Queue) Len() int {
+	if q.closed.Load() {
+		return 0
+	}
+
+	if q.statsCacheTTL > 0 {
+		stats, err := q.Stats()
+		if !q.succeeded("len", err) {
+			return 0
+		}
+		return stats.Pending
+	}
+
 	var count int
 	row := q.client.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE status = 'pending'", quoteIdent(q.tableName)))
 	err := row.Scan(&count)
-	if err != nil {
+	if !q.succeeded("len", err) {
 		return 0
 	}
 	return count
 }
 
-// Values returns all pending items in the queue
+// LenDetailed returns the number of items in every status, counted in a single
+// consistent read
+func (q *Queue) LenDetailed() (depth Depth, err error) {
+	defer func() { err = mapError(err) }()
+
+	if q.closed.Load() {
+		return Depth{}, ErrClosed
+	}
+
+	return q.countByStatus()
+}
+
+// Values returns all pending items in the queue in dequeue order, read from one
+// snapshot; use Export to stream large queues instead of loading them at once
 func (q *Queue) Values() []any {
-	rows, err := q.client.Query(fmt.Sprintf("SELECT data FROM %s WHERE status = 'pending' ORDER BY created_at ASC", quoteIdent(q.tableName)))
-	if err != nil {
+	if q.closed.Load() {
 		return nil
 	}
-	defer rows.Close()
 
 	var items []any
+	err := q.readSnapshot(context.Background(),
+		fmt.Sprintf("SELECT data FROM %s WHERE status = 'pending' ORDER BY %s", quoteIdent(q.tableName), q.orderBy()), nil,
+		func(rows *sql.Rows) error {
+			var data []byte
+			if err := rows.Scan(&data); err != nil {
+				return err
+			}
+
+			// Now we just add the byte array directly as we're storing byte arrays
+			// instead of JSON-serialized data
+			items = append(items, data)
+			return nil
+		})
+	if !q.succeeded("values", mapError(err)) {
+		return nil
+	}
+
+	return items
+}
+
+// Page returns up to limit pending items with their metadata in dequeue order,
+// skipping the first offset items
+func (q *Queue) Page(offset, limit int) (messages []Message, err error) {
+	defer func() { err = mapError(err) }()
+
+	if q.closed.Load() {
+		return nil, ErrClosed
+	}
+
+	rows, err := q.client.Query(fmt.Sprintf(
+		"SELECT %s FROM %s WHERE status = 'pending' ORDER BY %s LIMIT ? OFFSET ?",
+		q.messageColumns(), quoteIdent(q.tableName), q.orderBy(),
+	), limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
 	for rows.Next() {
-		var data []byte
-		if err := rows.Scan(&data); err != nil {
-			continue
+		m, err := q.scanMessage(rows)
+		if err != nil {
+			return nil, err
 		}
 
-		// Now we just add the byte array directly as we're storing byte arrays
-		// instead of JSON-serialized data
-		items = append(items, data)
+		messages = append(messages, m)
 	}
 
-	return items
+	return messages, rows.Err()
+}
+
+// Peek returns the item the next dequeue would deliver, with its metadata,
+// without changing its status, or ErrEmpty when no item is ready
+func (q *Queue) Peek() (Message, error) {
+	messages, err := q.PeekN(1)
+	if err != nil {
+		return Message{}, err
+	}
+
+	if len(messages) == 0 {
+		return Message{}, ErrEmpty
+	}
+
+	return messages[0], nil
+}
+
+// PeekN returns up to n items in the order the next dequeues would deliver them,
+// with their metadata, without changing their status. Unlike Page it skips items
+// that aren't ready, such as delayed or expired ones.
+func (q *Queue) PeekN(n int) (messages []Message, err error) {
+	defer func() { err = mapError(err) }()
+
+	if q.closed.Load() {
+		return nil, ErrClosed
+	}
+
+	if n <= 0 {
+		return nil, nil
+	}
+
+	ready, args := q.readyCond()
+
+	return q.queryMessages(q.client, fmt.Sprintf("SELECT %s FROM %s WHERE %s ORDER BY %s LIMIT ?",
+		q.messageColumns(), quoteIdent(q.tableName), ready, q.orderBy()), append(args, n)...)
+}
+
+// Import stores messages as given, including their status, priority, ack ID and
+// timestamps, bypassing enqueue interceptors. It is meant for restoring or seeding
+// a queue in a known state; items in processing without an ack ID are given one.
+// All messages are stored in a single transaction.
+func (q *Queue) Import(messages []Message) (err error) {
+	defer func() { err = mapError(err) }()
+	defer q.invalidateStats()
+	defer q.invalidateFront()
+
+	if q.closed.Load() {
+		return ErrClosed
+	}
+
+	tx, err := q.client.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	for _, m := range messages {
+		switch m.Status {
+		case "", StatusPending, StatusCompleted, StatusFailed:
+		case StatusProcessing:
+			if m.AckID == "" {
+				m.AckID = q.newAckID(q.now())
+			}
+		default:
+			return fmt.Errorf("unknown status %q", m.Status)
+		}
+
+		if err = q.insert(tx, &m); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
 }
 
 // Purge removes all items from the queue
+// Use PurgeContext to learn whether the purge was allowed and succeeded
 func (q *Queue) Purge() {
-	tx, err := q.client.Begin()
+	_, err := q.PurgeContext(context.Background())
+	q.succeeded("purge", err)
+}
+
+// PurgeContext removes all items from the queue once the manager's Authorizer,
+// consulted with ctx, allows it. With WithPurgeUndo the items are kept in a trash
+// table and can be restored with UndoLastPurge.
+// Returns the number of removed items
+func (q *Queue) PurgeContext(ctx context.Context) (purged int64, err error) {
+	defer func() { err = mapError(err) }()
+	defer q.invalidateStats()
+
+	if q.closed.Load() {
+		return 0, ErrClosed
+	}
+
+	if err := q.authorize(ctx, ActionPurge); err != nil {
+		return 0, err
+	}
+
+	tx, err := q.client.BeginTx(ctx, nil)
 	if err != nil {
-		return
+		return 0, err
 	}
 	defer func() {
 		if err != nil {
@@ -297,17 +1126,146 @@ func (q *Queue) Purge() {
 		}
 	}()
 
-	_, err = tx.Exec(fmt.Sprintf("DELETE FROM %s", quoteIdent(q.tableName)))
+	if q.manager.purgeUndoWindow > 0 {
+		if _, err = q.trashRows(tx); err != nil {
+			return 0, err
+		}
+	}
+
+	result, err := tx.Exec(fmt.Sprintf("DELETE FROM %s", quoteIdent(q.tableName)))
 	if err != nil {
-		return
+		return 0, err
 	}
 
-	err = tx.Commit()
+	if purged, err = result.RowsAffected(); err != nil {
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	// Trash past the undo window is only cleaned up opportunistically
+	q.manager.pruneTrash()
+
+	return purged, nil
 }
 
-// Close closes the queue and its database connection
+// Close closes the queue, any further operation on it fails with ErrClosed
+// The database connection is owned by Queues and stays open
 func (q *Queue) Close() error {
-	q.closed.Store(true)
+	if !q.closed.CompareAndSwap(false, true) {
+		return ErrClosed
+	}
+
+	q.stopLoops()
+
+	// Commit what is left of the asynchronous enqueues
+	if q.groupCommit != nil {
+		q.closeAsync()
+	}
+
+	return nil
+}
+
+// Reopen clears the closed state of the queue so it can be used again
+// The table is recreated if it went missing while the queue was closed
+// Returns ErrQueuesClosed if the Queues manager that created the queue was closed,
+// ErrUnknownQueue if the queue was deleted or dropped by WithDropEmptyAfter, and
+// ErrKindMismatch if it was converted; open it again from the manager instead
+func (q *Queue) Reopen() error {
+	if q.manager.closed.Load() {
+		return ErrQueuesClosed
+	}
+
+	q.manager.mu.Lock()
+	detached := q.detached
+	q.manager.mu.Unlock()
+
+	if detached != nil {
+		return detached
+	}
+
+	if !q.closed.Load() {
+		return nil
+	}
+
+	if err := q.initTable(); err != nil {
+		return fmt.Errorf("failed to initialize table: %w", err)
+	}
+
+	// Other processes may have written while the queue was closed
+	q.invalidateFront()
+	if q.groupCommit != nil {
+		q.groupCommit.mu.Lock()
+		q.groupCommit.closed = false
+		q.groupCommit.mu.Unlock()
+	}
+	q.closed.Store(false)
+	q.startLoops()
 
 	return nil
 }
+
+// preallocTable is the scratch table Preallocate fills and drops
+const preallocTable = "sqliteq_prealloc"
+
+// Preallocate grows the database file by roughly n bytes ahead of an anticipated burst
+// The space is reserved as free pages that SQLite reuses for new rows, so inserts
+// during the burst don't have to extend the file piece by piece on slow media.
+// The WAL grows by the same amount and keeps its size after checkpoints unless a
+// journal_size_limit is configured. Has no lasting effect with auto_vacuum enabled.
+func (q *Queue) Preallocate(n int) (err error) {
+	defer func() { err = mapError(err) }()
+
+	if q.closed.Load() {
+		return ErrClosed
+	}
+
+	if n <= 0 {
+		return nil
+	}
+
+	// A reserved name, so no queue is dropped with the scratch table
+	scratch := quoteIdent(preallocTable)
+
+	if _, err := q.client.Exec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (data BLOB)", scratch)); err != nil {
+		return err
+	}
+
+	tx, err := q.client.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Fill the scratch table in chunks to stay well below SQLite's blob size limit
+	const chunk = 1 << 20
+	for remaining := n; remaining > 0; remaining -= chunk {
+		size := remaining
+		if size > chunk {
+			size = chunk
+		}
+
+		_, err = tx.Exec(fmt.Sprintf("INSERT INTO %s (data) VALUES (zeroblob(?))", scratch), size)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	// Dropping the table hands its pages to the freelist while the file keeps its size
+	if _, err = q.client.Exec(fmt.Sprintf("DROP TABLE IF EXISTS %s", scratch)); err != nil {
+		return err
+	}
+
+	_, err = q.client.Exec("PRAGMA wal_checkpoint(PASSIVE)")
+	return err
+}
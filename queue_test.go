@@ -1,9 +1,11 @@
 package sqliteq
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"testing"
+	"time"
 )
 
 func TestSQLiteQueue(t *testing.T) {
@@ -342,3 +344,431 @@ func TestQuoteIdent(t *testing.T) {
 		})
 	}
 }
+
+func TestPreallocate(t *testing.T) {
+	dbPath := "test_preallocate.db"
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + "-wal")
+	defer os.Remove(dbPath + "-shm")
+
+	queues := New(dbPath)
+	q, err := queues.NewQueue("test_queue")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	defer queues.Close()
+
+	pageCount := func() int {
+		var pages int
+		if err := q.client.QueryRow("PRAGMA page_count").Scan(&pages); err != nil {
+			t.Fatalf("Failed to read page count: %v", err)
+		}
+		return pages
+	}
+
+	before := pageCount()
+
+	if err := q.Preallocate(4 << 20); err != nil {
+		t.Fatalf("Preallocate failed: %v", err)
+	}
+
+	var pageSize, freePages int
+	q.client.QueryRow("PRAGMA page_size").Scan(&pageSize)
+	q.client.QueryRow("PRAGMA freelist_count").Scan(&freePages)
+
+	if grown := (pageCount() - before) * pageSize; grown < 4<<20 {
+		t.Errorf("Expected database to grow by at least 4MiB, grew by %d bytes", grown)
+	}
+
+	if freePages*pageSize < 4<<20 {
+		t.Errorf("Expected at least 4MiB of free pages, got %d bytes", freePages*pageSize)
+	}
+
+	// The reserved space is reused by new items instead of growing the file
+	pages := pageCount()
+	for i := 0; i < 100; i++ {
+		q.Enqueue(make([]byte, 1024))
+	}
+
+	if pageCount() != pages {
+		t.Errorf("Expected page count to stay at %d, got %d", pages, pageCount())
+	}
+
+	// A queue named like the scratch table of another queue is left alone
+	neighbour, err := queues.NewQueue("test_queue_prealloc")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	neighbour.Enqueue("item")
+
+	if err := q.Preallocate(1 << 20); err != nil {
+		t.Fatalf("Preallocate failed: %v", err)
+	}
+
+	if neighbour.Len() != 1 {
+		t.Errorf("Expected the test_queue_prealloc queue to be kept, got length %d", neighbour.Len())
+	}
+}
+
+// Test that every operation honors the closed state
+func TestOperationsAfterClose(t *testing.T) {
+	dbPath := "test_after_close.db"
+	defer os.Remove(dbPath)
+
+	queues := New(dbPath)
+	q, err := queues.NewQueue("test_queue", WithRemoveOnComplete(false))
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	defer queues.Close()
+
+	q.Enqueue([]byte("pending item"))
+	q.Enqueue([]byte("claimed item"))
+	q.Dequeue()
+	_, _, ackID := q.DequeueWithAckId()
+	q.Enqueue([]byte("left behind"))
+
+	if err := q.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	t.Run("Close", func(t *testing.T) {
+		if err := q.Close(); !errors.Is(err, ErrClosed) {
+			t.Errorf("Expected ErrClosed on second Close, got %v", err)
+		}
+	})
+
+	t.Run("Enqueue", func(t *testing.T) {
+		if q.Enqueue([]byte("late item")) {
+			t.Error("Enqueue should fail after Close")
+		}
+
+		if err := q.enqueue([]byte("late item"), 0); !errors.Is(err, ErrClosed) {
+			t.Errorf("Expected ErrClosed, got %v", err)
+		}
+	})
+
+	t.Run("Dequeue", func(t *testing.T) {
+		if item, success := q.Dequeue(); success {
+			t.Errorf("Dequeue should fail after Close, got %v", item)
+		}
+
+		if item, success, _ := q.DequeueWithAckId(); success {
+			t.Errorf("DequeueWithAckId should fail after Close, got %v", item)
+		}
+
+		if _, err := q.dequeueInternal(true, ""); !errors.Is(err, ErrClosed) {
+			t.Errorf("Expected ErrClosed, got %v", err)
+		}
+	})
+
+	t.Run("Acknowledge", func(t *testing.T) {
+		if q.Acknowledge(ackID) {
+			t.Error("Acknowledge should fail after Close")
+		}
+
+		if err := q.acknowledge(ackID); !errors.Is(err, ErrClosed) {
+			t.Errorf("Expected ErrClosed, got %v", err)
+		}
+
+		var status string
+		q.client.QueryRow(fmt.Sprintf("SELECT status FROM %s WHERE ack_id = ?", q.tableName), ackID).Scan(&status)
+		if status != "processing" {
+			t.Errorf("Expected item to stay processing, got %q", status)
+		}
+	})
+
+	t.Run("ReadOperations", func(t *testing.T) {
+		if q.Len() != 0 {
+			t.Errorf("Expected Len 0 after Close, got %d", q.Len())
+		}
+
+		if values := q.Values(); values != nil {
+			t.Errorf("Expected nil Values after Close, got %v", values)
+		}
+	})
+
+	t.Run("Maintenance", func(t *testing.T) {
+		q.Purge()
+		q.RequeueNoAckRows()
+
+		if err := q.Preallocate(1024); !errors.Is(err, ErrClosed) {
+			t.Errorf("Expected ErrClosed from Preallocate, got %v", err)
+		}
+
+		// Neither Purge nor RequeueNoAckRows touched the table
+		var pending, processing int
+		q.client.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE status = 'pending'", q.tableName)).Scan(&pending)
+		q.client.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE status = 'processing'", q.tableName)).Scan(&processing)
+
+		if pending != 1 || processing != 1 {
+			t.Errorf("Expected 1 pending and 1 processing item, got %d and %d", pending, processing)
+		}
+	})
+}
+
+// Test reopening a closed queue
+func TestReopen(t *testing.T) {
+	dbPath := "test_reopen.db"
+	defer os.Remove(dbPath)
+
+	queues := New(dbPath)
+	q, err := queues.NewQueue("test_queue")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	q.Enqueue([]byte("before close"))
+	q.Close()
+
+	if q.Enqueue([]byte("while closed")) {
+		t.Error("Enqueue should fail while closed")
+	}
+
+	if err := q.Reopen(); err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+
+	// Reopening an open queue is a no-op
+	if err := q.Reopen(); err != nil {
+		t.Errorf("Reopen on open queue failed: %v", err)
+	}
+
+	if !q.Enqueue([]byte("after reopen")) {
+		t.Error("Enqueue failed after Reopen")
+	}
+
+	if q.Len() != 2 {
+		t.Errorf("Expected queue length 2, got %d", q.Len())
+	}
+
+	item, success := q.Dequeue()
+	if !success || string(item.([]byte)) != "before close" {
+		t.Errorf("Expected 'before close', got %v", item)
+	}
+
+	q.Close()
+	queues.Close()
+
+	if err := q.Reopen(); !errors.Is(err, ErrQueuesClosed) {
+		t.Errorf("Expected ErrQueuesClosed, got %v", err)
+	}
+}
+
+// Test payload ownership under concurrent consumers
+// Run with -race: each consumer scribbles over the payload it received, which
+// must never be observed by another consumer or by later reads
+func TestPayloadOwnership(t *testing.T) {
+	dbPath := "test_payload_ownership.db"
+	defer os.Remove(dbPath)
+
+	queues := New(dbPath)
+	q, err := queues.NewQueue("test_queue")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	defer queues.Close()
+
+	numItems := 50
+	for i := 0; i < numItems; i++ {
+		q.Enqueue([]byte(fmt.Sprintf("item-%03d", i)))
+	}
+
+	// Mutating a snapshot doesn't leak into the stored items
+	for _, value := range q.Values() {
+		copy(value.([]byte), "XXXX")
+	}
+
+	seen := make(chan string, numItems)
+	done := make(chan bool)
+	for w := 0; w < 4; w++ {
+		go func() {
+			for {
+				item, success := q.Dequeue()
+				if !success {
+					done <- true
+					return
+				}
+
+				data := item.([]byte)
+				seen <- string(data)
+				copy(data, "XXXX")
+			}
+		}()
+	}
+
+	for w := 0; w < 4; w++ {
+		<-done
+	}
+	close(seen)
+
+	count := 0
+	for value := range seen {
+		if value[:4] != "item" {
+			t.Errorf("Observed a payload mutated by another consumer: %q", value)
+		}
+		count++
+	}
+
+	if count != numItems {
+		t.Errorf("Expected %d dequeued items, got %d", numItems, count)
+	}
+}
+
+func TestPage(t *testing.T) {
+	dbPath := "test_page.db"
+	defer os.Remove(dbPath)
+
+	queues := New(dbPath)
+	q, err := queues.NewQueue("test_queue")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	defer queues.Close()
+
+	for i := 0; i < 5; i++ {
+		q.Enqueue([]byte(fmt.Sprintf("item-%d", i)))
+	}
+	q.DequeueWithAckId()
+
+	messages, err := q.Page(0, 10)
+	if err != nil {
+		t.Fatalf("Page failed: %v", err)
+	}
+
+	// The processing item is not part of the pending page
+	if len(messages) != 4 {
+		t.Fatalf("Expected 4 messages, got %d", len(messages))
+	}
+
+	if string(messages[0].Data) != "item-1" || messages[0].Priority != 0 {
+		t.Errorf("Expected 'item-1' with priority 0, got '%s' with priority %d", string(messages[0].Data), messages[0].Priority)
+	}
+
+	messages, err = q.Page(3, 10)
+	if err != nil || len(messages) != 1 || string(messages[0].Data) != "item-4" {
+		t.Errorf("Expected only 'item-4' past offset 3, got %v (%v)", messages, err)
+	}
+}
+
+func TestNewManager(t *testing.T) {
+	dbPath := "test_manager.db"
+	defer os.Remove(dbPath)
+
+	// Embedding the manager extends it while keeping it usable as Queues
+	type appQueues struct {
+		*Manager
+	}
+
+	manager, err := NewManager(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+
+	var queues Queues = appQueues{manager}
+	defer queues.Close()
+
+	q, err := queues.NewQueue("manager")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	if !q.Enqueue("item") || q.Len() != 1 {
+		t.Errorf("Expected 1 item, got %d", q.Len())
+	}
+}
+
+func TestDequeueN(t *testing.T) {
+	dbPath := "test_dequeue_n.db"
+	defer os.Remove(dbPath)
+
+	queues := New(dbPath)
+	defer queues.Close()
+
+	q, err := queues.NewQueue("test_queue")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	for i := 0; i < 5; i++ {
+		q.Enqueue(fmt.Sprintf("item%d", i))
+	}
+
+	t.Run("WithoutAck", func(t *testing.T) {
+		items := q.DequeueN(2)
+		if len(items) != 2 || string(items[0].([]byte)) != "item0" || string(items[1].([]byte)) != "item1" {
+			t.Fatalf("Expected item0 and item1, got %v", items)
+		}
+
+		if q.Len() != 3 {
+			t.Errorf("Expected 3 items left, got %d", q.Len())
+		}
+	})
+
+	t.Run("WithAck", func(t *testing.T) {
+		items, ackIDs := q.DequeueNWithAckIds(10)
+		if len(items) != 3 || len(ackIDs) != 3 {
+			t.Fatalf("Expected the remaining 3 items, got %v", items)
+		}
+
+		if string(items[0].([]byte)) != "item2" {
+			t.Errorf("Expected item2 first, got %s", items[0])
+		}
+
+		for _, ackID := range ackIDs {
+			if !q.Acknowledge(ackID) {
+				t.Errorf("Failed to acknowledge %s", ackID)
+			}
+		}
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		if items := q.DequeueN(3); len(items) != 0 {
+			t.Errorf("Expected no items, got %v", items)
+		}
+
+		if items, ackIDs := q.DequeueNWithAckIds(3); items != nil || ackIDs != nil {
+			t.Errorf("Expected no items, got %v", items)
+		}
+	})
+}
+
+func TestPeek(t *testing.T) {
+	dbPath := "test_peek.db"
+	defer os.Remove(dbPath)
+
+	queues := New(dbPath)
+	defer queues.Close()
+
+	pq, err := queues.NewPriorityQueue("test_queue")
+	if err != nil {
+		t.Fatalf("Failed to create priority queue: %v", err)
+	}
+
+	if _, err := pq.Peek(); !errors.Is(err, ErrEmpty) {
+		t.Errorf("Expected ErrEmpty, got %v", err)
+	}
+
+	pq.Enqueue("low", 5)
+	pq.Enqueue("high", 1)
+	pq.EnqueueAfter("delayed", 0, time.Hour)
+
+	m, err := pq.Peek()
+	if err != nil || string(m.Data) != "high" || m.Priority != 1 {
+		t.Fatalf("Expected 'high' with priority 1, got %+v, %v", m, err)
+	}
+
+	messages, err := pq.PeekN(10)
+	if err != nil || len(messages) != 2 || string(messages[1].Data) != "low" {
+		t.Fatalf("Expected 'high' and 'low' without the delayed item, got %v, %v", messages, err)
+	}
+
+	// Peeking leaves the items pending
+	if pq.Len() != 3 {
+		t.Errorf("Expected 3 pending items, got %d", pq.Len())
+	}
+
+	if item, _ := pq.Dequeue(); string(item.([]byte)) != "high" {
+		t.Errorf("Expected 'high', got %s", item)
+	}
+}
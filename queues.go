@@ -2,44 +2,327 @@ package sqliteq
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
-type queues struct {
+// Manager owns the database connection shared by the queues stored in one file.
+// It implements Queues and can be embedded or wrapped to extend it.
+type Manager struct {
 	client *sql.DB
+	closed atomic.Bool
+	// path is the database file, without the DSN's scheme and parameters
+	path string
+
+	authorizer Authorizer
+	// logger is told what the queues handle silently, see WithLogger
+	logger Logger
+	// ids generates ack IDs and lease holders, see WithIDGenerator
+	ids IDGenerator
+	// purgeUndoWindow is how long purged rows are kept for UndoLastPurge
+	purgeUndoWindow time.Duration
+	// dropEmptyAfter is how long a queue stays empty before it is dropped
+	dropEmptyAfter time.Duration
+	// lease serializes writers across processes, see WithWriteLease
+	lease *writeLease
+	// mmapSize and tempStore tune every connection, see WithMmapSize and WithTempStore
+	mmapSize  int64
+	tempStore TempStore
+
+	// background collection of empty queues, running between startGC and stopGC
+	stop chan struct{}
+	wg   sync.WaitGroup
+
+	mu     sync.Mutex
+	open   []*Queue
+	report OpenReport
 }
 
+// Queues creates queues stored in one database and closes them together
 type Queues interface {
 	NewQueue(queueKey string, opts ...Option) (*Queue, error)
 	NewPriorityQueue(queueKey string, opts ...Option) (*PriorityQueue, error)
 	Close() error
 }
 
-func New(dbPath string) Queues {
-	db, err := sql.Open("sqlite3", dbPath)
+// New opens the queues database at dbPath, panicking if it can't be opened
+// or fails its integrity check. Use Open to handle these errors instead.
+func New(dbPath string, opts ...ManagerOption) Queues {
+	q, err := Open(dbPath, opts...)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	return q
+}
+
+// Open opens the queues database at dbPath
+// The database is checked with PRAGMA quick_check; a damaged file is reported as a
+// *CorruptionError matching ErrCorrupt, which Recover can salvage.
+func Open(dbPath string, opts ...ManagerOption) (Queues, error) {
+	m, err := NewManager(dbPath, opts...)
 	if err != nil {
-		panic(fmt.Sprintf("failed to open database: %v", err))
+		return nil, err
 	}
 
+	return m, nil
+}
+
+var _ Queues = (*Manager)(nil)
+
+// NewManager opens the queues database at dbPath like Open, returning the concrete
+// *Manager instead of the Queues interface
+func NewManager(dbPath string, opts ...ManagerOption) (*Manager, error) {
+	// Count the frames before opening, as the first connection may checkpoint them
+	frames := walFrames(dbFile(dbPath) + "-wal")
+
+	m := &Manager{
+		path:   dbFile(dbPath),
+		logger: nopLogger{},
+		ids:    ulidGenerator{},
+		report: OpenReport{
+			QuickCheckPassed: true,
+			WALFrames:        frames,
+			Requeued:         make(map[string]int64),
+		},
+	}
+	m.mmapSize, m.tempStore = platformDefaults()
+
+	// Options are applied first, as some of them configure the connections
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	db := sql.OpenDB(newTunedConnector(dbPath, m.mmapSize, m.tempStore))
+
 	// Enable WAL mode for better concurrency
 	if _, err := db.Exec("PRAGMA journal_mode=WAL;"); err != nil {
 		db.Close()
-		panic(fmt.Sprintf("failed to enable WAL mode: %v", err))
+		return nil, fmt.Errorf("failed to enable WAL mode: %w", mapError(err))
 	}
 
-	return &queues{
-		client: db,
+	if err := quickCheck(db, dbPath); err != nil {
+		db.Close()
+		return nil, err
 	}
+
+	if err := initFormat(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to check database format: %w", mapError(err))
+	}
+
+	if err := initRegistry(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize queue registry: %w", mapError(err))
+	}
+
+	// Created here rather than with the queues, as dropping any queue clears its turns
+	if err := initFairnessTable(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize fairness turns: %w", mapError(err))
+	}
+
+	m.client = db
+
+	if m.lease != nil {
+		if err := initLeases(db); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to initialize write lease: %w", mapError(err))
+		}
+		m.lease.holder = m.ids.NewID()
+	}
+
+	m.startGC()
+
+	return m, nil
+}
+
+// NewQueue creates or opens the queue stored in the table queueKey
+func (m *Manager) NewQueue(queueKey string, opts ...Option) (*Queue, error) {
+	return newQueue(m, queueKey, opts...)
 }
 
-func (q *queues) NewQueue(queueKey string, opts ...Option) (*Queue, error) {
-	return newQueue(q.client, queueKey, opts...)
+// NewPriorityQueue creates or opens the priority queue stored in the table queueKey
+func (m *Manager) NewPriorityQueue(queueKey string, opts ...Option) (*PriorityQueue, error) {
+	return newPriorityQueue(m, queueKey, opts...)
 }
 
-func (q *queues) NewPriorityQueue(queueKey string, opts ...Option) (*PriorityQueue, error) {
-	return newPriorityQueue(q.client, queueKey, opts...)
+// track records a queue created by the manager so Close can stop it
+func (m *Manager) track(queue *Queue) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.open = append(m.open, queue)
+	m.report.Requeued[queue.tableName] = queue.requeuedOnOpen
+}
+
+// OpenReport describes the state the database was found in when it was opened
+func (m *Manager) OpenReport() OpenReport {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	report := m.report
+	report.Requeued = make(map[string]int64, len(m.report.Requeued))
+	for name, count := range m.report.Requeued {
+		report.Requeued[name] = count
+	}
+
+	return report
+}
+
+// Close closes every queue created by the manager and the database connection
+func (m *Manager) Close() error {
+	m.closed.Store(true)
+	m.stopGC()
+
+	// Closed once m.mu is released, see detach
+	m.mu.Lock()
+	open := m.open
+	m.open = nil
+	m.mu.Unlock()
+	closeQueues(open)
+
+	// Let the other processes write without waiting for the lease to expire
+	m.releaseLease()
+
+	return m.client.Close()
 }
 
-func (q *queues) Close() error {
-	return q.client.Close()
+// EnqueueTo adds an item to the named queue, creating the queue on first use, which
+// suits dynamic per-entity queues such as one queue per device. An open queue of
+// that name is reused with its options; otherwise one is opened with the defaults,
+// leaving its items in processing alone as they may be in flight in another process.
+func (m *Manager) EnqueueTo(name string, item any) error {
+	q, err := m.queue(name, true)
+	if err != nil {
+		return err
+	}
+
+	err = q.enqueue(item, 0)
+	if errors.Is(err, ErrClosed) {
+		// The queue was dropped by WithDropEmptyAfter in the meantime
+		if q, err = m.queue(name, true); err != nil {
+			return err
+		}
+		err = q.enqueue(item, 0)
+	}
+
+	return err
+}
+
+// queue returns an open queue routed to by name, opening one if there is none
+// without requeuing its items in processing.
+// With dynamic, a queue it creates may be dropped by WithDropEmptyAfter; without
+// it, the queue is kept even if EnqueueTo created it.
+func (m *Manager) queue(name string, dynamic bool) (*Queue, error) {
+	if m.closed.Load() {
+		return nil, ErrQueuesClosed
+	}
+
+	tableName, err := m.resolve(name)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	for _, q := range m.open {
+		if q.tableName == tableName && !q.closed.Load() {
+			m.mu.Unlock()
+			if q.dynamic && !dynamic {
+				return q, mapError(q.keep())
+			}
+			return q, nil
+		}
+	}
+	m.mu.Unlock()
+
+	// Open the queue as the kind it was created as, leaving its items' priorities
+	// at the default for the items enqueued here
+	kind, err := registeredKind(m.client, tableName)
+	if err != nil {
+		return nil, mapError(err)
+	}
+
+	// The items in processing may be in flight in another process
+	opts := []Option{withoutRecovery()}
+	if kind == kindPriorityQueue {
+		opts = append(opts, withPriority())
+	}
+	if dynamic {
+		opts = append(opts, withDynamic())
+	}
+
+	return newQueue(m, tableName, opts...)
+}
+
+// withoutRecovery opens a queue without returning its items in processing to
+// pending, for the queues the manager opens on the application's behalf
+func withoutRecovery() Option {
+	return func(q *Queue) {
+		q.implicit = true
+	}
+}
+
+// AckAll acknowledges items of several queues in one transaction, for consumers
+// that join work from several queues and must complete it together. ackIDs maps
+// queue names to the ack ID of the item to acknowledge in that queue. If any item
+// can't be acknowledged, none are.
+func (m *Manager) AckAll(ackIDs map[string]string) (err error) {
+	defer func() { err = mapError(err) }()
+
+	if m.closed.Load() {
+		return ErrQueuesClosed
+	}
+
+	if err = m.awaitLease(); err != nil {
+		return err
+	}
+
+	// Acknowledge in a stable order so failures are reproducible
+	names := make([]string, 0, len(ackIDs))
+	for name := range ackIDs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	queues := make([]*Queue, len(names))
+	for i, name := range names {
+		if queues[i], err = m.queue(name, false); err != nil {
+			return err
+		}
+	}
+
+	tx, err := m.client.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	claimedAt := make([]time.Time, len(queues))
+	for i, q := range queues {
+		defer q.invalidateStats()
+
+		if claimedAt[i], err = q.acknowledgeTx(tx, ackIDs[names[i]]); err != nil {
+			return fmt.Errorf("failed to acknowledge %s: %w", names[i], err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	for i, q := range queues {
+		q.observeAcks(claimedAt[i])
+		q.notifyAcked(ackIDs[names[i]])
+	}
+
+	return nil
 }
@@ -0,0 +1,109 @@
+package sqliteq
+
+import (
+	"fmt"
+	"time"
+)
+
+// RatePoint is the enqueue and dequeue throughput of a queue over one bucket of
+// time, see Rates
+type RatePoint struct {
+	// Start is when the bucket begins
+	Start time.Time
+	// Enqueued counts the items enqueued during the bucket
+	Enqueued int64
+	// Dequeued counts the items that left the queue during the bucket: those
+	// acknowledged, dequeued without an ack ID, failed, expired, moved or purged
+	Dequeued int64
+	// EnqueueRate and DequeueRate are the counts per second of the bucket
+	EnqueueRate float64
+	DequeueRate float64
+}
+
+// Rates returns the enqueue and dequeue counts of the queue over the last window,
+// in buckets of the given length, oldest first, for capacity dashboards without
+// external metrics infrastructure. They are computed from the samples recorded by
+// WithStatsHistory, by any process, so buckets shorter than its interval are
+// mostly empty and buckets without samples count zero. Under ArrivalOrder a
+// redelivered item counts as enqueued and dequeued again. Returns nil without
+// WithStatsHistory.
+func (q *Queue) Rates(window, bucket time.Duration) (points []RatePoint, err error) {
+	defer func() { err = mapError(err) }()
+
+	if q.closed.Load() {
+		return nil, ErrClosed
+	}
+
+	if q.statsInterval <= 0 || window <= 0 || bucket <= 0 {
+		return nil, nil
+	}
+
+	now := q.now()
+	start := now.Add(-window).Truncate(bucket)
+
+	points = make([]RatePoint, 0, int(now.Sub(start)/bucket)+1)
+	for at := start; at.Before(now); at = at.Add(bucket) {
+		points = append(points, RatePoint{Start: at})
+	}
+
+	// The last sample before the window is the baseline of the first one in it
+	rows, err := q.client.Query(fmt.Sprintf(`
+	SELECT sampled_at, pending + processing, enqueued FROM (
+		SELECT sampled_at, pending, processing, enqueued FROM %[1]s
+		WHERE queue = ? AND sampled_at < ? AND enqueued IS NOT NULL
+		ORDER BY sampled_at DESC LIMIT 1
+	)
+	UNION ALL
+	SELECT sampled_at, pending + processing, enqueued FROM %[1]s
+	WHERE queue = ? AND sampled_at >= ? AND enqueued IS NOT NULL
+	ORDER BY sampled_at ASC
+	`, quoteIdent(statsTable)), q.tableName, start, q.tableName, start)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var prevDepth, prevEnqueued int64
+	first := true
+
+	for rows.Next() {
+		var sampledAt time.Time
+		var depth, enqueued int64
+		if err := rows.Scan(&sampledAt, &depth, &enqueued); err != nil {
+			return nil, err
+		}
+
+		in := enqueued - prevEnqueued
+		out := in - (depth - prevDepth)
+		prevDepth, prevEnqueued = depth, enqueued
+
+		i := int(sampledAt.Sub(start) / bucket)
+		if first || sampledAt.Before(start) || i >= len(points) {
+			first = false
+			continue
+		}
+
+		// A counter behind the previous sample, e.g. after the queue was deleted
+		// and created again, starts a new baseline
+		if in < 0 {
+			continue
+		}
+		if out < 0 {
+			out = 0
+		}
+
+		points[i].Enqueued += in
+		points[i].Dequeued += out
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range points {
+		points[i].EnqueueRate = float64(points[i].Enqueued) / bucket.Seconds()
+		points[i].DequeueRate = float64(points[i].Dequeued) / bucket.Seconds()
+	}
+
+	return points, nil
+}
@@ -0,0 +1,67 @@
+package sqliteq
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestRates(t *testing.T) {
+	dbPath := "test_rates.db"
+	defer os.Remove(dbPath)
+
+	queues := New(dbPath)
+	defer queues.Close()
+
+	q, err := queues.NewQueue("rated", WithStatsHistory(time.Hour, 0))
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	plain, err := queues.NewQueue("plain")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	if points, err := plain.Rates(time.Hour, time.Minute); err != nil || points != nil {
+		t.Errorf("Expected no rates without WithStatsHistory, got %v (%v)", points, err)
+	}
+
+	start := q.now().Add(-3 * time.Minute).Truncate(time.Minute)
+	samples := []struct {
+		at                       time.Time
+		pending, processing, enq int
+	}{
+		{start.Add(-30 * time.Second), 5, 0, 5}, // baseline before the window
+		{start.Add(10 * time.Second), 8, 2, 15}, // 10 in, 5 out
+		{start.Add(70 * time.Second), 2, 2, 15}, // 6 out
+		{start.Add(80 * time.Second), 2, 2, 18}, // 3 in, 3 out
+	}
+	for _, s := range samples {
+		_, err := q.client.Exec(fmt.Sprintf("INSERT INTO %s (queue, sampled_at, pending, processing, completed, enqueued) VALUES (?, ?, ?, ?, 0, ?)", statsTable),
+			q.tableName, s.at, s.pending, s.processing, s.enq)
+		if err != nil {
+			t.Fatalf("Failed to insert sample: %v", err)
+		}
+	}
+
+	points, err := q.Rates(3*time.Minute, time.Minute)
+	if err != nil {
+		t.Fatalf("Rates failed: %v", err)
+	}
+
+	if len(points) < 3 || !points[0].Start.Equal(start) {
+		t.Fatalf("Expected buckets starting at %v, got %+v", start, points)
+	}
+
+	want := []struct{ enqueued, dequeued int64 }{{10, 5}, {3, 9}, {0, 0}}
+	for i, w := range want {
+		if p := points[i]; p.Enqueued != w.enqueued || p.Dequeued != w.dequeued {
+			t.Errorf("Expected %d enqueued and %d dequeued in bucket %d, got %+v", w.enqueued, w.dequeued, i, p)
+		}
+	}
+
+	if rate := points[0].EnqueueRate; rate != 10.0/60 {
+		t.Errorf("Expected an enqueue rate of 10/min, got %f/s", rate)
+	}
+}
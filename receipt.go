@@ -0,0 +1,124 @@
+package sqliteq
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrFailed is returned by WaitForAck when the item was set aside as failed
+var ErrFailed = errors.New("message failed")
+
+// ackPollInterval is how often WaitForAck checks the state of an item
+const ackPollInterval = 25 * time.Millisecond
+
+// EnqueueWithID adds an item to the queue and returns its ID, which WaitForAck
+// takes to confirm the item was processed
+func (q *Queue) EnqueueWithID(item any) (int64, error) {
+	return q.enqueueWithID(item, Message{})
+}
+
+// enqueueWithID inserts a message and reports the ID it was given
+func (q *Queue) enqueueWithID(item any, m Message) (id int64, err error) {
+	err = q.enqueueMessage(item, m, func(tx *sql.Tx, m *Message) error {
+		id = m.ID
+		return nil
+	})
+
+	return id, err
+}
+
+// WaitForAck blocks until the item with the given ID was acknowledged, so a
+// producer can confirm a critical message was processed end to end. It returns
+// nil once the item is completed or no longer in the queue, which includes items
+// dequeued without acknowledgment and items removed by a purge. An item set
+// aside as failed is reported as ErrFailed with the reason. Acknowledgments by
+// other processes are seen too, as the item's row is polled.
+func (q *Queue) WaitForAck(ctx context.Context, id int64) error {
+	ticker := time.NewTicker(ackPollInterval)
+	defer ticker.Stop()
+
+	for {
+		status, reason, err := q.messageStatus(id)
+		switch {
+		case err != nil:
+			return err
+		case status == StatusCompleted:
+			return nil
+		case status == StatusFailed:
+			return fmt.Errorf("%w: %s", ErrFailed, reason)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Watch reports the state changes of the item with the given ID, starting with its
+// current state, for progress displays. An item no longer in the queue is reported
+// as completed, as for WaitForAck. The channel is closed once the item is completed
+// or failed, when ctx is done, or when its state can't be read.
+func (q *Queue) Watch(ctx context.Context, id int64) <-chan Status {
+	changes := make(chan Status, 1)
+
+	go func() {
+		defer close(changes)
+
+		ticker := time.NewTicker(ackPollInterval)
+		defer ticker.Stop()
+
+		var last Status
+		for {
+			status, _, err := q.messageStatus(id)
+			if err != nil {
+				return
+			}
+
+			if status != last {
+				select {
+				case changes <- status:
+				case <-ctx.Done():
+					return
+				}
+				last = status
+			}
+
+			if status == StatusCompleted || status == StatusFailed {
+				return
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return changes
+}
+
+// messageStatus returns the status of an item and the reason it failed, reporting
+// an item no longer in the queue as completed
+func (q *Queue) messageStatus(id int64) (status Status, reason string, err error) {
+	defer func() { err = mapError(err) }()
+
+	if q.closed.Load() {
+		return "", "", ErrClosed
+	}
+
+	var failReason sql.NullString
+	err = q.client.QueryRow(
+		fmt.Sprintf("SELECT status, fail_reason FROM %s WHERE id = ?", quoteIdent(q.tableName)), id,
+	).Scan(&status, &failReason)
+	if errors.Is(err, sql.ErrNoRows) {
+		return StatusCompleted, "", nil
+	}
+
+	return status, failReason.String, err
+}
@@ -0,0 +1,107 @@
+package sqliteq
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestWaitForAck(t *testing.T) {
+	dbPath := "test_wait_for_ack.db"
+	defer os.Remove(dbPath)
+
+	queues := New(dbPath)
+	defer queues.Close()
+
+	q, err := queues.NewQueue("receipts")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	t.Run("Acknowledged", func(t *testing.T) {
+		id, err := q.EnqueueWithID("critical")
+		if err != nil {
+			t.Fatalf("EnqueueWithID failed: %v", err)
+		}
+
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			_, _, ackID := q.DequeueWithAckId()
+			q.Acknowledge(ackID)
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		if err := q.WaitForAck(ctx, id); err != nil {
+			t.Errorf("Expected the acknowledgment, got %v", err)
+		}
+	})
+
+	t.Run("Pending", func(t *testing.T) {
+		id, _ := q.EnqueueWithID("waiting")
+		defer q.Dequeue()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Millisecond)
+		defer cancel()
+
+		if err := q.WaitForAck(ctx, id); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+		}
+	})
+
+	t.Run("Failed", func(t *testing.T) {
+		id, _ := q.EnqueueWithID("doomed")
+		if _, err := q.FailPending(context.Background(), Filter{}, "bad payload"); err != nil {
+			t.Fatalf("FailPending failed: %v", err)
+		}
+
+		err := q.WaitForAck(context.Background(), id)
+		if !errors.Is(err, ErrFailed) {
+			t.Errorf("Expected ErrFailed, got %v", err)
+		}
+	})
+}
+
+func TestWatch(t *testing.T) {
+	dbPath := "test_watch.db"
+	defer os.Remove(dbPath)
+
+	queues := New(dbPath)
+	defer queues.Close()
+
+	q, err := queues.NewQueue("receipts")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	id, err := q.EnqueueWithID("tracked")
+	if err != nil {
+		t.Fatalf("EnqueueWithID failed: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	changes := q.Watch(ctx, id)
+
+	if status := <-changes; status != StatusPending {
+		t.Fatalf("Expected %s first, got %s", StatusPending, status)
+	}
+
+	_, _, ackID := q.DequeueWithAckId()
+	if status := <-changes; status != StatusProcessing {
+		t.Fatalf("Expected %s, got %s", StatusProcessing, status)
+	}
+
+	q.Acknowledge(ackID)
+	if status := <-changes; status != StatusCompleted {
+		t.Fatalf("Expected %s, got %s", StatusCompleted, status)
+	}
+
+	if _, open := <-changes; open {
+		t.Error("Expected the channel to be closed once the item completed")
+	}
+}
@@ -0,0 +1,296 @@
+package sqliteq
+
+import (
+	"database/sql"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// RecoverMode selects how Recover deals with a corrupted database file
+type RecoverMode int
+
+const (
+	// RecoverSalvage copies every readable row into a fresh database file
+	RecoverSalvage RecoverMode = iota
+	// RecoverReset starts over with an empty database file
+	RecoverReset
+)
+
+// RecoverReport describes what Recover did
+type RecoverReport struct {
+	// QuarantinePath is where the corrupted file was moved to
+	QuarantinePath string
+	// Tables is the number of tables recreated in the fresh file
+	Tables int
+	// Rows is the number of rows salvaged into the fresh file
+	Rows int
+	// Damaged lists the tables in which some rows could not be read
+	Damaged []string
+}
+
+// OpenReport describes the state the database was found in when it was opened,
+// to track crash health: a clean shutdown leaves no WAL frames and no items in processing
+type OpenReport struct {
+	// QuickCheckPassed is whether PRAGMA quick_check found no problem
+	QuickCheckPassed bool
+	// WALFrames is the number of committed frames found in the WAL, replayed on open
+	WALFrames int
+	// Requeued is the number of unacknowledged items returned to pending, per queue
+	// opened since, by the recovery done when a queue is created
+	Requeued map[string]int64
+}
+
+// walFrames counts the frames of the WAL file at path belonging to its current generation
+// A missing or malformed WAL has no frames
+func walFrames(path string) int {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+
+	const headerSize, frameHeaderSize = 32, 24
+
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(f, header); err != nil {
+		return 0
+	}
+
+	pageSize := int64(binary.BigEndian.Uint32(header[8:12]))
+	if pageSize == 1 {
+		pageSize = 65536
+	}
+	if pageSize < 512 {
+		return 0
+	}
+
+	// Frames left over from before the last checkpoint carry stale salts
+	salts := header[16:24]
+	frame := make([]byte, frameHeaderSize)
+	frames := 0
+
+	for offset := int64(headerSize); ; offset += frameHeaderSize + pageSize {
+		if _, err := f.ReadAt(frame, offset); err != nil {
+			break
+		}
+
+		if string(frame[8:16]) != string(salts) {
+			break
+		}
+
+		frames++
+	}
+
+	return frames
+}
+
+// quickCheck runs PRAGMA quick_check and reports any problem as a *CorruptionError
+func quickCheck(db *sql.DB, path string) error {
+	rows, err := db.Query("PRAGMA quick_check")
+	if err != nil {
+		if err = mapError(err); errors.Is(err, ErrCorrupt) {
+			return &CorruptionError{Path: path, Problems: []string{err.Error()}}
+		}
+		return fmt.Errorf("failed to check database integrity: %w", err)
+	}
+	defer rows.Close()
+
+	var problems []string
+	for rows.Next() {
+		var result string
+		if err := rows.Scan(&result); err != nil {
+			return fmt.Errorf("failed to check database integrity: %w", mapError(err))
+		}
+
+		if result != "ok" {
+			problems = append(problems, result)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		problems = append(problems, mapError(err).Error())
+	}
+
+	if len(problems) > 0 {
+		return &CorruptionError{Path: path, Problems: problems}
+	}
+
+	return nil
+}
+
+// Recover replaces a corrupted database file at dbPath with a fresh one
+// The corrupted file is kept next to it with a .corrupt-<timestamp> suffix. With
+// RecoverSalvage every row that can still be read is copied into the fresh file,
+// so queues reopened afterwards keep their salvaged items. The database must not be
+// open while it is recovered.
+func Recover(dbPath string, mode RecoverMode) (RecoverReport, error) {
+	var report RecoverReport
+	freshPath := dbPath + ".recover"
+
+	removeDatabase(freshPath)
+
+	if mode == RecoverSalvage {
+		if err := salvage(dbPath, freshPath, &report); err != nil {
+			removeDatabase(freshPath)
+			return report, err
+		}
+	}
+
+	report.QuarantinePath = fmt.Sprintf("%s.corrupt-%d", dbPath, time.Now().Unix())
+	for _, suffix := range []string{"", "-wal", "-shm"} {
+		if err := os.Rename(dbPath+suffix, report.QuarantinePath+suffix); err != nil && !os.IsNotExist(err) {
+			return report, fmt.Errorf("failed to quarantine database: %w", err)
+		}
+	}
+
+	if mode == RecoverSalvage {
+		if err := os.Rename(freshPath, dbPath); err != nil {
+			return report, fmt.Errorf("failed to install recovered database: %w", err)
+		}
+	}
+
+	return report, nil
+}
+
+// salvage copies the schema and every readable row of src into a new database at dst
+func salvage(src, dst string, report *RecoverReport) error {
+	from, err := sql.Open("sqlite3", src)
+	if err != nil {
+		return err
+	}
+	defer from.Close()
+
+	to, err := sql.Open("sqlite3", dst)
+	if err != nil {
+		return err
+	}
+	defer to.Close()
+
+	type object struct{ name, sql string }
+	var tables, indexes []object
+
+	rows, err := from.Query("SELECT type, name, sql FROM sqlite_master WHERE sql IS NOT NULL AND name NOT LIKE 'sqlite_%'")
+	if err != nil {
+		return fmt.Errorf("failed to read schema: %w", mapError(err))
+	}
+	for rows.Next() {
+		var kind string
+		var o object
+		if err := rows.Scan(&kind, &o.name, &o.sql); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to read schema: %w", mapError(err))
+		}
+
+		switch kind {
+		case "table":
+			tables = append(tables, o)
+		case "index":
+			indexes = append(indexes, o)
+		}
+	}
+	rows.Close()
+
+	for _, table := range tables {
+		if _, err := to.Exec(table.sql); err != nil {
+			return fmt.Errorf("failed to recreate table %s: %w", table.name, err)
+		}
+		report.Tables++
+
+		copied, complete, err := salvageTable(from, to, table.name)
+		if err != nil {
+			return err
+		}
+
+		report.Rows += copied
+		if !complete {
+			report.Damaged = append(report.Damaged, table.name)
+		}
+	}
+
+	// Indexes are rebuilt from the salvaged rows rather than read from the damaged file
+	for _, index := range indexes {
+		if _, err := to.Exec(index.sql); err != nil {
+			return fmt.Errorf("failed to recreate index %s: %w", index.name, err)
+		}
+	}
+
+	return nil
+}
+
+// salvageTable copies the readable rows of a table, scanning forward by rowid until
+// the first unreadable page and then backward from the end to reach rows behind it
+// Reports whether the whole table could be read
+func salvageTable(from, to *sql.DB, table string) (int, bool, error) {
+	var columns []string
+	rows, err := from.Query("SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return 0, false, nil
+	}
+	for rows.Next() {
+		var name string
+		if rows.Scan(&name) == nil {
+			columns = append(columns, quoteIdent(name))
+		}
+	}
+	rows.Close()
+
+	if len(columns) == 0 {
+		return 0, false, nil
+	}
+
+	list := strings.Join(columns, ", ")
+	insert := fmt.Sprintf("INSERT OR IGNORE INTO %s (%s) VALUES (?%s)",
+		quoteIdent(table), list, strings.Repeat(", ?", len(columns)-1))
+
+	copied := 0
+	scan := func(order string) (bool, error) {
+		rows, err := from.Query(fmt.Sprintf("SELECT %s FROM %s ORDER BY rowid %s", list, quoteIdent(table), order))
+		if err != nil {
+			return false, nil
+		}
+		defer rows.Close()
+
+		values := make([]any, len(columns))
+		dest := make([]any, len(columns))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+
+		for rows.Next() {
+			if err := rows.Scan(dest...); err != nil {
+				return false, nil
+			}
+
+			result, err := to.Exec(insert, values...)
+			if err != nil {
+				return false, fmt.Errorf("failed to copy row of %s: %w", table, err)
+			}
+
+			if n, _ := result.RowsAffected(); n > 0 {
+				copied++
+			}
+		}
+
+		return rows.Err() == nil, nil
+	}
+
+	complete, err := scan("ASC")
+	if err != nil || complete {
+		return copied, complete, err
+	}
+
+	_, err = scan("DESC")
+	return copied, false, err
+}
+
+// removeDatabase removes a database file along with its WAL and shared memory files
+func removeDatabase(path string) {
+	for _, suffix := range []string{"", "-wal", "-shm"} {
+		os.Remove(path + suffix)
+	}
+}
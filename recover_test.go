@@ -0,0 +1,192 @@
+package sqliteq
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// corruptDatabase creates a database with a queue of items and overwrites one of its pages
+func corruptDatabase(t *testing.T, dbPath string, items int) {
+	t.Helper()
+
+	queues := New(dbPath)
+	q, err := queues.NewQueue("test_queue")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	for i := 0; i < items; i++ {
+		q.Enqueue([]byte(fmt.Sprintf("item-%04d-%0500d", i, i)))
+	}
+	queues.Close()
+
+	f, err := os.OpenFile(dbPath, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("Failed to open database file: %v", err)
+	}
+	defer f.Close()
+
+	info, _ := f.Stat()
+	garbage := make([]byte, 4096)
+	for i := range garbage {
+		garbage[i] = 0xA5
+	}
+
+	// Overwrite a page in the middle of the table
+	offset := (info.Size() / 4096 / 2) * 4096
+	if _, err := f.WriteAt(garbage, offset); err != nil {
+		t.Fatalf("Failed to corrupt database: %v", err)
+	}
+}
+
+func TestOpenDetectsCorruption(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "corrupt.db")
+	corruptDatabase(t, dbPath, 200)
+
+	_, err := Open(dbPath)
+	if !errors.Is(err, ErrCorrupt) {
+		t.Fatalf("Expected ErrCorrupt, got %v", err)
+	}
+
+	var corruption *CorruptionError
+	if !errors.As(err, &corruption) || corruption.Path != dbPath || len(corruption.Problems) == 0 {
+		t.Errorf("Expected a CorruptionError describing the problems, got %v", err)
+	}
+}
+
+func TestRecover(t *testing.T) {
+	t.Run("Salvage", func(t *testing.T) {
+		dbPath := filepath.Join(t.TempDir(), "salvage.db")
+		corruptDatabase(t, dbPath, 200)
+
+		report, err := Recover(dbPath, RecoverSalvage)
+		if err != nil {
+			t.Fatalf("Recover failed: %v", err)
+		}
+
+		if _, err := os.Stat(report.QuarantinePath); err != nil {
+			t.Errorf("Expected the corrupted file to be quarantined: %v", err)
+		}
+
+		// Depending on the page hit, the damage may be in an index that is rebuilt
+		if report.Rows == 0 || report.Rows > 200 {
+			t.Errorf("Expected up to 200 salvaged rows, got %d", report.Rows)
+		}
+
+		queues, err := Open(dbPath)
+		if err != nil {
+			t.Fatalf("Expected the recovered database to open, got %v", err)
+		}
+		defer queues.Close()
+
+		q, err := queues.NewQueue("test_queue")
+		if err != nil {
+			t.Fatalf("Failed to reopen queue: %v", err)
+		}
+
+		if q.Len() != report.Rows {
+			t.Errorf("Expected %d salvaged items, got %d", report.Rows, q.Len())
+		}
+
+		item, success := q.Dequeue()
+		if !success || string(item.([]byte))[:9] != "item-0000" {
+			t.Errorf("Expected the first item to survive, got %v", item)
+		}
+
+		if !q.Enqueue([]byte("new item")) {
+			t.Error("Enqueue failed on the recovered database")
+		}
+	})
+
+	t.Run("Reset", func(t *testing.T) {
+		dbPath := filepath.Join(t.TempDir(), "reset.db")
+		corruptDatabase(t, dbPath, 200)
+
+		if _, err := Recover(dbPath, RecoverReset); err != nil {
+			t.Fatalf("Recover failed: %v", err)
+		}
+
+		queues, err := Open(dbPath)
+		if err != nil {
+			t.Fatalf("Expected a fresh database to open, got %v", err)
+		}
+		defer queues.Close()
+
+		q, _ := queues.NewQueue("test_queue")
+		if q.Len() != 0 {
+			t.Errorf("Expected an empty queue, got %d items", q.Len())
+		}
+	})
+}
+
+func TestOpenReport(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "live.db")
+	crashPath := filepath.Join(dir, "crashed.db")
+
+	queues := New(dbPath)
+	defer queues.Close()
+
+	q, err := queues.NewQueue("test_queue")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	q.Enqueue([]byte("item 1"))
+	q.Enqueue([]byte("item 2"))
+	q.DequeueWithAckId()
+
+	// Copying the files of a live database leaves them as a crash would
+	for _, suffix := range []string{"", "-wal"} {
+		data, err := os.ReadFile(dbPath + suffix)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", dbPath+suffix, err)
+		}
+		os.WriteFile(crashPath+suffix, data, 0o644)
+	}
+
+	crashed, err := NewManager(crashPath)
+	if err != nil {
+		t.Fatalf("Failed to open crashed database: %v", err)
+	}
+	defer crashed.Close()
+
+	recovered, err := crashed.NewQueue("test_queue")
+	if err != nil {
+		t.Fatalf("Failed to open queue: %v", err)
+	}
+
+	report := crashed.OpenReport()
+	if !report.QuickCheckPassed || report.WALFrames == 0 {
+		t.Errorf("Expected a passing check with replayed WAL frames, got %+v", report)
+	}
+
+	if report.Requeued["test_queue"] != 1 {
+		t.Errorf("Expected 1 requeued item, got %d", report.Requeued["test_queue"])
+	}
+
+	stats, err := recovered.Stats()
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+
+	if stats.RequeuedOnOpen != 1 || stats.Pending != 2 || stats.Processing != 0 {
+		t.Errorf("Expected 2 pending items with 1 requeued on open, got %+v", stats)
+	}
+
+	// A cleanly closed database has nothing to replay
+	crashed.Close()
+
+	reopened, err := NewManager(crashPath)
+	if err != nil {
+		t.Fatalf("Failed to reopen database: %v", err)
+	}
+	defer reopened.Close()
+
+	if frames := reopened.OpenReport().WALFrames; frames != 0 {
+		t.Errorf("Expected no WAL frames after a clean shutdown, got %d", frames)
+	}
+}
@@ -0,0 +1,14 @@
+package sqliteq
+
+// Redact returns payload as it may be displayed by operational tooling such as
+// logs, dashboards and ExportRedacted, passed through the function set with WithRedactor.
+// Without one, payload is returned unchanged. Payloads returned by Dequeue and
+// similar methods or Export are never redacted.
+func (q *Queue) Redact(payload []byte) []byte {
+	if q.redactor == nil {
+		return payload
+	}
+
+	// The redactor may modify its argument, which must not reach stored payloads
+	return q.redactor(append([]byte(nil), payload...))
+}
@@ -0,0 +1,101 @@
+package sqliteq
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// RedeliveryOrder decides where items returned to pending are dequeued relative to
+// items of the same priority enqueued after they first were
+type RedeliveryOrder int
+
+const (
+	// ReclaimedFirst keeps the place of redelivered items: they are dequeued ahead
+	// of the items enqueued after them, so retries run as soon as possible
+	ReclaimedFirst RedeliveryOrder = iota
+	// ArrivalOrder moves redelivered items to the back of the line, behind every item
+	// pending when they return, as if they were enqueued again
+	ArrivalOrder
+)
+
+// String returns the name of the order
+func (o RedeliveryOrder) String() string {
+	switch o {
+	case ReclaimedFirst:
+		return "reclaimed-first"
+	case ArrivalOrder:
+		return "arrival-order"
+	}
+
+	return "unknown"
+}
+
+// requeue returns at most limit items matching cond to pending, applying the
+// assignments in set, which must include the status. The arguments of set come
+// first in args, followed by those of cond; a negative limit requeues every match.
+// Under ArrivalOrder the items get new seqs following the queue's counter, keeping
+// their relative order.
+func (q *Queue) requeue(set, cond string, limit int, args ...any) (result sql.Result, err error) {
+	defer func() {
+		if err == nil {
+			requeued, _ := result.RowsAffected()
+			q.notifyRequeued(requeued)
+		}
+	}()
+
+	// A single statement needs no transaction
+	if q.redeliveryOrder != ArrivalOrder {
+		return q.client.Exec(q.requeueQuery(set, cond), append(args, limit)...)
+	}
+
+	tx, err := q.client.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if result, err = q.requeueTx(tx, set, cond, limit, args...); err != nil {
+		return nil, err
+	}
+
+	return result, tx.Commit()
+}
+
+// requeueTx is requeue within tx
+func (q *Queue) requeueTx(tx *sql.Tx, set, cond string, limit int, args ...any) (sql.Result, error) {
+	if q.redeliveryOrder != ArrivalOrder {
+		return tx.Exec(q.requeueQuery(set, cond), append(args, limit)...)
+	}
+
+	base, err := q.advanceSeq(tx, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := tx.Exec(fmt.Sprintf(
+		"UPDATE %[1]s SET seq = ? + r.n, %[2]s FROM (SELECT id, ROW_NUMBER() OVER (ORDER BY seq) AS n FROM %[1]s WHERE %[3]s ORDER BY seq LIMIT ?) AS r WHERE %[1]s.id = r.id",
+		quoteIdent(q.tableName), set, cond,
+	), append(append([]any{base}, args...), limit)...)
+	if err != nil {
+		return nil, err
+	}
+
+	requeued, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = q.advanceSeq(tx, requeued); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// requeueQuery returns the statement requeuing items in place, keeping their seq
+func (q *Queue) requeueQuery(set, cond string) string {
+	return fmt.Sprintf(
+		"UPDATE %[1]s SET %[2]s WHERE id IN (SELECT id FROM %[1]s WHERE %[3]s LIMIT ?)",
+		quoteIdent(q.tableName), set, cond,
+	)
+}
@@ -0,0 +1,344 @@
+package sqliteq
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// registryTable lists the queues of the database and the aliases routing to them
+const registryTable = "sqliteq_queues"
+
+// Kinds of registry entries
+const (
+	kindQueue         = "queue"
+	kindPriorityQueue = "priority"
+	kindAlias         = "alias"
+)
+
+// ErrAliasConflict is returned by Alias when the alias is already the name of a queue
+var ErrAliasConflict = errors.New("alias conflicts with an existing queue")
+
+// initRegistry creates the registry table if it doesn't exist
+func initRegistry(db *sql.DB) error {
+	_, err := db.Exec(fmt.Sprintf(`
+	CREATE TABLE IF NOT EXISTS %s (
+		name TEXT PRIMARY KEY,
+		kind TEXT NOT NULL,
+		target TEXT,
+		created_at TIMESTAMP NOT NULL,
+		empty_since TIMESTAMP,
+		empty_seq INTEGER,
+		labels TEXT,
+		dynamic INTEGER NOT NULL DEFAULT 0
+	)`, quoteIdent(registryTable)))
+	if err != nil {
+		return err
+	}
+
+	// Registries created before dynamic queues were flagged have none
+	exists, err := hasColumn(db, registryTable, "dynamic")
+	if err != nil || exists {
+		return err
+	}
+
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN dynamic INTEGER NOT NULL DEFAULT 0", quoteIdent(registryTable)))
+	return err
+}
+
+// kind returns the registry kind of the queue
+func (q *Queue) kind() string {
+	if q.priority {
+		return kindPriorityQueue
+	}
+
+	return kindQueue
+}
+
+// register records a queue in the registry, keeping the original entry on reopen.
+// A queue first created by EnqueueTo is flagged dynamic until the application
+// opens it.
+func (q *Queue) register() error {
+	_, err := q.client.Exec(
+		fmt.Sprintf("INSERT OR IGNORE INTO %s (name, kind, created_at, dynamic) VALUES (?, ?, ?, ?)", quoteIdent(registryTable)),
+		q.tableName, q.kind(), q.now(), q.dynamic,
+	)
+	if err != nil {
+		return err
+	}
+
+	if !q.dynamic {
+		if err := q.keep(); err != nil {
+			return err
+		}
+	}
+
+	if q.labels == nil {
+		return nil
+	}
+
+	return q.manager.SetLabels(q.tableName, q.labels)
+}
+
+// keep clears the dynamic flag of the queue, so WithDropEmptyAfter leaves it alone
+func (q *Queue) keep() error {
+	q.dynamic = false
+
+	_, err := q.client.Exec(
+		fmt.Sprintf("UPDATE %s SET dynamic = 0 WHERE name = ? AND dynamic = 1", quoteIdent(registryTable)), q.tableName,
+	)
+
+	return err
+}
+
+// SetLabels replaces the labels of a queue, such as team=payments or tier=critical,
+// so large installs can filter and alert by owner. Labels are stored in the registry
+// and reported by Stats. A nil or empty map removes them.
+func (m *Manager) SetLabels(queue string, labels map[string]string) (err error) {
+	defer func() { err = mapError(err) }()
+
+	if m.closed.Load() {
+		return ErrQueuesClosed
+	}
+
+	if queue, err = m.resolve(queue); err != nil {
+		return err
+	}
+
+	var encoded sql.NullString
+	if len(labels) > 0 {
+		b, err := json.Marshal(labels)
+		if err != nil {
+			return err
+		}
+		encoded = sql.NullString{String: string(b), Valid: true}
+	}
+
+	result, err := m.client.Exec(
+		fmt.Sprintf("UPDATE %s SET labels = ? WHERE name = ? AND kind != ?", quoteIdent(registryTable)),
+		encoded, queue, kindAlias,
+	)
+	if err != nil {
+		return err
+	}
+
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		if err == nil {
+			err = fmt.Errorf("unknown queue %s", queue)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Labels returns the labels of a queue, nil if it has none
+func (m *Manager) Labels(queue string) (labels map[string]string, err error) {
+	defer func() { err = mapError(err) }()
+
+	if m.closed.Load() {
+		return nil, ErrQueuesClosed
+	}
+
+	if queue, err = m.resolve(queue); err != nil {
+		return nil, err
+	}
+
+	var encoded sql.NullString
+	err = m.client.QueryRow(
+		fmt.Sprintf("SELECT labels FROM %s WHERE name = ? AND kind != ?", quoteIdent(registryTable)), queue, kindAlias,
+	).Scan(&encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeLabels(encoded)
+}
+
+// QueuesWithLabel returns the names of the queues whose label key is set to value
+func (m *Manager) QueuesWithLabel(key, value string) (names []string, err error) {
+	defer func() { err = mapError(err) }()
+
+	if m.closed.Load() {
+		return nil, ErrQueuesClosed
+	}
+
+	rows, err := m.client.Query(
+		fmt.Sprintf("SELECT name FROM %s WHERE kind != ? AND json_extract(labels, '$.' || json_quote(?)) = ? ORDER BY name", quoteIdent(registryTable)),
+		kindAlias, key, value,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+
+	return names, rows.Err()
+}
+
+// QueueInfo describes a queue of the database, as listed by List
+type QueueInfo struct {
+	Name string
+	// Priority reports whether it was created as a PriorityQueue
+	Priority bool
+	// CreatedAt is when the queue was first opened
+	CreatedAt time.Time
+}
+
+// List returns the queues of the database by name, whether opened by this process
+// or another, so tooling can enumerate them without hardcoding names. Aliases are
+// left out.
+func (m *Manager) List() (queues []QueueInfo, err error) {
+	defer func() { err = mapError(err) }()
+
+	if m.closed.Load() {
+		return nil, ErrQueuesClosed
+	}
+
+	rows, err := m.client.Query(
+		fmt.Sprintf("SELECT name, kind, created_at FROM %s WHERE kind != ? ORDER BY name", quoteIdent(registryTable)), kindAlias,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var info QueueInfo
+		var kind string
+		if err := rows.Scan(&info.Name, &kind, &info.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		info.Priority = kind == kindPriorityQueue
+		queues = append(queues, info)
+	}
+
+	return queues, rows.Err()
+}
+
+// decodeLabels decodes labels stored in the registry
+func decodeLabels(encoded sql.NullString) (map[string]string, error) {
+	if !encoded.Valid {
+		return nil, nil
+	}
+
+	var labels map[string]string
+	if err := json.Unmarshal([]byte(encoded.String), &labels); err != nil {
+		return nil, fmt.Errorf("invalid labels: %w", err)
+	}
+
+	return labels, nil
+}
+
+// resolve returns the queue a name routes to: the target of an alias or the name itself
+func (m *Manager) resolve(name string) (string, error) {
+	var target sql.NullString
+	err := m.client.QueryRow(
+		fmt.Sprintf("SELECT target FROM %s WHERE name = ? AND kind = ?", quoteIdent(registryTable)),
+		name, kindAlias,
+	).Scan(&target)
+	if errors.Is(err, sql.ErrNoRows) {
+		return name, nil
+	}
+	if err != nil {
+		return "", mapError(err)
+	}
+
+	return target.String, nil
+}
+
+// registeredKind returns the registry kind of the queue table name, empty when it isn't
+// registered yet
+func registeredKind(db querier, name string) (string, error) {
+	var kind string
+	err := db.QueryRow(
+		fmt.Sprintf("SELECT kind FROM %s WHERE name = ? AND kind != ?", quoteIdent(registryTable)),
+		name, kindAlias,
+	).Scan(&kind)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+
+	return kind, err
+}
+
+// checkKind returns ErrKindMismatch when the queue is registered as the other kind,
+// which only ConvertToPriority and ConvertToPlain change
+func (q *Queue) checkKind() error {
+	kind, err := registeredKind(q.client, q.tableName)
+	if err != nil || kind == "" || kind == q.kind() {
+		return err
+	}
+
+	if kind == kindPriorityQueue {
+		return fmt.Errorf("%w: %s is a priority queue, see ConvertToPlain", ErrKindMismatch, q.tableName)
+	}
+
+	return fmt.Errorf("%w: %s is a plain queue, see ConvertToPriority", ErrKindMismatch, q.tableName)
+}
+
+// Alias routes the name alias to the queue target, so NewQueue(alias) and
+// NewPriorityQueue(alias) open target. Producers can keep using a stable name
+// while the physical queue behind it is swapped, e.g. Alias("emails-v2", "emails")
+// during a migration. Calling Alias again repoints the alias; queues already open
+// keep the target they were opened with.
+func (m *Manager) Alias(target, alias string) (err error) {
+	defer func() { err = mapError(err) }()
+
+	if m.closed.Load() {
+		return ErrQueuesClosed
+	}
+
+	// Aliases of aliases route straight to the final queue
+	if target, err = m.resolve(target); err != nil {
+		return err
+	}
+
+	if target == alias {
+		return fmt.Errorf("%w: %s can't route to itself", ErrAliasConflict, alias)
+	}
+
+	var kind string
+	err = m.client.QueryRow(
+		fmt.Sprintf("SELECT kind FROM %s WHERE name = ?", quoteIdent(registryTable)), alias,
+	).Scan(&kind)
+	if err == nil && kind != kindAlias {
+		return fmt.Errorf("%w: %s", ErrAliasConflict, alias)
+	}
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	_, err = m.client.Exec(fmt.Sprintf(`
+	INSERT INTO %s (name, kind, target, created_at) VALUES (?, ?, ?, ?)
+	ON CONFLICT (name) DO UPDATE SET target = excluded.target
+	`, quoteIdent(registryTable)), alias, kindAlias, target, time.Now().UTC())
+
+	return err
+}
+
+// Unalias removes an alias created with Alias
+func (m *Manager) Unalias(alias string) (err error) {
+	defer func() { err = mapError(err) }()
+
+	if m.closed.Load() {
+		return ErrQueuesClosed
+	}
+
+	_, err = m.client.Exec(
+		fmt.Sprintf("DELETE FROM %s WHERE name = ? AND kind = ?", quoteIdent(registryTable)),
+		alias, kindAlias,
+	)
+
+	return err
+}
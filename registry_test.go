@@ -0,0 +1,331 @@
+package sqliteq
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestAlias(t *testing.T) {
+	dbPath := "test_alias.db"
+	defer os.Remove(dbPath)
+
+	queues, err := NewManager(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	defer queues.Close()
+
+	v1, err := queues.NewQueue("emails")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	v2, err := queues.NewQueue("emails_v2")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	if err := queues.Alias("emails_v2", "mail"); err != nil {
+		t.Fatalf("Alias failed: %v", err)
+	}
+
+	producer, err := queues.NewQueue("mail")
+	if err != nil {
+		t.Fatalf("Failed to open alias: %v", err)
+	}
+
+	producer.Enqueue("welcome")
+
+	if v2.Len() != 1 || v1.Len() != 0 {
+		t.Errorf("Expected the item in emails_v2, got v1=%d v2=%d", v1.Len(), v2.Len())
+	}
+
+	t.Run("Chained", func(t *testing.T) {
+		if err := queues.Alias("mail", "outbox"); err != nil {
+			t.Fatalf("Alias failed: %v", err)
+		}
+
+		q, err := queues.NewQueue("outbox")
+		if err != nil {
+			t.Fatalf("Failed to open alias: %v", err)
+		}
+
+		if q.tableName != "emails_v2" {
+			t.Errorf("Expected outbox to route to emails_v2, got %s", q.tableName)
+		}
+	})
+
+	t.Run("Repoint", func(t *testing.T) {
+		if err := queues.Alias("emails", "mail"); err != nil {
+			t.Fatalf("Alias failed: %v", err)
+		}
+
+		q, _ := queues.NewQueue("mail")
+		if q.tableName != "emails" {
+			t.Errorf("Expected mail to route to emails, got %s", q.tableName)
+		}
+	})
+
+	t.Run("Conflict", func(t *testing.T) {
+		if err := queues.Alias("emails_v2", "emails"); !errors.Is(err, ErrAliasConflict) {
+			t.Errorf("Expected ErrAliasConflict for an existing queue name, got %v", err)
+		}
+	})
+
+	t.Run("Unalias", func(t *testing.T) {
+		if err := queues.Unalias("mail"); err != nil {
+			t.Fatalf("Unalias failed: %v", err)
+		}
+
+		q, _ := queues.NewQueue("mail")
+		if q.tableName != "mail" {
+			t.Errorf("Expected mail to be its own queue again, got %s", q.tableName)
+		}
+	})
+}
+
+func TestEnqueueTo(t *testing.T) {
+	dbPath := "test_enqueue_to.db"
+	defer os.Remove(dbPath)
+
+	queues, err := NewManager(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	defer queues.Close()
+
+	for _, device := range []string{"device_1", "device_2", "device_1"} {
+		if err := queues.EnqueueTo(device, "reading"); err != nil {
+			t.Fatalf("EnqueueTo %s failed: %v", device, err)
+		}
+	}
+
+	q, err := queues.NewQueue("device_1")
+	if err != nil {
+		t.Fatalf("Failed to open queue: %v", err)
+	}
+
+	if q.Len() != 2 {
+		t.Errorf("Expected 2 items for device_1, got %d", q.Len())
+	}
+
+	// The queue opened by the first EnqueueTo is reused
+	if n := len(queues.open); n != 3 {
+		t.Errorf("Expected 3 open queues, got %d", n)
+	}
+
+	if err := queues.EnqueueTo("device_3", nil); err == nil {
+		t.Error("Expected an error for an unsupported payload")
+	}
+
+	t.Run("InFlightItemsAreLeftAlone", func(t *testing.T) {
+		// Another process is working on an item of the queue
+		consumer, err := q.DequeueMessageWithAckId()
+		if err != nil {
+			t.Fatalf("Dequeue failed: %v", err)
+		}
+
+		producer, err := NewManager(dbPath)
+		if err != nil {
+			t.Fatalf("Failed to create manager: %v", err)
+		}
+		defer producer.Close()
+
+		if err := producer.EnqueueTo("device_1", "reading"); err != nil {
+			t.Fatalf("EnqueueTo failed: %v", err)
+		}
+
+		if depth, _ := q.LenDetailed(); depth.Processing != 1 {
+			t.Errorf("Expected the item in flight to stay in processing, got %+v", depth)
+		}
+
+		if err := q.Ack(consumer.AckID); err != nil {
+			t.Errorf("Expected the consumer to acknowledge its item, got %v", err)
+		}
+	})
+}
+
+func TestLabels(t *testing.T) {
+	dbPath := "test_labels.db"
+	defer os.Remove(dbPath)
+
+	queues, err := NewManager(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	defer queues.Close()
+
+	payments, err := queues.NewQueue("payments", WithLabels(map[string]string{"team": "payments", "tier": "critical"}))
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	if _, err := queues.NewQueue("reports", WithLabels(map[string]string{"team": "analytics"})); err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	stats, err := payments.Stats()
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+
+	if stats.Labels["team"] != "payments" || stats.Labels["tier"] != "critical" {
+		t.Errorf("Expected labels in stats, got %v", stats.Labels)
+	}
+
+	names, err := queues.QueuesWithLabel("tier", "critical")
+	if err != nil || len(names) != 1 || names[0] != "payments" {
+		t.Errorf("Expected [payments], got %v (%v)", names, err)
+	}
+
+	if err := queues.SetLabels("reports", map[string]string{"team": "analytics", "tier": "critical"}); err != nil {
+		t.Fatalf("SetLabels failed: %v", err)
+	}
+
+	if names, _ := queues.QueuesWithLabel("tier", "critical"); len(names) != 2 {
+		t.Errorf("Expected 2 critical queues, got %v", names)
+	}
+
+	if err := queues.SetLabels("payments", nil); err != nil {
+		t.Fatalf("SetLabels failed: %v", err)
+	}
+
+	if labels, _ := queues.Labels("payments"); labels != nil {
+		t.Errorf("Expected labels to be removed, got %v", labels)
+	}
+
+	if err := queues.SetLabels("missing", map[string]string{"team": "none"}); err == nil {
+		t.Error("Expected an error for an unknown queue")
+	}
+}
+
+func TestAckAll(t *testing.T) {
+	dbPath := "test_ack_all.db"
+	defer os.Remove(dbPath)
+
+	queues, err := NewManager(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	defer queues.Close()
+
+	orders, _ := queues.NewQueue("orders")
+	payments, _ := queues.NewQueue("payments", WithRemoveOnComplete(false))
+
+	orders.Enqueue("order")
+	payments.Enqueue("payment")
+	_, _, orderAck := orders.DequeueWithAckId()
+	_, _, paymentAck := payments.DequeueWithAckId()
+
+	t.Run("RollsBackOnFailure", func(t *testing.T) {
+		err := queues.AckAll(map[string]string{"orders": orderAck, "payments": "unknown"})
+		if !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("Expected sql.ErrNoRows for the unknown ack ID, got %v", err)
+		}
+
+		if depth, _ := orders.LenDetailed(); depth.Processing != 1 {
+			t.Errorf("Expected the order to stay in processing, got %+v", depth)
+		}
+	})
+
+	t.Run("CommitsTogether", func(t *testing.T) {
+		if err := queues.AckAll(map[string]string{"orders": orderAck, "payments": paymentAck}); err != nil {
+			t.Fatalf("AckAll failed: %v", err)
+		}
+
+		if depth, _ := orders.LenDetailed(); depth != (Depth{}) {
+			t.Errorf("Expected the order to be removed, got %+v", depth)
+		}
+
+		if depth, _ := payments.LenDetailed(); depth != (Depth{Completed: 1}) {
+			t.Errorf("Expected the payment to be completed, got %+v", depth)
+		}
+	})
+}
+
+func TestList(t *testing.T) {
+	dbPath := "test_list.db"
+	defer os.Remove(dbPath)
+
+	queues, err := NewManager(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	defer queues.Close()
+
+	if _, err := queues.NewQueue("emails"); err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	if _, err := queues.NewPriorityQueue("alerts"); err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	if err := queues.Alias("emails", "mail"); err != nil {
+		t.Fatalf("Alias failed: %v", err)
+	}
+
+	list, err := queues.List()
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+
+	if len(list) != 2 || list[0].Name != "alerts" || !list[0].Priority || list[1].Name != "emails" || list[1].Priority {
+		t.Errorf("Expected alerts (priority) and emails, got %+v", list)
+	}
+
+	if list[0].CreatedAt.IsZero() {
+		t.Error("Expected a creation time")
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	dbPath := "test_describe.db"
+	defer os.Remove(dbPath)
+
+	queues, err := NewManager(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	defer queues.Close()
+
+	if exists, err := queues.Exists("emails"); err != nil || exists {
+		t.Errorf("Expected no emails queue yet, got %v (%v)", exists, err)
+	}
+
+	q, err := queues.NewQueue("emails", WithMaxLength(100), WithLabels(map[string]string{"team": "growth"}))
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	q.Enqueue("hello")
+	q.Enqueue("world")
+	q.DequeueWithAckId()
+
+	if err := queues.Alias("emails", "mail"); err != nil {
+		t.Fatalf("Alias failed: %v", err)
+	}
+
+	if exists, err := queues.Exists("mail"); err != nil || !exists {
+		t.Errorf("Expected the alias to exist, got %v (%v)", exists, err)
+	}
+
+	d, err := queues.Describe("mail")
+	if err != nil {
+		t.Fatalf("Describe failed: %v", err)
+	}
+
+	if d.Name != "emails" || d.Priority || d.CreatedAt.IsZero() || d.Labels["team"] != "growth" {
+		t.Errorf("Expected the emails queue with its labels, got %+v", d)
+	}
+	if d.Depth.Pending != 1 || d.Depth.Processing != 1 || d.PayloadBytes != 10 {
+		t.Errorf("Expected 1 pending and 1 processing item of 10 bytes, got %+v and %d", d.Depth, d.PayloadBytes)
+	}
+	if d.Options == nil || d.Options.MaxLength != 100 {
+		t.Errorf("Expected the options of the open queue, got %+v", d.Options)
+	}
+
+	if _, err := queues.Describe("missing"); !errors.Is(err, ErrUnknownQueue) {
+		t.Errorf("Expected ErrUnknownQueue, got %v", err)
+	}
+}
@@ -0,0 +1,156 @@
+package sqliteq
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestEnqueueRepeating(t *testing.T) {
+	dbPath := "test_repeating.db"
+	defer os.Remove(dbPath)
+
+	queues := New(dbPath)
+	defer queues.Close()
+
+	t.Run("RepeatsAfterAcknowledge", func(t *testing.T) {
+		q, err := queues.NewQueue("repeat_ack")
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+
+		if !q.EnqueueRepeating("tick", 50*time.Millisecond, time.Time{}) {
+			t.Fatal("Failed to enqueue repeating item")
+		}
+
+		_, ok, ackID := q.DequeueWithAckId()
+		if !ok {
+			t.Fatal("Expected the first occurrence to be due at once")
+		}
+
+		if !q.Acknowledge(ackID) {
+			t.Fatal("Failed to acknowledge item")
+		}
+
+		if q.Len() != 1 {
+			t.Errorf("Expected next occurrence to be pending, got length %d", q.Len())
+		}
+
+		if _, ok := q.Dequeue(); ok {
+			t.Error("Expected next occurrence not to be due yet")
+		}
+
+		time.Sleep(60 * time.Millisecond)
+
+		data, ok := q.Dequeue()
+		if !ok {
+			t.Fatal("Expected next occurrence to be due")
+		}
+
+		if string(data.([]byte)) != "tick" {
+			t.Errorf("Expected tick, got %s", data)
+		}
+	})
+
+	t.Run("StopsAfterUntil", func(t *testing.T) {
+		q, err := queues.NewQueue("repeat_until")
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+
+		q.EnqueueRepeating("once", time.Hour, time.Now().Add(time.Minute))
+
+		if _, ok := q.Dequeue(); !ok {
+			t.Fatal("Failed to dequeue item")
+		}
+
+		if q.Len() != 0 {
+			t.Errorf("Expected no further occurrence, got length %d", q.Len())
+		}
+	})
+
+	t.Run("KeepsPriority", func(t *testing.T) {
+		pq, err := queues.NewPriorityQueue("repeat_priority")
+		if err != nil {
+			t.Fatalf("Failed to create priority queue: %v", err)
+		}
+
+		pq.EnqueueRepeating("urgent", 1, time.Hour, time.Time{})
+		pq.Dequeue()
+
+		messages, err := pq.Page(0, 10)
+		if err != nil {
+			t.Fatalf("Failed to page: %v", err)
+		}
+
+		if len(messages) != 1 {
+			t.Fatalf("Expected 1 message, got %d", len(messages))
+		}
+
+		m := messages[0]
+		if m.Priority != 1 || m.RepeatEvery != time.Hour || m.NotBefore.IsZero() {
+			t.Errorf("Expected next occurrence with priority 1 every hour, got %+v", m)
+		}
+	})
+
+	t.Run("RejectsNonPositiveInterval", func(t *testing.T) {
+		q, err := queues.NewQueue("repeat_invalid")
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+
+		if q.EnqueueRepeating("never", 0, time.Time{}) {
+			t.Error("Expected zero interval to be rejected")
+		}
+	})
+}
+
+func TestEnqueueDelayed(t *testing.T) {
+	dbPath := "test_delayed.db"
+	defer os.Remove(dbPath)
+
+	queues := New(dbPath)
+	defer queues.Close()
+
+	q, err := queues.NewQueue("test_queue")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	if !q.EnqueueAfter("later", 50*time.Millisecond) || !q.EnqueueAt("tomorrow", time.Now().Add(24*time.Hour)) {
+		t.Fatal("Failed to enqueue delayed items")
+	}
+	q.Enqueue("now")
+
+	if item, _ := q.Dequeue(); string(item.([]byte)) != "now" {
+		t.Errorf("Expected the undelayed item first, got %s", item)
+	}
+
+	if _, success := q.Dequeue(); success {
+		t.Error("Expected no item before its time")
+	}
+
+	time.Sleep(60 * time.Millisecond)
+
+	if item, success := q.Dequeue(); !success || string(item.([]byte)) != "later" {
+		t.Errorf("Expected 'later' once its delay passed, got %v", item)
+	}
+
+	if _, success := q.Dequeue(); success {
+		t.Error("Expected 'tomorrow' to stay scheduled")
+	}
+
+	t.Run("Priority", func(t *testing.T) {
+		pq, err := queues.NewPriorityQueue("test_priority_queue")
+		if err != nil {
+			t.Fatalf("Failed to create priority queue: %v", err)
+		}
+
+		pq.EnqueueAfter("urgent but later", 0, time.Hour)
+		pq.Enqueue("routine", 5)
+
+		if item, _ := pq.Dequeue(); string(item.([]byte)) != "routine" {
+			t.Errorf("Expected the due item despite its lower priority, got %s", item)
+		}
+	})
+}
@@ -0,0 +1,77 @@
+package sqliteq
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Defaults for WithWriteRetry
+const (
+	defaultWriteRetryAttempts = 3
+	defaultWriteRetryBackoff  = 10 * time.Millisecond
+)
+
+// retryWrite runs a write until it succeeds, fails with an error that isn't
+// retriable, or the attempts set with WithWriteRetry are used up, doubling the
+// backoff between attempts
+func (q *Queue) retryWrite(write func() error) error {
+	backoff := q.writeRetryBackoff
+
+	for attempt := 1; ; attempt++ {
+		err := write()
+		if err == nil || !IsRetriable(err) || attempt >= q.writeRetryAttempts || q.closed.Load() {
+			return err
+		}
+
+		q.contention.retries.Add(1)
+		q.contention.lockWait.Add(int64(backoff))
+		q.logger().Warn("retrying write", "queue", q.tableName, "attempt", attempt, "backoff", backoff, "error", err)
+
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// Contention describes how often writes through a queue found the database busy,
+// to tell when a hot queue would be better off in its own database file
+type Contention struct {
+	// BusyErrors is the number of write attempts that failed because the database
+	// was busy or locked, including those that were retried
+	BusyErrors int64
+	// Retries is the number of write attempts repeated by WithWriteRetry
+	Retries int64
+	// LockWait is the time spent in failed write attempts, which includes SQLite's
+	// busy timeout, and in the backoff between retries
+	LockWait time.Duration
+}
+
+// contentionCounters accumulate the Contention of a queue since it was opened
+type contentionCounters struct {
+	busyErrors atomic.Int64
+	retries    atomic.Int64
+	lockWait   atomic.Int64
+}
+
+// observeWrite records a write started at start that failed with *err, if the
+// database was busy, and logs its rollback. It is deferred by write methods.
+func (q *Queue) observeWrite(start time.Time, err *error) {
+	if *err != nil && !quiet(*err) {
+		q.logger().Debug("write rolled back", "queue", q.tableName, "error", *err)
+	}
+
+	if !IsRetriable(*err) {
+		return
+	}
+
+	q.contention.busyErrors.Add(1)
+	q.contention.lockWait.Add(int64(time.Since(start)))
+}
+
+// Contention returns the write contention seen by the queue since it was opened
+func (q *Queue) Contention() Contention {
+	return Contention{
+		BusyErrors: q.contention.busyErrors.Load(),
+		Retries:    q.contention.retries.Load(),
+		LockWait:   time.Duration(q.contention.lockWait.Load()),
+	}
+}
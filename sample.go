@@ -0,0 +1,47 @@
+package sqliteq
+
+import "fmt"
+
+// samplePayloadLimit is the number of payload bytes Sample returns per item
+const samplePayloadLimit = 1024
+
+// Sample returns up to n items with the given status picked uniformly at random,
+// or from all items when status is empty, so operators can see what a large
+// backlog holds without exporting it. Payloads are passed through the
+// WithRedactor function and cut to their first 1 KiB. The items are returned in
+// insertion order and their status is left unchanged.
+func (q *Queue) Sample(n int, status Status) (messages []Message, err error) {
+	defer func() { err = mapError(err) }()
+
+	if q.closed.Load() {
+		return nil, ErrClosed
+	}
+
+	if n <= 0 {
+		return nil, nil
+	}
+
+	cond, args := "1", []any{}
+	if status != "" {
+		cond, args = "status = ?", append(args, status)
+	}
+
+	// Picking the IDs first sorts the status index only, not the payloads
+	messages, err = q.queryMessages(q.client, fmt.Sprintf(
+		"SELECT %[1]s FROM %[2]s WHERE id IN (SELECT id FROM %[2]s WHERE %[3]s ORDER BY RANDOM() LIMIT ?) ORDER BY seq",
+		q.messageColumns(), quoteIdent(q.tableName), cond,
+	), append(args, n)...)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range messages {
+		data := q.Redact(messages[i].Data)
+		if len(data) > samplePayloadLimit {
+			data = data[:samplePayloadLimit]
+		}
+		messages[i].Data = data
+	}
+
+	return messages, nil
+}
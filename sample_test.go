@@ -0,0 +1,80 @@
+package sqliteq
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSample(t *testing.T) {
+	dbPath := "test_sample.db"
+	defer os.Remove(dbPath)
+
+	queues := New(dbPath)
+	defer queues.Close()
+
+	q, err := queues.NewQueue("backlog", WithRedactor(func(payload []byte) []byte {
+		return bytes.ReplaceAll(payload, []byte("secret"), []byte("******"))
+	}))
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	for i := 0; i < 50; i++ {
+		q.Enqueue("secret job")
+	}
+	q.Enqueue(strings.Repeat("x", 2*samplePayloadLimit))
+	q.DequeueNWithAckIds(5)
+
+	t.Run("ByStatus", func(t *testing.T) {
+		messages, err := q.Sample(10, StatusPending)
+		if err != nil {
+			t.Fatalf("Sample failed: %v", err)
+		}
+
+		if len(messages) != 10 {
+			t.Fatalf("Expected 10 items, got %d", len(messages))
+		}
+
+		seen := make(map[int64]bool)
+		for _, m := range messages {
+			if m.Status != StatusPending {
+				t.Errorf("Expected only pending items, got %s", m.Status)
+			}
+			if seen[m.ID] {
+				t.Errorf("Expected distinct items, got %d twice", m.ID)
+			}
+			seen[m.ID] = true
+		}
+
+		processing, _ := q.Sample(10, StatusProcessing)
+		if len(processing) != 5 {
+			t.Errorf("Expected the 5 processing items, got %d", len(processing))
+		}
+
+		if stats, _ := q.Stats(); stats.Pending != 46 || stats.Processing != 5 {
+			t.Errorf("Expected sampling to leave the items unchanged, got %+v", stats)
+		}
+	})
+
+	t.Run("Payloads", func(t *testing.T) {
+		messages, err := q.Sample(100, "")
+		if err != nil {
+			t.Fatalf("Sample failed: %v", err)
+		}
+
+		if len(messages) != 51 {
+			t.Fatalf("Expected all 51 items, got %d", len(messages))
+		}
+
+		for _, m := range messages[:50] {
+			if string(m.Data) != "****** job" {
+				t.Errorf("Expected a redacted payload, got %q", m.Data)
+			}
+		}
+		if n := len(messages[50].Data); n != samplePayloadLimit {
+			t.Errorf("Expected the large payload cut to %d bytes, got %d", samplePayloadLimit, n)
+		}
+	})
+}
@@ -0,0 +1,241 @@
+package sqliteq
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// schedulesTable stores the recurring job definitions of a Scheduler
+const schedulesTable = "sqliteq_schedules"
+
+// schedulerPoll is how often Scheduler.Run checks for due jobs
+const schedulerPoll = time.Second
+
+// ErrUnknownSchedule is returned by Scheduler.Remove for a name with no schedule
+var ErrUnknownSchedule = errors.New("unknown schedule")
+
+// errScheduleFired reports a due job fired by another scheduler in the meantime
+var errScheduleFired = errors.New("schedule already fired")
+
+// Schedule is a recurring job definition
+type Schedule struct {
+	// Name identifies the schedule
+	Name string
+	// Spec is the cron expression, e.g. "*/5 * * * *", "@daily" or "@every 90s",
+	// evaluated in UTC
+	Spec string
+	// Queue is the name of the queue jobs are enqueued into, which may be an alias
+	Queue string
+	// Payload is the item enqueued every time the schedule fires
+	Payload []byte
+	// NextRun is when the schedule fires next
+	NextRun time.Time
+	// LastRun is when the schedule last fired, zero if it never did
+	LastRun time.Time
+}
+
+// Scheduler enqueues jobs into queues of the database on cron-like schedules,
+// stored alongside the queues so periodic jobs survive restarts. Several processes
+// may run schedulers on the same database: each due job is enqueued once.
+type Scheduler struct {
+	manager *Manager
+}
+
+// NewScheduler returns a scheduler for the jobs stored in the database. Jobs are
+// enqueued by Run or RunDue.
+func (m *Manager) NewScheduler() (*Scheduler, error) {
+	if m.closed.Load() {
+		return nil, ErrQueuesClosed
+	}
+
+	_, err := m.client.Exec(fmt.Sprintf(`
+	CREATE TABLE IF NOT EXISTS %[1]s (
+		name TEXT PRIMARY KEY,
+		spec TEXT NOT NULL,
+		queue TEXT NOT NULL,
+		payload BLOB NOT NULL,
+		next_run TIMESTAMP NOT NULL,
+		last_run TIMESTAMP,
+		created_at TIMESTAMP NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS %[2]s ON %[1]s (next_run);
+	`, quoteIdent(schedulesTable), quoteIdent(schedulesTable+"_next_run_idx")))
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize schedules: %w", mapError(err))
+	}
+
+	return &Scheduler{manager: m}, nil
+}
+
+// Add schedules payload to be enqueued into queue whenever spec fires, replacing
+// any schedule of the same name. spec is a five-field cron expression (minute,
+// hour, day of month, month, day of week) with *, lists, ranges and steps, one of
+// @yearly, @monthly, @weekly, @daily and @hourly, or "@every <duration>".
+func (s *Scheduler) Add(name, spec, queue string, payload any) (err error) {
+	defer func() { err = mapError(err) }()
+
+	schedule, err := parseCron(spec)
+	if err != nil {
+		return err
+	}
+
+	data, err := payloadBytes(payload)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	next := schedule.next(now)
+	if next.IsZero() {
+		return fmt.Errorf("invalid schedule %q: never fires", spec)
+	}
+
+	_, err = s.manager.client.Exec(fmt.Sprintf(`
+	INSERT INTO %s (name, spec, queue, payload, next_run, created_at) VALUES (?, ?, ?, ?, ?, ?)
+	ON CONFLICT (name) DO UPDATE SET
+		spec = excluded.spec, queue = excluded.queue, payload = excluded.payload, next_run = excluded.next_run
+	`, quoteIdent(schedulesTable)), name, spec, queue, data, next, now)
+
+	return err
+}
+
+// Remove deletes the schedule of the given name
+func (s *Scheduler) Remove(name string) (err error) {
+	defer func() { err = mapError(err) }()
+
+	result, err := s.manager.client.Exec(fmt.Sprintf("DELETE FROM %s WHERE name = ?", quoteIdent(schedulesTable)), name)
+	if err != nil {
+		return err
+	}
+
+	removed, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if removed == 0 {
+		return fmt.Errorf("%w: %s", ErrUnknownSchedule, name)
+	}
+
+	return nil
+}
+
+// List returns the schedules ordered by name
+func (s *Scheduler) List() ([]Schedule, error) {
+	return s.query("SELECT name, spec, queue, payload, next_run, last_run FROM %s ORDER BY name")
+}
+
+// query reads schedules with a statement formatted with the table name
+func (s *Scheduler) query(query string, args ...any) (schedules []Schedule, err error) {
+	defer func() { err = mapError(err) }()
+
+	rows, err := s.manager.client.Query(fmt.Sprintf(query, quoteIdent(schedulesTable)), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var sc Schedule
+		var lastRun sql.NullTime
+		if err := rows.Scan(&sc.Name, &sc.Spec, &sc.Queue, &sc.Payload, &sc.NextRun, &lastRun); err != nil {
+			return nil, err
+		}
+
+		sc.LastRun = lastRun.Time
+		schedules = append(schedules, sc)
+	}
+
+	return schedules, rows.Err()
+}
+
+// RunDue enqueues a job for every schedule that is due and moves it to its next
+// run. A schedule that missed several runs, e.g. while no scheduler was running,
+// fires once. Enqueuing a job and moving its schedule happen in one transaction.
+// Returns the number of jobs enqueued
+func (s *Scheduler) RunDue() (enqueued int, err error) {
+	if s.manager.closed.Load() {
+		return 0, ErrQueuesClosed
+	}
+
+	now := time.Now().UTC()
+
+	due, err := s.query("SELECT name, spec, queue, payload, next_run, last_run FROM %s WHERE next_run <= ? ORDER BY next_run", now)
+	if err != nil {
+		return 0, err
+	}
+
+	var errs []error
+	for _, sc := range due {
+		fired, err := s.fire(sc, now)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("schedule %s: %w", sc.Name, err))
+			continue
+		}
+
+		if fired {
+			enqueued++
+		}
+	}
+
+	return enqueued, errors.Join(errs...)
+}
+
+// fire enqueues the job of a due schedule unless another scheduler fired it first
+func (s *Scheduler) fire(sc Schedule, now time.Time) (bool, error) {
+	schedule, err := parseCron(sc.Spec)
+	if err != nil {
+		return false, err
+	}
+
+	q, err := s.manager.queue(sc.Queue, false)
+	if err != nil {
+		return false, err
+	}
+
+	err = q.enqueueMessage(sc.Payload, Message{}, func(tx *sql.Tx, m *Message) error {
+		result, err := tx.Exec(
+			fmt.Sprintf("UPDATE %s SET next_run = ?, last_run = ? WHERE name = ? AND next_run = ?", quoteIdent(schedulesTable)),
+			schedule.next(now), now, sc.Name, sc.NextRun,
+		)
+		if err != nil {
+			return err
+		}
+
+		moved, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if moved == 0 {
+			return errScheduleFired
+		}
+
+		return nil
+	})
+	if errors.Is(err, errScheduleFired) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// Run enqueues due jobs every second until ctx is done, returning its error.
+// Failures of individual schedules don't stop it; they are retried on the next tick.
+func (s *Scheduler) Run(ctx context.Context) error {
+	ticker := time.NewTicker(schedulerPoll)
+	defer ticker.Stop()
+
+	for {
+		s.RunDue()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
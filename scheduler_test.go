@@ -0,0 +1,91 @@
+package sqliteq
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestScheduler(t *testing.T) {
+	dbPath := "test_scheduler.db"
+	defer os.Remove(dbPath)
+
+	queues, err := NewManager(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	defer queues.Close()
+
+	s, err := queues.NewScheduler()
+	if err != nil {
+		t.Fatalf("NewScheduler failed: %v", err)
+	}
+
+	if err := s.Add("cleanup", "@hourly", "jobs", "run cleanup"); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	if err := s.Add("broken", "every now and then", "jobs", "x"); err == nil {
+		t.Error("Expected an invalid spec to be rejected")
+	}
+
+	t.Run("NotDue", func(t *testing.T) {
+		if enqueued, err := s.RunDue(); err != nil || enqueued != 0 {
+			t.Errorf("Expected nothing enqueued, got %d, %v", enqueued, err)
+		}
+	})
+
+	// Pretend the schedule is due
+	client := queues.client
+	client.Exec("UPDATE sqliteq_schedules SET next_run = ?", time.Now().UTC().Add(-3*time.Hour))
+
+	t.Run("Due", func(t *testing.T) {
+		// A second scheduler on the same database must not enqueue the job again
+		other, _ := queues.NewScheduler()
+
+		enqueued, err := s.RunDue()
+		if err != nil || enqueued != 1 {
+			t.Fatalf("Expected 1 job enqueued, got %d, %v", enqueued, err)
+		}
+
+		if enqueued, _ := other.RunDue(); enqueued != 0 {
+			t.Errorf("Expected the job enqueued once, got %d more", enqueued)
+		}
+
+		q, err := queues.NewQueue("jobs")
+		if err != nil {
+			t.Fatalf("Failed to open queue: %v", err)
+		}
+
+		if item, ok := q.Dequeue(); !ok || string(item.([]byte)) != "run cleanup" {
+			t.Errorf("Expected the scheduled job, got %v", item)
+		}
+
+		if q.Len() != 0 {
+			t.Errorf("Expected missed runs to fire once, got %d more jobs", q.Len())
+		}
+	})
+
+	t.Run("List", func(t *testing.T) {
+		schedules, err := s.List()
+		if err != nil || len(schedules) != 1 {
+			t.Fatalf("Expected 1 schedule, got %v, %v", schedules, err)
+		}
+
+		sc := schedules[0]
+		if sc.LastRun.IsZero() || !sc.NextRun.After(time.Now()) || sc.NextRun.Minute() != 0 {
+			t.Errorf("Expected the schedule moved to the next hour, got %+v", sc)
+		}
+	})
+
+	t.Run("Remove", func(t *testing.T) {
+		if err := s.Remove("cleanup"); err != nil {
+			t.Errorf("Remove failed: %v", err)
+		}
+
+		if err := s.Remove("cleanup"); !errors.Is(err, ErrUnknownSchedule) {
+			t.Errorf("Expected ErrUnknownSchedule, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,104 @@
+package sqliteq
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// column is a column added to queue tables after the original schema
+type column struct {
+	name       string
+	definition string
+	// backfill optionally fills the new column for existing rows, formatted with the table name
+	backfill string
+}
+
+// queueColumns are added to queue tables missing them when a queue is opened,
+// so tables created by older versions keep working
+var queueColumns = []column{
+	{"not_before", "TIMESTAMP", ""},
+	{"repeat_every", "INTEGER NOT NULL DEFAULT 0", ""},
+	{"repeat_until", "TIMESTAMP", ""},
+	// Items enqueued before seq existed keep their insertion order
+	{"seq", "INTEGER", "UPDATE %s SET seq = id"},
+	{"fail_reason", "TEXT", ""},
+	{"attempts", "INTEGER NOT NULL DEFAULT 0", ""},
+	{"origin", "TEXT", ""},
+	{"expires_at", "TIMESTAMP", ""},
+	{"pinned", "INTEGER NOT NULL DEFAULT 0", ""},
+	{"headers", "TEXT", ""},
+	{"message_id", "TEXT", ""},
+	{"correlation_id", "TEXT", ""},
+	{"epoch", "INTEGER NOT NULL DEFAULT 0", ""},
+}
+
+// sequencesTable holds the per-queue counters assigning seq to new items
+const sequencesTable = "sqliteq_sequences"
+
+// migrateColumns adds the columns missing from the queue table
+func (q *Queue) migrateColumns() error {
+	for _, c := range queueColumns {
+		exists, err := hasColumn(q.client, q.tableName, c.name)
+		if err != nil {
+			return err
+		}
+
+		if exists {
+			continue
+		}
+
+		if _, err := q.client.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", quoteIdent(q.tableName), quoteIdent(c.name), c.definition)); err != nil {
+			return fmt.Errorf("failed to add column %s: %w", c.name, err)
+		}
+
+		if c.backfill != "" {
+			if _, err := q.client.Exec(fmt.Sprintf(c.backfill, quoteIdent(q.tableName))); err != nil {
+				return fmt.Errorf("failed to backfill column %s: %w", c.name, err)
+			}
+		}
+	}
+
+	_, err := q.client.Exec(fmt.Sprintf(`
+	CREATE INDEX IF NOT EXISTS %[2]s ON %[1]s (seq) WHERE status = 'pending';
+	CREATE INDEX IF NOT EXISTS %[4]s ON %[1]s (expires_at) WHERE expires_at IS NOT NULL;
+	CREATE UNIQUE INDEX IF NOT EXISTS %[5]s ON %[1]s (message_id) WHERE message_id IS NOT NULL;
+	CREATE INDEX IF NOT EXISTS %[6]s ON %[1]s (correlation_id) WHERE correlation_id IS NOT NULL;
+	CREATE TABLE IF NOT EXISTS %[3]s (
+		queue TEXT PRIMARY KEY,
+		seq INTEGER NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS %[7]s (
+		queue TEXT PRIMARY KEY,
+		epoch INTEGER NOT NULL
+	);
+	`, quoteIdent(q.tableName), quoteIdent(q.tableName+"_pending_idx"), quoteIdent(sequencesTable), quoteIdent(q.tableName+"_expires_idx"),
+		quoteIdent(q.tableName+"_message_id_idx"), quoteIdent(q.tableName+"_correlation_idx"), quoteIdent(epochsTable)))
+
+	return err
+}
+
+// nextSeq increments the queue's counter within tx and returns the new value
+// The counter starts after the highest seq in the table, so it survives a lost counter row
+func (q *Queue) nextSeq(tx *sql.Tx) (int64, error) {
+	return q.advanceSeq(tx, 1)
+}
+
+// advanceSeq adds n to the queue's counter within tx and returns the new value
+func (q *Queue) advanceSeq(tx *sql.Tx, n int64) (seq int64, err error) {
+	err = tx.QueryRow(
+		fmt.Sprintf("UPDATE %s SET seq = seq + ? WHERE queue = ? RETURNING seq", quoteIdent(sequencesTable)),
+		n, q.tableName,
+	).Scan(&seq)
+	if !errors.Is(err, sql.ErrNoRows) {
+		return seq, err
+	}
+
+	// Only a missing counter row needs the table scanned for its highest seq
+	err = tx.QueryRow(fmt.Sprintf(
+		"INSERT INTO %s (queue, seq) SELECT ?, COALESCE(MAX(seq), 0) + ? FROM %s RETURNING seq",
+		quoteIdent(sequencesTable), quoteIdent(q.tableName),
+	), q.tableName, n).Scan(&seq)
+
+	return seq, err
+}
@@ -0,0 +1,85 @@
+package sqliteq
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestSeqOrdering(t *testing.T) {
+	dbPath := "test_seq.db"
+	defer os.Remove(dbPath)
+
+	manager := New(dbPath)
+	defer manager.Close()
+
+	t.Run("CoarseTimestamps", func(t *testing.T) {
+		// Every item gets the same timestamp, so only seq keeps them in order
+		q, err := manager.NewQueue("seq_coarse", WithTimePrecision(24*time.Hour))
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+
+		for i := 0; i < 20; i++ {
+			q.Enqueue(i)
+		}
+
+		messages, err := q.Page(0, 20)
+		if err != nil {
+			t.Fatalf("Failed to page: %v", err)
+		}
+
+		for i, m := range messages {
+			if string(m.Data) != fmt.Sprint(i) {
+				t.Fatalf("Expected item %d at position %d, got %s", i, i, m.Data)
+			}
+
+			if !m.CreatedAt.Equal(messages[0].CreatedAt) {
+				t.Errorf("Expected truncated timestamps to match, got %v and %v", m.CreatedAt, messages[0].CreatedAt)
+			}
+
+			if i > 0 && m.Seq <= messages[i-1].Seq {
+				t.Errorf("Expected increasing seq, got %d after %d", m.Seq, messages[i-1].Seq)
+			}
+		}
+	})
+
+	t.Run("BackfillsExistingTable", func(t *testing.T) {
+		// A table created before seq existed, with timestamps that ran backwards
+		_, err := manager.(*Manager).client.Exec(`
+		CREATE TABLE seq_legacy (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			data BLOB NOT NULL,
+			status TEXT NOT NULL,
+			ack_id TEXT UNIQUE,
+			ack BOOLEAN DEFAULT 0,
+			created_at TIMESTAMP,
+			updated_at TIMESTAMP
+		);
+		INSERT INTO seq_legacy (data, status, created_at) VALUES ('first', 'pending', '2030-01-01 00:00:00+00:00');
+		INSERT INTO seq_legacy (data, status, created_at) VALUES ('second', 'pending', '2020-01-01 00:00:00+00:00');
+		`)
+		if err != nil {
+			t.Fatalf("Failed to create legacy table: %v", err)
+		}
+
+		q, err := manager.NewQueue("seq_legacy")
+		if err != nil {
+			t.Fatalf("Failed to open legacy queue: %v", err)
+		}
+
+		q.Enqueue("third")
+
+		for _, want := range []string{"first", "second", "third"} {
+			data, ok := q.Dequeue()
+			if !ok {
+				t.Fatalf("Expected %s, got nothing", want)
+			}
+
+			if string(data.([]byte)) != want {
+				t.Errorf("Expected %s, got %s", want, data)
+			}
+		}
+	})
+}
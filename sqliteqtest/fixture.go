@@ -0,0 +1,63 @@
+package sqliteqtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/goptics/sqliteq"
+)
+
+// Fixture describes an item to seed a queue with. Zero fields take the defaults
+// of a freshly enqueued item: pending status and the current time.
+type Fixture struct {
+	Payload   string         `json:"payload"`
+	Priority  int            `json:"priority,omitempty"`
+	Status    sqliteq.Status `json:"status,omitempty"`
+	AckID     string         `json:"ack_id,omitempty"`
+	CreatedAt time.Time      `json:"created_at,omitempty"`
+	UpdatedAt time.Time      `json:"updated_at,omitempty"`
+	NotBefore time.Time      `json:"not_before,omitempty"`
+}
+
+// Importer is a queue that can be seeded, satisfied by both *sqliteq.Queue and
+// *sqliteq.PriorityQueue
+type Importer interface {
+	Import(messages []sqliteq.Message) error
+}
+
+// Seed stores the fixtures in q in order, establishing a precise queue state
+// including items already in processing or completed
+func Seed(q Importer, fixtures []Fixture) error {
+	messages := make([]sqliteq.Message, len(fixtures))
+	for i, f := range fixtures {
+		messages[i] = sqliteq.Message{
+			Data:      []byte(f.Payload),
+			Status:    f.Status,
+			Priority:  f.Priority,
+			AckID:     f.AckID,
+			CreatedAt: f.CreatedAt,
+			UpdatedAt: f.UpdatedAt,
+			NotBefore: f.NotBefore,
+		}
+	}
+
+	return q.Import(messages)
+}
+
+// LoadFixtures reads fixtures from a JSON file holding an array of objects with
+// the fields of Fixture, timestamps in RFC 3339 format
+func LoadFixtures(path string) ([]Fixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var fixtures []Fixture
+	if err := json.Unmarshal(data, &fixtures); err != nil {
+		return nil, fmt.Errorf("failed to parse fixtures %s: %w", path, err)
+	}
+
+	return fixtures, nil
+}
@@ -0,0 +1,78 @@
+package sqliteqtest
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/goptics/sqliteq"
+)
+
+func TestSeed(t *testing.T) {
+	dbPath := "test_seed.db"
+	defer os.Remove(dbPath)
+
+	queues := sqliteq.New(dbPath)
+	defer queues.Close()
+
+	pq, err := queues.NewPriorityQueue("seed", sqliteq.WithRemoveOnComplete(false))
+	if err != nil {
+		t.Fatalf("Failed to create priority queue: %v", err)
+	}
+
+	fixtures, err := LoadFixtures("testdata/fixtures.json")
+	if err != nil {
+		t.Fatalf("Failed to load fixtures: %v", err)
+	}
+
+	// Pending items are listed in reverse priority order to check they are honored
+	fixtures[2], fixtures[3] = fixtures[3], fixtures[2]
+
+	if err := Seed(pq, fixtures); err != nil {
+		t.Fatalf("Failed to seed queue: %v", err)
+	}
+
+	depth, err := pq.LenDetailed()
+	if err != nil {
+		t.Fatalf("LenDetailed failed: %v", err)
+	}
+
+	if want := (sqliteq.Depth{Pending: 2, Processing: 1, Completed: 1}); depth != want {
+		t.Errorf("Expected %+v, got %+v", want, depth)
+	}
+
+	messages, err := pq.Page(0, 1)
+	if err != nil || len(messages) != 1 {
+		t.Fatalf("Failed to page: %v", err)
+	}
+
+	if want := time.Date(2024, 1, 1, 0, 2, 0, 0, time.UTC); !messages[0].CreatedAt.Equal(want) {
+		t.Errorf("Expected created at %v, got %v", want, messages[0].CreatedAt)
+	}
+
+	if !pq.Acknowledge("ack-1") {
+		t.Error("Expected the seeded ack ID to be acknowledged")
+	}
+
+	AssertNextPayload(t, pq, "urgent")
+	AssertNextPayload(t, pq, "routine")
+}
+
+func TestSeedRejectsUnknownStatus(t *testing.T) {
+	dbPath := "test_seed_status.db"
+	defer os.Remove(dbPath)
+
+	queues := sqliteq.New(dbPath)
+	defer queues.Close()
+
+	q, err := queues.NewQueue("seed_status")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	if err := Seed(q, []Fixture{{Payload: "a"}, {Payload: "b", Status: "lost"}}); err == nil {
+		t.Error("Expected an error for an unknown status")
+	}
+
+	AssertDepth(t, q, 0)
+}
@@ -0,0 +1,73 @@
+// Package sqliteqtest provides helpers for asserting on queue state in tests
+// of applications built on sqliteq.
+package sqliteqtest
+
+import (
+	"bytes"
+	"testing"
+)
+
+// Queue is the part of a queue the helpers use, satisfied by both
+// *sqliteq.Queue and *sqliteq.PriorityQueue
+type Queue interface {
+	Len() int
+	Dequeue() (any, bool)
+}
+
+// AssertDepth fails the test unless q has exactly want pending items
+func AssertDepth(t testing.TB, q Queue, want int) {
+	t.Helper()
+
+	if got := q.Len(); got != want {
+		t.Errorf("Expected queue depth %d, got %d", want, got)
+	}
+}
+
+// AssertNextPayload dequeues the next item and fails the test unless its payload
+// equals want, given as a string or []byte
+func AssertNextPayload(t testing.TB, q Queue, want any) {
+	t.Helper()
+
+	var wantBytes []byte
+	switch w := want.(type) {
+	case []byte:
+		wantBytes = w
+	case string:
+		wantBytes = []byte(w)
+	default:
+		t.Fatalf("Unsupported payload type %T, expected string or []byte", want)
+	}
+
+	item, ok := q.Dequeue()
+	if !ok {
+		t.Fatalf("Expected next payload %q, got an empty queue", wantBytes)
+	}
+
+	if got := payload(item); !bytes.Equal(got, wantBytes) {
+		t.Errorf("Expected next payload %q, got %q", wantBytes, got)
+	}
+}
+
+// DrainAll dequeues every pending item and returns their payloads in dequeue order
+func DrainAll(t testing.TB, q Queue) [][]byte {
+	t.Helper()
+
+	var payloads [][]byte
+	for {
+		item, ok := q.Dequeue()
+		if !ok {
+			return payloads
+		}
+
+		payloads = append(payloads, payload(item))
+	}
+}
+
+// payload returns the bytes of a dequeued item
+func payload(item any) []byte {
+	if b, ok := item.([]byte); ok {
+		return b
+	}
+
+	return nil
+}
@@ -0,0 +1,47 @@
+package sqliteqtest
+
+import (
+	"os"
+	"testing"
+
+	"github.com/goptics/sqliteq"
+)
+
+func TestHelpers(t *testing.T) {
+	dbPath := "test_sqliteqtest.db"
+	defer os.Remove(dbPath)
+
+	queues := sqliteq.New(dbPath)
+	defer queues.Close()
+
+	q, err := queues.NewQueue("helpers")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	q.Enqueue("a")
+	q.Enqueue([]byte("b"))
+	q.Enqueue("c")
+
+	AssertDepth(t, q, 3)
+	AssertNextPayload(t, q, "a")
+	AssertNextPayload(t, q, []byte("b"))
+
+	payloads := DrainAll(t, q)
+	if len(payloads) != 1 || string(payloads[0]) != "c" {
+		t.Errorf("Expected [c], got %q", payloads)
+	}
+
+	AssertDepth(t, q, 0)
+
+	pq, err := queues.NewPriorityQueue("helpers_priority")
+	if err != nil {
+		t.Fatalf("Failed to create priority queue: %v", err)
+	}
+
+	pq.Enqueue("low", 5)
+	pq.Enqueue("high", 1)
+
+	AssertNextPayload(t, pq, "high")
+	AssertDepth(t, pq, 1)
+}
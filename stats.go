@@ -0,0 +1,241 @@
+package sqliteq
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// statsTable holds the periodic snapshots recorded by WithStatsHistory for all queues
+const statsTable = "sqliteq_stats"
+
+// Stats is a snapshot of a queue's health
+type Stats struct {
+	Pending    int
+	Processing int
+	Completed  int
+	Failed     int
+	// RequeuedOnOpen is the number of unacknowledged items returned to pending when
+	// the queue was created, left over by a previous run that didn't shut down cleanly
+	RequeuedOnOpen int64
+	// Labels are the queue's labels from the registry
+	Labels map[string]string
+	// Contention is the write contention seen by the queue since it was opened
+	Contention Contention
+	// Stalled is set while pending items have gone without a dequeue for longer than
+	// the WithIdleAlert limit
+	Stalled bool
+	// OldestPending is how long the oldest pending item has been waiting, zero when
+	// no item is pending
+	OldestPending time.Duration
+	// LastEnqueuedAt is when the newest item still stored was enqueued, zero when
+	// the queue is empty
+	LastEnqueuedAt time.Time
+	// LastDequeuedAt is when an item was last dequeued through this queue instance,
+	// zero before the first dequeue; like ETA, other processes are not observed
+	LastDequeuedAt time.Time
+}
+
+// StatsSample is a snapshot of a queue's depth at a point in time
+type StatsSample struct {
+	SampledAt  time.Time
+	Pending    int
+	Processing int
+	Completed  int
+}
+
+// initStatsTable creates the stats history table if it doesn't exist
+func initStatsTable(db *sql.DB) error {
+	_, err := db.Exec(fmt.Sprintf(`
+	CREATE TABLE IF NOT EXISTS %[1]s (
+		queue TEXT NOT NULL,
+		sampled_at TIMESTAMP NOT NULL,
+		pending INTEGER NOT NULL,
+		processing INTEGER NOT NULL,
+		completed INTEGER NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS %[2]s ON %[1]s (queue, sampled_at);
+	`, quoteIdent(statsTable), quoteIdent(statsTable+"_queue_idx")))
+	if err != nil {
+		return err
+	}
+
+	// Samples taken before enqueued existed have it NULL and are left out of Rates
+	exists, err := hasColumn(db, statsTable, "enqueued")
+	if err != nil || exists {
+		return err
+	}
+
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN enqueued INTEGER", quoteIdent(statsTable)))
+	return err
+}
+
+// Depth is the number of items in each status
+type Depth struct {
+	Pending    int
+	Processing int
+	Completed  int
+	Failed     int
+}
+
+// countByStatus counts the items of every status of the queue
+func (q *Queue) countByStatus() (Depth, error) {
+	return countByStatus(q.client, q.tableName)
+}
+
+// countByStatus counts the items of every status of a queue table in a single
+// statement, which reads one snapshot. Each count searches a covering status index
+// instead of scanning the table.
+func countByStatus(db querier, table string) (Depth, error) {
+	var d Depth
+	err := db.QueryRow(fmt.Sprintf(`
+	SELECT
+		(SELECT COUNT(*) FROM %[1]s WHERE status = 'pending'),
+		(SELECT COUNT(*) FROM %[1]s WHERE status = 'processing'),
+		(SELECT COUNT(*) FROM %[1]s WHERE status = 'completed'),
+		(SELECT COUNT(*) FROM %[1]s WHERE status = 'failed')
+	`, quoteIdent(table))).Scan(&d.Pending, &d.Processing, &d.Completed, &d.Failed)
+
+	return d, err
+}
+
+// readStats reads the depth of the queue with the enqueue times of its oldest
+// pending and newest items in a single statement, so they match one snapshot
+func (q *Queue) readStats() (d Depth, oldest, newest sql.NullTime, err error) {
+	err = q.client.QueryRow(fmt.Sprintf(`
+	SELECT
+		(SELECT COUNT(*) FROM %[1]s WHERE status = 'pending'),
+		(SELECT COUNT(*) FROM %[1]s WHERE status = 'processing'),
+		(SELECT COUNT(*) FROM %[1]s WHERE status = 'completed'),
+		(SELECT COUNT(*) FROM %[1]s WHERE status = 'failed'),
+		oldest.created_at,
+		newest.created_at
+	FROM (SELECT 1)
+	LEFT JOIN (SELECT created_at FROM %[1]s WHERE status = 'pending' ORDER BY created_at LIMIT 1) oldest ON 1
+	LEFT JOIN (SELECT created_at FROM %[1]s ORDER BY id DESC LIMIT 1) newest ON 1
+	`, quoteIdent(q.tableName))).Scan(&d.Pending, &d.Processing, &d.Completed, &d.Failed, &oldest, &newest)
+
+	return d, oldest, newest, err
+}
+
+// Stats returns a snapshot of the queue's health: the items in each status, how
+// long the oldest pending item has waited and when items were last enqueued and
+// dequeued
+func (q *Queue) Stats() (stats Stats, err error) {
+	defer func() { err = mapError(err) }()
+
+	if q.closed.Load() {
+		return Stats{}, ErrClosed
+	}
+
+	stats, gen, ok := q.cachedStats()
+	if ok {
+		stats.Contention = q.Contention()
+		stats.Stalled = q.stalled()
+		stats.LastDequeuedAt = q.lastDequeuedAt()
+		return stats, nil
+	}
+
+	depth, oldest, newest, err := q.readStats()
+	if err != nil {
+		return Stats{}, err
+	}
+
+	labels, err := q.manager.Labels(q.tableName)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	stats = Stats{
+		Pending:        depth.Pending,
+		Processing:     depth.Processing,
+		Completed:      depth.Completed,
+		Failed:         depth.Failed,
+		RequeuedOnOpen: q.requeuedOnOpen,
+		Labels:         labels,
+		Contention:     q.Contention(),
+		Stalled:        q.stalled(),
+		LastEnqueuedAt: newest.Time,
+		LastDequeuedAt: q.lastDequeuedAt(),
+	}
+	if oldest.Valid {
+		stats.OldestPending = q.now().Sub(oldest.Time)
+	}
+	q.cacheStats(gen, stats)
+
+	return stats, nil
+}
+
+// sampleStats records the current depth and enqueue counter, and prunes samples
+// past the retention
+func (q *Queue) sampleStats() error {
+	depth, err := q.countByStatus()
+	if err != nil {
+		return err
+	}
+
+	now := q.now()
+
+	// The counter assigning seqs counts every enqueue, by any process
+	_, err = q.client.Exec(
+		fmt.Sprintf("INSERT INTO %s (queue, sampled_at, pending, processing, completed, enqueued) VALUES (?, ?, ?, ?, ?, COALESCE((SELECT seq FROM %s WHERE queue = ?), 0))",
+			quoteIdent(statsTable), quoteIdent(sequencesTable)),
+		q.tableName, now, depth.Pending, depth.Processing, depth.Completed, q.tableName,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = q.pruneStats()
+	return err
+}
+
+// pruneStats deletes the samples past the retention
+// Returns the number of deleted samples
+func (q *Queue) pruneStats() (int64, error) {
+	if q.statsRetention <= 0 {
+		return 0, nil
+	}
+
+	cutoff := q.now().Add(-q.statsRetention)
+
+	return q.inBatches(func(limit int) (sql.Result, error) {
+		return q.client.Exec(
+			fmt.Sprintf("DELETE FROM %[1]s WHERE rowid IN (SELECT rowid FROM %[1]s WHERE queue = ? AND sampled_at < ? LIMIT ?)", quoteIdent(statsTable)),
+			q.tableName, cutoff, limit,
+		)
+	})
+}
+
+// StatsHistory returns the samples recorded by WithStatsHistory since the given time, oldest first
+func (q *Queue) StatsHistory(since time.Time) (samples []StatsSample, err error) {
+	defer func() { err = mapError(err) }()
+
+	if q.closed.Load() {
+		return nil, ErrClosed
+	}
+
+	if q.statsInterval <= 0 {
+		return nil, nil
+	}
+
+	rows, err := q.client.Query(
+		fmt.Sprintf("SELECT sampled_at, pending, processing, completed FROM %s WHERE queue = ? AND sampled_at >= ? ORDER BY sampled_at ASC", quoteIdent(statsTable)),
+		q.tableName, since.UTC(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var s StatsSample
+		if err := rows.Scan(&s.SampledAt, &s.Pending, &s.Processing, &s.Completed); err != nil {
+			return nil, err
+		}
+
+		samples = append(samples, s)
+	}
+
+	return samples, rows.Err()
+}
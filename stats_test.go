@@ -0,0 +1,245 @@
+package sqliteq
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestStatsHistory(t *testing.T) {
+	dbPath := "test_stats_history.db"
+	defer os.Remove(dbPath)
+
+	queues := New(dbPath)
+	q, err := queues.NewQueue("test_queue", WithStatsHistory(10*time.Millisecond, time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	defer queues.Close()
+
+	start := time.Now()
+
+	q.Enqueue([]byte("item 1"))
+	q.Enqueue([]byte("item 2"))
+	q.DequeueWithAckId()
+
+	t.Run("Sampling", func(t *testing.T) {
+		deadline := time.Now().Add(2 * time.Second)
+		var samples []StatsSample
+
+		for time.Now().Before(deadline) {
+			samples, err = q.StatsHistory(start)
+			if err != nil {
+				t.Fatalf("StatsHistory failed: %v", err)
+			}
+
+			if len(samples) > 0 {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		if len(samples) == 0 {
+			t.Fatal("Expected at least one sample")
+		}
+
+		last := samples[len(samples)-1]
+		if last.Pending != 1 || last.Processing != 1 {
+			t.Errorf("Expected 1 pending and 1 processing, got %+v", last)
+		}
+	})
+
+	t.Run("Retention", func(t *testing.T) {
+		old := time.Now().UTC().Add(-2 * time.Hour)
+		_, err := q.client.Exec(fmt.Sprintf("INSERT INTO %s (queue, sampled_at, pending, processing, completed) VALUES (?, ?, 0, 0, 0)", statsTable), q.tableName, old)
+		if err != nil {
+			t.Fatalf("Failed to insert old sample: %v", err)
+		}
+
+		q.sampleStats()
+
+		samples, err := q.StatsHistory(old.Add(-time.Minute))
+		if err != nil {
+			t.Fatalf("StatsHistory failed: %v", err)
+		}
+
+		for _, s := range samples {
+			if s.SampledAt.Before(start.Add(-time.Second)) {
+				t.Errorf("Expected sample from %v to be pruned", s.SampledAt)
+			}
+		}
+	})
+
+	t.Run("StopsOnClose", func(t *testing.T) {
+		q.Close()
+
+		var before, after int
+		q.client.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s", statsTable)).Scan(&before)
+		time.Sleep(50 * time.Millisecond)
+		q.client.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s", statsTable)).Scan(&after)
+
+		if before != after {
+			t.Errorf("Expected no samples after Close, got %d new", after-before)
+		}
+	})
+}
+
+func TestLenDetailed(t *testing.T) {
+	dbPath := "test_len_detailed.db"
+	defer os.Remove(dbPath)
+
+	queues := New(dbPath)
+	defer queues.Close()
+
+	q, err := queues.NewQueue("len_detailed", WithRemoveOnComplete(false))
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	for i := 0; i < 4; i++ {
+		q.Enqueue(i)
+	}
+
+	_, _, ackID := q.DequeueWithAckId()
+	q.Acknowledge(ackID)
+	q.DequeueWithAckId()
+
+	depth, err := q.LenDetailed()
+	if err != nil {
+		t.Fatalf("LenDetailed failed: %v", err)
+	}
+
+	if want := (Depth{Pending: 2, Processing: 1, Completed: 1}); depth != want {
+		t.Errorf("Expected %+v, got %+v", want, depth)
+	}
+
+	q.Close()
+	if _, err := q.LenDetailed(); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed, got %v", err)
+	}
+}
+
+func TestStatsCache(t *testing.T) {
+	dbPath := "test_stats_cache.db"
+	defer os.Remove(dbPath)
+
+	queues := New(dbPath)
+	defer queues.Close()
+
+	q, err := queues.NewQueue("cached", WithStatsCacheTTL(100*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	q.Enqueue("a")
+	if q.Len() != 1 {
+		t.Fatalf("Expected length 1, got %d", q.Len())
+	}
+
+	t.Run("LocalWritesInvalidate", func(t *testing.T) {
+		q.Enqueue("b")
+		if q.Len() != 2 {
+			t.Errorf("Expected length 2 after a local enqueue, got %d", q.Len())
+		}
+
+		q.Dequeue()
+		if q.Len() != 1 {
+			t.Errorf("Expected length 1 after a local dequeue, got %d", q.Len())
+		}
+	})
+
+	t.Run("ExternalWritesAfterTTL", func(t *testing.T) {
+		other, err := sql.Open("sqlite3", dbPath)
+		if err != nil {
+			t.Fatalf("Failed to open second connection: %v", err)
+		}
+		defer other.Close()
+
+		q.Len()
+		if _, err := other.Exec("UPDATE cached SET status = 'completed'"); err != nil {
+			t.Fatalf("Failed to update: %v", err)
+		}
+
+		if q.Len() != 1 {
+			t.Errorf("Expected the cached length 1, got %d", q.Len())
+		}
+
+		time.Sleep(150 * time.Millisecond)
+		if q.Len() != 0 {
+			t.Errorf("Expected length 0 once the TTL elapsed, got %d", q.Len())
+		}
+	})
+
+	t.Run("ReadRacingWrite", func(t *testing.T) {
+		q.Enqueue("c")
+
+		// A read started before a local write finishes after its invalidation
+		_, gen, _ := q.cachedStats()
+		q.invalidateStats()
+		q.cacheStats(gen, Stats{Pending: 42})
+
+		if q.Len() != 1 {
+			t.Errorf("Expected the racing read not to be cached, got length %d", q.Len())
+		}
+	})
+
+	t.Run("Labels", func(t *testing.T) {
+		labeled, err := queues.NewQueue("labeled", WithStatsCacheTTL(time.Minute), WithLabels(map[string]string{"team": "core"}))
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+
+		stats, _ := labeled.Stats()
+		stats.Labels["team"] = "changed"
+
+		if stats, _ := labeled.Stats(); stats.Labels["team"] != "core" {
+			t.Errorf("Expected the cached labels to be unaffected, got %v", stats.Labels)
+		}
+	})
+}
+
+func TestStatsTimes(t *testing.T) {
+	dbPath := "test_stats_times.db"
+	defer os.Remove(dbPath)
+
+	queues := New(dbPath)
+	defer queues.Close()
+
+	q, err := queues.NewQueue("timed")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	stats, err := q.Stats()
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	if stats.OldestPending != 0 || !stats.LastEnqueuedAt.IsZero() || !stats.LastDequeuedAt.IsZero() {
+		t.Errorf("Expected zero times for an empty queue, got %+v", stats)
+	}
+
+	before := time.Now()
+	q.Enqueue("first")
+	time.Sleep(20 * time.Millisecond)
+	q.Enqueue("second")
+
+	stats, _ = q.Stats()
+	if stats.OldestPending < 20*time.Millisecond {
+		t.Errorf("Expected the oldest item to have waited at least 20ms, got %v", stats.OldestPending)
+	}
+	if stats.LastEnqueuedAt.Before(before.Add(20 * time.Millisecond)) {
+		t.Errorf("Expected the last enqueue at the second item, got %v", stats.LastEnqueuedAt)
+	}
+
+	q.Dequeue()
+	stats, _ = q.Stats()
+	if stats.LastDequeuedAt.Before(before) {
+		t.Errorf("Expected the dequeue to be recorded, got %v", stats.LastDequeuedAt)
+	}
+	if stats.OldestPending >= time.Since(before) || stats.Pending != 1 {
+		t.Errorf("Expected the second item to be the oldest pending, got %v with %d pending", stats.OldestPending, stats.Pending)
+	}
+}
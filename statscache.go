@@ -0,0 +1,83 @@
+package sqliteq
+
+import (
+	"sync"
+	"time"
+)
+
+// statsCache holds the last Stats result for WithStatsCacheTTL
+type statsCache struct {
+	mu    sync.Mutex
+	stats Stats
+	at    time.Time
+	valid bool
+	// gen changes on every invalidation, so reads racing with a write aren't cached
+	gen uint64
+}
+
+// cachedStats returns the cached stats if they are younger than the TTL
+// Returns false and the generation cacheStats has to match when there are none
+func (q *Queue) cachedStats() (Stats, uint64, bool) {
+	if q.statsCacheTTL <= 0 {
+		return Stats{}, 0, false
+	}
+
+	c := &q.statsCache
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.valid || time.Since(c.at) >= q.statsCacheTTL {
+		return Stats{}, c.gen, false
+	}
+
+	stats := c.stats
+	stats.Labels = copyLabels(stats.Labels)
+
+	return stats, c.gen, true
+}
+
+// cacheStats stores stats read after cachedStats returned gen, unless a local
+// write invalidated the cache meanwhile
+func (q *Queue) cacheStats(gen uint64, stats Stats) {
+	if q.statsCacheTTL <= 0 {
+		return
+	}
+
+	c := &q.statsCache
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.gen != gen {
+		return
+	}
+
+	stats.Labels = copyLabels(stats.Labels)
+	c.stats, c.at, c.valid = stats, time.Now(), true
+}
+
+// invalidateStats drops the cached stats after a write through this queue
+func (q *Queue) invalidateStats() {
+	if q.statsCacheTTL <= 0 {
+		return
+	}
+
+	c := &q.statsCache
+	c.mu.Lock()
+	c.valid = false
+	c.gen++
+	c.mu.Unlock()
+}
+
+// copyLabels returns a copy of labels, so callers can't change the cached map
+func copyLabels(labels map[string]string) map[string]string {
+	if labels == nil {
+		return nil
+	}
+
+	c := make(map[string]string, len(labels))
+	for key, value := range labels {
+		c[key] = value
+	}
+
+	return c
+}
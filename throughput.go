@@ -0,0 +1,213 @@
+package sqliteq
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// rateWindow is the time constant of the moving averages: events older than a few
+// windows barely influence the estimate
+const rateWindow = time.Minute
+
+// rateEstimator tracks an exponential moving average of events per second
+type rateEstimator struct {
+	mu    sync.Mutex
+	rate  float64
+	last  time.Time
+	total int64
+}
+
+// observe records n events happening at now
+func (r *rateEstimator) observe(n int, now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.total += int64(n)
+
+	if r.last.IsZero() {
+		r.last = now
+		return
+	}
+
+	elapsed := now.Sub(r.last).Seconds()
+	if elapsed <= 0 {
+		// Events within the same instant are folded into the next observation
+		elapsed = 1e-9
+	}
+
+	alpha := 1 - math.Exp(-elapsed/rateWindow.Seconds())
+	r.rate = alpha*(float64(n)/elapsed) + (1-alpha)*r.rate
+	r.last = now
+}
+
+// count returns the number of events observed
+func (r *rateEstimator) count() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.total
+}
+
+// lastAt returns when events were last observed, zero before the first one
+func (r *rateEstimator) lastAt() time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.last
+}
+
+// rateAt returns the estimated events per second at now, decaying while no events happen
+func (r *rateEstimator) rateAt(now time.Time) float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.last.IsZero() {
+		return 0
+	}
+
+	idle := now.Sub(r.last).Seconds()
+	if idle <= 0 {
+		return r.rate
+	}
+
+	return r.rate * math.Exp(-idle/rateWindow.Seconds())
+}
+
+// ETA estimates how long it takes to drain the pending items at the recent dequeue rate
+// The rate is an exponential moving average over roughly the last minute of dequeues
+// made through this queue instance; dequeues by other processes are not observed.
+// Returns false when no estimate is possible because nothing was dequeued recently.
+func (q *Queue) ETA() (time.Duration, bool) {
+	depth := q.Len()
+	if depth == 0 {
+		return 0, !q.closed.Load()
+	}
+
+	rate := q.dequeueRate.rateAt(time.Now())
+	if rate < 1e-6 {
+		return 0, false
+	}
+
+	return time.Duration(float64(depth) / rate * float64(time.Second)), true
+}
+
+// lastDequeuedAt returns when an item was last dequeued through this queue
+func (q *Queue) lastDequeuedAt() time.Time {
+	return q.dequeueRate.lastAt()
+}
+
+// Metrics is a snapshot of the throughput of a queue instance
+type Metrics struct {
+	// EnqueueRate, DequeueRate and AckRate are in items per second, exponential
+	// moving averages over roughly the last minute that decay while idle
+	EnqueueRate float64
+	DequeueRate float64
+	AckRate     float64
+	// Enqueued, Dequeued and Acked count the items since the queue was opened
+	Enqueued int64
+	Dequeued int64
+	Acked    int64
+	// AckLatency is the distribution of the time acknowledged items spent in
+	// processing, from their claim (or a later Pin) to their ack
+	AckLatency LatencyHistogram
+}
+
+// LatencyHistogram is a cumulative histogram of durations
+type LatencyHistogram struct {
+	// Count is the number of observations and Sum their total
+	Count int64
+	Sum   time.Duration
+	// Buckets count the observations at or below each upper bound, in increasing
+	// order of bounds
+	Buckets []LatencyBucket
+}
+
+// LatencyBucket counts the observations of a LatencyHistogram up to UpperBound
+type LatencyBucket struct {
+	UpperBound time.Duration
+	Count      int64
+}
+
+// latencyBounds are the bucket upper bounds of the ack latency histogram, from
+// quick handlers to long-running jobs
+var latencyBounds = []time.Duration{
+	10 * time.Millisecond, 50 * time.Millisecond, 100 * time.Millisecond, 250 * time.Millisecond, 500 * time.Millisecond,
+	time.Second, 2500 * time.Millisecond, 5 * time.Second, 10 * time.Second, 30 * time.Second,
+	time.Minute, 5 * time.Minute, 15 * time.Minute,
+}
+
+// latencyHistogram accumulates a LatencyHistogram over latencyBounds
+type latencyHistogram struct {
+	mu sync.Mutex
+	// counts holds the observations of each bucket, not cumulated, with those
+	// past the last bound at the end
+	counts []int64
+	count  int64
+	sum    time.Duration
+}
+
+// observe records a duration
+func (h *latencyHistogram) observe(d time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.counts == nil {
+		h.counts = make([]int64, len(latencyBounds)+1)
+	}
+
+	i := sort.Search(len(latencyBounds), func(i int) bool { return d <= latencyBounds[i] })
+	h.counts[i]++
+	h.count++
+	h.sum += d
+}
+
+// snapshot returns the histogram with cumulative bucket counts
+func (h *latencyHistogram) snapshot() LatencyHistogram {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s := LatencyHistogram{Count: h.count, Sum: h.sum, Buckets: make([]LatencyBucket, len(latencyBounds))}
+
+	var cumulative int64
+	for i, bound := range latencyBounds {
+		if h.counts != nil {
+			cumulative += h.counts[i]
+		}
+		s.Buckets[i] = LatencyBucket{UpperBound: bound, Count: cumulative}
+	}
+
+	return s
+}
+
+// observeAcks records items acknowledged through the queue, claimed at the given
+// times; zero times are items that weren't in processing
+func (q *Queue) observeAcks(claimedAt ...time.Time) {
+	now := q.now()
+	for _, at := range claimedAt {
+		if !at.IsZero() {
+			q.ackLatency.observe(now.Sub(at))
+		}
+	}
+
+	q.ackRate.observe(len(claimedAt), time.Now())
+}
+
+// Metrics returns the recent enqueue, dequeue and ack rates of the queue with
+// their totals, so applications can alert on stalled consumers or producer spikes.
+// Like ETA, only operations made through this queue instance are observed;
+// enqueues in the caller's transaction with EnqueueTx are not.
+func (q *Queue) Metrics() Metrics {
+	now := time.Now()
+
+	return Metrics{
+		EnqueueRate: q.enqueueRate.rateAt(now),
+		DequeueRate: q.dequeueRate.rateAt(now),
+		AckRate:     q.ackRate.rateAt(now),
+		Enqueued:    q.enqueueRate.count(),
+		Dequeued:    q.dequeueRate.count(),
+		Acked:       q.ackRate.count(),
+		AckLatency:  q.ackLatency.snapshot(),
+	}
+}
@@ -0,0 +1,130 @@
+package sqliteq
+
+import (
+	"math"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestRateEstimator(t *testing.T) {
+	var r rateEstimator
+	start := time.Now()
+
+	if r.rateAt(start) != 0 {
+		t.Errorf("Expected no rate before any event, got %f", r.rateAt(start))
+	}
+
+	// A steady 10 events per second converges to a rate of 10
+	for i := 0; i <= 3000; i++ {
+		r.observe(1, start.Add(time.Duration(i)*100*time.Millisecond))
+	}
+
+	now := start.Add(300 * time.Second)
+	if rate := r.rateAt(now); math.Abs(rate-10) > 0.5 {
+		t.Errorf("Expected rate close to 10/s, got %f", rate)
+	}
+
+	// The rate decays while no events happen
+	if rate := r.rateAt(now.Add(5 * time.Minute)); rate > 0.1 {
+		t.Errorf("Expected rate to decay after 5 idle minutes, got %f", rate)
+	}
+}
+
+func TestETA(t *testing.T) {
+	dbPath := "test_eta.db"
+	defer os.Remove(dbPath)
+
+	queues := New(dbPath)
+	q, err := queues.NewQueue("test_queue")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	defer queues.Close()
+
+	if eta, ok := q.ETA(); !ok || eta != 0 {
+		t.Errorf("Expected ETA 0 for an empty queue, got %v (%v)", eta, ok)
+	}
+
+	for i := 0; i < 20; i++ {
+		q.Enqueue([]byte("item"))
+	}
+
+	if _, ok := q.ETA(); ok {
+		t.Error("Expected no ETA before anything was dequeued")
+	}
+
+	for i := 0; i < 10; i++ {
+		q.Dequeue()
+		time.Sleep(time.Millisecond)
+	}
+
+	eta, ok := q.ETA()
+	if !ok || eta <= 0 {
+		t.Errorf("Expected a positive ETA after dequeues, got %v (%v)", eta, ok)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	dbPath := "test_metrics_rates.db"
+	defer os.Remove(dbPath)
+
+	queues := New(dbPath)
+	defer queues.Close()
+
+	q, err := queues.NewQueue("rates")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	if m := q.Metrics(); m.Enqueued != 0 || m.EnqueueRate != 0 || m.AckLatency.Count != 0 {
+		t.Errorf("Expected empty metrics for a new queue, got %+v", m)
+	}
+
+	for i := 0; i < 10; i++ {
+		q.Enqueue("item")
+		time.Sleep(time.Millisecond)
+	}
+
+	_, ackIDs := q.DequeueNWithAckIds(4)
+	q.Acknowledge(ackIDs[0])
+	if err := q.AckMany(ackIDs[1:3]); err != nil {
+		t.Fatalf("AckMany failed: %v", err)
+	}
+	q.Dequeue()
+
+	m := q.Metrics()
+	if m.Enqueued != 10 || m.Dequeued != 5 || m.Acked != 3 {
+		t.Errorf("Expected 10 enqueued, 5 dequeued and 3 acked, got %+v", m)
+	}
+	if m.EnqueueRate <= 0 || m.DequeueRate <= 0 || m.AckRate <= 0 {
+		t.Errorf("Expected positive rates, got %+v", m)
+	}
+
+	latency := m.AckLatency
+	if latency.Count != 3 || latency.Sum < 0 {
+		t.Errorf("Expected the latency of 3 acks, got %+v", latency)
+	}
+	if last := latency.Buckets[len(latency.Buckets)-1]; last.Count != 3 {
+		t.Errorf("Expected all acks under the %v bound, got %d", last.UpperBound, last.Count)
+	}
+}
+
+func TestLatencyHistogram(t *testing.T) {
+	var h latencyHistogram
+	for _, d := range []time.Duration{time.Millisecond, 10 * time.Millisecond, 200 * time.Millisecond, time.Hour} {
+		h.observe(d)
+	}
+
+	s := h.snapshot()
+	if s.Count != 4 || s.Sum != time.Hour+211*time.Millisecond {
+		t.Errorf("Expected 4 observations summing to 1h0.211s, got %d and %v", s.Count, s.Sum)
+	}
+
+	want := map[time.Duration]int64{10 * time.Millisecond: 2, 100 * time.Millisecond: 2, 250 * time.Millisecond: 3, 15 * time.Minute: 3}
+	for _, b := range s.Buckets {
+		if n, ok := want[b.UpperBound]; ok && b.Count != n {
+			t.Errorf("Expected %d observations up to %v, got %d", n, b.UpperBound, b.Count)
+		}
+	}
+}
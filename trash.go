@@ -0,0 +1,308 @@
+package sqliteq
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// purgesTable logs the purges whose rows were moved to a trash table
+const purgesTable = "sqliteq_purges"
+
+// ErrNothingToUndo is returned by UndoLastPurge when no purge can be undone
+var ErrNothingToUndo = errors.New("no purge to undo")
+
+// initPurgesTable creates the purge log if it doesn't exist
+func initPurgesTable(tx *sql.Tx) error {
+	_, err := tx.Exec(fmt.Sprintf(`
+	CREATE TABLE IF NOT EXISTS %s (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		queue TEXT NOT NULL,
+		purged_at TIMESTAMP NOT NULL,
+		rows INTEGER NOT NULL
+	)`, quoteIdent(purgesTable)))
+
+	return err
+}
+
+// trashTable returns the table holding the purged rows of the queue
+func (q *Queue) trashTable() string {
+	return q.tableName + "_trash"
+}
+
+// tableColumns returns the quoted column names of a table
+func tableColumns(tx *sql.Tx, table string) ([]string, error) {
+	rows, err := tx.Query("SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		columns = append(columns, name)
+	}
+
+	return columns, rows.Err()
+}
+
+// trashRows moves every row of the queue into its trash table within tx and logs
+// the purge, so UndoLastPurge can restore it
+// Returns the number of moved rows
+func (q *Queue) trashRows(tx *sql.Tx) (int64, error) {
+	if err := initPurgesTable(tx); err != nil {
+		return 0, err
+	}
+
+	// The trash table mirrors the queue table plus the purge it belongs to
+	columns, err := mirrorTable(tx, q.tableName, q.trashTable(), "0 AS purge_id")
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := tx.Exec(
+		fmt.Sprintf("INSERT INTO %s (queue, purged_at, rows) VALUES (?, ?, 0)", quoteIdent(purgesTable)),
+		q.tableName, q.now(),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	purgeID, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	list := quoteColumns(columns)
+	result, err = tx.Exec(
+		fmt.Sprintf("INSERT INTO %s (%s, purge_id) SELECT %s, ? FROM %s", quoteIdent(q.trashTable()), list, list, quoteIdent(q.tableName)),
+		purgeID,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	moved, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(fmt.Sprintf("UPDATE %s SET rows = ? WHERE id = ?", quoteIdent(purgesTable)), moved, purgeID)
+
+	return moved, err
+}
+
+// mirrorTable creates the table mirror with the columns of table plus extra, unless
+// it exists, and adds the columns table gained since
+// Returns the columns of table
+func mirrorTable(tx *sql.Tx, table, mirror, extra string) ([]string, error) {
+	_, err := tx.Exec(fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s AS SELECT *, %s FROM %s WHERE 0",
+		quoteIdent(mirror), extra, quoteIdent(table),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	columns, err := tableColumns(tx, table)
+	if err != nil {
+		return nil, err
+	}
+
+	mirrorColumns, err := tableColumns(tx, mirror)
+	if err != nil {
+		return nil, err
+	}
+	existing := make(map[string]bool, len(mirrorColumns))
+	for _, c := range mirrorColumns {
+		existing[c] = true
+	}
+	for _, c := range columns {
+		if !existing[c] {
+			if _, err := tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", quoteIdent(mirror), quoteIdent(c))); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return columns, nil
+}
+
+// quoteColumns joins quoted column names into a select list
+func quoteColumns(columns []string) string {
+	quoted := make([]string, len(columns))
+	for i, c := range columns {
+		quoted[i] = quoteIdent(c)
+	}
+
+	return strings.Join(quoted, ", ")
+}
+
+// UndoLastPurge restores the rows of the most recent purge, of any queue, made
+// within the undo window set with WithPurgeUndo. Restored items regain their
+// original status and position. Each purge can only be undone once.
+// Returns the name of the restored queue and the number of restored items
+func (m *Manager) UndoLastPurge() (queue string, restored int64, err error) {
+	defer func() { err = mapError(err) }()
+
+	if m.closed.Load() {
+		return "", 0, ErrQueuesClosed
+	}
+
+	if m.purgeUndoWindow <= 0 {
+		return "", 0, ErrNothingToUndo
+	}
+
+	tx, err := m.client.Begin()
+	if err != nil {
+		return "", 0, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err = initPurgesTable(tx); err != nil {
+		return "", 0, err
+	}
+
+	var purgeID int64
+	err = tx.QueryRow(
+		fmt.Sprintf("SELECT id, queue FROM %s WHERE purged_at >= ? ORDER BY id DESC LIMIT 1", quoteIdent(purgesTable)),
+		time.Now().UTC().Add(-m.purgeUndoWindow),
+	).Scan(&purgeID, &queue)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = ErrNothingToUndo
+	}
+	if err != nil {
+		return "", 0, err
+	}
+
+	trash := queue + "_trash"
+	columns, err := tableColumns(tx, queue)
+	if err != nil {
+		return "", 0, err
+	}
+
+	// Columns the queue gained since the purge, such as priority after
+	// ConvertToPriority, take their defaults
+	trashColumns, err := tableColumns(tx, trash)
+	if err != nil {
+		return "", 0, err
+	}
+	trashed := make(map[string]bool, len(trashColumns))
+	for _, c := range trashColumns {
+		trashed[c] = true
+	}
+	kept := columns[:0]
+	for _, c := range columns {
+		if trashed[c] {
+			kept = append(kept, c)
+		}
+	}
+
+	list := quoteColumns(kept)
+	result, err := tx.Exec(
+		fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s WHERE purge_id = ?", quoteIdent(queue), list, list, quoteIdent(trash)),
+		purgeID,
+	)
+	if err != nil {
+		return "", 0, err
+	}
+
+	if restored, err = result.RowsAffected(); err != nil {
+		return "", 0, err
+	}
+
+	if _, err = tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE purge_id = ?", quoteIdent(trash)), purgeID); err != nil {
+		return "", 0, err
+	}
+
+	if _, err = tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE id = ?", quoteIdent(purgesTable)), purgeID); err != nil {
+		return "", 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return "", 0, err
+	}
+
+	m.mu.Lock()
+	for _, q := range m.open {
+		if q.tableName == queue {
+			q.invalidateStats()
+			q.invalidateFront()
+		}
+	}
+	m.mu.Unlock()
+
+	return queue, restored, nil
+}
+
+// pruneTrash deletes the trashed rows of purges older than the undo window
+// Returns the number of deleted rows
+func (m *Manager) pruneTrash() (pruned int64, err error) {
+	if m.purgeUndoWindow <= 0 {
+		return 0, nil
+	}
+
+	tx, err := m.client.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err = initPurgesTable(tx); err != nil {
+		return 0, err
+	}
+
+	cutoff := time.Now().UTC().Add(-m.purgeUndoWindow)
+
+	rows, err := tx.Query(
+		fmt.Sprintf("SELECT id, queue FROM %s WHERE purged_at < ?", quoteIdent(purgesTable)), cutoff,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	expired := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var queue string
+		if err = rows.Scan(&id, &queue); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		expired[id] = queue
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for id, queue := range expired {
+		result, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE purge_id = ?", quoteIdent(queue+"_trash")), id)
+		if err != nil {
+			return 0, err
+		}
+
+		deleted, _ := result.RowsAffected()
+		pruned += deleted
+
+		if _, err = tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE id = ?", quoteIdent(purgesTable)), id); err != nil {
+			return 0, err
+		}
+	}
+
+	return pruned, tx.Commit()
+}
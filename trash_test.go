@@ -0,0 +1,91 @@
+package sqliteq
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestUndoLastPurge(t *testing.T) {
+	dbPath := "test_undo_purge.db"
+	defer os.Remove(dbPath)
+
+	queues, err := NewManager(dbPath, WithPurgeUndo(time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	defer queues.Close()
+
+	q, err := queues.NewPriorityQueue("undo")
+	if err != nil {
+		t.Fatalf("Failed to create priority queue: %v", err)
+	}
+
+	q.Enqueue("first", 1)
+	q.Enqueue("second", 2)
+	q.Enqueue("in-flight", 0)
+	q.DequeueWithAckId()
+
+	q.Purge()
+	if q.Len() != 0 {
+		t.Fatalf("Expected an empty queue after purging, got %d", q.Len())
+	}
+
+	q.Enqueue("after", 1)
+
+	queue, restored, err := queues.UndoLastPurge()
+	if err != nil {
+		t.Fatalf("UndoLastPurge failed: %v", err)
+	}
+
+	if queue != "undo" || restored != 3 {
+		t.Errorf("Expected 3 items restored to undo, got %d to %s", restored, queue)
+	}
+
+	depth, _ := q.LenDetailed()
+	if depth.Pending != 3 || depth.Processing != 1 {
+		t.Errorf("Expected 3 pending and 1 processing item, got %+v", depth)
+	}
+
+	for _, want := range []string{"first", "after", "second"} {
+		item, ok := q.Dequeue()
+		if !ok || string(item.([]byte)) != want {
+			t.Errorf("Expected %s, got %v", want, item)
+		}
+	}
+
+	if _, _, err := queues.UndoLastPurge(); !errors.Is(err, ErrNothingToUndo) {
+		t.Errorf("Expected ErrNothingToUndo after undoing, got %v", err)
+	}
+}
+
+func TestPurgeUndoWindow(t *testing.T) {
+	dbPath := "test_purge_window.db"
+	defer os.Remove(dbPath)
+
+	queues, err := NewManager(dbPath, WithPurgeUndo(10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	defer queues.Close()
+
+	q, err := queues.NewQueue("window")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	q.Enqueue("item")
+	q.Purge()
+
+	time.Sleep(20 * time.Millisecond)
+
+	if _, _, err := queues.UndoLastPurge(); !errors.Is(err, ErrNothingToUndo) {
+		t.Errorf("Expected ErrNothingToUndo past the window, got %v", err)
+	}
+
+	pruned, err := queues.pruneTrash()
+	if err != nil || pruned != 1 {
+		t.Errorf("Expected 1 trashed row to be pruned, got %d (%v)", pruned, err)
+	}
+}
@@ -0,0 +1,108 @@
+package sqliteq
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"runtime"
+	"strconv"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// TempStore decides where SQLite keeps the temporary tables and indexes it builds
+// for sorts and large scans, such as those of Values and Page
+type TempStore int
+
+const (
+	// TempStoreFile keeps temporary data in files, SQLite's own default, bounding
+	// memory use at the cost of disk I/O on large scans
+	TempStoreFile TempStore = iota + 1
+	// TempStoreMemory keeps temporary data in memory, sparing large scans from
+	// writing and reading back temporary files
+	TempStoreMemory
+)
+
+// String returns the name of the setting
+func (s TempStore) String() string {
+	switch s {
+	case TempStoreFile:
+		return "file"
+	case TempStoreMemory:
+		return "memory"
+	}
+
+	return "unknown"
+}
+
+// defaultMmapSize is the memory-mapped I/O limit on platforms where it is safe: a
+// 64-bit address space, and not Windows, where mapped files can't be truncated
+const defaultMmapSize = 256 << 20
+
+// platformDefaults returns the mmap size and temp store used unless WithMmapSize
+// and WithTempStore say otherwise. 32-bit platforms keep SQLite's defaults, as
+// they have little address space to map and often little memory to spare.
+func platformDefaults() (mmapSize int64, tempStore TempStore) {
+	if strconv.IntSize < 64 {
+		return 0, TempStoreFile
+	}
+
+	if runtime.GOOS == "windows" {
+		return 0, TempStoreMemory
+	}
+
+	return defaultMmapSize, TempStoreMemory
+}
+
+// WithMmapSize sets how many bytes of the database file SQLite reads through
+// memory-mapped I/O instead of read calls, which speeds up large scans of databases
+// bigger than the page cache. Zero disables it. Defaults to 256 MiB on 64-bit
+// platforms other than Windows, where it is disabled.
+func WithMmapSize(n int64) ManagerOption {
+	return func(m *Manager) {
+		if n >= 0 {
+			m.mmapSize = n
+		}
+	}
+}
+
+// WithTempStore sets where SQLite keeps the temporary data of sorts and large scans.
+// Defaults to TempStoreMemory on 64-bit platforms and TempStoreFile on others.
+func WithTempStore(store TempStore) ManagerOption {
+	return func(m *Manager) {
+		if store == TempStoreFile || store == TempStoreMemory {
+			m.tempStore = store
+		}
+	}
+}
+
+// tunedConnector opens connections to dsn running the manager's tuning pragmas,
+// which are per connection and not covered by the driver's DSN parameters
+type tunedConnector struct {
+	dsn    string
+	driver *sqlite3.SQLiteDriver
+}
+
+// newTunedConnector returns a connector applying mmapSize and tempStore to every
+// connection of the pool
+func newTunedConnector(dsn string, mmapSize int64, tempStore TempStore) *tunedConnector {
+	pragmas := fmt.Sprintf("PRAGMA mmap_size = %d; PRAGMA temp_store = %d;", mmapSize, tempStore)
+
+	return &tunedConnector{
+		dsn: dsn,
+		driver: &sqlite3.SQLiteDriver{
+			ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+				_, err := conn.Exec(pragmas, nil)
+				return err
+			},
+		},
+	}
+}
+
+func (c *tunedConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+func (c *tunedConnector) Driver() driver.Driver {
+	return c.driver
+}
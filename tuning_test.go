@@ -0,0 +1,53 @@
+package sqliteq
+
+import (
+	"context"
+	"os"
+	"testing"
+)
+
+func TestTuning(t *testing.T) {
+	dbPath := "test_tuning.db"
+	defer os.Remove(dbPath)
+
+	pragmas := func(m *Manager) (mmapSize int64, tempStore TempStore) {
+		// Hold two connections at once, so the pragmas are checked on a fresh one too
+		ctx := context.Background()
+		busy, err := m.client.Conn(ctx)
+		if err != nil {
+			t.Fatalf("Failed to get a connection: %v", err)
+		}
+		defer busy.Close()
+
+		conn, err := m.client.Conn(ctx)
+		if err != nil {
+			t.Fatalf("Failed to get a connection: %v", err)
+		}
+		defer conn.Close()
+
+		conn.QueryRowContext(ctx, "PRAGMA mmap_size").Scan(&mmapSize)
+		conn.QueryRowContext(ctx, "PRAGMA temp_store").Scan(&tempStore)
+		return mmapSize, tempStore
+	}
+
+	m, err := NewManager(dbPath, WithMmapSize(1<<20), WithTempStore(TempStoreFile))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+
+	if mmapSize, tempStore := pragmas(m); mmapSize != 1<<20 || tempStore != TempStoreFile {
+		t.Errorf("Expected mmap_size %d and temp store file, got %d and %v", 1<<20, mmapSize, tempStore)
+	}
+	m.Close()
+
+	m, err = NewManager(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer m.Close()
+
+	wantMmap, wantTemp := platformDefaults()
+	if mmapSize, tempStore := pragmas(m); mmapSize != wantMmap || tempStore != wantTemp {
+		t.Errorf("Expected the platform defaults %d and %v, got %d and %v", wantMmap, wantTemp, mmapSize, tempStore)
+	}
+}
@@ -0,0 +1,67 @@
+package sqliteq
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// UpdatePayload rewrites the payload of the pending item with the given ID with
+// the result of fn, called with its current payload, e.g. to fix a bad field in
+// queued jobs instead of deleting and enqueueing them again. The item keeps its
+// place in the queue and its updated_at is bumped. The read and the write happen
+// in one transaction, so an item dequeued meanwhile is left alone; an error from
+// fn is returned as is and leaves the item unchanged.
+// An ID no pending item has is reported as ErrNotPending.
+func (q *Queue) UpdatePayload(id int64, fn func(old []byte) ([]byte, error)) (err error) {
+	defer func() { err = mapError(err) }()
+	defer q.observeWrite(time.Now(), &err)
+
+	if q.closed.Load() {
+		return ErrClosed
+	}
+
+	if err = q.manager.awaitLease(); err != nil {
+		return err
+	}
+
+	tx, err := q.client.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var old []byte
+	err = tx.QueryRow(fmt.Sprintf("SELECT data FROM %s WHERE id = ? AND status = 'pending'", quoteIdent(q.tableName)), id).Scan(&old)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: %d: %w", ErrNotPending, id, err)
+	}
+	if err != nil {
+		return err
+	}
+
+	data, err := fn(old)
+	if err != nil {
+		return err
+	}
+
+	if data == nil {
+		// data is NOT NULL; an empty payload is stored as such
+		data = []byte{}
+	}
+
+	_, err = tx.Exec(
+		fmt.Sprintf("UPDATE %s SET data = ?, updated_at = ? WHERE id = ?", quoteIdent(q.tableName)),
+		data, q.now(), id,
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,67 @@
+package sqliteq
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestUpdatePayload(t *testing.T) {
+	dbPath := "test_update_payload.db"
+	defer os.Remove(dbPath)
+
+	queues := New(dbPath)
+	defer queues.Close()
+
+	q, err := queues.NewQueue("jobs")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	first, _ := q.EnqueueWithID(`{"email":"a@example,com"}`)
+	second, _ := q.EnqueueWithID(`{"email":"b@example.com"}`)
+
+	before, _ := q.Peek()
+
+	err = q.UpdatePayload(first, func(old []byte) ([]byte, error) {
+		return bytes.Replace(old, []byte("example,com"), []byte("example.com"), 1), nil
+	})
+	if err != nil {
+		t.Fatalf("UpdatePayload failed: %v", err)
+	}
+
+	m, err := q.DequeueMessageWithAckId()
+	if err != nil {
+		t.Fatalf("DequeueMessageWithAckId failed: %v", err)
+	}
+	if m.ID != first || string(m.Data) != `{"email":"a@example.com"}` {
+		t.Errorf("Expected the fixed first item, got %d: %s", m.ID, m.Data)
+	}
+	if !m.UpdatedAt.After(before.UpdatedAt) {
+		t.Errorf("Expected updated_at to be bumped past %v, got %v", before.UpdatedAt, m.UpdatedAt)
+	}
+
+	t.Run("NotPending", func(t *testing.T) {
+		err := q.UpdatePayload(first, func(old []byte) ([]byte, error) { return old, nil })
+		if !errors.Is(err, ErrNotPending) {
+			t.Errorf("Expected ErrNotPending for an item in processing, got %v", err)
+		}
+
+		if err := q.UpdatePayload(999, func(old []byte) ([]byte, error) { return old, nil }); !errors.Is(err, ErrNotPending) {
+			t.Errorf("Expected ErrNotPending for an unknown ID, got %v", err)
+		}
+	})
+
+	t.Run("FnError", func(t *testing.T) {
+		errBad := errors.New("bad payload")
+		err := q.UpdatePayload(second, func(old []byte) ([]byte, error) { return []byte("changed"), errBad })
+		if !errors.Is(err, errBad) {
+			t.Errorf("Expected the error of fn, got %v", err)
+		}
+
+		if m, _ := q.Peek(); string(m.Data) != `{"email":"b@example.com"}` {
+			t.Errorf("Expected the payload to be left unchanged, got %s", m.Data)
+		}
+	})
+}
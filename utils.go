@@ -1,6 +1,15 @@
 package sqliteq
 
-import "strings"
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
 
 // Applies quotes to an identifier escaping any internal quotes.
 // See: https://www.sqlite.org/lang_keywords.html
@@ -9,3 +18,51 @@ func quoteIdent(name string) string {
 	escaped := strings.ReplaceAll(name, `"`, `""`)
 	return `"` + escaped + `"`
 }
+
+// hasColumn reports whether the table has a column with the given name
+func hasColumn(db *sql.DB, table, column string) (bool, error) {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?", table, column).Scan(&count)
+
+	return count > 0, err
+}
+
+// payloadBytes converts an enqueued item to the bytes read back on dequeue
+// Values other than byte slices and strings are rendered the way SQLite would
+// render them as text, so they read back exactly as before being normalized
+func payloadBytes(item any) ([]byte, error) {
+	value, err := driver.DefaultParameterConverter.ConvertValue(item)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported payload: %w", err)
+	}
+
+	switch v := value.(type) {
+	case []byte:
+		return v, nil
+	case string:
+		return []byte(v), nil
+	case int64:
+		return strconv.AppendInt(nil, v, 10), nil
+	case float64:
+		return strconv.AppendFloat(nil, v, 'g', -1, 64), nil
+	case bool:
+		if v {
+			return []byte("1"), nil
+		}
+		return []byte("0"), nil
+	case time.Time:
+		return []byte(v.Format(sqlite3.SQLiteTimestampFormats[0])), nil
+	default:
+		return nil, fmt.Errorf("unsupported payload type %T", item)
+	}
+}
+
+// dbFile returns the file path of a database DSN, stripping the file: scheme and query
+func dbFile(dsn string) string {
+	dsn = strings.TrimPrefix(dsn, "file:")
+	if i := strings.IndexByte(dsn, '?'); i >= 0 {
+		dsn = dsn[:i]
+	}
+
+	return dsn
+}